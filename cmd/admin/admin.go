@@ -13,6 +13,7 @@ func NewCmd(cfg *config.Config) *cli.Command {
 		Subcommands: []*cli.Command{
 			helpers.NewGenKeysCmd(cfg.Auth),
 			helpers.NewMigrateCmd(cfg.Database),
+			helpers.NewReplayCmd(cfg),
 		},
 	}
 }
@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"calc/common/config"
+	"calc/internal/services/replay"
+	"context"
+	"fmt"
+	"github.com/urfave/cli/v2"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func NewReplayCmd(cfg *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "replays recorded market data through the calculator and prints opportunities report",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "c",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "recording file",
+				Required: true,
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Usage: "replay speed relative to real time, 0 - as fast as possible",
+				Value: 0,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return Replay(cfg, ctx.String("file"), ctx.Float64("speed"))
+		},
+	}
+}
+
+// Replay воспроизводит запись рыночных данных и печатает отчет по арбитражным возможностям
+func Replay(cfg *config.Config, path string, speed float64) error {
+	report, err := replay.Run(context.Background(), cfg, path, speed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("records: %d, period: %s - %s (%s)\n\n",
+		report.Records,
+		report.From.Format(time.RFC3339),
+		report.To.Format(time.RFC3339),
+		report.To.Sub(report.From),
+	)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PAIR\tBUY\tSELL\tOPENED\tDURATION\tPEAK %\tAVG %\tUPDATES\tSTATUS")
+	for _, o := range report.Opportunities {
+		status := "closed"
+		if o.ClosedAt == nil {
+			status = "open"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.4f\t%.4f\t%d\t%s\n",
+			o.Pair,
+			o.BuyExchange,
+			o.SellExchange,
+			o.OpenedAt.Format(time.RFC3339),
+			o.Duration(report.To),
+			o.PeakProfit,
+			o.AvgProfit,
+			o.Updates,
+			status,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	currencies := make([]string, 0, len(report.PnL))
+	for currency := range report.PnL {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	fmt.Printf("\nopportunities: %d\n", len(report.Opportunities))
+	for _, currency := range currencies {
+		fmt.Printf("hypothetical P&L %s: %.4f\n", currency, report.PnL[currency])
+	}
+
+	return nil
+}
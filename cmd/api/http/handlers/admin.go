@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"calc/cmd/api/http/handlers/requests"
+	"calc/cmd/api/http/handlers/responses"
+	"calc/internal/berrors"
+	"calc/internal/services/auth"
+	"calc/internal/services/exchange"
+	"net/http"
+)
+
+type adminGroup struct {
+	exchangeService *exchange.Service
+}
+
+func newAdminGroup(exchangeService *exchange.Service) *adminGroup {
+	return &adminGroup{
+		exchangeService: exchangeService,
+	}
+}
+
+// AddPair godoc
+// @Tags Admin
+// @Router /admin/pairs/{pair} [post]
+// @Summary subscribes to pair on every exchange that trades it and starts arbitrage calculation
+// @Security JWT-Token
+// @Produce json
+// @Param pair path string true "Pair"
+// @Success 200 {object} responses.PairSubscription
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 401
+// @Failure 403 {object} berrors.BusinessError
+// @Failure 500
+func (ag *adminGroup) AddPair(r *http.Request) (interface{}, error) {
+	var req requests.Pair
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	exchanges, err := ag.exchangeService.AddPair(r.Context(), req.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.PairSubscription{
+		Pair:      req.Pair,
+		Exchanges: exchanges,
+	}, nil
+}
+
+// RemovePair godoc
+// @Tags Admin
+// @Router /admin/pairs/{pair} [delete]
+// @Summary unsubscribes from pair on all exchanges and removes its arbitrage combinations
+// @Security JWT-Token
+// @Produce json
+// @Param pair path string true "Pair"
+// @Success 200 {object} responses.PairSubscription
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 401
+// @Failure 403 {object} berrors.BusinessError
+// @Failure 500
+func (ag *adminGroup) RemovePair(r *http.Request) (interface{}, error) {
+	var req requests.Pair
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	if err := ag.exchangeService.RemovePair(r.Context(), req.Pair); err != nil {
+		return nil, err
+	}
+
+	return &responses.PairSubscription{
+		Pair:      req.Pair,
+		Exchanges: []string{},
+	}, nil
+}
@@ -5,41 +5,57 @@ import (
 	"calc/internal/adapters/db"
 	"calc/internal/berrors"
 	"calc/internal/services/check"
+	"calc/internal/services/exchange"
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 )
 
 type checkGroup struct {
-	build string
-	db    db.DB
+	build           string
+	db              db.DB
+	exchangeService *exchange.Service
 }
 
-func newCheckGroup(build string, db db.DB) *checkGroup {
+func newCheckGroup(build string, db db.DB, exchangeService *exchange.Service) *checkGroup {
 	return &checkGroup{
-		build: build,
-		db:    db,
+		build:           build,
+		db:              db,
+		exchangeService: exchangeService,
 	}
 }
 
 // Readiness godoc
 // @Tags Check
 // @Router /check/readiness [get]
-// @Summary Check readiness database
-// @Description Check readiness database
+// @Summary Check readiness database and exchanges
+// @Description Check readiness database and exchanges: at least two exchanges must deliver fresh quotes
 // @Produce json
 // @Success 200 {object} responses.Health
 // @Failure 400 {object} berrors.BusinessError
 // @Failure 500
-func (cg checkGroup) Readiness(_ *http.Request) (interface{}, error) {
+func (cg checkGroup) Readiness(r *http.Request) (interface{}, error) {
 	status := "ok"
 
 	if err := cg.db.StatusCheck(context.Background()); err != nil {
 		return nil, berrors.WrapWithError(check.ErrDBNotReady, err)
 	}
 
+	health := cg.exchangeService.Health(r.Context())
+
+	exchanges := make(map[string]string, len(health))
+	for _, h := range health {
+		exchanges[h.Exchange] = string(h.Status)
+	}
+
+	if !exchange.Ready(health) {
+		return nil, berrors.WrapWithError(check.ErrExchangesNotReady, fmt.Errorf("exchanges: %v", exchanges))
+	}
+
 	return responses.Health{
-		Status: status,
+		Status:    status,
+		Exchanges: exchanges,
 	}, nil
 }
 
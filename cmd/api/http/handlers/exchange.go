@@ -48,6 +48,35 @@ func (eg *exchangeGroup) Pairs(r *http.Request) (interface{}, error) {
 	return eg.exchangeService.Pairs(r.Context(), mux.Vars(r)["exchange"])
 }
 
+// CommonPairs godoc
+// @Tags Exchange
+// @Router /exchange/pairs/common [get]
+// @Summary returns pairs traded on several exchanges that pass quote, volume and min-notional filters
+// @Produce json
+// @Success 200 {object} responses.CommonPairs
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) CommonPairs(r *http.Request) (interface{}, error) {
+	common, updatedAt, err := eg.exchangeService.CommonPairs(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &responses.CommonPairs{
+		UpdatedAt: updatedAt,
+		Pairs:     make([]*responses.CommonPair, 0, len(common)),
+	}
+	for _, pair := range common {
+		resp.Pairs = append(resp.Pairs, &responses.CommonPair{
+			Pair:    pair.Pair,
+			Quote:   pair.Quote,
+			Volumes: pair.Volumes,
+		})
+	}
+
+	return resp, nil
+}
+
 // Price godoc
 // @Tags Exchange
 // @Router /exchange/{exchange}/price/{pair} [get]
@@ -63,12 +92,71 @@ func (eg *exchangeGroup) Price(r *http.Request) (interface{}, error) {
 	return eg.exchangeService.Price(r.Context(), vars["exchange"], vars["pair"])
 }
 
+// Status godoc
+// @Tags Exchange
+// @Router /exchange/{exchange}/status [get]
+// @Summary returns connection state and quote freshness of exchange pairs
+// @Produce json
+// @Param exchange path string true "Exchange"
+// @Success 200 {object} responses.ExchangeStatus
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) Status(r *http.Request) (interface{}, error) {
+	health, err := eg.exchangeService.Status(r.Context(), mux.Vars(r)["exchange"])
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &responses.ExchangeStatus{
+		Exchange:   health.Exchange,
+		Status:     string(health.Status),
+		Connection: health.Connection,
+		StaleAfter: health.StaleAfter.Seconds(),
+		Pairs:      make([]*responses.PairStatus, 0, len(health.Pairs)),
+	}
+
+	if !health.LastUpdate.IsZero() {
+		resp.LastUpdate = &health.LastUpdate
+	}
+
+	for _, p := range health.Pairs {
+		pair := &responses.PairStatus{
+			Pair:  p.Pair,
+			Stale: p.Stale,
+		}
+
+		if !p.LastUpdate.IsZero() {
+			lastUpdate := p.LastUpdate
+			pair.LastUpdate = &lastUpdate
+		}
+
+		resp.Pairs = append(resp.Pairs, pair)
+	}
+
+	return resp, nil
+}
+
 // Top godoc
 // @Tags Exchange
 // @Router /exchange/top [get]
-// @Summary returns the top most profitable pairs for arbitrage
+// @Summary returns the top most profitable exchange pairings for arbitrage sorted by net profit
 // @Produce json
-// @Success 200 {object} responses.Top
+// @Param limit query int false "Limit, 20 by default, up to 1000"
+// @Param offset query int false "Offset, not allowed with cursor"
+// @Param cursor query string false "Cursor of the next page from the previous response"
+// @Param pair query string false "Pair"
+// @Param base query string false "Base asset"
+// @Param quote query string false "Quote asset"
+// @Param exchange query string false "Buy or sell exchange"
+// @Param exchanges query string false "Comma-separated exchanges for both buy and sell"
+// @Param exclude_exchanges query string false "Comma-separated exchanges excluded from buy and sell"
+// @Param min_profit query number false "Minimum net profit, %"
+// @Param max_profit query number false "Maximum net profit, %"
+// @Param min_volume query number false "Minimum profitable volume in base asset"
+// @Param updated_since query string false "Updated since, RFC3339"
+// @Param sort_by query string false "Sort by: net_profit, profit, max_volume, updated_at or pair"
+// @Param sort_dir query string false "Sort direction: asc or desc"
+// @Success 200 {object} responses.TopPage
 // @Failure 400 {object} berrors.BusinessError
 // @Failure 500
 func (eg *exchangeGroup) Top(r *http.Request) (interface{}, error) {
@@ -77,20 +165,320 @@ func (eg *exchangeGroup) Top(r *http.Request) (interface{}, error) {
 		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
 	}
 
-	top, err := eg.exchangeService.Top(r.Context(), req.Limit)
+	top, next, err := eg.exchangeService.Top(r.Context(), exchange.TopArgs{
+		Limit:            req.Limit,
+		Offset:           req.Offset,
+		Pair:             req.Pair,
+		Base:             req.Base,
+		Quote:            req.Quote,
+		Exchange:         req.Exchange,
+		Exchanges:        req.Exchanges,
+		ExcludeExchanges: req.ExcludeExchanges,
+		MinProfit:        req.MinProfit,
+		MaxProfit:        req.MaxProfit,
+		MinVolume:        req.MinVolume,
+		UpdatedSince:     req.UpdatedSince,
+		SortBy:           req.SortBy,
+		SortDir:          req.SortDir,
+		Cursor:           req.Cursor,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	resp := make([]*responses.Top, 0)
+	resp := &responses.TopPage{
+		Items:      make([]*responses.Top, 0, len(top)),
+		NextCursor: next,
+	}
 	for _, t := range top {
-		resp = append(resp, &responses.Top{
-			Pair:         t.Pair,
-			BuyExchange:  t.BuyExchange,
-			SellExchange: t.SellExchange,
-			BuyPrice:     t.BuyPrice,
-			SellPrice:    t.SellPrice,
-			Profit:       t.Profit,
+		resp.Items = append(resp.Items, &responses.Top{
+			Pair:              t.Pair,
+			BuyExchange:       t.BuyExchange,
+			SellExchange:      t.SellExchange,
+			BuyPrice:          t.BuyPrice,
+			BuyQuantity:       t.BuyQuantity,
+			SellPrice:         t.SellPrice,
+			SellQuantity:      t.SellQuantity,
+			BuyFee:            t.BuyFee,
+			SellFee:           t.SellFee,
+			TransferNetwork:   t.TransferNetwork,
+			TransferFee:       t.TransferFee,
+			Profit:            t.Profit,
+			NetProfit:         t.NetProfit,
+			MaxVolume:         t.MaxVolume,
+			BuyVWAP:           t.BuyVWAP,
+			SellVWAP:          t.SellVWAP,
+			ProfitAtVolume:    t.ProfitAtVolume,
+			InsufficientDepth: t.InsufficientDepth,
+			UpdatedAt:         t.UpdatedAt,
+		})
+	}
+
+	return resp, nil
+}
+
+// TopTriangular godoc
+// @Tags Exchange
+// @Router /exchange/top/triangular [get]
+// @Summary returns the top most profitable triangular cycles within an exchange sorted by net profit
+// @Produce json
+// @Param exchange query string false "Exchange"
+// @Success 200 {object} responses.TopTriangular
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) TopTriangular(r *http.Request) (interface{}, error) {
+	var req requests.TopTriangular
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	top, err := eg.exchangeService.TopTriangular(r.Context(), req.Limit, req.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*responses.TopTriangular, 0)
+	for _, t := range top {
+		legs := make([]*responses.TriangularLeg, 0, len(t.Legs))
+		for _, l := range t.Legs {
+			legs = append(legs, &responses.TriangularLeg{
+				Pair:  l.Pair,
+				Side:  l.Side,
+				Price: l.Price,
+				Fee:   l.Fee,
+			})
+		}
+
+		resp = append(resp, &responses.TopTriangular{
+			Exchange:  t.Exchange,
+			Route:     t.Route,
+			Legs:      legs,
+			Profit:    t.Profit,
+			NetProfit: t.NetProfit,
+		})
+	}
+
+	return resp, nil
+}
+
+// TopCarry godoc
+// @Tags Exchange
+// @Router /exchange/top/carry [get]
+// @Summary returns spot-perpetual basis and cross-exchange funding rate positions sorted by annualized carry after fees
+// @Produce json
+// @Param kind query string false "Kind: basis or funding"
+// @Param limit query int false "Limit, 20 by default"
+// @Param pair query string false "Pair"
+// @Success 200 {object} responses.TopCarry
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) TopCarry(r *http.Request) (interface{}, error) {
+	var req requests.TopCarry
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	top, err := eg.exchangeService.TopCarry(r.Context(), req.Limit, req.Kind, req.Pair)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*responses.TopCarry, 0)
+	for _, c := range top {
+		carry := &responses.TopCarry{
+			Kind:            c.Kind,
+			Pair:            c.Pair,
+			LongExchange:    c.LongExchange,
+			LongMarket:      c.LongMarket,
+			ShortExchange:   c.ShortExchange,
+			LongPrice:       c.LongPrice,
+			ShortPrice:      c.ShortPrice,
+			LongFunding:     c.LongFunding,
+			ShortFunding:    c.ShortFunding,
+			Basis:           c.Basis,
+			Fees:            c.Fees,
+			AnnualizedCarry: c.AnnualizedCarry,
+		}
+
+		if !c.NextFundingTime.IsZero() {
+			nextFundingTime := c.NextFundingTime
+			carry.NextFundingTime = &nextFundingTime
+		}
+
+		resp = append(resp, carry)
+	}
+
+	return resp, nil
+}
+
+// TopRoutes godoc
+// @Tags Exchange
+// @Router /exchange/top/routes [get]
+// @Summary returns the top most profitable multi-leg routes across exchanges sorted by net profit
+// @Produce json
+// @Param limit query int false "Limit, 20 by default"
+// @Success 200 {object} responses.Route
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) TopRoutes(r *http.Request) (interface{}, error) {
+	var req requests.TopRoutes
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	routes, err := eg.exchangeService.TopRoutes(r.Context(), req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]*responses.Route, 0)
+	for _, route := range routes {
+		legs := make([]*responses.RouteLeg, 0, len(route.Legs))
+		for _, l := range route.Legs {
+			legs = append(legs, &responses.RouteLeg{
+				Type:       l.Type,
+				Exchange:   l.Exchange,
+				Asset:      l.Asset,
+				Pair:       l.Pair,
+				Side:       l.Side,
+				Price:      l.Price,
+				ToExchange: l.ToExchange,
+				Network:    l.Network,
+				Fee:        l.Fee,
+			})
+		}
+
+		resp = append(resp, &responses.Route{
+			Route:     route.Route,
+			Legs:      legs,
+			NetProfit: route.NetProfit,
+		})
+	}
+
+	return resp, nil
+}
+
+// ActiveOpportunities godoc
+// @Tags Exchange
+// @Router /exchange/opportunities/active [get]
+// @Summary returns open arbitrage opportunities sorted by peak profit
+// @Produce json
+// @Param pair query string false "Pair"
+// @Param exchange query string false "Buy or sell exchange"
+// @Param min_duration query string false "Minimum duration, e.g. 30s"
+// @Success 200 {array} responses.Opportunity
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) ActiveOpportunities(r *http.Request) (interface{}, error) {
+	return eg.opportunities(r, true)
+}
+
+// HistoricalOpportunities godoc
+// @Tags Exchange
+// @Router /exchange/opportunities/history [get]
+// @Summary returns closed arbitrage opportunities sorted by opening time
+// @Produce json
+// @Param pair query string false "Pair"
+// @Param exchange query string false "Buy or sell exchange"
+// @Param min_duration query string false "Minimum duration, e.g. 30s"
+// @Success 200 {array} responses.Opportunity
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) HistoricalOpportunities(r *http.Request) (interface{}, error) {
+	return eg.opportunities(r, false)
+}
+
+func (eg *exchangeGroup) opportunities(r *http.Request, active bool) (interface{}, error) {
+	var req requests.Opportunities
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	opportunities, err := eg.exchangeService.Opportunities(r.Context(), exchange.OpportunitiesArgs{
+		Limit:       req.Limit,
+		Active:      active,
+		Pair:        req.Pair,
+		Exchange:    req.Exchange,
+		MinDuration: req.MinDuration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	resp := make([]*responses.Opportunity, 0)
+	for _, o := range opportunities {
+		resp = append(resp, &responses.Opportunity{
+			ID:           o.ID,
+			Pair:         o.Pair,
+			BuyExchange:  o.BuyExchange,
+			SellExchange: o.SellExchange,
+			OpenedAt:     o.OpenedAt,
+			ClosedAt:     o.ClosedAt,
+			Duration:     o.Duration(now).Seconds(),
+			PeakProfit:   o.PeakProfit,
+			AvgProfit:    o.AvgProfit,
+			LastProfit:   o.LastProfit,
+			Updates:      o.Updates,
+		})
+	}
+
+	return resp, nil
+}
+
+// History godoc
+// @Tags Exchange
+// @Router /exchange/history/{pair} [get]
+// @Summary returns OHLC candles of exchange mid prices and net profit spreads of a pair
+// @Produce json
+// @Param pair path string true "Pair"
+// @Param from query string false "Period start, RFC3339"
+// @Param to query string false "Period end, RFC3339"
+// @Param resolution query string false "Candle resolution: 1s, 1m or 1h"
+// @Success 200 {object} responses.History
+// @Failure 400 {object} berrors.BusinessError
+// @Failure 500
+func (eg *exchangeGroup) History(r *http.Request) (interface{}, error) {
+	var req requests.History
+	if err := requests.Bind(r, &req); err != nil {
+		return nil, berrors.WrapWithError(auth.ErrInvalidInput, err)
+	}
+
+	pair := mux.Vars(r)["pair"]
+	quotes, spreads, err := eg.exchangeService.History(r.Context(), pair, req.From, req.To, req.Resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &responses.History{
+		Pair:       pair,
+		Resolution: req.Resolution,
+		Quotes:     make([]*responses.QuoteCandle, 0, len(quotes)),
+		Spreads:    make([]*responses.SpreadCandle, 0, len(spreads)),
+	}
+
+	for _, c := range quotes {
+		resp.Quotes = append(resp.Quotes, &responses.QuoteCandle{
+			Exchange: c.Exchange,
+			Time:     c.Time,
+			Open:     c.Open,
+			High:     c.High,
+			Low:      c.Low,
+			Close:    c.Close,
+			Bid:      c.Bid,
+			Ask:      c.Ask,
+		})
+	}
+
+	for _, c := range spreads {
+		resp.Spreads = append(resp.Spreads, &responses.SpreadCandle{
+			BuyExchange:  c.BuyExchange,
+			SellExchange: c.SellExchange,
+			Time:         c.Time,
+			Open:         c.Open,
+			High:         c.High,
+			Low:          c.Low,
+			Close:        c.Close,
 		})
 	}
 
@@ -108,38 +496,39 @@ func (eg *exchangeGroup) Top(r *http.Request) (interface{}, error) {
 // @Failure 400 {object} berrors.BusinessError
 // @Failure 500
 func (eg *exchangeGroup) WSPrice(ctx context.Context, c *websocket.Conn, vars map[string]string) error {
-	ch := make(chan float64)
-	errCh := make(chan error)
-
 	pair, exch := vars["pair"], vars["exchange"]
-	go func() {
-		err := eg.exchangeService.WSPrice(ctx, exch, pair, ch)
-		if err != nil {
-			errCh <- err
-		}
-	}()
+
+	sub, err := eg.exchangeService.WSPrice(exch, pair)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
 
 	for {
-		select {
-		case <-ctx.Done():
+		data, ok := sub.Next(ctx)
+		if !ok {
 			return nil
-		case err := <-errCh:
+		}
+
+		err := c.WriteJSON(struct {
+			Pair        string    `json:"pair"`
+			Exchange    string    `json:"exchange"`
+			Bid         float64   `json:"bid"`
+			BidQuantity float64   `json:"bid_quantity"`
+			Ask         float64   `json:"ask"`
+			AskQuantity float64   `json:"ask_quantity"`
+			Time        time.Time `json:"time"`
+		}{
+			Pair:        pair,
+			Exchange:    exch,
+			Bid:         data.Bid,
+			BidQuantity: data.BidQuantity,
+			Ask:         data.Ask,
+			AskQuantity: data.AskQuantity,
+			Time:        time.Now(),
+		})
+		if err != nil {
 			return err
-		case price := <-ch:
-			err := c.WriteJSON(struct {
-				Pair     string    `json:"pair"`
-				Exchange string    `json:"exchange"`
-				Price    float64   `json:"price"`
-				Time     time.Time `json:"time"`
-			}{
-				Pair:     pair,
-				Exchange: exch,
-				Price:    price,
-				Time:     time.Now(),
-			})
-			if err != nil {
-				return err
-			}
 		}
 	}
 }
@@ -16,6 +16,7 @@ func API(
 	build string,
 	db db.DB,
 	jwtAuth *jwt.Authenticator,
+	admins []uint64,
 	authService *auth.Service,
 	exchangeService *exchange.Service,
 ) http.Handler {
@@ -25,7 +26,7 @@ func API(
 	r.Route("/api/v1", func(r *mux.Router) {
 		r.Use(middlewares.Logger)
 
-		cg := newCheckGroup(build, db)
+		cg := newCheckGroup(build, db, exchangeService)
 		r.Route("/check", func(r *mux.Router) {
 			r.Handle("/readiness", cg.Readiness).Methods(http.MethodGet)
 			r.Handle("/liveness", cg.Liveness).Methods(http.MethodGet)
@@ -49,17 +50,33 @@ func API(
 		r.Route("/exchange", func(r *mux.Router) {
 			//r.Use(middlewares.Verify(jwtAuth, jwt.Access))
 			r.Handle("", eg.Exchanges).Methods(http.MethodGet)
+			r.Handle("/pairs/common", eg.CommonPairs).Methods(http.MethodGet)
 			r.Handle("/{exchange}/pairs", eg.Pairs).Methods(http.MethodGet)
 			r.Handle("/{exchange}/price/{pair}", eg.Price).Methods(http.MethodGet)
+			r.Handle("/{exchange}/status", eg.Status).Methods(http.MethodGet)
 			r.Handle("/top", eg.Top).Methods(http.MethodGet)
+			r.Handle("/top/triangular", eg.TopTriangular).Methods(http.MethodGet)
+			r.Handle("/top/routes", eg.TopRoutes).Methods(http.MethodGet)
+			r.Handle("/top/carry", eg.TopCarry).Methods(http.MethodGet)
+			r.Handle("/opportunities/active", eg.ActiveOpportunities).Methods(http.MethodGet)
+			r.Handle("/opportunities/history", eg.HistoricalOpportunities).Methods(http.MethodGet)
+			r.Handle("/history/{pair}", eg.History).Methods(http.MethodGet)
 			r.Route("/ws", func(r *mux.Router) {
 				r.WSHandle("/{exchange}/price/{pair}", eg.WSPrice).Methods(http.MethodGet)
 			})
 		})
+
+		adg := newAdminGroup(exchangeService)
+		r.Route("/admin", func(r *mux.Router) {
+			r.Use(middlewares.Verify(jwtAuth, jwt.Access))
+			r.Use(middlewares.Admin(admins))
+			r.Handle("/pairs/{pair}", adg.AddPair).Methods(http.MethodPost)
+			r.Handle("/pairs/{pair}", adg.RemovePair).Methods(http.MethodDelete)
+		})
 	})
 
 	return handlers.CORS(
-		handlers.AllowedMethods([]string{http.MethodGet, http.MethodPost, http.MethodPut}),
+		handlers.AllowedMethods([]string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}),
 		handlers.AllowedHeaders([]string{
 			"Authorization",
 			"Content-Type",
@@ -0,0 +1,61 @@
+package requests
+
+import (
+	"calc/internal/domain"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type History struct {
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Resolution domain.Resolution `json:"resolution"`
+}
+
+// Bind разбирает период в RFC3339, по умолчанию последний час с минутными свечами.
+// Период не длиннее domain.Resolution.MaxSpan интервала.
+func (e *History) Bind(req *http.Request) error {
+	q := req.URL.Query()
+
+	e.To = time.Now().UTC()
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return err
+		}
+
+		e.To = t.UTC()
+	}
+
+	e.From = e.To.Add(-time.Hour)
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return err
+		}
+
+		e.From = t.UTC()
+	}
+
+	e.Resolution = domain.ResolutionMinute
+	if resolution := q.Get("resolution"); resolution != "" {
+		r, err := domain.NewResolution(resolution)
+		if err != nil {
+			return err
+		}
+
+		e.Resolution = r
+	}
+
+	if !e.From.Before(e.To) {
+		return errors.New("from is not before to")
+	}
+
+	if span := e.Resolution.MaxSpan(); e.To.Sub(e.From) > span {
+		return fmt.Errorf("period is longer than %s for resolution %s", span, e.Resolution)
+	}
+
+	return nil
+}
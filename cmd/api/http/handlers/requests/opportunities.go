@@ -0,0 +1,44 @@
+package requests
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Opportunities struct {
+	Limit       uint          `json:"limit"`
+	Pair        string        `json:"pair"`
+	Exchange    string        `json:"exchange"`
+	MinDuration time.Duration `json:"min_duration"`
+}
+
+func (e *Opportunities) Bind(req *http.Request) error {
+	q := req.URL.Query()
+
+	e.Limit = 20
+	e.Pair = q.Get("pair")
+	e.Exchange = q.Get("exchange")
+
+	limitString := q.Get("limit")
+	if limitString != "" {
+		limit, err := strconv.ParseUint(limitString, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		e.Limit = uint(limit)
+	}
+
+	minDurationString := q.Get("min_duration")
+	if minDurationString != "" {
+		minDuration, err := time.ParseDuration(minDurationString)
+		if err != nil {
+			return err
+		}
+
+		e.MinDuration = minDuration
+	}
+
+	return nil
+}
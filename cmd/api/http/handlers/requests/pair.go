@@ -0,0 +1,16 @@
+package requests
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Pair struct {
+	Pair string `json:"pair" validate:"required,uppercase,contains=_"`
+}
+
+func (e *Pair) Bind(req *http.Request) error {
+	e.Pair = mux.Vars(req)["pair"]
+
+	return nil
+}
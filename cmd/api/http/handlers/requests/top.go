@@ -1,18 +1,191 @@
 package requests
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Top struct {
-	Limit uint `json:"limit"`
+	Limit            uint     `json:"limit" validate:"min=1,max=1000"`
+	Offset           uint     `json:"offset" validate:"excluded_with=Cursor"`
+	Pair             string   `json:"pair" validate:"omitempty,uppercase,contains=_"`
+	Base             string   `json:"base" validate:"omitempty,uppercase,excludes=_"`
+	Quote            string   `json:"quote" validate:"omitempty,uppercase,excludes=_"`
+	Exchange         string   `json:"exchange"`
+	Exchanges        []string `json:"exchanges" validate:"dive,required"`
+	ExcludeExchanges []string `json:"exclude_exchanges" validate:"dive,required"`
+	MinProfit        *float64 `json:"min_profit"`
+	MaxProfit        *float64 `json:"max_profit"`
+	MinVolume        *float64 `json:"min_volume" validate:"omitempty,gte=0"`
+	// UpdatedSince в RFC3339
+	UpdatedSince time.Time `json:"updated_since"`
+	SortBy       string    `json:"sort_by" validate:"oneof=net_profit profit max_volume updated_at pair"`
+	SortDir      string    `json:"sort_dir" validate:"oneof=asc desc"`
+	Cursor       string    `json:"cursor"`
 }
 
+// Bind разбирает параметры рейтинга. По умолчанию 20 комбинаций по убыванию чистой прибыли,
+// при сортировке по паре - по возрастанию. Списки бирж передаются через запятую или повтором параметра.
 func (e *Top) Bind(req *http.Request) error {
 	q := req.URL.Query()
 
 	e.Limit = 20
+	e.Pair = q.Get("pair")
+	e.Base = q.Get("base")
+	e.Quote = q.Get("quote")
+	e.Exchange = q.Get("exchange")
+	e.Exchanges = list(q["exchanges"])
+	e.ExcludeExchanges = list(q["exclude_exchanges"])
+	e.Cursor = q.Get("cursor")
+
+	limitString := q.Get("limit")
+	if limitString != "" {
+		limit, err := strconv.ParseUint(limitString, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		e.Limit = uint(limit)
+	}
+
+	offsetString := q.Get("offset")
+	if offsetString != "" {
+		offset, err := strconv.ParseUint(offsetString, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		e.Offset = uint(offset)
+	}
+
+	var err error
+	if e.MinProfit, err = optionalFloat(q.Get("min_profit")); err != nil {
+		return err
+	}
+
+	if e.MaxProfit, err = optionalFloat(q.Get("max_profit")); err != nil {
+		return err
+	}
+
+	if e.MinProfit != nil && e.MaxProfit != nil && *e.MaxProfit < *e.MinProfit {
+		return errors.New("max_profit is less than min_profit")
+	}
+
+	if e.MinVolume, err = optionalFloat(q.Get("min_volume")); err != nil {
+		return err
+	}
+
+	if updatedSince := q.Get("updated_since"); updatedSince != "" {
+		t, err := time.Parse(time.RFC3339, updatedSince)
+		if err != nil {
+			return err
+		}
+
+		e.UpdatedSince = t.UTC()
+	}
+
+	e.SortBy = q.Get("sort_by")
+	if e.SortBy == "" {
+		e.SortBy = "net_profit"
+	}
+
+	e.SortDir = q.Get("sort_dir")
+	if e.SortDir == "" {
+		e.SortDir = "desc"
+		if e.SortBy == "pair" {
+			e.SortDir = "asc"
+		}
+	}
+
+	return nil
+}
+
+// list объединяет повторы параметра и значения через запятую, пустые значения пропускаются
+func list(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+
+	return result
+}
+
+func optionalFloat(str string) (*float64, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+type TopRoutes struct {
+	Limit uint `json:"limit" validate:"max=1000"`
+}
+
+func (e *TopRoutes) Bind(req *http.Request) error {
+	e.Limit = 20
+
+	limitString := req.URL.Query().Get("limit")
+	if limitString != "" {
+		limit, err := strconv.ParseUint(limitString, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		e.Limit = uint(limit)
+	}
+
+	return nil
+}
+
+type TopTriangular struct {
+	Limit    uint   `json:"limit"`
+	Exchange string `json:"exchange"`
+}
+
+func (e *TopTriangular) Bind(req *http.Request) error {
+	q := req.URL.Query()
+
+	e.Limit = 20
+	e.Exchange = q.Get("exchange")
+
+	limitString := q.Get("limit")
+	if limitString != "" {
+		limit, err := strconv.ParseUint(limitString, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		e.Limit = uint(limit)
+	}
+
+	return nil
+}
+
+type TopCarry struct {
+	Limit uint   `json:"limit"`
+	Kind  string `json:"kind" validate:"omitempty,oneof=basis funding"`
+	Pair  string `json:"pair"`
+}
+
+func (e *TopCarry) Bind(req *http.Request) error {
+	q := req.URL.Query()
+
+	e.Limit = 20
+	e.Kind = q.Get("kind")
+	e.Pair = q.Get("pair")
 
 	limitString := q.Get("limit")
 	if limitString != "" {
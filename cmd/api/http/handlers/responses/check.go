@@ -2,6 +2,8 @@ package responses
 
 type Health struct {
 	Status string `json:"status"`
+	// Exchanges состояние бирж по названию
+	Exchanges map[string]string `json:"exchanges,omitempty"`
 }
 
 type Info struct {
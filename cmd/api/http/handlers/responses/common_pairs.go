@@ -0,0 +1,15 @@
+package responses
+
+import "time"
+
+type CommonPairs struct {
+	UpdatedAt time.Time     `json:"updated_at"`
+	Pairs     []*CommonPair `json:"pairs"`
+}
+
+type CommonPair struct {
+	Pair  string `json:"pair"`
+	Quote string `json:"quote"`
+	// Volumes объем за 24 часа в валюте котировки по биржам
+	Volumes map[string]float64 `json:"volumes"`
+}
@@ -0,0 +1,20 @@
+package responses
+
+import "time"
+
+type ExchangeStatus struct {
+	Exchange string `json:"exchange"`
+	// Status connected, degraded, stale или down
+	Status     string     `json:"status"`
+	Connection string     `json:"connection"`
+	LastUpdate *time.Time `json:"last_update"`
+	// StaleAfter порог устаревания в секундах
+	StaleAfter float64       `json:"stale_after"`
+	Pairs      []*PairStatus `json:"pairs"`
+}
+
+type PairStatus struct {
+	Pair       string     `json:"pair"`
+	LastUpdate *time.Time `json:"last_update"`
+	Stale      bool       `json:"stale"`
+}
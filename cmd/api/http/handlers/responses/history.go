@@ -0,0 +1,34 @@
+package responses
+
+import (
+	"calc/internal/domain"
+	"time"
+)
+
+type History struct {
+	Pair       string            `json:"pair"`
+	Resolution domain.Resolution `json:"resolution"`
+	Quotes     []*QuoteCandle    `json:"quotes"`
+	Spreads    []*SpreadCandle   `json:"spreads"`
+}
+
+type QuoteCandle struct {
+	Exchange string    `json:"exchange"`
+	Time     time.Time `json:"time"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Bid      float64   `json:"bid"`
+	Ask      float64   `json:"ask"`
+}
+
+type SpreadCandle struct {
+	BuyExchange  string    `json:"buy_exchange"`
+	SellExchange string    `json:"sell_exchange"`
+	Time         time.Time `json:"time"`
+	Open         float64   `json:"open"`
+	High         float64   `json:"high"`
+	Low          float64   `json:"low"`
+	Close        float64   `json:"close"`
+}
@@ -0,0 +1,18 @@
+package responses
+
+import "time"
+
+type Opportunity struct {
+	ID           uint64     `json:"id"`
+	Pair         string     `json:"pair"`
+	BuyExchange  string     `json:"buy_exchange"`
+	SellExchange string     `json:"sell_exchange"`
+	OpenedAt     time.Time  `json:"opened_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	// Duration длительность в секундах
+	Duration   float64 `json:"duration"`
+	PeakProfit float64 `json:"peak_profit"`
+	AvgProfit  float64 `json:"avg_profit"`
+	LastProfit float64 `json:"last_profit"`
+	Updates    int     `json:"updates"`
+}
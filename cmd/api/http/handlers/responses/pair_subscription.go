@@ -0,0 +1,7 @@
+package responses
+
+type PairSubscription struct {
+	Pair string `json:"pair"`
+	// Exchanges биржи, на которых есть подписка на пару
+	Exchanges []string `json:"exchanges"`
+}
@@ -0,0 +1,19 @@
+package responses
+
+type Route struct {
+	Route     string      `json:"route"`
+	Legs      []*RouteLeg `json:"legs"`
+	NetProfit float64     `json:"net_profit"`
+}
+
+type RouteLeg struct {
+	Type       string  `json:"type"`
+	Exchange   string  `json:"exchange"`
+	Asset      string  `json:"asset"`
+	Pair       string  `json:"pair,omitempty"`
+	Side       string  `json:"side,omitempty"`
+	Price      float64 `json:"price,omitempty"`
+	ToExchange string  `json:"to_exchange,omitempty"`
+	Network    string  `json:"network,omitempty"`
+	Fee        float64 `json:"fee"`
+}
@@ -1,10 +1,63 @@
 package responses
 
+import "time"
+
 type Top struct {
-	Pair         string  `json:"pair"`
-	BuyExchange  string  `json:"buy_exchange"`
-	SellExchange string  `json:"sell_exchange"`
-	BuyPrice     float64 `json:"buy_price"`
-	SellPrice    float64 `json:"sell_price"`
-	Profit       float64 `json:"profit"`
+	Pair            string  `json:"pair"`
+	BuyExchange     string  `json:"buy_exchange"`
+	SellExchange    string  `json:"sell_exchange"`
+	BuyPrice        float64 `json:"buy_price"`
+	BuyQuantity     float64 `json:"buy_quantity"`
+	SellPrice       float64 `json:"sell_price"`
+	SellQuantity    float64 `json:"sell_quantity"`
+	BuyFee          float64 `json:"buy_fee"`
+	SellFee         float64 `json:"sell_fee"`
+	TransferNetwork string  `json:"transfer_network"`
+	TransferFee     float64 `json:"transfer_fee"`
+	Profit          float64 `json:"profit"`
+	NetProfit       float64 `json:"net_profit"`
+	MaxVolume       float64 `json:"max_volume"`
+	BuyVWAP         float64 `json:"buy_vwap"`
+	SellVWAP        float64 `json:"sell_vwap"`
+	ProfitAtVolume  float64 `json:"profit_at_volume"`
+	// InsufficientDepth стакана не хватает на объем сделки, profit_at_volume не посчитана
+	InsufficientDepth bool      `json:"insufficient_depth"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TopPage страница рейтинга, NextCursor пустой на последней странице
+type TopPage struct {
+	Items      []*Top `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type TopTriangular struct {
+	Exchange  string           `json:"exchange"`
+	Route     string           `json:"route"`
+	Legs      []*TriangularLeg `json:"legs"`
+	Profit    float64          `json:"profit"`
+	NetProfit float64          `json:"net_profit"`
+}
+
+type TriangularLeg struct {
+	Pair  string  `json:"pair"`
+	Side  string  `json:"side"`
+	Price float64 `json:"price"`
+	Fee   float64 `json:"fee"`
+}
+
+type TopCarry struct {
+	Kind            string     `json:"kind"`
+	Pair            string     `json:"pair"`
+	LongExchange    string     `json:"long_exchange"`
+	LongMarket      string     `json:"long_market"`
+	ShortExchange   string     `json:"short_exchange"`
+	LongPrice       float64    `json:"long_price"`
+	ShortPrice      float64    `json:"short_price"`
+	LongFunding     float64    `json:"long_funding"`
+	ShortFunding    float64    `json:"short_funding"`
+	Basis           float64    `json:"basis"`
+	Fees            float64    `json:"fees"`
+	AnnualizedCarry float64    `json:"annualized_carry"`
+	NextFundingTime *time.Time `json:"next_funding_time,omitempty"`
 }
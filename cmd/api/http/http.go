@@ -5,13 +5,28 @@ import (
 	"calc/cmd/api/http/handlers/requests"
 	"calc/common/config"
 	"calc/foundation/jwt"
+	_ "calc/internal/adapters/client/exchanges/binance"
+	_ "calc/internal/adapters/client/exchanges/bybit"
+	_ "calc/internal/adapters/client/exchanges/exmo"
+	_ "calc/internal/adapters/client/exchanges/gate"
+	_ "calc/internal/adapters/client/exchanges/htx"
+	_ "calc/internal/adapters/client/exchanges/kraken"
+	_ "calc/internal/adapters/client/exchanges/kucoin"
+	_ "calc/internal/adapters/client/exchanges/okx"
+	_ "calc/internal/adapters/client/exchanges/uniswap"
 	"calc/internal/adapters/client/sender"
 	"calc/internal/adapters/client/sender/mobizon"
 	"calc/internal/adapters/client/sender/mocks"
 	"calc/internal/adapters/db/postgres"
 	"calc/internal/adapters/db/postgres/migrations"
+	"calc/internal/domain"
 	"calc/internal/services/auth"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
 	"calc/internal/services/exchange"
+	"calc/internal/services/health"
+	"calc/internal/services/history"
+	"calc/internal/services/recorder"
 	"calc/internal/services/refresh_token_keeper"
 	"context"
 	"fmt"
@@ -23,6 +38,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
@@ -126,7 +142,80 @@ func run(cfg *config.Config) error {
 		cfg.Auth.MaxAttempts,
 	)
 
-	exchangeService := exchange.NewService(ctx, cfg, db.Arbitrage())
+	historyService := history.NewService(ctx, cfg, db.Candle())
+	healthTracker := health.NewTracker(cfg)
+
+	calculateService := calculator.NewCalculateService(
+		ctx,
+		cfg,
+		db.Arbitrage(),
+		db.TriangularArbitrage(),
+		db.Carry(),
+		db.Route(),
+		db.Opportunity(),
+		historyService,
+		healthTracker,
+	)
+
+	// калькулятор и история подписываются до запуска адаптеров, чтобы получить первые котировки
+	marketBus := bus.New(cfg.Bus)
+	// калькулятору нужна последняя котировка пары: при отставании ждущие котировки заменяются новыми,
+	// и адаптеры не ждут расчета
+	calculatorSub := marketBus.Subscribe("calculator", bus.Filter{}, bus.WithPolicy(bus.PolicyConflate))
+	historySub := marketBus.Subscribe("history", bus.Filter{Event: bus.EventQuote}, bus.WithPolicy(bus.PolicyBlock))
+
+	if cfg.Recorder != nil && cfg.Recorder.Enabled {
+		log.Info().Msgf("http: Recording market data to %q", cfg.Recorder.Path)
+
+		rec, err := recorder.NewRecorder(ctx, cfg.Recorder.Path, cfg.Recorder.Buffer)
+		if err != nil {
+			return errors.Wrap(err, "failed to init market data recorder")
+		}
+
+		defer func() {
+			if err := rec.Close(); err != nil {
+				log.Error().Stack().Err(err).Msg("closing recorder")
+			}
+		}()
+
+		calculateService = recorder.Wrap(calculateService, rec)
+	}
+
+	// адаптеры останавливаются при завершении раньше остальных сервисов, чтобы дописать принятые котировки
+	exchangesCtx, stopExchanges := context.WithCancel(ctx)
+	defer stopExchanges()
+
+	exchangeService, err := exchange.NewService(
+		exchangesCtx,
+		cfg,
+		db.Arbitrage(),
+		db.TriangularArbitrage(),
+		db.Carry(),
+		db.Route(),
+		db.Opportunity(),
+		historyService,
+		healthTracker,
+		calculateService,
+		marketBus,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to init exchanges")
+	}
+
+	var consumers sync.WaitGroup
+	consumers.Add(2)
+	go func() {
+		defer consumers.Done()
+		calculatorSub.Consume(ctx, func(data *domain.Data) {
+			if err := calculateService.Save(data); err != nil {
+				log.Error().Stack().Err(err).Msg("http: failed to put data on calculator")
+			}
+		})
+	}()
+	go func() {
+		defer consumers.Done()
+		historySub.Consume(ctx, historyService.Record)
+	}()
 
 	// =========================================================================
 	// Start Debug Service
@@ -160,6 +249,7 @@ func run(cfg *config.Config) error {
 			cfg.Version,
 			db,
 			jwtAuth,
+			cfg.Auth.Admins,
 			authService,
 			exchangeService,
 		),
@@ -199,6 +289,30 @@ func run(cfg *config.Config) error {
 			return errors.Wrap(err, "could not stop server gracefully")
 		}
 
+		// сначала останавливаются адаптеры, подписчики шины дочитывают очереди,
+		// калькулятор досчитывает принятые котировки и записывает комбинации до закрытия базы
+		stopExchanges()
+		calculatorSub.Drain()
+		historySub.Drain()
+
+		drained := make(chan struct{})
+		go func() {
+			consumers.Wait()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-shutdownCtx.Done():
+			log.Error().Msg("http: timed out draining market data bus")
+		}
+
+		if err := calculateService.Flush(shutdownCtx); err != nil {
+			log.Error().Stack().Err(err).Msg("http: failed to flush arbitrages")
+		}
+
+		cancel()
+
 		log.Info().Msgf("http: %v: Completed shutdown", sig)
 	}
 
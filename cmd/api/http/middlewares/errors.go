@@ -16,4 +16,8 @@ var (
 		ErrCode: baseCode + 2,
 		Message: jwt.ErrInvalidToken.Error(),
 	}
+	ErrForbidden = &berrors.BusinessError{
+		ErrCode: baseCode + 3,
+		Message: "account has no access to this method",
+	}
 )
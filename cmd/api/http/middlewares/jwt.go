@@ -38,3 +38,28 @@ func Verify(jwtAuth *jwt.Authenticator, tokenType jwt.TokenType) func(http.Handl
 		})
 	}
 }
+
+// Admin пропускает только аккаунты из списка администраторов, должен идти после Verify
+func Admin(accountIDs []uint64) func(http.Handler) http.Handler {
+	admins := make(map[uint64]struct{}, len(accountIDs))
+	for _, id := range accountIDs {
+		admins[id] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accountID, ok := r.Context().Value(AccountIDCtxKey).(uint64)
+			if !ok {
+				respondError(w, r, ErrNoToken, http.StatusUnauthorized)
+				return
+			}
+
+			if _, ok := admins[accountID]; !ok {
+				respondError(w, r, ErrForbidden, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
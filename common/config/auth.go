@@ -9,4 +9,6 @@ type Auth struct {
 	MaxAttempts     int           `yaml:"max_attempts"`
 	AccessLifetime  time.Duration `yaml:"access_lifetime"`
 	RefreshLifetime time.Duration `yaml:"refresh_lifetime"`
+	// Admins аккаунты, которым доступны административные методы
+	Admins []uint64 `yaml:"admins"`
 }
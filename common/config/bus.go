@@ -0,0 +1,16 @@
+package config
+
+// Bus настройки шины рыночных данных
+type Bus struct {
+	// Buffer размер очереди подписчика по умолчанию
+	Buffer int `yaml:"buffer"`
+	// Policy поведение при переполнении очереди: block, drop_newest, drop_oldest, conflate
+	Policy string `yaml:"policy"`
+	// Subscribers настройки очередей по имени подписчика: calculator, history, ws
+	Subscribers map[string]*BusSubscriber `yaml:"subscribers"`
+}
+
+type BusSubscriber struct {
+	Buffer int    `yaml:"buffer"`
+	Policy string `yaml:"policy"`
+}
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// Carry настройки поиска базиса спот - бессрочный фьючерс и арбитража ставок финансирования между биржами
+type Carry struct {
+	Enabled bool `yaml:"enabled"`
+	// Holding горизонт удержания позиции, на который распределяются комиссии и базис
+	Holding time.Duration `yaml:"holding"`
+	// MinCarry минимальная годовая доходность после комиссий в процентах
+	MinCarry float64 `yaml:"min_carry"`
+}
+
+// Futures бессрочные фьючерсы USDⓈ-M биржи
+type Futures struct {
+	Enabled bool   `yaml:"enabled"`
+	URL     string `yaml:"url"`
+	WsURL   string `yaml:"ws_url"`
+	// Pairs пары фьючерсов, по умолчанию пары спота биржи
+	Pairs []string `yaml:"pairs"`
+	Fees  *Fees    `yaml:"fees"`
+}
+
+// FuturesPairs возвращает пары фьючерсов биржи
+func (c *ExchangeConfig) FuturesPairs() []string {
+	if c.Futures == nil || !c.Futures.Enabled {
+		return nil
+	}
+
+	if len(c.Futures.Pairs) > 0 {
+		return c.Futures.Pairs
+	}
+
+	return c.Pairs
+}
@@ -15,6 +15,9 @@ type Config struct {
 	Auth      *Auth     `yaml:"auth"`
 	Exchanges *Exchange `yaml:"exchanges"`
 	Sender    *Sender   `yaml:"sender"`
+	History   *History  `yaml:"history"`
+	Recorder  *Recorder `yaml:"recorder"`
+	Bus       *Bus      `yaml:"bus"`
 }
 
 var cfg Config
@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// Dex настройки биржи на пулах AMM, цены читаются через JSON-RPC узла сети из url
+type Dex struct {
+	// PollInterval период опроса пулов, по умолчанию 12s
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// TradeSize объем сделки в валюте котировки, для которого считается проскальзывание
+	TradeSize float64 `yaml:"trade_size"`
+	// GasLimit газ на один обмен, по умолчанию зависит от версии пула
+	GasLimit uint64 `yaml:"gas_limit"`
+	// NativeAsset валюта, в которой платится газ, например ETH
+	NativeAsset string `yaml:"native_asset"`
+	// Pools пулы по парам BASE_QUOTE
+	Pools map[string]*Pool `yaml:"pools"`
+}
+
+// Pool пул AMM
+type Pool struct {
+	Address string `yaml:"address"`
+	// Version v2 - пул с резервами, v3 - пул с концентрированной ликвидностью
+	Version string `yaml:"version"`
+	// Base адрес токена базовой валюты пары
+	Base string `yaml:"base"`
+	// Fee комиссия пула v2 в процентах, у пулов v3 читается из контракта
+	Fee float64 `yaml:"fee"`
+}
@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// Discovery настройки поиска пар, общих для нескольких бирж. Найденные пары заменяют пары из конфига.
+type Discovery struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval период обновления списка пар
+	Interval time.Duration `yaml:"interval"`
+	// MinExchanges минимальное число бирж, на которых пара проходит фильтры
+	MinExchanges int `yaml:"min_exchanges"`
+	// Quotes допустимые валюты котировки, пустой список - любые
+	Quotes []string `yaml:"quotes"`
+	// MinVolume минимальный объем за 24 часа на бирже по валюте котировки
+	MinVolume map[string]float64 `yaml:"min_volume"`
+	// CacheTTL время жизни списка пар, найденного по запросу при выключенном поиске
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
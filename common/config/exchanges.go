@@ -1,12 +1,108 @@
 package config
 
+import "time"
+
 type Exchange struct {
-	Pairs   []string                   `yaml:"pairs"`
-	Configs map[string]*ExchangeConfig `yaml:"configs"`
+	Pairs []string `yaml:"pairs"`
+	// TradeSize объем сделки в валюте котировки, для которого считается стоимость перевода актива
+	TradeSize map[string]float64 `yaml:"trade_size"`
+	// Combinations число лучших комбинаций бирж покупки и продажи по паре, 0 - все комбинации
+	Combinations int `yaml:"combinations"`
+	// AllowUnknownTransfers считать перевод актива возможным и бесплатным, если у одной из бирж нет данных о сетях.
+	// По умолчанию такие комбинации не участвуют в расчетах.
+	AllowUnknownTransfers bool `yaml:"allow_unknown_transfers"`
+	// StaleAfter время без сообщений по паре, после которого ее котировка не участвует в расчетах
+	StaleAfter time.Duration `yaml:"stale_after"`
+	// Aliases канонические коды валют по кодам бирж, например XBT: BTC
+	Aliases     map[string]string          `yaml:"aliases"`
+	Triangular  *Triangular                `yaml:"triangular"`
+	Routes      *Routes                    `yaml:"routes"`
+	Opportunity *Opportunity               `yaml:"opportunity"`
+	Discovery   *Discovery                 `yaml:"discovery"`
+	Carry       *Carry                     `yaml:"carry"`
+	Pipeline    *Pipeline                  `yaml:"pipeline"`
+	Configs     map[string]*ExchangeConfig `yaml:"configs"`
+}
+
+// Triangular настройки поиска циклов обмена внутри одной биржи
+type Triangular struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxLength максимальная длина цикла: 3 или 4
+	MaxLength int `yaml:"max_length"`
+}
+
+// Routes настройки поиска циклов обмена и перевода между биржами
+type Routes struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxLength максимальное число шагов в маршруте, включая переводы
+	MaxLength int `yaml:"max_length"`
+}
+
+// Opportunity пороги чистой прибыли в процентах для открытия и закрытия арбитражной возможности
+type Opportunity struct {
+	OpenThreshold  float64 `yaml:"open_threshold"`
+	CloseThreshold float64 `yaml:"close_threshold"`
 }
 
 type ExchangeConfig struct {
-	URL   string   `yaml:"url"`
-	WsURL string   `yaml:"ws_url"`
-	Pairs []string `yaml:"pairs"`
+	URL       string     `yaml:"url"`
+	WsURL     string     `yaml:"ws_url"`
+	Websocket *Websocket `yaml:"websocket"`
+	// StaleAfter порог устаревания котировок биржи, по умолчанию общий
+	StaleAfter time.Duration `yaml:"stale_after"`
+	// Aliases алиасы валют биржи, дополняют общие
+	Aliases map[string]string `yaml:"aliases"`
+	Pairs   []string          `yaml:"pairs"`
+	Fees    *Fees             `yaml:"fees"`
+	Assets  Assets            `yaml:"assets"`
+	Futures *Futures          `yaml:"futures"`
+	Dex     *Dex              `yaml:"dex"`
+}
+
+// Fees комиссии биржи в процентах, используются если биржа не отдает их по API
+type Fees struct {
+	Maker float64         `yaml:"maker"`
+	Taker float64         `yaml:"taker"`
+	Pairs map[string]*Fee `yaml:"pairs"`
+}
+
+type Fee struct {
+	Maker float64 `yaml:"maker"`
+	Taker float64 `yaml:"taker"`
+}
+
+func (f *Fees) Get(pair string) *Fee {
+	if f == nil {
+		return &Fee{}
+	}
+
+	if fee, ok := f.Pairs[pair]; ok && fee != nil {
+		return fee
+	}
+
+	return &Fee{
+		Maker: f.Maker,
+		Taker: f.Taker,
+	}
+}
+
+// Assets сети ввода и вывода по активам, используются если биржа не отдает их по API
+type Assets map[string][]*AssetNetwork
+
+type AssetNetwork struct {
+	Network         string  `yaml:"network"`
+	WithdrawFee     float64 `yaml:"withdraw_fee"`
+	MinWithdraw     float64 `yaml:"min_withdraw"`
+	DepositEnabled  bool    `yaml:"deposit_enabled"`
+	WithdrawEnabled bool    `yaml:"withdraw_enabled"`
+}
+
+func (a Assets) Get(asset, network string) *AssetNetwork {
+	for _, n := range a[asset] {
+		if n.Network == network {
+			return n
+		}
+	}
+
+	return nil
 }
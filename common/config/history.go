@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// History настройки хранения истории котировок и спредов
+type History struct {
+	Enabled bool `yaml:"enabled"`
+	// Buffer размер очереди записей, при переполнении новые записи отбрасываются
+	Buffer         int           `yaml:"buffer"`
+	FlushInterval  time.Duration `yaml:"flush_interval"`
+	RollupInterval time.Duration `yaml:"rollup_interval"`
+	// Retention время хранения свечей по интервалам 1s, 1m, 1h
+	Retention map[string]time.Duration `yaml:"retention"`
+}
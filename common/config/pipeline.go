@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// Pipeline настройки обработки котировок калькулятором
+type Pipeline struct {
+	// Shards число обработчиков, между которыми распределяются пары, по умолчанию число CPU
+	Shards int `yaml:"shards"`
+	// Queue размер очереди котировок обработчика
+	Queue int `yaml:"queue"`
+	// FlushInterval период записи накопленных изменений комбинаций в базу
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// BatchSize число накопленных изменений, при котором запись начинается до истечения FlushInterval
+	BatchSize int `yaml:"batch_size"`
+	// Block котировки ждут места в заполненной очереди вместо замены ждущей котировки той же пары.
+	// Нужно воспроизведению записи, где считается каждая котировка.
+	Block bool `yaml:"block"`
+}
@@ -0,0 +1,8 @@
+package config
+
+// Recorder настройки записи нормализованных котировок в файл для последующего воспроизведения
+type Recorder struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	Buffer  int    `yaml:"buffer"`
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// Websocket настройки подключения к websocket биржи, незаданные значения берутся по умолчанию
+type Websocket struct {
+	// PingInterval период отправки ping, соединение без сообщений дольше ReadTimeout переподключается
+	PingInterval time.Duration `yaml:"ping_interval"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	// MinBackoff и MaxBackoff границы экспоненциальной задержки между переподключениями
+	MinBackoff time.Duration `yaml:"min_backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
@@ -2,132 +2,235 @@ package binance
 
 import (
 	"calc/common/config"
-	"calc/foundation/id"
 	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
 	"calc/internal/adapters/client/exchanges/binance/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
 	"calc/internal/domain"
+	"calc/internal/services/bus"
 	"calc/internal/services/calculator"
+	"calc/internal/services/health"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	exchangeInfoUri = "/exchangeInfo"
 	tickerPriceUri  = "/ticker/price"
+	ticker24hUri    = "/ticker/24hr"
+	depthUri        = "/depth"
 	chunksCount     = 3
+	depthLevels     = 20
+	// snapshotLimit глубина снапшота стакана, запрос до 100 уровней дешевле по весу
+	snapshotLimit = 100
 )
 
 var (
-	promPrices = map[string]prometheus.Gauge{}
-
-	errConsumingRateIsTooSlow = errors.New("message consuming rate is too slow")
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "binance_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
 )
 
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "binance",
+		Capabilities: exchanges.Capabilities{
+			Spot:    true,
+			Futures: true,
+			Depth:   true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewBinance(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols, deps.FuturesSymbols)
+		},
+	})
+}
+
 type Binance struct {
 	ctx        context.Context
-	cancel     func()
 	url        string
-	wsURL      string
 	logger     *zerolog.Logger
 	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
 	calculator calculator.CalculateService
-	chans      map[string]map[string]chan<- *domain.Data
-	prices     map[string]float64
-	symbols    map[string]string
-	pairs      []string
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+	// requestID номер последнего сообщения подписки
+	requestID int64
+	// futures nil, если фьючерсы не включены в конфиге
+	futures *futures
+
+	client.Subscriptions
 }
 
-func NewBinance(ctx context.Context, cfg *config.ExchangeConfig, calculator calculator.CalculateService) *Binance {
+func NewBinance(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+	futuresRegistry *symbols.Registry,
+) *Binance {
 	httpClient := client.NewHTTPClient()
 
 	binanceLogger := log.Logger.With().Str("logger", "binance").Logger()
 
-	binanceCtx, cancel := context.WithCancel(ctx)
-
 	binance := &Binance{
-		ctx:        binanceCtx,
-		cancel:     cancel,
+		ctx:        ctx,
 		url:        cfg.URL,
-		wsURL:      cfg.WsURL,
 		logger:     &binanceLogger,
 		httpClient: httpClient,
-		chans:      make(map[string]map[string]chan<- *domain.Data),
 		calculator: calculator,
-		prices:     make(map[string]float64),
-		symbols:    make(map[string]string),
-		pairs:      cfg.Pairs,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
 	}
 
-	go func() {
-		for {
-			if err := binance.run(); err != nil {
-				binanceLogger.Error().Stack().Err(err).Msg("failed to run binance")
-				binance.cancel()
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
 
-				if err == errConsumingRateIsTooSlow {
-					continue
-				}
+	for _, pair := range cfg.Pairs {
+		binance.Register(pair)
+	}
 
-				return
-			}
+	binance.books = orderbook.NewManager(ctx, "binance", depthLevels, binance.depth, binance.save)
+	binance.wsClient = client.NewWSClient("binance", cfg.WsURL, client.WSHandler{
+		Subscribe: binance.subscribe,
+		Handle:    binance.handle,
+	}, cfg.Websocket)
 
-			break
-		}
+	if cfg.Futures != nil && cfg.Futures.Enabled {
+		binance.futures = newFutures(ctx, cfg, calculator, marketBus, futuresRegistry, healthTracker)
+	}
+
+	go func() {
+		binance.loadSymbols()
+		binance.loadFees()
+		binance.loadNetworks()
+
+		binance.wsClient.Run(binance.ctx)
 	}()
 
 	return binance
 }
 
-func (e *Binance) run() error {
-	logger := e.logger.With().Str("method", "run").Logger()
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *Binance) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + quote
+		})
+	}
 
-	logger.Info().Msg(strings.Join(e.pairs, ","))
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(e.wsURL, nil)
-	if err != nil {
-		logger.Error().Stack().Err(err).Msg("failed to connect")
-		return err
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
 	}
-	defer c.Close()
-
-	//c.SetPingHandler(func(message string) error {
-	//	logger.Info().Msg("send pong message")
-	//	err := c.WriteControl(websocket.PongMessage, nil, time.Now().Add(time.Second))
-	//	if err != nil {
-	//		if err == websocket.ErrCloseSent {
-	//			logger.Error().Stack().Err(err).Msg("close connection on pong message")
-	//			return nil
-	//		}
-	//		logger.Error().Stack().Err(err).Msg("failed to send pong message")
-	//	}
-	//
-	//	return err
-	//})
-
-	for _, pair := range e.pairs {
-		promPrices[pair] = prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace:   "calc",
-			Name:        "binance_price",
-			Help:        "pair price",
-			ConstLabels: prometheus.Labels{"pair": pair},
+}
+
+func (e *Binance) loadFees() {
+	for _, pair := range e.SubscribedPairs() {
+		fee := e.fees.Get(pair)
+		e.calculator.SetFee(&domain.Fee{
+			Exchange: "binance",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
 		})
-		prometheus.MustRegister(promPrices[pair])
-		e.chans[pair] = make(map[string]chan<- *domain.Data)
-		e.symbols[strings.ReplaceAll(pair, "_", "")] = pair
 	}
 
+	fees, err := e.Fees(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load fees, using config fees")
+		return
+	}
+
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *Binance) loadNetworks() {
+	for _, network := range e.configNetworks() {
+		e.calculator.SetAssetNetwork(network)
+	}
+
+	networks, err := e.Networks(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load asset networks, using config networks")
+		return
+	}
+
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *Binance) configNetworks() []*domain.AssetNetwork {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "binance",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks
+}
+
+func (e *Binance) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	e.Reset()
+
+	e.books.Reset()
+
 	k := 0
 	streams := make([][]string, 0)
-	for _, pair := range e.pairs {
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
 		if k%chunksCount == 0 {
 			k = 0
 		}
@@ -136,82 +239,333 @@ func (e *Binance) run() error {
 			streams = append(streams, []string{})
 		}
 
-		streams[k] = append(streams[k], fmt.Sprintf("%s@bookTicker", strings.ReplaceAll(strings.ToLower(pair), "_", "")))
+		streams[k] = append(streams[k], stream(symbol))
 		k++
 	}
 
-	for i, stream := range streams {
+	for _, params := range streams {
 		time.Sleep(time.Millisecond * 500)
 
-		init := struct {
-			Method string   `json:"method"`
-			Params []string `json:"params"`
-			Id     int      `json:"id"`
-		}{
-			Id:     i,
-			Method: "SUBSCRIBE",
-			Params: stream,
-		}
+		init := e.request("SUBSCRIBE", params)
 
-		err = c.WriteJSON(init)
-		if err != nil {
+		if err := conn.WriteJSON(init); err != nil {
 			logger.Error().Stack().Err(err).Msg("failed to write init message")
 			return err
 		}
 		logger.Debug().Msgf("init message %v successful sended", init)
 	}
 
-	for {
-		select {
-		case <-e.ctx.Done():
-			err := c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			if err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to close connection")
-				return err
-			}
+	return nil
+}
 
-			logger.Info().Msgf("connection closed %v", e.ctx.Err())
-			return nil
-		default:
-			var ticker *response.WSTicker
-			if err := c.ReadJSON(&ticker); err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to read message")
-				return err
-			}
+// request сообщение подписки или отписки от потоков
+func (e *Binance) request(method string, params []string) interface{} {
+	id := atomic.AddInt64(&e.requestID, 1)
 
-			if ticker.Result != nil {
-				logger.Error().Stack().Msgf("failed on response message [%s]", ticker.Result.ErrorMessage)
-				return errors.New(ticker.Result.ErrorMessage)
+	if method == "SUBSCRIBE" {
+		pairs := make([]string, 0, len(params))
+		for _, param := range params {
+			if pair, ok := e.symbols.Pair(strings.ToUpper(strings.Split(param, "@")[0])); ok {
+				pairs = append(pairs, pair)
 			}
+		}
 
-			pair := e.symbols[ticker.Symbol]
+		e.Track(strconv.FormatInt(id, 10), pairs...)
+	}
 
-			if e.prices[pair] == ticker.Bid {
-				continue
-			}
-			e.prices[pair] = ticker.Bid
+	return struct {
+		Method string   `json:"method"`
+		Params []string `json:"params"`
+		Id     int64    `json:"id"`
+	}{
+		Id:     id,
+		Method: method,
+		Params: params,
+	}
+}
 
-			data := &domain.Data{
-				Exchange: "binance",
-				Pair:     pair,
-				Price:    ticker.Bid,
-			}
+func stream(symbol string) string {
+	return fmt.Sprintf("%s@depth@100ms", strings.ToLower(symbol))
+}
 
-			if err := e.calculator.Save(data); err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to put data on calculator")
-				return err
-			}
+// Subscribe подписывается на стакан пары. Без соединения подписка отправится при подключении.
+func (e *Binance) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
 
-			for _, ch := range e.chans[pair] {
-				ch <- data
-			}
+	if !e.Register(pair) {
+		return nil
+	}
+
+	fee := e.fees.Get(pair)
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "binance",
+		Pair:     pair,
+		Maker:    fee.Maker,
+		Taker:    fee.Taker,
+	})
+
+	if err := e.wsClient.WriteJSON(e.request("SUBSCRIBE", []string{stream(symbol)})); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от стакана пары
+func (e *Binance) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("binance", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("UNSUBSCRIBE", []string{stream(symbol)})); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Binance) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	var depth *response.WSDepth
+	if err := json.Unmarshal(message, &depth); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	if depth.Error != nil {
+		// ошибка подписки одной пары не должна рвать поток остальных
+		rejected := e.Reject(strconv.FormatInt(depth.ID, 10), depth.Error.ErrorMessage, e.symbols.Native)
+		logger.Error().Stack().Strs("pairs", rejected).Msgf("failed on response message [%s]", depth.Error.ErrorMessage)
+		return nil
+	}
+
+	// ответ на запрос подписки
+	if depth.Stream == "" {
+		e.Confirm(strconv.FormatInt(depth.ID, 10))
+		return nil
+	}
+
+	pair, ok := e.symbols.Pair(strings.ToUpper(strings.Split(depth.Stream, "@")[0]))
+	if !ok {
+		logger.Warn().Str("stream", depth.Stream).Msg("unknown symbol")
+		return nil
+	}
 
-			promPrices[pair].Set(data.Price)
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	bids, err := levels(depth.Data.Bids)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse bids")
+		return nil
+	}
+
+	asks, err := levels(depth.Data.Asks)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse asks")
+		return nil
+	}
+
+	update := &orderbook.Update{
+		FirstID: depth.Data.FirstUpdateID,
+		LastID:  depth.Data.FinalUpdateID,
+		Bids:    bids,
+		Asks:    asks,
+	}
+
+	// дельта без изменений лучших уровней тоже подтверждает, что поток пары жив
+	if e.books.Update(pair, update) {
+		e.health.Touch("binance", pair, time.Now().UTC())
+	}
+
+	return nil
+}
+
+// save передает изменившийся стакан в шину рыночных данных
+func (e *Binance) save(data *domain.Data) {
+	// стакан, загруженный до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.health.Touch("binance", data.Pair, data.Time)
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+// depth загружает снапшот стакана для синхронизации с дельтами
+func (e *Binance) depth(ctx context.Context, pair string) (*orderbook.Snapshot, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, depthUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Add("symbol", symbol)
+	q.Add("limit", strconv.Itoa(snapshotLimit))
+
+	u.RawQuery = q.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request depth")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var depth response.Depth
+	if err := json.NewDecoder(resp.Body).Decode(&depth); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode depth response")
+		return nil, err
+	}
+
+	bids, err := levels(depth.Bids)
+	if err != nil {
+		return nil, err
+	}
+
+	asks, err := levels(depth.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderbook.Snapshot{
+		ID:   depth.LastUpdateID,
+		Bids: bids,
+		Asks: asks,
+	}, nil
+}
+
+func levels(raw []response.PriceLevel) ([]domain.PriceLevel, error) {
+	result := make([]domain.PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := level.Price()
+		if err != nil {
+			return nil, err
 		}
+
+		quantity, err := level.Quantity()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, domain.PriceLevel{
+			Price:    price,
+			Quantity: quantity,
+		})
 	}
+
+	return result, nil
 }
 
 func (e *Binance) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает символы, доступные для спотовой торговли
+func (e *Binance) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	tradable, err := e.exchangeInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(tradable))
+	for _, symbol := range tradable {
+		markets = append(markets, symbols.Symbol{
+			Native: symbol.Symbol,
+			Base:   symbol.BaseAsset,
+			Quote:  symbol.QuoteAsset,
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+func (e *Binance) Markets(ctx context.Context) ([]*domain.Market, error) {
+	tradable, err := e.exchangeInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, ticker24hUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request 24h tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickers []*response.Ticker24h
+	if err := json.NewDecoder(resp.Body).Decode(&tickers); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode 24h tickers response")
+		return nil, err
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		volumes[ticker.Symbol] = ticker.QuoteVolume
+	}
+
+	markets := make([]*domain.Market, 0, len(tradable))
+	for _, symbol := range tradable {
+		markets = append(markets, &domain.Market{
+			Exchange:    "binance",
+			Pair:        e.symbols.Canonical(symbol.BaseAsset, symbol.QuoteAsset),
+			Base:        e.symbols.Asset(symbol.BaseAsset),
+			Quote:       e.symbols.Asset(symbol.QuoteAsset),
+			Volume:      volumes[symbol.Symbol],
+			MinNotional: symbol.MinNotional(),
+		})
+	}
+
+	return markets, nil
+}
+
+// exchangeInfo возвращает символы, доступные для спотовой торговли, с их фильтрами
+func (e *Binance) exchangeInfo(ctx context.Context) ([]*response.Symbol, error) {
 	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, exchangeInfoUri))
 	if err != nil {
 		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
@@ -232,17 +586,22 @@ func (e *Binance) Pairs(ctx context.Context) ([]string, error) {
 		return nil, err
 	}
 
-	var pairs []string
+	var tradable []*response.Symbol
 	for _, symbol := range exchangeInfo.Symbols {
 		if symbol.Status == "TRADING" && symbol.IsSpotTradingAllowed && symbol.HasPermission("SPOT") {
-			pairs = append(pairs, fmt.Sprintf("%s_%s", symbol.BaseAsset, symbol.QuoteAsset))
+			tradable = append(tradable, symbol)
 		}
 	}
 
-	return pairs, nil
+	return tradable, nil
 }
 
 func (e *Binance) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
 	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickerPriceUri))
 	if err != nil {
 		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
@@ -250,7 +609,7 @@ func (e *Binance) Price(ctx context.Context, pair string) (float64, error) {
 	}
 
 	q := u.Query()
-	q.Add("symbol", strings.ReplaceAll(pair, "_", ""))
+	q.Add("symbol", symbol)
 
 	u.RawQuery = q.Encode()
 
@@ -271,18 +630,31 @@ func (e *Binance) Price(ctx context.Context, pair string) (float64, error) {
 	return tickerPrice.Price, nil
 }
 
-func (e *Binance) WSPrice(ctx context.Context, pair string, ch chan<- *domain.Data) {
-	chanID := id.ULID().String()
-	e.chans[pair][chanID] = ch
+// Fees возвращает комиссии из конфига: публичного эндпоинта с комиссиями у Binance нет
+func (e *Binance) Fees(_ context.Context) ([]*domain.Fee, error) {
+	pairs := e.SubscribedPairs()
+
+	fees := make([]*domain.Fee, 0, len(pairs))
+	for _, pair := range pairs {
+		fee := e.fees.Get(pair)
+		fees = append(fees, &domain.Fee{
+			Exchange: "binance",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
 
-	defer delete(e.chans[pair], chanID)
+	return fees, nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-e.ctx.Done():
-			return
-		}
-	}
+// Networks возвращает сети из конфига: эндпоинт с сетями вывода у Binance требует подписи
+func (e *Binance) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	return e.configNetworks(), nil
+}
+
+func (e *Binance) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("binance", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
 }
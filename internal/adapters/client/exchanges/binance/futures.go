@@ -0,0 +1,290 @@
+package binance
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges/binance/response"
+	"calc/internal/adapters/client/funding"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// fundingInterval период финансирования USDⓈ-M фьючерсов по умолчанию
+	fundingInterval = 8 * time.Hour
+	// futuresChunkSize число потоков в одном сообщении подписки
+	futuresChunkSize = 100
+)
+
+// futures получает лучшие цены и ставки финансирования бессрочных фьючерсов USDⓈ-M
+type futures struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	funding    *funding.Tracker
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	symbols    *symbols.Registry
+	health     *health.Tracker
+	fees       *config.Fees
+	pairs      []string
+	// requestID номер последнего сообщения подписки
+	requestID int64
+}
+
+func newFutures(ctx context.Context, cfg *config.ExchangeConfig, calculator calculator.CalculateService, marketBus *bus.Bus, registry *symbols.Registry, healthTracker *health.Tracker) *futures {
+	futuresLogger := log.Logger.With().Str("logger", "binance_futures").Logger()
+
+	f := &futures{
+		ctx:        ctx,
+		url:        cfg.Futures.URL,
+		logger:     &futuresLogger,
+		httpClient: client.NewHTTPClient(),
+		calculator: calculator,
+		bus:        marketBus,
+		symbols:    registry,
+		health:     healthTracker,
+		fees:       cfg.Futures.Fees,
+		pairs:      cfg.FuturesPairs(),
+	}
+
+	f.funding = funding.NewTracker(f.save)
+	// bookTicker присылает лучшие цены целиком, снапшоты по REST не нужны
+	f.books = orderbook.NewManager(ctx, "binance", 1, nil, f.funding.Save)
+	f.wsClient = client.NewWSClient("binance_futures", cfg.Futures.WsURL, client.WSHandler{
+		Subscribe: f.subscribe,
+		Handle:    f.handle,
+	}, cfg.Websocket)
+
+	go func() {
+		f.loadSymbols()
+		f.loadFees()
+
+		f.wsClient.Run(f.ctx)
+	}()
+
+	return f
+}
+
+// loadSymbols загружает бессрочные контракты, пары контрактов имеют вид BASEQUOTE, как на споте
+func (f *futures) loadSymbols() {
+	markets, err := f.markets(f.ctx)
+	if err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(f.pairs, func(base, quote string) string {
+			return base + quote
+		})
+	}
+
+	if duplicates := f.symbols.Load(markets); len(duplicates) > 0 {
+		f.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := f.symbols.Unmapped(f.pairs); len(unmapped) > 0 {
+		f.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+func (f *futures) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	resp, err := f.httpClient.Get(ctx, fmt.Sprintf("%s%s", f.url, exchangeInfoUri))
+	if err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to request exchange info")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var exchangeInfo response.FuturesExchangeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&exchangeInfo); err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to decode exchange info response")
+		return nil, err
+	}
+
+	var markets []symbols.Symbol
+	for _, symbol := range exchangeInfo.Symbols {
+		if symbol.ContractType != "PERPETUAL" || symbol.Status != "TRADING" {
+			continue
+		}
+
+		markets = append(markets, symbols.Symbol{
+			Native: symbol.Symbol,
+			Base:   symbol.BaseAsset,
+			Quote:  symbol.QuoteAsset,
+		})
+	}
+
+	return markets, nil
+}
+
+func (f *futures) loadFees() {
+	for _, pair := range f.pairs {
+		fee := f.fees.Get(pair)
+		f.calculator.SetFee(&domain.Fee{
+			Exchange: "binance",
+			Pair:     pair,
+			Market:   domain.MarketPerp,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+}
+
+func (f *futures) subscribe(conn client.WSConn) error {
+	logger := f.logger.With().Str("method", "subscribe").Logger()
+
+	logger.Info().Msg(strings.Join(f.pairs, ","))
+
+	f.books.Reset()
+
+	var streams []string
+	for _, pair := range f.pairs {
+		symbol, err := f.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		symbol = strings.ToLower(symbol)
+		streams = append(streams, symbol+"@bookTicker", symbol+"@markPrice@1s")
+	}
+
+	for start := 0; start < len(streams); start += futuresChunkSize {
+		end := start + futuresChunkSize
+		if end > len(streams) {
+			end = len(streams)
+		}
+
+		init := struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+			Id     int64    `json:"id"`
+		}{
+			Id:     atomic.AddInt64(&f.requestID, 1),
+			Method: "SUBSCRIBE",
+			Params: streams[start:end],
+		}
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+func (f *futures) handle(message []byte) error {
+	logger := f.logger.With().Str("method", "handle").Logger()
+
+	var msg *response.WSFutures
+	if err := json.Unmarshal(message, &msg); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	if msg.Error != nil {
+		// ошибка подписки одного символа не должна рвать поток остальных
+		logger.Error().Stack().Msgf("failed on response message [%s]", msg.Error.ErrorMessage)
+		return nil
+	}
+
+	if msg.Stream == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(msg.Stream, "@", 2)
+	pair, ok := f.symbols.Pair(strings.ToUpper(parts[0]))
+	if !ok || len(parts) != 2 {
+		logger.Warn().Str("stream", msg.Stream).Msg("unknown symbol")
+		return nil
+	}
+
+	switch {
+	case parts[1] == "bookTicker":
+		var ticker *response.BookTicker
+		if err := json.Unmarshal(msg.Data, &ticker); err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to read book ticker")
+			return err
+		}
+
+		bid, err := level(ticker.BidPrice, ticker.BidQuantity)
+		if err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to parse bid")
+			return nil
+		}
+
+		ask, err := level(ticker.AskPrice, ticker.AskQuantity)
+		if err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to parse ask")
+			return nil
+		}
+
+		// неизменившиеся цены тоже подтверждают, что поток пары жив
+		f.health.Touch(health.Source("binance", domain.MarketPerp), pair, time.Now().UTC())
+
+		f.books.Snapshot(pair, &orderbook.Snapshot{
+			Bids: []domain.PriceLevel{bid},
+			Asks: []domain.PriceLevel{ask},
+		})
+	case strings.HasPrefix(parts[1], "markPrice"):
+		var markPrice *response.MarkPrice
+		if err := json.Unmarshal(msg.Data, &markPrice); err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to read mark price")
+			return err
+		}
+
+		price, _ := strconv.ParseFloat(markPrice.MarkPrice, 64)
+		rate, err := strconv.ParseFloat(markPrice.FundingRate, 64)
+		if err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to parse funding rate")
+			return nil
+		}
+
+		f.funding.Update(pair, &funding.Rate{
+			MarkPrice:       price,
+			FundingRate:     rate * 100,
+			Interval:        fundingInterval,
+			NextFundingTime: time.Unix(0, markPrice.NextFundingTime*int64(time.Millisecond)).UTC(),
+		})
+	}
+
+	return nil
+}
+
+// save публикует котировку фьючерса в шину рыночных данных с типом события perp
+func (f *futures) save(data *domain.Data) {
+	f.bus.Publish(data)
+}
+
+func level(price, quantity string) (domain.PriceLevel, error) {
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return domain.PriceLevel{}, err
+	}
+
+	q, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return domain.PriceLevel{}, err
+	}
+
+	return domain.PriceLevel{
+		Price:    p,
+		Quantity: q,
+	}, nil
+}
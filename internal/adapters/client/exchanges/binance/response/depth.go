@@ -0,0 +1,8 @@
+package response
+
+// Depth снапшот стакана /depth
+type Depth struct {
+	LastUpdateID uint64       `json:"lastUpdateId"`
+	Bids         []PriceLevel `json:"bids"`
+	Asks         []PriceLevel `json:"asks"`
+}
@@ -1,5 +1,7 @@
 package response
 
+import "strconv"
+
 type ExchangeInfo struct {
 	Timezone        string        `json:"timezone"`
 	ServerTime      int64         `json:"serverTime"`
@@ -8,22 +10,42 @@ type ExchangeInfo struct {
 }
 
 type Symbol struct {
-	Symbol                     string        `json:"symbol"`
-	Status                     string        `json:"status"`
-	BaseAsset                  string        `json:"baseAsset"`
-	BaseAssetPrecision         int           `json:"baseAssetPrecision"`
-	QuoteAsset                 string        `json:"quoteAsset"`
-	QuotePrecision             int           `json:"quotePrecision"`
-	QuoteAssetPrecision        int           `json:"quoteAssetPrecision"`
-	OrderTypes                 []string      `json:"orderTypes"`
-	IcebergAllowed             bool          `json:"icebergAllowed"`
-	OcoAllowed                 bool          `json:"ocoAllowed"`
-	QuoteOrderQtyMarketAllowed bool          `json:"quoteOrderQtyMarketAllowed"`
-	AllowTrailingStop          bool          `json:"allowTrailingStop"`
-	IsSpotTradingAllowed       bool          `json:"isSpotTradingAllowed"`
-	IsMarginTradingAllowed     bool          `json:"isMarginTradingAllowed"`
-	Filters                    []interface{} `json:"filters"`
-	Permissions                []string      `json:"permissions"`
+	Symbol                     string    `json:"symbol"`
+	Status                     string    `json:"status"`
+	BaseAsset                  string    `json:"baseAsset"`
+	BaseAssetPrecision         int       `json:"baseAssetPrecision"`
+	QuoteAsset                 string    `json:"quoteAsset"`
+	QuotePrecision             int       `json:"quotePrecision"`
+	QuoteAssetPrecision        int       `json:"quoteAssetPrecision"`
+	OrderTypes                 []string  `json:"orderTypes"`
+	IcebergAllowed             bool      `json:"icebergAllowed"`
+	OcoAllowed                 bool      `json:"ocoAllowed"`
+	QuoteOrderQtyMarketAllowed bool      `json:"quoteOrderQtyMarketAllowed"`
+	AllowTrailingStop          bool      `json:"allowTrailingStop"`
+	IsSpotTradingAllowed       bool      `json:"isSpotTradingAllowed"`
+	IsMarginTradingAllowed     bool      `json:"isMarginTradingAllowed"`
+	Filters                    []*Filter `json:"filters"`
+	Permissions                []string  `json:"permissions"`
+}
+
+type Filter struct {
+	FilterType  string `json:"filterType"`
+	MinNotional string `json:"minNotional"`
+}
+
+// MinNotional минимальная сумма ордера из фильтров MIN_NOTIONAL или NOTIONAL
+func (s *Symbol) MinNotional() float64 {
+	for _, filter := range s.Filters {
+		if filter.FilterType != "MIN_NOTIONAL" && filter.FilterType != "NOTIONAL" {
+			continue
+		}
+
+		if minNotional, err := strconv.ParseFloat(filter.MinNotional, 64); err == nil {
+			return minNotional
+		}
+	}
+
+	return 0
 }
 
 func (s *Symbol) HasPermission(perm string) bool {
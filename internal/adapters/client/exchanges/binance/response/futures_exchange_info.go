@@ -0,0 +1,16 @@
+package response
+
+// FuturesExchangeInfo ответ /fapi/v1/exchangeInfo
+type FuturesExchangeInfo struct {
+	Symbols []*FuturesSymbol `json:"symbols"`
+}
+
+type FuturesSymbol struct {
+	Symbol       string `json:"symbol"`
+	Pair         string `json:"pair"`
+	ContractType string `json:"contractType"`
+	Status       string `json:"status"`
+	BaseAsset    string `json:"baseAsset"`
+	QuoteAsset   string `json:"quoteAsset"`
+	MarginAsset  string `json:"marginAsset"`
+}
@@ -0,0 +1,6 @@
+package response
+
+type Ticker24h struct {
+	Symbol      string  `json:"symbol"`
+	QuoteVolume float64 `json:"quoteVolume,string"`
+}
@@ -0,0 +1,40 @@
+package response
+
+import "strconv"
+
+// WSDepth сообщение combined stream с дельтами стакана <symbol>@depth@100ms
+type WSDepth struct {
+	ID     int64  `json:"id"`
+	Stream string `json:"stream"`
+	Data   struct {
+		Symbol string `json:"s"`
+		// FirstUpdateID и FinalUpdateID номера первого и последнего обновления в дельте
+		FirstUpdateID uint64       `json:"U"`
+		FinalUpdateID uint64       `json:"u"`
+		Bids          []PriceLevel `json:"b"`
+		Asks          []PriceLevel `json:"a"`
+	} `json:"data"`
+	Error *struct {
+		Code         int    `json:"code"`
+		ErrorMessage string `json:"msg"`
+	} `json:"error"`
+}
+
+// PriceLevel уровень стакана в формате [price, quantity]
+type PriceLevel []string
+
+func (l PriceLevel) Price() (float64, error) {
+	return l.parse(0)
+}
+
+func (l PriceLevel) Quantity() (float64, error) {
+	return l.parse(1)
+}
+
+func (l PriceLevel) parse(i int) (float64, error) {
+	if len(l) <= i {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(l[i], 64)
+}
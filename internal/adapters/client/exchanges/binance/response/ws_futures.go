@@ -0,0 +1,30 @@
+package response
+
+import "encoding/json"
+
+// WSFutures сообщение combined stream фьючерсов: <symbol>@bookTicker или <symbol>@markPrice@1s
+type WSFutures struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+	Error  *struct {
+		Code         int    `json:"code"`
+		ErrorMessage string `json:"msg"`
+	} `json:"error"`
+}
+
+// BookTicker лучшие цены фьючерса
+type BookTicker struct {
+	Symbol      string `json:"s"`
+	BidPrice    string `json:"b"`
+	BidQuantity string `json:"B"`
+	AskPrice    string `json:"a"`
+	AskQuantity string `json:"A"`
+}
+
+// MarkPrice цена маркировки и ставка финансирования фьючерса, ставка в долях за период
+type MarkPrice struct {
+	Symbol          string `json:"s"`
+	MarkPrice       string `json:"p"`
+	FundingRate     string `json:"r"`
+	NextFundingTime int64  `json:"T"`
+}
@@ -0,0 +1,598 @@
+package bybit
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/exchanges/bybit/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	instrumentsUri = "/v5/market/instruments-info"
+	tickersUri     = "/v5/market/tickers"
+	topic          = "orderbook.1."
+	// chunkSize число топиков в одном сообщении подписки, больше биржа не принимает
+	chunkSize = 10
+)
+
+var (
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "bybit_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
+
+	errNotFound = errors.New("not found")
+)
+
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "bybit",
+		Capabilities: exchanges.Capabilities{
+			Spot:    true,
+			Futures: true,
+			Depth:   true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewBybit(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols, deps.FuturesSymbols)
+		},
+	})
+}
+
+// Bybit получает лучшие цены из топика orderbook.1, символ пары имеет вид BASEQUOTE
+type Bybit struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+	// requestID номер последнего сообщения подписки
+	requestID int64
+	// futures nil, если фьючерсы не включены в конфиге
+	futures *futures
+
+	client.Subscriptions
+}
+
+func NewBybit(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+	futuresRegistry *symbols.Registry,
+) *Bybit {
+	httpClient := client.NewHTTPClient()
+
+	bybitLogger := log.Logger.With().Str("logger", "bybit").Logger()
+
+	bybit := &Bybit{
+		ctx:        ctx,
+		url:        cfg.URL,
+		logger:     &bybitLogger,
+		httpClient: httpClient,
+		calculator: calculator,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
+
+	for _, pair := range cfg.Pairs {
+		bybit.Register(pair)
+	}
+
+	// топик присылает лучшие цены целиком, снапшоты по REST не нужны
+	bybit.books = orderbook.NewManager(ctx, "bybit", 1, nil, bybit.save)
+	bybit.wsClient = client.NewWSClient("bybit", cfg.WsURL, client.WSHandler{
+		Subscribe: bybit.subscribe,
+		Handle:    bybit.handle,
+		// без прикладного ping биржа закрывает соединение через 10 минут
+		Ping: func(conn client.WSConn) error {
+			return conn.WriteJSON(&struct {
+				ReqID string `json:"req_id"`
+				Op    string `json:"op"`
+			}{
+				ReqID: strconv.FormatInt(atomic.AddInt64(&bybit.requestID, 1), 10),
+				Op:    "ping",
+			})
+		},
+	}, cfg.Websocket)
+
+	if cfg.Futures != nil && cfg.Futures.Enabled {
+		bybit.futures = newFutures(ctx, cfg, calculator, marketBus, futuresRegistry, healthTracker)
+	}
+
+	go func() {
+		bybit.loadSymbols()
+		bybit.loadFees()
+		bybit.loadNetworks()
+
+		bybit.wsClient.Run(bybit.ctx)
+	}()
+
+	return bybit
+}
+
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *Bybit) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + quote
+		})
+	}
+
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+func (e *Bybit) loadFees() {
+	fees, _ := e.Fees(e.ctx)
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *Bybit) loadNetworks() {
+	networks, _ := e.Networks(e.ctx)
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *Bybit) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	e.Reset()
+
+	var symbolList []string
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		symbolList = append(symbolList, symbol)
+	}
+
+	for start := 0; start < len(symbolList); start += chunkSize {
+		end := start + chunkSize
+		if end > len(symbolList) {
+			end = len(symbolList)
+		}
+
+		init := e.request("subscribe", symbolList[start:end]...)
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+// request сообщение подписки или отписки от лучших цен пар
+func (e *Bybit) request(op string, symbols ...string) interface{} {
+	reqID := strconv.FormatInt(atomic.AddInt64(&e.requestID, 1), 10)
+
+	args := make([]string, 0, len(symbols))
+	pairs := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		args = append(args, topic+symbol)
+
+		if pair, ok := e.symbols.Pair(symbol); ok {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if op == "subscribe" {
+		e.Track(reqID, pairs...)
+	}
+
+	return struct {
+		ReqID string   `json:"req_id"`
+		Op    string   `json:"op"`
+		Args  []string `json:"args"`
+	}{
+		ReqID: reqID,
+		Op:    op,
+		Args:  args,
+	}
+}
+
+// Subscribe подписывается на лучшие цены пары. Без соединения подписка отправится при подключении.
+func (e *Bybit) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	fee := e.fees.Get(pair)
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "bybit",
+		Pair:     pair,
+		Maker:    fee.Maker,
+		Taker:    fee.Taker,
+	})
+
+	if err := e.wsClient.WriteJSON(e.request("subscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от лучших цен пары
+func (e *Bybit) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("bybit", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("unsubscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Bybit) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	var msg *response.WSMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	// ответы на подписку и ping
+	if msg.Op != "" {
+		if msg.Success != nil && !*msg.Success {
+			// ошибка подписки одной пары не должна рвать поток остальных
+			rejected := e.Reject(msg.ReqID, msg.RetMsg, e.symbols.Native)
+			logger.Error().Stack().Strs("pairs", rejected).Msgf("failed on response message [%s] %s", msg.Op, msg.RetMsg)
+			return nil
+		}
+
+		e.Confirm(msg.ReqID)
+
+		return nil
+	}
+
+	if !strings.HasPrefix(msg.Topic, topic) || msg.Data == nil {
+		return nil
+	}
+
+	pair, ok := e.symbols.Pair(msg.Data.Symbol)
+	if !ok {
+		logger.Warn().Str("symbol", msg.Data.Symbol).Msg("unknown symbol")
+		return nil
+	}
+
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	bids, err := levels(msg.Data.Bids)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse bids")
+		return nil
+	}
+
+	asks, err := levels(msg.Data.Asks)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse asks")
+		return nil
+	}
+
+	// неизменившиеся цены тоже подтверждают, что поток пары жив
+	e.health.Touch("bybit", pair, time.Now().UTC())
+
+	// стакан глубины 1 всегда приходит снапшотом
+	e.books.Snapshot(pair, &orderbook.Snapshot{
+		Bids: bids,
+		Asks: asks,
+	})
+
+	return nil
+}
+
+// save передает изменившиеся цены в шину рыночных данных
+func (e *Bybit) save(data *domain.Data) {
+	// цены, пришедшие до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+func levels(raw []response.PriceLevel) ([]domain.PriceLevel, error) {
+	result := make([]domain.PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := level.Price()
+		if err != nil {
+			return nil, err
+		}
+
+		quantity, err := level.Quantity()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, domain.PriceLevel{
+			Price:    price,
+			Quantity: quantity,
+		})
+	}
+
+	return result, nil
+}
+
+func (e *Bybit) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает инструменты, доступные для торговли
+func (e *Bybit) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	instruments, err := e.instruments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(instruments))
+	for _, instrument := range instruments {
+		markets = append(markets, symbols.Symbol{
+			Native: instrument.Symbol,
+			Base:   instrument.BaseCoin,
+			Quote:  instrument.QuoteCoin,
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+func (e *Bybit) Markets(ctx context.Context) ([]*domain.Market, error) {
+	instruments, err := e.instruments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, err := e.tickers(ctx, url.Values{"category": {"spot"}})
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		volumes[ticker.Symbol], _ = strconv.ParseFloat(ticker.Turnover24h, 64)
+	}
+
+	markets := make([]*domain.Market, 0, len(instruments))
+	for _, instrument := range instruments {
+		var minNotional float64
+		if instrument.LotSizeFilter != nil {
+			minNotional, _ = strconv.ParseFloat(instrument.LotSizeFilter.MinOrderAmt, 64)
+		}
+
+		markets = append(markets, &domain.Market{
+			Exchange:    "bybit",
+			Pair:        e.symbols.Canonical(instrument.BaseCoin, instrument.QuoteCoin),
+			Base:        e.symbols.Asset(instrument.BaseCoin),
+			Quote:       e.symbols.Asset(instrument.QuoteCoin),
+			Volume:      volumes[instrument.Symbol],
+			MinNotional: minNotional,
+		})
+	}
+
+	return markets, nil
+}
+
+// instruments возвращает спотовые инструменты в статусе Trading
+func (e *Bybit) instruments(ctx context.Context) ([]*response.Instrument, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, instrumentsUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Add("category", "spot")
+
+	u.RawQuery = q.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request instruments")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var instrumentsResponse response.InstrumentsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&instrumentsResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode instruments response")
+		return nil, err
+	}
+
+	if instrumentsResponse.RetCode != 0 {
+		e.logger.Error().Stack().Msgf("failed on instruments response [%d] %s", instrumentsResponse.RetCode, instrumentsResponse.RetMsg)
+		return nil, errors.New(instrumentsResponse.RetMsg)
+	}
+
+	var trading []*response.Instrument
+	for _, instrument := range instrumentsResponse.Result.List {
+		if instrument.Status == "Trading" {
+			trading = append(trading, instrument)
+		}
+	}
+
+	return trading, nil
+}
+
+func (e *Bybit) tickers(ctx context.Context, query url.Values) ([]*response.Ticker, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickersUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	u.RawQuery = query.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickersResponse response.TickersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickersResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	if tickersResponse.RetCode != 0 {
+		e.logger.Error().Stack().Msgf("failed on tickers response [%d] %s", tickersResponse.RetCode, tickersResponse.RetMsg)
+		return nil, errors.New(tickersResponse.RetMsg)
+	}
+
+	return tickersResponse.Result.List, nil
+}
+
+func (e *Bybit) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	tickers, err := e.tickers(ctx, url.Values{"category": {"spot"}, "symbol": {symbol}})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tickers) == 0 {
+		return 0, errNotFound
+	}
+
+	return strconv.ParseFloat(tickers[0].LastPrice, 64)
+}
+
+// Fees возвращает комиссии из конфига: эндпоинт с комиссиями у Bybit требует подписи
+func (e *Bybit) Fees(_ context.Context) ([]*domain.Fee, error) {
+	pairs := e.SubscribedPairs()
+
+	fees := make([]*domain.Fee, 0, len(pairs))
+	for _, pair := range pairs {
+		fee := e.fees.Get(pair)
+		fees = append(fees, &domain.Fee{
+			Exchange: "bybit",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает сети из конфига: эндпоинт с сетями вывода у Bybit требует подписи
+func (e *Bybit) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "bybit",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func (e *Bybit) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("bybit", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
+}
@@ -0,0 +1,348 @@
+package bybit
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges/bybit/response"
+	"calc/internal/adapters/client/funding"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tickersTopic = "tickers."
+	// fundingInterval период финансирования, если биржа его не передала
+	fundingInterval = 8 * time.Hour
+)
+
+// futures получает лучшие цены и ставки финансирования линейных бессрочных фьючерсов
+type futures struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	funding    *funding.Tracker
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	symbols    *symbols.Registry
+	health     *health.Tracker
+	fees       *config.Fees
+	pairs      []string
+	// intervals периоды финансирования по символам, заполняются до подключения
+	intervals map[string]time.Duration
+	// rates последние ставки по символам, дельты топика tickers накладываются на них. Используется только в handle.
+	rates map[string]*funding.Rate
+	// requestID номер последнего сообщения подписки
+	requestID int64
+}
+
+func newFutures(ctx context.Context, cfg *config.ExchangeConfig, calculator calculator.CalculateService, marketBus *bus.Bus, registry *symbols.Registry, healthTracker *health.Tracker) *futures {
+	futuresLogger := log.Logger.With().Str("logger", "bybit_futures").Logger()
+
+	f := &futures{
+		ctx:        ctx,
+		url:        cfg.Futures.URL,
+		logger:     &futuresLogger,
+		httpClient: client.NewHTTPClient(),
+		calculator: calculator,
+		bus:        marketBus,
+		symbols:    registry,
+		health:     healthTracker,
+		fees:       cfg.Futures.Fees,
+		pairs:      cfg.FuturesPairs(),
+		intervals:  make(map[string]time.Duration),
+		rates:      make(map[string]*funding.Rate),
+	}
+
+	f.funding = funding.NewTracker(f.save)
+	// топик присылает лучшие цены целиком, снапшоты по REST не нужны
+	f.books = orderbook.NewManager(ctx, "bybit", 1, nil, f.funding.Save)
+	f.wsClient = client.NewWSClient("bybit_futures", cfg.Futures.WsURL, client.WSHandler{
+		Subscribe: f.subscribe,
+		Handle:    f.handle,
+		Ping: func(conn client.WSConn) error {
+			return conn.WriteJSON(&struct {
+				ReqID string `json:"req_id"`
+				Op    string `json:"op"`
+			}{
+				ReqID: strconv.FormatInt(atomic.AddInt64(&f.requestID, 1), 10),
+				Op:    "ping",
+			})
+		},
+	}, cfg.Websocket)
+
+	go func() {
+		f.loadSymbols()
+		f.loadFees()
+
+		f.wsClient.Run(f.ctx)
+	}()
+
+	return f
+}
+
+// loadSymbols загружает линейные бессрочные контракты и их периоды финансирования
+func (f *futures) loadSymbols() {
+	instruments, err := f.instruments(f.ctx)
+	if err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+	}
+
+	var markets []symbols.Symbol
+	for _, instrument := range instruments {
+		markets = append(markets, symbols.Symbol{
+			Native: instrument.Symbol,
+			Base:   instrument.BaseCoin,
+			Quote:  instrument.QuoteCoin,
+		})
+
+		if instrument.FundingInterval > 0 {
+			f.intervals[instrument.Symbol] = time.Duration(instrument.FundingInterval) * time.Minute
+		}
+	}
+
+	if err != nil {
+		markets = symbols.FromPairs(f.pairs, func(base, quote string) string {
+			return base + quote
+		})
+	}
+
+	if duplicates := f.symbols.Load(markets); len(duplicates) > 0 {
+		f.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := f.symbols.Unmapped(f.pairs); len(unmapped) > 0 {
+		f.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+// instruments возвращает линейные бессрочные контракты в статусе Trading
+func (f *futures) instruments(ctx context.Context) ([]*response.Instrument, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", f.url, instrumentsUri))
+	if err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Add("category", "linear")
+	q.Add("limit", "1000")
+
+	u.RawQuery = q.Encode()
+
+	resp, err := f.httpClient.Get(ctx, u.String())
+	if err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to request instruments")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var instrumentsResponse response.InstrumentsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&instrumentsResponse); err != nil {
+		f.logger.Error().Stack().Err(err).Msg("failed to decode instruments response")
+		return nil, err
+	}
+
+	if instrumentsResponse.RetCode != 0 {
+		f.logger.Error().Stack().Msgf("failed on instruments response [%d] %s", instrumentsResponse.RetCode, instrumentsResponse.RetMsg)
+		return nil, errors.New(instrumentsResponse.RetMsg)
+	}
+
+	var trading []*response.Instrument
+	for _, instrument := range instrumentsResponse.Result.List {
+		if instrument.Status == "Trading" && instrument.ContractType == "LinearPerpetual" {
+			trading = append(trading, instrument)
+		}
+	}
+
+	return trading, nil
+}
+
+func (f *futures) loadFees() {
+	for _, pair := range f.pairs {
+		fee := f.fees.Get(pair)
+		f.calculator.SetFee(&domain.Fee{
+			Exchange: "bybit",
+			Pair:     pair,
+			Market:   domain.MarketPerp,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+}
+
+func (f *futures) subscribe(conn client.WSConn) error {
+	logger := f.logger.With().Str("method", "subscribe").Logger()
+
+	logger.Info().Msg(strings.Join(f.pairs, ","))
+
+	f.books.Reset()
+	// после переподключения топик tickers присылает снапшоты заново
+	f.rates = make(map[string]*funding.Rate)
+
+	var args []string
+	for _, pair := range f.pairs {
+		symbol, err := f.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		args = append(args, topic+symbol, tickersTopic+symbol)
+	}
+
+	for start := 0; start < len(args); start += chunkSize {
+		end := start + chunkSize
+		if end > len(args) {
+			end = len(args)
+		}
+
+		init := struct {
+			ReqID string   `json:"req_id"`
+			Op    string   `json:"op"`
+			Args  []string `json:"args"`
+		}{
+			ReqID: strconv.FormatInt(atomic.AddInt64(&f.requestID, 1), 10),
+			Op:    "subscribe",
+			Args:  args[start:end],
+		}
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+func (f *futures) handle(message []byte) error {
+	logger := f.logger.With().Str("method", "handle").Logger()
+
+	var msg *response.WSFutures
+	if err := json.Unmarshal(message, &msg); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	// ответы на подписку и ping
+	if msg.Op != "" {
+		if msg.Success != nil && !*msg.Success {
+			// ошибка подписки одного символа не должна рвать поток остальных
+			logger.Error().Stack().Msgf("failed on response message [%s] %s", msg.Op, msg.RetMsg)
+		}
+
+		return nil
+	}
+
+	if len(msg.Data) == 0 {
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(msg.Topic, topic):
+		var book *response.OrderBook
+		if err := json.Unmarshal(msg.Data, &book); err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to read order book")
+			return err
+		}
+
+		pair, ok := f.symbols.Pair(book.Symbol)
+		if !ok {
+			logger.Warn().Str("symbol", book.Symbol).Msg("unknown symbol")
+			return nil
+		}
+
+		bids, err := levels(book.Bids)
+		if err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to parse bids")
+			return nil
+		}
+
+		asks, err := levels(book.Asks)
+		if err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to parse asks")
+			return nil
+		}
+
+		// неизменившиеся цены тоже подтверждают, что поток пары жив
+		f.health.Touch(health.Source("bybit", domain.MarketPerp), pair, time.Now().UTC())
+
+		f.books.Snapshot(pair, &orderbook.Snapshot{
+			Bids: bids,
+			Asks: asks,
+		})
+	case strings.HasPrefix(msg.Topic, tickersTopic):
+		var ticker *response.FuturesTicker
+		if err := json.Unmarshal(msg.Data, &ticker); err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to read ticker")
+			return err
+		}
+
+		symbol := strings.TrimPrefix(msg.Topic, tickersTopic)
+		pair, ok := f.symbols.Pair(symbol)
+		if !ok {
+			logger.Warn().Str("symbol", symbol).Msg("unknown symbol")
+			return nil
+		}
+
+		f.funding.Update(pair, f.rate(symbol, ticker))
+	}
+
+	return nil
+}
+
+// rate накладывает поля тикера на последнюю ставку символа, пустые поля означают, что значение не изменилось
+func (f *futures) rate(symbol string, ticker *response.FuturesTicker) *funding.Rate {
+	rate := &funding.Rate{Interval: fundingInterval}
+	if previous, ok := f.rates[symbol]; ok {
+		*rate = *previous
+	}
+
+	if interval, ok := f.intervals[symbol]; ok {
+		rate.Interval = interval
+	}
+
+	if ticker.MarkPrice != "" {
+		rate.MarkPrice, _ = strconv.ParseFloat(ticker.MarkPrice, 64)
+	}
+
+	if ticker.FundingRate != "" {
+		if fundingRate, err := strconv.ParseFloat(ticker.FundingRate, 64); err == nil {
+			rate.FundingRate = fundingRate * 100
+		}
+	}
+
+	if ticker.NextFundingTime != "" {
+		if ms, err := strconv.ParseInt(ticker.NextFundingTime, 10, 64); err == nil {
+			rate.NextFundingTime = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+		}
+	}
+
+	f.rates[symbol] = rate
+
+	return rate
+}
+
+// save публикует котировку фьючерса в шину рыночных данных с типом события perp
+func (f *futures) save(data *domain.Data) {
+	f.bus.Publish(data)
+}
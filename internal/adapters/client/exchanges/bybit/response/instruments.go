@@ -0,0 +1,29 @@
+package response
+
+// InstrumentsResponse ответ /v5/market/instruments-info
+type InstrumentsResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		Category string        `json:"category"`
+		List     []*Instrument `json:"list"`
+	} `json:"result"`
+}
+
+type Instrument struct {
+	Symbol        string         `json:"symbol"`
+	BaseCoin      string         `json:"baseCoin"`
+	QuoteCoin     string         `json:"quoteCoin"`
+	Status        string         `json:"status"`
+	LotSizeFilter *LotSizeFilter `json:"lotSizeFilter"`
+	// ContractType и FundingInterval заполнены только для фьючерсов, FundingInterval в минутах
+	ContractType    string `json:"contractType"`
+	FundingInterval int64  `json:"fundingInterval"`
+}
+
+type LotSizeFilter struct {
+	BasePrecision string `json:"basePrecision"`
+	MinOrderQty   string `json:"minOrderQty"`
+	// MinOrderAmt минимальная сумма ордера в котируемой валюте
+	MinOrderAmt string `json:"minOrderAmt"`
+}
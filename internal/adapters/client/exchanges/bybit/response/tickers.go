@@ -0,0 +1,21 @@
+package response
+
+// TickersResponse ответ /v5/market/tickers
+type TickersResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		Category string    `json:"category"`
+		List     []*Ticker `json:"list"`
+	} `json:"result"`
+}
+
+type Ticker struct {
+	Symbol    string `json:"symbol"`
+	LastPrice string `json:"lastPrice"`
+	Bid1Price string `json:"bid1Price"`
+	Ask1Price string `json:"ask1Price"`
+	Volume24h string `json:"volume24h"`
+	// Turnover24h объем за 24 часа в котируемой валюте
+	Turnover24h string `json:"turnover24h"`
+}
@@ -0,0 +1,22 @@
+package response
+
+import "encoding/json"
+
+// WSFutures сообщение топиков фьючерсов или ответ на подписку и ping, Data зависит от топика
+type WSFutures struct {
+	Success *bool           `json:"success"`
+	RetMsg  string          `json:"ret_msg"`
+	Op      string          `json:"op"`
+	Topic   string          `json:"topic"`
+	Type    string          `json:"type"`
+	Ts      int64           `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// FuturesTicker данные топика tickers. В сообщениях delta отсутствуют неизменившиеся поля.
+type FuturesTicker struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	FundingRate     string `json:"fundingRate"`
+	NextFundingTime string `json:"nextFundingTime"`
+}
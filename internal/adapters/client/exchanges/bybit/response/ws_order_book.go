@@ -0,0 +1,42 @@
+package response
+
+import "strconv"
+
+// WSMessage сообщение топика orderbook или ответ на подписку и ping
+type WSMessage struct {
+	ReqID   string     `json:"req_id"`
+	Success *bool      `json:"success"`
+	RetMsg  string     `json:"ret_msg"`
+	Op      string     `json:"op"`
+	Topic   string     `json:"topic"`
+	Type    string     `json:"type"`
+	Ts      int64      `json:"ts"`
+	Data    *OrderBook `json:"data"`
+}
+
+type OrderBook struct {
+	Symbol   string       `json:"s"`
+	Bids     []PriceLevel `json:"b"`
+	Asks     []PriceLevel `json:"a"`
+	UpdateID int64        `json:"u"`
+	Seq      int64        `json:"seq"`
+}
+
+// PriceLevel уровень стакана в формате [price, size]
+type PriceLevel []string
+
+func (l PriceLevel) Price() (float64, error) {
+	return l.parse(0)
+}
+
+func (l PriceLevel) Quantity() (float64, error) {
+	return l.parse(1)
+}
+
+func (l PriceLevel) parse(i int) (float64, error) {
+	if len(l) <= i {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(l[i], 64)
+}
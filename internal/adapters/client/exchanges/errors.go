@@ -7,4 +7,5 @@ import (
 var (
 	ErrExchangeNotFound     = errors.New("exchange not found")
 	ErrExchangeNotImplement = errors.New("exchange not implement")
+	ErrUnknownExchange      = errors.New("unknown exchange")
 )
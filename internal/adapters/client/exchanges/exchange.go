@@ -7,6 +7,15 @@ import (
 
 type Exchange interface {
 	Pairs(ctx context.Context) ([]string, error)
+	// Markets торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+	Markets(ctx context.Context) ([]*domain.Market, error)
 	Price(ctx context.Context, pair string) (float64, error)
-	WSPrice(ctx context.Context, pair string, ch chan<- *domain.Data)
+	Fees(ctx context.Context) ([]*domain.Fee, error)
+	Networks(ctx context.Context) ([]*domain.AssetNetwork, error)
+	// Subscribe подписывается на стакан пары во время работы
+	Subscribe(ctx context.Context, pair string) error
+	// Unsubscribe отписывается от стакана пары
+	Unsubscribe(ctx context.Context, pair string) error
+	// Health состояние соединения и свежесть котировок по парам
+	Health(ctx context.Context) *domain.ExchangeHealth
 }
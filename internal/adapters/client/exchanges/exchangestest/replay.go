@@ -0,0 +1,119 @@
+package exchangestest
+
+import (
+	"calc/common/config"
+	"calc/internal/services/bus"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reconnectBackoff задержка переподключения адаптера в тестах, чтобы обрыв соединения не ждал секунду
+const reconnectBackoff = 10 * time.Millisecond
+
+// Adapter адаптер биржи под тестом
+type Adapter interface {
+	Subscribed(pair string) bool
+}
+
+// NewAdapter запускает адаптер биржи с конфигом, который указывает на сервер
+type NewAdapter func(ctx context.Context, s *Server, cfg *config.ExchangeConfig, calc *Calculator, marketBus *bus.Bus) Adapter
+
+// Case сценарий проигрывания записанных кадров биржи адаптеру
+type Case struct {
+	Name  string
+	Pairs []string
+	// REST файлы тел ответов на REST запросы по пути запроса
+	REST map[string]string
+	// Sessions кадры по подключениям, каждая сессия кроме последней заканчивается обрывом соединения
+	Sessions []Session
+	// Quotes котировки всех сессий по порядку
+	Quotes []Quote
+	// Rejected пары, которые биржа отклонила: они должны быть сняты с подписки, остальные пары - нет
+	Rejected []string
+	// Resubscribed символы биржи, которые должны быть в сообщениях последнего подключения,
+	// Dropped - символы, которых в них быть не должно
+	Resubscribed []string
+	Dropped      []string
+	// Check дополнительные проверки после получения котировок
+	Check func(t *testing.T, s *Server)
+}
+
+// Replay проигрывает сценарии: для каждого запускает сервер с кадрами сессий и адаптер,
+// ждет котировки и проверяет подписки и число подключений
+func Replay(t *testing.T, newAdapter NewAdapter, cases []Case, options ...Option) {
+	t.Helper()
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			server := NewServer(t, c.Sessions, options...)
+			for path, file := range c.REST {
+				body, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatalf("failed to read response: %v", err)
+				}
+
+				server.Handle(path, string(body))
+			}
+
+			marketBus := bus.New(nil)
+			sub := marketBus.Subscribe("test", bus.Filter{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			e := newAdapter(ctx, server, &config.ExchangeConfig{
+				URL:   server.URL,
+				WsURL: server.WsURL(),
+				Pairs: c.Pairs,
+				Websocket: &config.Websocket{
+					MinBackoff: reconnectBackoff,
+					MaxBackoff: reconnectBackoff,
+				},
+			}, &Calculator{}, marketBus)
+
+			Expect(t, sub, c.Quotes...)
+
+			for _, pair := range c.Pairs {
+				rejected := contains(c.Rejected, pair)
+				if e.Subscribed(pair) == rejected {
+					t.Errorf("pair %s subscribed = %v, want %v", pair, !rejected, rejected)
+				}
+			}
+
+			if n := server.Connections(); n != len(c.Sessions) {
+				t.Errorf("connections = %d, want %d", n, len(c.Sessions))
+			}
+
+			messages := strings.Join(server.ReceivedOn(len(c.Sessions)-1), "\n")
+			for _, symbol := range c.Resubscribed {
+				if !strings.Contains(messages, symbol) {
+					t.Errorf("symbol %s is not subscribed after reconnect", symbol)
+				}
+			}
+
+			for _, symbol := range c.Dropped {
+				if strings.Contains(messages, symbol) {
+					t.Errorf("rejected symbol %s is subscribed after reconnect", symbol)
+				}
+			}
+
+			if c.Check != nil {
+				c.Check(t, server)
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
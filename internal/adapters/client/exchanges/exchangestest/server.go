@@ -0,0 +1,345 @@
+// Package exchangestest помогает тестировать адаптеры бирж на записанных сообщениях websocket
+package exchangestest
+
+import (
+	"bufio"
+	"bytes"
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"compress/gzip"
+	"context"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	wsPath  = "/ws"
+	timeout = 5 * time.Second
+)
+
+var upgrader = websocket.Upgrader{}
+
+type Option func(s *Server)
+
+// WithGzip отправляет кадры сжатыми бинарными сообщениями, как HTX
+func WithGzip() Option {
+	return func(s *Server) {
+		s.gzip = true
+	}
+}
+
+// Session кадры одного websocket подключения из файла, по кадру в строке
+type Session struct {
+	// After число сообщений клиента в подключении, после которого сервер отправляет кадры
+	After  int
+	Frames string
+}
+
+// Server биржа для тестов адаптеров: на REST запросы отвечает заданными телами или 404,
+// в websocket отправляет кадры сессии подключения по порядку. После кадров каждой сессии,
+// кроме последней, сервер закрывает соединение, последняя сессия отвечает и следующим подключениям.
+type Server struct {
+	*httptest.Server
+
+	t        *testing.T
+	sessions []Session
+	frames   [][][]byte
+	gzip     bool
+
+	mu   sync.Mutex
+	rest map[string]string
+	// received сообщения клиента по подключениям
+	received [][]string
+}
+
+// NewServer запускает сервер, он останавливается в конце теста
+func NewServer(t *testing.T, sessions []Session, options ...Option) *Server {
+	t.Helper()
+
+	s := &Server{
+		t:        t,
+		sessions: sessions,
+		frames:   make([][][]byte, 0, len(sessions)),
+		rest:     make(map[string]string),
+	}
+
+	for _, session := range sessions {
+		s.frames = append(s.frames, ReadFrames(t, session.Frames))
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// WsURL адрес websocket сервера
+func (s *Server) WsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + wsPath
+}
+
+// Handle задает тело ответа на REST запрос path
+func (s *Server) Handle(path, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rest[path] = body
+}
+
+// Received возвращает сообщения, полученные от клиента по websocket во всех подключениях
+func (s *Server) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var received []string
+	for _, messages := range s.received {
+		received = append(received, messages...)
+	}
+
+	return received
+}
+
+// ReceivedOn возвращает сообщения, полученные от клиента в подключении с номером n, начиная с нуля
+func (s *Server) ReceivedOn(n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n < 0 || n >= len(s.received) {
+		return nil
+	}
+
+	return append([]string(nil), s.received[n]...)
+}
+
+// Connections число websocket подключений клиента
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.received)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == wsPath {
+		s.serveWS(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	body, ok := s.rest[r.URL.Path]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(body))
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.t.Errorf("failed to upgrade connection: %v", err)
+		return
+	}
+
+	defer conn.Close()
+
+	s.mu.Lock()
+	n := len(s.received)
+	s.received = append(s.received, nil)
+	s.mu.Unlock()
+
+	i := n
+	if i >= len(s.sessions) {
+		i = len(s.sessions) - 1
+	}
+
+	count := 0
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.received[n] = append(s.received[n], string(message))
+		s.mu.Unlock()
+
+		count++
+		if count != s.sessions[i].After {
+			continue
+		}
+
+		for _, frame := range s.frames[i] {
+			if err := s.write(conn, frame); err != nil {
+				return
+			}
+		}
+
+		// обрыв соединения: клиент должен переподключиться и подписаться заново
+		if i < len(s.sessions)-1 {
+			return
+		}
+	}
+}
+
+func (s *Server) write(conn *websocket.Conn, frame []byte) error {
+	if !s.gzip {
+		return conn.WriteMessage(websocket.TextMessage, frame)
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(frame); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+// ReadFrames читает кадры из файла, по кадру в строке, пустые строки пропускаются
+func ReadFrames(t *testing.T, path string) [][]byte {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open frames: %v", err)
+	}
+
+	defer file.Close()
+
+	var frames [][]byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			frames = append(frames, []byte(line))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read frames: %v", err)
+	}
+
+	return frames
+}
+
+// Next ждет следующую котировку подписки шины
+func Next(t *testing.T, sub *bus.Subscription) *domain.Data {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, ok := sub.Next(ctx)
+	if !ok {
+		t.Fatal("no data published")
+	}
+
+	return data
+}
+
+// Quote ожидаемые лучшие цены котировки
+type Quote struct {
+	Exchange    string
+	Pair        string
+	Bid         float64
+	BidQuantity float64
+	Ask         float64
+	AskQuantity float64
+}
+
+// Expect ждет котировки подписки шины и сравнивает их лучшие цены с ожидаемыми по порядку
+func Expect(t *testing.T, sub *bus.Subscription, quotes ...Quote) {
+	t.Helper()
+
+	for i, want := range quotes {
+		data := Next(t, sub)
+
+		got := Quote{
+			Exchange:    data.Exchange,
+			Pair:        data.Pair,
+			Bid:         data.Bid,
+			BidQuantity: data.BidQuantity,
+			Ask:         data.Ask,
+			AskQuantity: data.AskQuantity,
+		}
+
+		if got != want {
+			t.Errorf("quote %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+// Eventually ждет выполнения условия
+func Eventually(t *testing.T, condition func() bool, msg string) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Calculator калькулятор, который ничего не считает и только запоминает комиссии, переданные адаптером
+type Calculator struct {
+	mu   sync.Mutex
+	fees map[string]*domain.Fee
+}
+
+var _ calculator.CalculateService = (*Calculator)(nil)
+
+// Fee возвращает последнюю комиссию пары биржи
+func (c *Calculator) Fee(exchange, pair string) (*domain.Fee, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fee, ok := c.fees[exchange+":"+pair]
+
+	return fee, ok
+}
+
+func (c *Calculator) Save(*domain.Data) error { return nil }
+
+func (c *Calculator) SetFee(fee *domain.Fee) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.fees == nil {
+		c.fees = make(map[string]*domain.Fee)
+	}
+
+	c.fees[fee.Exchange+":"+fee.Pair] = fee
+}
+
+func (c *Calculator) SetAssetNetwork(*domain.AssetNetwork) {}
+
+func (c *Calculator) AddPair(string) {}
+
+func (c *Calculator) RemovePair(string) error { return nil }
+
+func (c *Calculator) Flush(context.Context) error { return nil }
+
+func (c *Calculator) Top(filters.ArbitrageParams) ([]*domain.Arbitrage, bool) { return nil, false }
@@ -2,178 +2,467 @@ package exmo
 
 import (
 	"calc/common/config"
-	"calc/foundation/id"
 	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
 	"calc/internal/adapters/client/exchanges/exmo/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
 	"calc/internal/domain"
+	"calc/internal/services/bus"
 	"calc/internal/services/calculator"
+	"calc/internal/services/health"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	tickersUri      = "/required_amount"
-	pairSettingsUri = "/pair_settings"
+	tickersUri         = "/required_amount"
+	tickerUri          = "/ticker"
+	pairSettingsUri    = "/pair_settings"
+	cryptoProvidersUri = "/payments/providers/crypto/list"
 )
 
 var (
-	promPrices = map[string]prometheus.Gauge{}
-
-	errConsumingRateIsTooSlow = errors.New("message consuming rate is too slow")
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "exmo_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
 )
 
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "exmo",
+		Capabilities: exchanges.Capabilities{
+			Spot:  true,
+			Depth: true,
+			Fees:  true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewExmo(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols)
+		},
+	})
+}
+
 type Exmo struct {
 	ctx        context.Context
-	cancel     func()
 	url        string
-	wsURL      string
 	logger     *zerolog.Logger
 	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
 	calculator calculator.CalculateService
-	chans      map[string]map[string]chan<- *domain.Data
-	prices     map[string]float64
-	pairs      []string
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+	// requestID номер последнего сообщения подписки
+	requestID int64
+
+	client.Subscriptions
 }
 
-func NewExmo(ctx context.Context, cfg *config.ExchangeConfig, calculator calculator.CalculateService) *Exmo {
+func NewExmo(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+) *Exmo {
 	httpClient := client.NewHTTPClient()
 
 	exmoLogger := log.Logger.With().Str("logger", "exmo").Logger()
 
-	exmoCtx, cancel := context.WithCancel(ctx)
-
 	exmo := &Exmo{
-		ctx:        exmoCtx,
-		cancel:     cancel,
+		ctx:        ctx,
 		url:        cfg.URL,
-		wsURL:      cfg.WsURL,
 		logger:     &exmoLogger,
 		httpClient: httpClient,
-		chans:      make(map[string]map[string]chan<- *domain.Data),
 		calculator: calculator,
-		prices:     make(map[string]float64),
-		pairs:      cfg.Pairs,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
 	}
 
-	go func() {
-		for {
-			if err := exmo.run(); err != nil {
-				exmo.cancel()
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
 
-				if err == errConsumingRateIsTooSlow {
-					continue
-				}
+	for _, pair := range cfg.Pairs {
+		exmo.Register(pair)
+	}
 
-				return
-			}
+	// exmo присылает стакан целиком, снапшоты по REST не нужны
+	exmo.books = orderbook.NewManager(ctx, "exmo", 0, nil, exmo.save)
+	exmo.wsClient = client.NewWSClient("exmo", cfg.WsURL, client.WSHandler{
+		Subscribe: exmo.subscribe,
+		Handle:    exmo.handle,
+	}, cfg.Websocket)
 
-			break
-		}
+	go func() {
+		exmo.loadSymbols()
+		exmo.loadFees()
+		exmo.loadNetworks()
+
+		exmo.wsClient.Run(exmo.ctx)
 	}()
 
 	return exmo
 }
 
-func (e *Exmo) run() error {
-	logger := e.logger.With().Str("method", "run").Logger()
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *Exmo) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + "_" + quote
+		})
+	}
 
-	logger.Info().Msg(strings.Join(e.pairs, ","))
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(e.wsURL, nil)
-	if err != nil {
-		logger.Error().Stack().Err(err).Msg("failed to connect")
-		return err
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
 	}
-	defer c.Close()
+}
 
-	topics := make([]string, len(e.pairs))
-	for i, pair := range e.pairs {
-		promPrices[pair] = prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace:   "calc",
-			Name:        "exmo_price",
-			Help:        "pair price",
-			ConstLabels: prometheus.Labels{"pair": pair},
+func (e *Exmo) loadFees() {
+	for _, pair := range e.SubscribedPairs() {
+		fee := e.fees.Get(pair)
+		e.calculator.SetFee(&domain.Fee{
+			Exchange: "exmo",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
 		})
-		prometheus.MustRegister(promPrices[pair])
-		e.chans[pair] = make(map[string]chan<- *domain.Data)
-		topics[i] = fmt.Sprintf("spot/ticker:%s", pair)
 	}
 
-	init := struct {
-		Id     int      `json:"id"`
+	fees, err := e.Fees(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load fees, using config fees")
+		return
+	}
+
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *Exmo) loadNetworks() {
+	for _, network := range e.configNetworks() {
+		e.calculator.SetAssetNetwork(network)
+	}
+
+	networks, err := e.Networks(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load asset networks, using config networks")
+		return
+	}
+
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *Exmo) configNetworks() []*domain.AssetNetwork {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "exmo",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks
+}
+
+func (e *Exmo) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	topics := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		topics = append(topics, topic(symbol))
+	}
+
+	init := e.request("subscribe", topics)
+
+	if err := conn.WriteJSON(init); err != nil {
+		logger.Error().Stack().Err(err).Msg("failed to write init message")
+		return err
+	}
+	logger.Debug().Msgf("init message %v successful sended", init)
+
+	return nil
+}
+
+// request сообщение подписки или отписки от топиков
+func (e *Exmo) request(method string, topics []string) interface{} {
+	return struct {
+		Id     int64    `json:"id"`
 		Method string   `json:"method"`
 		Topics []string `json:"topics"`
 	}{
-		Id:     1,
-		Method: "subscribe",
+		Id:     atomic.AddInt64(&e.requestID, 1),
+		Method: method,
 		Topics: topics,
 	}
+}
+
+func topic(symbol string) string {
+	return fmt.Sprintf("spot/order_book_snapshots:%s", symbol)
+}
+
+// Subscribe подписывается на стакан пары. Без соединения подписка отправится при подключении.
+func (e *Exmo) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("subscribe", []string{topic(symbol)})); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
 
-	err = c.WriteJSON(init)
+	return nil
+}
+
+// Unsubscribe отписывается от стакана пары
+func (e *Exmo) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("exmo", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
 	if err != nil {
-		logger.Error().Stack().Err(err).Msg("failed to write init message")
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("unsubscribe", []string{topic(symbol)})); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
 		return err
 	}
-	logger.Debug().Msgf("init message %v successful sended", init)
 
-	for {
-		select {
-		case <-e.ctx.Done():
-			err := c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			if err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to close connection")
-				return err
-			}
+	return nil
+}
 
-			logger.Info().Msgf("connection closed %v", e.ctx.Err())
-			return nil
-		default:
-			var ticker *response.WSTicker
-			if err := c.ReadJSON(&ticker); err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to read message")
-				return err
-			}
+func (e *Exmo) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
 
-			if ticker.Event == "error" {
-				return errors.New(ticker.Message)
-			} else if ticker.Event != "update" {
-				continue
-			}
+	var book *response.WSOrderBook
+	if err := json.Unmarshal(message, &book); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
 
-			pair := strings.Split(ticker.Topic, ":")[1]
-			if e.prices[pair] == ticker.Data.BuyPrice {
-				continue
-			}
-			e.prices[pair] = ticker.Data.BuyPrice
+	if book.Event == "error" {
+		return errors.New(book.Message)
+	} else if book.Event != "update" && book.Event != "snapshot" {
+		return nil
+	}
 
-			data := &domain.Data{
-				Exchange: "exmo",
-				Pair:     pair,
-				Price:    ticker.Data.BuyPrice,
-			}
-			if err := e.calculator.Save(data); err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to put data on calculator")
-				return err
-			}
+	pair, ok := e.symbols.Pair(strings.TrimPrefix(book.Topic, "spot/order_book_snapshots:"))
+	if !ok {
+		logger.Warn().Str("topic", book.Topic).Msg("unknown symbol")
+		return nil
+	}
 
-			for _, ch := range e.chans[pair] {
-				ch <- data
-			}
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	bids, err := levels(book.Data.Bid)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse bids")
+		return nil
+	}
+
+	asks, err := levels(book.Data.Ask)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse asks")
+		return nil
+	}
+
+	// неизменившийся стакан тоже подтверждает, что поток пары жив
+	e.health.Touch("exmo", pair, time.Now().UTC())
 
-			promPrices[pair].Set(data.Price)
+	e.books.Snapshot(pair, &orderbook.Snapshot{
+		Bids: bids,
+		Asks: asks,
+	})
+
+	return nil
+}
+
+// save передает изменившийся стакан в шину рыночных данных
+func (e *Exmo) save(data *domain.Data) {
+	// стакан, загруженный до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+func levels(raw []response.PriceLevel) ([]domain.PriceLevel, error) {
+	result := make([]domain.PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := level.Price()
+		if err != nil {
+			return nil, err
+		}
+
+		quantity, err := level.Quantity()
+		if err != nil {
+			return nil, err
 		}
+
+		result = append(result, domain.PriceLevel{
+			Price:    price,
+			Quantity: quantity,
+		})
 	}
+
+	return result, nil
 }
 
 func (e *Exmo) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает пары из настроек биржи, символ exmo имеет вид BASE_QUOTE
+func (e *Exmo) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	settingsResponse, err := e.pairSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(settingsResponse))
+	for pair := range settingsResponse {
+		assets := strings.SplitN(pair, "_", 2)
+		if len(assets) != 2 {
+			continue
+		}
+
+		markets = append(markets, symbols.Symbol{
+			Native: pair,
+			Base:   assets[0],
+			Quote:  assets[1],
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+func (e *Exmo) Markets(ctx context.Context) ([]*domain.Market, error) {
+	settingsResponse, err := e.pairSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickerUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickers response.TickerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickers); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	markets := make([]*domain.Market, 0, len(settingsResponse))
+	for pair, settings := range settingsResponse {
+		assets := strings.SplitN(pair, "_", 2)
+		if len(assets) != 2 {
+			continue
+		}
+
+		market := &domain.Market{
+			Exchange: "exmo",
+			Pair:     e.symbols.Canonical(assets[0], assets[1]),
+			Base:     e.symbols.Asset(assets[0]),
+			Quote:    e.symbols.Asset(assets[1]),
+		}
+		if ticker, ok := tickers[pair]; ok {
+			market.Volume, _ = strconv.ParseFloat(ticker.VolCurr, 64)
+		}
+		market.MinNotional, _ = strconv.ParseFloat(settings.MinAmount, 64)
+
+		markets = append(markets, market)
+	}
+
+	return markets, nil
+}
+
+// pairSettings возвращает настройки пар: ограничения ордеров и комиссии
+func (e *Exmo) pairSettings(ctx context.Context) (response.PairSettingsResponse, error) {
 	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, pairSettingsUri))
 	if err != nil {
 		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
@@ -182,7 +471,7 @@ func (e *Exmo) Pairs(ctx context.Context) ([]string, error) {
 
 	resp, err := e.httpClient.Get(ctx, u.String())
 	if err != nil {
-		e.logger.Error().Stack().Err(err).Msg("failed to request ticker")
+		e.logger.Error().Stack().Err(err).Msg("failed to request pair settings")
 		return nil, err
 	}
 
@@ -190,19 +479,19 @@ func (e *Exmo) Pairs(ctx context.Context) ([]string, error) {
 
 	var settingsResponse response.PairSettingsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&settingsResponse); err != nil {
-		e.logger.Error().Stack().Err(err).Msg("failed to decode ticker response")
+		e.logger.Error().Stack().Err(err).Msg("failed to decode pair settings response")
 		return nil, err
 	}
 
-	var pairs []string
-	for pair := range settingsResponse {
-		pairs = append(pairs, pair)
-	}
-
-	return pairs, nil
+	return settingsResponse, nil
 }
 
 func (e *Exmo) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
 	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickersUri))
 	if err != nil {
 		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
@@ -210,7 +499,7 @@ func (e *Exmo) Price(ctx context.Context, pair string) (float64, error) {
 	}
 
 	q := u.Query()
-	q.Add("pair", pair)
+	q.Add("pair", symbol)
 	q.Add("quantity", "1")
 
 	u.RawQuery = q.Encode()
@@ -232,18 +521,117 @@ func (e *Exmo) Price(ctx context.Context, pair string) (float64, error) {
 	return amount.Amount, nil
 }
 
-func (e *Exmo) WSPrice(ctx context.Context, pair string, ch chan<- *domain.Data) {
-	chanID := id.ULID().String()
-	e.chans[pair][chanID] = ch
+func (e *Exmo) Fees(ctx context.Context) ([]*domain.Fee, error) {
+	settingsResponse, err := e.pairSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fees := make([]*domain.Fee, 0, len(settingsResponse))
+	for pair, settings := range settingsResponse {
+		taker, err := strconv.ParseFloat(settings.CommissionTakerPercent, 64)
+		if err != nil {
+			return nil, err
+		}
 
-	defer delete(e.chans[pair], chanID)
+		maker, err := strconv.ParseFloat(settings.CommissionMakerPercent, 64)
+		if err != nil {
+			return nil, err
+		}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-e.ctx.Done():
-			return
+		canonical, ok := e.symbols.Pair(pair)
+		if !ok {
+			continue
 		}
+
+		fees = append(fees, &domain.Fee{
+			Exchange: "exmo",
+			Pair:     canonical,
+			Maker:    maker,
+			Taker:    taker,
+		})
 	}
+
+	return fees, nil
+}
+
+func (e *Exmo) Networks(ctx context.Context) ([]*domain.AssetNetwork, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, cryptoProvidersUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request crypto providers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var providersResponse response.CryptoProvidersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&providersResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode crypto providers response")
+		return nil, err
+	}
+
+	var networks []*domain.AssetNetwork
+	for native, providers := range providersResponse {
+		asset := e.symbols.Asset(native)
+		byName := make(map[string]*domain.AssetNetwork)
+		for _, provider := range providers {
+			network, ok := byName[provider.Name]
+			if !ok {
+				network = &domain.AssetNetwork{
+					Exchange: "exmo",
+					Asset:    asset,
+					Network:  provider.Name,
+				}
+				if assetNetwork := e.assets.Get(asset, provider.Name); assetNetwork != nil {
+					network.WithdrawFee = assetNetwork.WithdrawFee
+					network.MinWithdraw = assetNetwork.MinWithdraw
+				}
+
+				byName[provider.Name] = network
+				networks = append(networks, network)
+			}
+
+			switch provider.Type {
+			case "deposit":
+				network.DepositEnabled = provider.Enabled
+			case "withdraw":
+				network.WithdrawEnabled = provider.Enabled
+				if min, err := strconv.ParseFloat(provider.Min, 64); err == nil {
+					network.MinWithdraw = min
+				}
+				if fee, ok := parseCommission(provider.CommissionDesc, native); ok {
+					network.WithdrawFee = fee
+				}
+			}
+		}
+	}
+
+	return networks, nil
+}
+
+// parseCommission разбирает описание комиссии вида "0.0005 BTC", процентные комиссии не поддерживаются
+func parseCommission(desc, asset string) (float64, bool) {
+	fields := strings.Fields(desc)
+	if len(fields) != 2 || fields[1] != asset {
+		return 0, false
+	}
+
+	fee, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return fee, true
+}
+
+func (e *Exmo) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("exmo", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
 }
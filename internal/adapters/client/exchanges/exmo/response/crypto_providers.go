@@ -0,0 +1,15 @@
+package response
+
+type CryptoProvidersResponse map[string][]*CryptoProvider
+
+type CryptoProvider struct {
+	Type                  string `json:"type"`
+	Name                  string `json:"name"`
+	CurrencyName          string `json:"currency_name"`
+	Min                   string `json:"min"`
+	Max                   string `json:"max"`
+	Enabled               bool   `json:"enabled"`
+	Comment               string `json:"comment"`
+	CommissionDesc        string `json:"commission_desc"`
+	CurrencyConfirmations int    `json:"currency_confirmations"`
+}
@@ -0,0 +1,12 @@
+package response
+
+type TickerResponse map[string]*Ticker
+
+type Ticker struct {
+	BuyPrice  string `json:"buy_price"`
+	SellPrice string `json:"sell_price"`
+	LastTrade string `json:"last_trade"`
+	Vol       string `json:"vol"`
+	VolCurr   string `json:"vol_curr"`
+	Updated   int64  `json:"updated"`
+}
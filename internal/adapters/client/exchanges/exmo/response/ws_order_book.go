@@ -0,0 +1,33 @@
+package response
+
+import "strconv"
+
+type WSOrderBook struct {
+	Ts      int64  `json:"ts"`
+	Event   string `json:"event"`
+	Topic   string `json:"topic"`
+	Message string `json:"message"`
+	Data    struct {
+		Ask []PriceLevel `json:"ask"`
+		Bid []PriceLevel `json:"bid"`
+	} `json:"data"`
+}
+
+// PriceLevel уровень стакана в формате [price, quantity, amount]
+type PriceLevel []string
+
+func (l PriceLevel) Price() (float64, error) {
+	return l.parse(0)
+}
+
+func (l PriceLevel) Quantity() (float64, error) {
+	return l.parse(1)
+}
+
+func (l PriceLevel) parse(i int) (float64, error) {
+	if len(l) <= i {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(l[i], 64)
+}
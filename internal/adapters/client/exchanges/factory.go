@@ -2,44 +2,85 @@ package exchanges
 
 import (
 	"calc/common/config"
-	"calc/internal/adapters/client/exchanges/binance"
-	"calc/internal/adapters/client/exchanges/exmo"
-	"calc/internal/adapters/client/exchanges/gate"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/services/bus"
 	"calc/internal/services/calculator"
+	"calc/internal/services/health"
 	"context"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"sort"
 )
 
 type ExchangeFactory struct {
 	cfg       *config.Config
 	logger    *zerolog.Logger
 	exchanges map[string]Exchange
+	adapters  map[string]*Adapter
 }
 
-func NewExchangeFactory(ctx context.Context, cfg *config.Config, calculateService calculator.CalculateService) *ExchangeFactory {
+// NewExchangeFactory проверяет конфиги всех бирж и только затем запускает адаптеры,
+// чтобы ошибка в конфиге одной биржи не оставляла запущенными остальные
+func NewExchangeFactory(
+	ctx context.Context,
+	cfg *config.Config,
+	calculateService calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+) (*ExchangeFactory, error) {
 	factoryLogger := log.With().Str("logger", "exchange_factory").Logger()
 
-	exchanges := make(map[string]Exchange)
-	for exchange, exchangeCfg := range cfg.Exchanges.Configs {
-		var exch Exchange
-		switch exchange {
-		case "exmo":
-			exch = exmo.NewExmo(ctx, exchangeCfg, calculateService)
-		case "binance":
-			exch = binance.NewBinance(ctx, exchangeCfg, calculateService)
-		case "gate":
-			exch = gate.NewGate(ctx, exchangeCfg, calculateService)
+	names := make([]string, 0, len(cfg.Exchanges.Configs))
+	for name := range cfg.Exchanges.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	selected := make(map[string]*Adapter, len(names))
+	for _, name := range names {
+		adapter, ok := lookup(name)
+		if !ok {
+			return nil, errors.Wrapf(ErrUnknownExchange, "%s, registered: %v", name, Adapters())
 		}
 
-		exchanges[exchange] = exch
+		validate := adapter.Validate
+		if validate == nil {
+			validate = ValidateConfig
+		}
+
+		if err := validate(cfg.Exchanges.Configs[name]); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s config", name)
+		}
+
+		if futures := cfg.Exchanges.Configs[name].Futures; futures != nil && futures.Enabled && !adapter.Capabilities.Futures {
+			return nil, errors.Errorf("invalid %s config: futures are not supported", name)
+		}
+
+		selected[name] = adapter
+	}
+
+	exchanges := make(map[string]Exchange, len(names))
+	for _, name := range names {
+		exchangeCfg := cfg.Exchanges.Configs[name]
+
+		exchanges[name] = selected[name].New(ctx, exchangeCfg, &Deps{
+			Calculator:     calculateService,
+			Bus:            marketBus,
+			Health:         healthTracker,
+			Symbols:        symbols.NewRegistry(cfg.Exchanges.Aliases, exchangeCfg.Aliases),
+			FuturesSymbols: symbols.NewRegistry(cfg.Exchanges.Aliases, exchangeCfg.Aliases),
+		})
+
+		factoryLogger.Info().Str("exchange", name).Interface("capabilities", selected[name].Capabilities).Msg("exchange adapter started")
 	}
 
 	return &ExchangeFactory{
 		cfg:       cfg,
 		logger:    &factoryLogger,
 		exchanges: exchanges,
-	}
+		adapters:  selected,
+	}, nil
 }
 
 func (f *ExchangeFactory) List() []string {
@@ -59,3 +100,12 @@ func (f *ExchangeFactory) Get(exchange string) (Exchange, error) {
 	f.logger.Error().Stack().Err(ErrExchangeNotFound).Msgf("%s exchange not found", exchange)
 	return nil, ErrExchangeNotFound
 }
+
+// Capabilities возвращает возможности адаптера биржи
+func (f *ExchangeFactory) Capabilities(exchange string) (Capabilities, error) {
+	if adapter, ok := f.adapters[exchange]; ok {
+		return adapter.Capabilities, nil
+	}
+
+	return Capabilities{}, ErrExchangeNotFound
+}
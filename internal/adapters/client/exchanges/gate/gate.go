@@ -2,173 +2,520 @@ package gate
 
 import (
 	"calc/common/config"
-	"calc/foundation/id"
 	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
 	"calc/internal/adapters/client/exchanges/gate/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
 	"calc/internal/domain"
+	"calc/internal/services/bus"
 	"calc/internal/services/calculator"
+	"calc/internal/services/health"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	pairsUri  = "/spot/currency_pairs"
-	tickerUri = "/spot/tickers"
+	pairsUri      = "/spot/currency_pairs"
+	tickerUri     = "/spot/tickers"
+	currenciesUri = "/spot/currencies"
+	orderBookUri  = "/spot/order_book"
+	depthLevels   = 20
+	// snapshotLimit глубина снапшота стакана
+	snapshotLimit = 100
 )
 
 var (
-	promPrices = map[string]prometheus.Gauge{}
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "gate_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
 
 	errNotFound = errors.New("not found")
 )
 
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "gate",
+		Capabilities: exchanges.Capabilities{
+			Spot:  true,
+			Depth: true,
+			Fees:  true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewGate(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols)
+		},
+	})
+}
+
 type Gate struct {
 	ctx        context.Context
-	cancel     func()
 	url        string
-	wsURL      string
 	logger     *zerolog.Logger
 	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
 	calculator calculator.CalculateService
-	chans      map[string]map[string]chan<- *domain.Data
-	prices     map[string]float64
-	pairs      []string
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+
+	client.Subscriptions
 }
 
-func NewGate(ctx context.Context, cfg *config.ExchangeConfig, calculator calculator.CalculateService) *Gate {
+func NewGate(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+) *Gate {
 	httpClient := client.NewHTTPClient()
 
 	gateLogger := log.Logger.With().Str("logger", "gate").Logger()
 
-	gateCtx, cancel := context.WithCancel(ctx)
-
 	gate := &Gate{
-		ctx:        gateCtx,
-		cancel:     cancel,
+		ctx:        ctx,
 		url:        cfg.URL,
-		wsURL:      cfg.WsURL,
 		logger:     &gateLogger,
 		httpClient: httpClient,
-		chans:      make(map[string]map[string]chan<- *domain.Data),
 		calculator: calculator,
-		prices:     make(map[string]float64),
-		pairs:      cfg.Pairs,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
 	}
 
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
+
+	for _, pair := range cfg.Pairs {
+		gate.Register(pair)
+	}
+
+	gate.books = orderbook.NewManager(ctx, "gate", depthLevels, gate.orderBook, gate.save)
+	gate.wsClient = client.NewWSClient("gate", cfg.WsURL, client.WSHandler{
+		Subscribe: gate.subscribe,
+		Handle:    gate.handle,
+	}, cfg.Websocket)
+
 	go func() {
-		for {
-			if err := gate.run(); err != nil {
-				gate.cancel()
-				return
-			}
+		gate.loadSymbols()
+		gate.loadFees()
+		gate.loadNetworks()
 
-			break
-		}
+		gate.wsClient.Run(gate.ctx)
 	}()
 
 	return gate
 }
 
-func (e *Gate) run() error {
-	logger := e.logger.With().Str("method", "run").Logger()
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *Gate) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + "_" + quote
+		})
+	}
 
-	logger.Info().Msg(strings.Join(e.pairs, ","))
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(e.wsURL, nil)
-	if err != nil {
-		logger.Error().Stack().Err(err).Msg("failed to connect")
-		return err
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
 	}
-	defer c.Close()
+}
 
-	pairs := make([]string, len(e.pairs))
-	for i, pair := range e.pairs {
-		promPrices[pair] = prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace:   "calc",
-			Name:        "gate_price",
-			Help:        "pair price",
-			ConstLabels: prometheus.Labels{"pair": pair},
+func (e *Gate) loadFees() {
+	for _, pair := range e.SubscribedPairs() {
+		fee := e.fees.Get(pair)
+		e.calculator.SetFee(&domain.Fee{
+			Exchange: "gate",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
 		})
-		prometheus.MustRegister(promPrices[pair])
-		e.chans[pair] = make(map[string]chan<- *domain.Data)
-		pairs[i] = pair
 	}
 
-	init := struct {
+	fees, err := e.Fees(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load fees, using config fees")
+		return
+	}
+
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *Gate) loadNetworks() {
+	for _, network := range e.configNetworks() {
+		e.calculator.SetAssetNetwork(network)
+	}
+
+	networks, err := e.Networks(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load asset networks, using config networks")
+		return
+	}
+
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *Gate) configNetworks() []*domain.AssetNetwork {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "gate",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks
+}
+
+func (e *Gate) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	e.books.Reset()
+
+	// spot.order_book_update принимает одну пару на подписку
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		init := request("subscribe", symbol)
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+// request сообщение подписки или отписки от дельт стакана пары
+func request(event, symbol string) interface{} {
+	return struct {
 		Time    int64    `json:"time"`
 		Channel string   `json:"channel"`
 		Event   string   `json:"event"`
 		Payload []string `json:"payload"`
 	}{
 		Time:    time.Now().Unix(),
-		Channel: "spot.tickers",
-		Event:   "subscribe",
-		Payload: pairs,
+		Channel: "spot.order_book_update",
+		Event:   event,
+		Payload: []string{symbol, "100ms"},
 	}
+}
 
-	err = c.WriteJSON(init)
+// Subscribe подписывается на стакан пары. Без соединения подписка отправится при подключении.
+func (e *Gate) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
 	if err != nil {
-		logger.Error().Stack().Err(err).Msg("failed to write init message")
 		return err
 	}
-	logger.Debug().Msgf("init message %v successful sended", init)
-
-	for {
-		select {
-		case <-e.ctx.Done():
-			err := c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			if err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to close connection")
-				return err
-			}
-
-			logger.Info().Msgf("connection closed %v", e.ctx.Err())
-			return nil
-		default:
-			var ticker *response.WSTicker
-			if err := c.ReadJSON(&ticker); err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to read message")
-				return err
-			}
-
-			if ticker.Event != "update" {
-				continue
-			}
-
-			if e.prices[ticker.Result.CurrencyPair] == ticker.Result.Last {
-				continue
-			}
-			e.prices[ticker.Result.CurrencyPair] = ticker.Result.Last
-
-			data := &domain.Data{
-				Exchange: "gate",
-				Pair:     ticker.Result.CurrencyPair,
-				Price:    ticker.Result.Last,
-			}
-			if err := e.calculator.Save(data); err != nil {
-				logger.Error().Stack().Err(err).Msgf("failed to put data on calculator")
-				return err
-			}
-
-			for _, ch := range e.chans[ticker.Result.CurrencyPair] {
-				ch <- data
-			}
-
-			promPrices[ticker.Result.CurrencyPair].Set(data.Price)
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(request("subscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от стакана пары
+func (e *Gate) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("gate", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(request("unsubscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Gate) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	var book *response.WSOrderBook
+	if err := json.Unmarshal(message, &book); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	if book.Error != nil {
+		logger.Error().Stack().Msgf("failed on response message [%s]", book.Error.Message)
+		return errors.New(book.Error.Message)
+	}
+
+	if book.Event != "update" {
+		return nil
+	}
+
+	pair, ok := e.symbols.Pair(book.Result.Symbol)
+	if !ok {
+		logger.Warn().Str("symbol", book.Result.Symbol).Msg("unknown symbol")
+		return nil
+	}
+
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	bids, err := levels(book.Result.Bids)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse bids")
+		return nil
+	}
+
+	asks, err := levels(book.Result.Asks)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse asks")
+		return nil
+	}
+
+	update := &orderbook.Update{
+		FirstID: book.Result.FirstUpdateID,
+		LastID:  book.Result.LastUpdateID,
+		Bids:    bids,
+		Asks:    asks,
+	}
+
+	// дельта без изменений лучших уровней тоже подтверждает, что поток пары жив
+	if e.books.Update(pair, update) {
+		e.health.Touch("gate", pair, time.Now().UTC())
+	}
+
+	return nil
+}
+
+// save передает изменившийся стакан в шину рыночных данных
+func (e *Gate) save(data *domain.Data) {
+	// стакан, загруженный до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.health.Touch("gate", data.Pair, data.Time)
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+// orderBook загружает снапшот стакана, его id служит базовым номером для дельт
+func (e *Gate) orderBook(ctx context.Context, pair string) (*orderbook.Snapshot, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, orderBookUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Add("currency_pair", symbol)
+	q.Add("limit", strconv.Itoa(snapshotLimit))
+	q.Add("with_id", "true")
+
+	u.RawQuery = q.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request order book")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var book response.OrderBook
+	if err := json.NewDecoder(resp.Body).Decode(&book); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode order book response")
+		return nil, err
+	}
+
+	bids, err := levels(book.Bids)
+	if err != nil {
+		return nil, err
+	}
+
+	asks, err := levels(book.Asks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderbook.Snapshot{
+		ID:   book.ID,
+		Bids: bids,
+		Asks: asks,
+	}, nil
+}
+
+func levels(raw []response.PriceLevel) ([]domain.PriceLevel, error) {
+	result := make([]domain.PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := level.Price()
+		if err != nil {
+			return nil, err
+		}
+
+		quantity, err := level.Quantity()
+		if err != nil {
+			return nil, err
 		}
+
+		result = append(result, domain.PriceLevel{
+			Price:    price,
+			Quantity: quantity,
+		})
 	}
+
+	return result, nil
 }
 
 func (e *Gate) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает пары, доступные для торговли
+func (e *Gate) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	tradable, err := e.currencyPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(tradable))
+	for _, pair := range tradable {
+		markets = append(markets, symbols.Symbol{
+			Native: pair.Id,
+			Base:   pair.Base,
+			Quote:  pair.Quote,
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+func (e *Gate) Markets(ctx context.Context) ([]*domain.Market, error) {
+	tradable, err := e.currencyPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickerUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickers []*response.Ticker
+	if err := json.NewDecoder(resp.Body).Decode(&tickers); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		volumes[ticker.CurrencyPair] = ticker.QuoteVolume
+	}
+
+	markets := make([]*domain.Market, 0, len(tradable))
+	for _, pair := range tradable {
+		// пустая минимальная сумма означает отсутствие ограничения
+		minNotional, _ := strconv.ParseFloat(pair.MinQuoteAmount, 64)
+
+		markets = append(markets, &domain.Market{
+			Exchange:    "gate",
+			Pair:        e.symbols.Canonical(pair.Base, pair.Quote),
+			Base:        e.symbols.Asset(pair.Base),
+			Quote:       e.symbols.Asset(pair.Quote),
+			Volume:      volumes[pair.Id],
+			MinNotional: minNotional,
+		})
+	}
+
+	return markets, nil
+}
+
+// currencyPairs возвращает пары, доступные для торговли, с их ограничениями
+func (e *Gate) currencyPairs(ctx context.Context) ([]*response.Pair, error) {
 	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, pairsUri))
 	if err != nil {
 		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
@@ -189,17 +536,22 @@ func (e *Gate) Pairs(ctx context.Context) ([]string, error) {
 		return nil, err
 	}
 
-	var pairs []string
+	var tradable []*response.Pair
 	for _, pair := range pairsResponse {
 		if pair.TradeStatus == "tradable" {
-			pairs = append(pairs, pair.Id)
+			tradable = append(tradable, pair)
 		}
 	}
 
-	return pairs, nil
+	return tradable, nil
 }
 
 func (e *Gate) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
 	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickerUri))
 	if err != nil {
 		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
@@ -207,7 +559,7 @@ func (e *Gate) Price(ctx context.Context, pair string) (float64, error) {
 	}
 
 	q := u.Query()
-	q.Add("currency_pair", pair)
+	q.Add("currency_pair", symbol)
 
 	u.RawQuery = q.Encode()
 
@@ -232,18 +584,90 @@ func (e *Gate) Price(ctx context.Context, pair string) (float64, error) {
 	return tickers[0].Last, nil
 }
 
-func (e *Gate) WSPrice(ctx context.Context, pair string, ch chan<- *domain.Data) {
-	chanID := id.ULID().String()
-	e.chans[pair][chanID] = ch
+func (e *Gate) Fees(ctx context.Context) ([]*domain.Fee, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, pairsUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request currency pairs")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var pairsResponse response.PairsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&pairsResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode currency pairs response")
+		return nil, err
+	}
+
+	fees := make([]*domain.Fee, 0, len(pairsResponse))
+	for _, pair := range pairsResponse {
+		fee, err := strconv.ParseFloat(pair.Fee, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		fees = append(fees, &domain.Fee{
+			Exchange: "gate",
+			Pair:     e.symbols.Canonical(pair.Base, pair.Quote),
+			Maker:    fee,
+			Taker:    fee,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает признаки ввода и вывода из API, комиссии и минимальные суммы вывода берутся из конфига
+func (e *Gate) Networks(ctx context.Context) ([]*domain.AssetNetwork, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, currenciesUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
 
-	defer delete(e.chans[pair], chanID)
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request currencies")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var currenciesResponse response.CurrenciesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&currenciesResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode currencies response")
+		return nil, err
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-e.ctx.Done():
-			return
+	networks := make([]*domain.AssetNetwork, 0, len(currenciesResponse))
+	for _, currency := range currenciesResponse {
+		network := &domain.AssetNetwork{
+			Exchange:        "gate",
+			Asset:           e.symbols.Asset(currency.Currency),
+			Network:         currency.Chain,
+			DepositEnabled:  !currency.Delisted && !currency.DepositDisabled,
+			WithdrawEnabled: !currency.Delisted && !currency.WithdrawDisabled,
 		}
+
+		if assetNetwork := e.assets.Get(network.Asset, currency.Chain); assetNetwork != nil {
+			network.WithdrawFee = assetNetwork.WithdrawFee
+			network.MinWithdraw = assetNetwork.MinWithdraw
+		}
+
+		networks = append(networks, network)
 	}
+
+	return networks, nil
+}
+
+func (e *Gate) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("gate", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
 }
@@ -0,0 +1,13 @@
+package response
+
+type CurrenciesResponse []*Currency
+
+type Currency struct {
+	Currency         string `json:"currency"`
+	Delisted         bool   `json:"delisted"`
+	WithdrawDisabled bool   `json:"withdraw_disabled"`
+	WithdrawDelayed  bool   `json:"withdraw_delayed"`
+	DepositDisabled  bool   `json:"deposit_disabled"`
+	TradeDisabled    bool   `json:"trade_disabled"`
+	Chain            string `json:"chain"`
+}
@@ -0,0 +1,8 @@
+package response
+
+// OrderBook снапшот стакана /spot/order_book с with_id=true, ID - номер последнего вошедшего обновления
+type OrderBook struct {
+	ID   uint64       `json:"id"`
+	Bids []PriceLevel `json:"bids"`
+	Asks []PriceLevel `json:"asks"`
+}
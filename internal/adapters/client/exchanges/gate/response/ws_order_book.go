@@ -0,0 +1,42 @@
+package response
+
+import "strconv"
+
+// WSOrderBook сообщение канала spot.order_book_update с дельтами стакана
+type WSOrderBook struct {
+	Time    int    `json:"time"`
+	Channel string `json:"channel"`
+	Event   string `json:"event"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Result struct {
+		Time   int64  `json:"t"`
+		Symbol string `json:"s"`
+		// FirstUpdateID и LastUpdateID номера первого и последнего обновления в дельте
+		FirstUpdateID uint64       `json:"U"`
+		LastUpdateID  uint64       `json:"u"`
+		Bids          []PriceLevel `json:"b"`
+		Asks          []PriceLevel `json:"a"`
+	} `json:"result"`
+}
+
+// PriceLevel уровень стакана в формате [price, amount]
+type PriceLevel []string
+
+func (l PriceLevel) Price() (float64, error) {
+	return l.parse(0)
+}
+
+func (l PriceLevel) Quantity() (float64, error) {
+	return l.parse(1)
+}
+
+func (l PriceLevel) parse(i int) (float64, error) {
+	if len(l) <= i {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(l[i], 64)
+}
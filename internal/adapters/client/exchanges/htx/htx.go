@@ -0,0 +1,561 @@
+package htx
+
+import (
+	"bytes"
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/exchanges/htx/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	symbolsUri = "/v1/common/symbols"
+	tickersUri = "/market/tickers"
+	mergedUri  = "/market/detail/merged"
+)
+
+var (
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "htx_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
+
+	errNotFound = errors.New("not found")
+)
+
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "htx",
+		Capabilities: exchanges.Capabilities{
+			Spot:  true,
+			Depth: true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewHTX(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols)
+		},
+	})
+}
+
+// HTX получает лучшие цены из канала market.$symbol.bbo, символ пары имеет вид basequote в нижнем регистре.
+// Сообщения websocket сжаты gzip.
+type HTX struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+	// requestID номер последнего сообщения подписки
+	requestID int64
+
+	client.Subscriptions
+}
+
+func NewHTX(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+) *HTX {
+	httpClient := client.NewHTTPClient()
+
+	htxLogger := log.Logger.With().Str("logger", "htx").Logger()
+
+	htx := &HTX{
+		ctx:        ctx,
+		url:        cfg.URL,
+		logger:     &htxLogger,
+		httpClient: httpClient,
+		calculator: calculator,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
+
+	for _, pair := range cfg.Pairs {
+		htx.Register(pair)
+	}
+
+	// канал присылает лучшие цены целиком, снапшоты по REST не нужны
+	htx.books = orderbook.NewManager(ctx, "htx", 1, nil, htx.save)
+	htx.wsClient = client.NewWSClient("htx", cfg.WsURL, client.WSHandler{
+		Subscribe: htx.subscribe,
+		Handle:    htx.handle,
+	}, cfg.Websocket)
+
+	go func() {
+		htx.loadSymbols()
+		htx.loadFees()
+		htx.loadNetworks()
+
+		htx.wsClient.Run(htx.ctx)
+	}()
+
+	return htx
+}
+
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *HTX) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return strings.ToLower(base + quote)
+		})
+	}
+
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+func (e *HTX) loadFees() {
+	fees, _ := e.Fees(e.ctx)
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *HTX) loadNetworks() {
+	networks, _ := e.Networks(e.ctx)
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *HTX) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	e.Reset()
+
+	// биржа принимает один канал в сообщении подписки
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		init := e.request("subscribe", symbol)
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+// request сообщение подписки или отписки от лучших цен пары, в сообщении передается один канал
+func (e *HTX) request(op string, symbols ...string) interface{} {
+	ch := "market." + strings.Join(symbols, ",") + ".bbo"
+	reqID := strconv.FormatInt(atomic.AddInt64(&e.requestID, 1), 10)
+
+	if op == "subscribe" {
+		pairs := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			if pair, ok := e.symbols.Pair(symbol); ok {
+				pairs = append(pairs, pair)
+			}
+		}
+
+		e.Track(reqID, pairs...)
+	}
+
+	if op == "unsubscribe" {
+		return struct {
+			Unsub string `json:"unsub"`
+			ID    string `json:"id"`
+		}{
+			Unsub: ch,
+			ID:    reqID,
+		}
+	}
+
+	return struct {
+		Sub string `json:"sub"`
+		ID  string `json:"id"`
+	}{
+		Sub: ch,
+		ID:  reqID,
+	}
+}
+
+// Subscribe подписывается на лучшие цены пары. Без соединения подписка отправится при подключении.
+func (e *HTX) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	fee := e.fees.Get(pair)
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "htx",
+		Pair:     pair,
+		Maker:    fee.Maker,
+		Taker:    fee.Taker,
+	})
+
+	if err := e.wsClient.WriteJSON(e.request("subscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от лучших цен пары
+func (e *HTX) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("htx", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("unsubscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *HTX) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	reader, err := gzip.NewReader(bytes.NewReader(message))
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	defer reader.Close()
+
+	message, err = io.ReadAll(reader)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to decompress message")
+		return err
+	}
+
+	var msg *response.WSMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	// без ответа на ping биржа закрывает соединение
+	if msg.Ping != 0 {
+		return e.wsClient.WriteJSON(&struct {
+			Pong int64 `json:"pong"`
+		}{
+			Pong: msg.Ping,
+		})
+	}
+
+	switch msg.Status {
+	case "error":
+		// ошибка подписки одной пары не должна рвать поток остальных
+		rejected := e.Reject(msg.ID, msg.ErrMsg, e.symbols.Native)
+		logger.Error().Stack().Strs("pairs", rejected).Msgf("failed on response message [%s] %s", msg.ErrCode, msg.ErrMsg)
+		return nil
+	case "ok":
+		e.Confirm(msg.ID)
+	}
+
+	if msg.Tick == nil || !strings.HasPrefix(msg.Ch, "market.") {
+		return nil
+	}
+
+	symbol := strings.TrimSuffix(strings.TrimPrefix(msg.Ch, "market."), ".bbo")
+
+	pair, ok := e.symbols.Pair(symbol)
+	if !ok {
+		logger.Warn().Str("symbol", symbol).Msg("unknown symbol")
+		return nil
+	}
+
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	// неизменившиеся цены тоже подтверждают, что поток пары жив
+	e.health.Touch("htx", pair, time.Now().UTC())
+
+	e.books.Snapshot(pair, &orderbook.Snapshot{
+		Bids: []domain.PriceLevel{{Price: msg.Tick.Bid, Quantity: msg.Tick.BidSize}},
+		Asks: []domain.PriceLevel{{Price: msg.Tick.Ask, Quantity: msg.Tick.AskSize}},
+	})
+
+	return nil
+}
+
+// save передает изменившиеся цены в шину рыночных данных
+func (e *HTX) save(data *domain.Data) {
+	// цены, пришедшие до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+func (e *HTX) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает символы, доступные для торговли
+func (e *HTX) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	symbolList, err := e.symbolList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(symbolList))
+	for _, symbol := range symbolList {
+		markets = append(markets, symbols.Symbol{
+			Native: symbol.Symbol,
+			Base:   symbol.BaseCurrency,
+			Quote:  symbol.QuoteCurrency,
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+func (e *HTX) Markets(ctx context.Context) ([]*domain.Market, error) {
+	symbolList, err := e.symbolList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, err := e.tickers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		volumes[ticker.Symbol] = ticker.Vol
+	}
+
+	markets := make([]*domain.Market, 0, len(symbolList))
+	for _, symbol := range symbolList {
+		markets = append(markets, &domain.Market{
+			Exchange:    "htx",
+			Pair:        e.symbols.Canonical(symbol.BaseCurrency, symbol.QuoteCurrency),
+			Base:        e.symbols.Asset(symbol.BaseCurrency),
+			Quote:       e.symbols.Asset(symbol.QuoteCurrency),
+			Volume:      volumes[symbol.Symbol],
+			MinNotional: symbol.MinOrderValue,
+		})
+	}
+
+	return markets, nil
+}
+
+// symbolList возвращает символы в статусе online
+func (e *HTX) symbolList(ctx context.Context) ([]*response.Symbol, error) {
+	resp, err := e.httpClient.Get(ctx, fmt.Sprintf("%s%s", e.url, symbolsUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request symbols")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var symbolsResponse response.SymbolsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&symbolsResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode symbols response")
+		return nil, err
+	}
+
+	if symbolsResponse.Status != "ok" {
+		e.logger.Error().Stack().Msgf("failed on symbols response [%s] %s", symbolsResponse.ErrCode, symbolsResponse.ErrMsg)
+		return nil, errors.New(symbolsResponse.ErrMsg)
+	}
+
+	var online []*response.Symbol
+	for _, symbol := range symbolsResponse.Data {
+		if symbol.State == "online" {
+			online = append(online, symbol)
+		}
+	}
+
+	return online, nil
+}
+
+func (e *HTX) tickers(ctx context.Context) ([]*response.Ticker, error) {
+	resp, err := e.httpClient.Get(ctx, fmt.Sprintf("%s%s", e.url, tickersUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickersResponse response.TickersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickersResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	if tickersResponse.Status != "ok" {
+		e.logger.Error().Stack().Msgf("failed on tickers response [%s] %s", tickersResponse.ErrCode, tickersResponse.ErrMsg)
+		return nil, errors.New(tickersResponse.ErrMsg)
+	}
+
+	return tickersResponse.Data, nil
+}
+
+func (e *HTX) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, mergedUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return 0, err
+	}
+
+	q := u.Query()
+	q.Add("symbol", symbol)
+
+	u.RawQuery = q.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request ticker")
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	var mergedResponse response.MergedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mergedResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode ticker response")
+		return 0, err
+	}
+
+	if mergedResponse.Tick == nil {
+		return 0, errNotFound
+	}
+
+	return mergedResponse.Tick.Close, nil
+}
+
+// Fees возвращает комиссии из конфига: эндпоинт с комиссиями у HTX требует подписи
+func (e *HTX) Fees(_ context.Context) ([]*domain.Fee, error) {
+	pairs := e.SubscribedPairs()
+
+	fees := make([]*domain.Fee, 0, len(pairs))
+	for _, pair := range pairs {
+		fee := e.fees.Get(pair)
+		fees = append(fees, &domain.Fee{
+			Exchange: "htx",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает сети из конфига: эндпоинт с сетями вывода у HTX требует подписи
+func (e *HTX) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "htx",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func (e *HTX) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("htx", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
+}
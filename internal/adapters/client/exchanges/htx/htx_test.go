@@ -0,0 +1,64 @@
+package htx
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/exchanges/exchangestest"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/services/bus"
+	"context"
+	"strings"
+	"testing"
+)
+
+// streamQuotes котировки записи потока: повтор цен не публикуется
+var streamQuotes = []exchangestest.Quote{
+	{Exchange: "htx", Pair: "BTC_USDT", Bid: 36510.01, BidQuantity: 1.023581, Ask: 36510.02, AskQuantity: 0.369184},
+	{Exchange: "htx", Pair: "ETH_USDT", Bid: 2057.84, BidQuantity: 12.4831, Ask: 2057.85, AskQuantity: 3.1522},
+	{Exchange: "htx", Pair: "BTC_USDT", Bid: 36510.01, BidQuantity: 0.803581, Ask: 36510.02, AskQuantity: 0.369184},
+	{Exchange: "htx", Pair: "BTC_USDT", Bid: 36509.8, BidQuantity: 0.41, Ask: 36509.81, AskQuantity: 0.05},
+}
+
+// reconnectQuotes котировки после переподключения
+var reconnectQuotes = []exchangestest.Quote{
+	{Exchange: "htx", Pair: "BTC_USDT", Bid: 36514.2, BidQuantity: 0.61734, Ask: 36514.21, AskQuantity: 0.2213},
+	{Exchange: "htx", Pair: "ETH_USDT", Bid: 2058.1, BidQuantity: 7.3341, Ask: 2058.11, AskQuantity: 1.906},
+}
+
+func TestStream(t *testing.T) {
+	newAdapter := func(ctx context.Context, s *exchangestest.Server, cfg *config.ExchangeConfig, calc *exchangestest.Calculator, marketBus *bus.Bus) exchangestest.Adapter {
+		return NewHTX(ctx, cfg, calc, marketBus, nil, symbols.NewRegistry())
+	}
+
+	exchangestest.Replay(t, newAdapter, []exchangestest.Case{
+		{
+			Name:     "stream",
+			Pairs:    []string{"BTC_USDT", "ETH_USDT", "SRM_USDT"},
+			Sessions: []exchangestest.Session{{After: 3, Frames: "testdata/bbo.jsonl"}},
+			Quotes:   streamQuotes,
+			Rejected: []string{"SRM_USDT"},
+			Check: func(t *testing.T, s *exchangestest.Server) {
+				exchangestest.Eventually(t, func() bool {
+					for _, message := range s.Received() {
+						if strings.Contains(message, `{"pong":1700000000050}`) {
+							return true
+						}
+					}
+					return false
+				}, "no pong for server ping")
+			},
+		},
+		{
+			// обрыв после первой сессии: адаптер переподключается и подписывается заново без отклоненной пары
+			Name:  "reconnect",
+			Pairs: []string{"BTC_USDT", "ETH_USDT", "SRM_USDT"},
+			Sessions: []exchangestest.Session{
+				{After: 3, Frames: "testdata/bbo.jsonl"},
+				{After: 2, Frames: "testdata/bbo-reconnect.jsonl"},
+			},
+			Quotes:       append(append([]exchangestest.Quote(nil), streamQuotes...), reconnectQuotes...),
+			Rejected:     []string{"SRM_USDT"},
+			Resubscribed: []string{"market.btcusdt.bbo", "market.ethusdt.bbo"},
+			Dropped:      []string{"market.srmusdt.bbo"},
+		},
+	}, exchangestest.WithGzip())
+}
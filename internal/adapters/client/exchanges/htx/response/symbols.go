@@ -0,0 +1,18 @@
+package response
+
+// SymbolsResponse ответ /v1/common/symbols
+type SymbolsResponse struct {
+	Status  string    `json:"status"`
+	ErrCode string    `json:"err-code"`
+	ErrMsg  string    `json:"err-msg"`
+	Data    []*Symbol `json:"data"`
+}
+
+type Symbol struct {
+	Symbol        string `json:"symbol"`
+	BaseCurrency  string `json:"base-currency"`
+	QuoteCurrency string `json:"quote-currency"`
+	State         string `json:"state"`
+	// MinOrderValue минимальная сумма ордера в котируемой валюте
+	MinOrderValue float64 `json:"min-order-value"`
+}
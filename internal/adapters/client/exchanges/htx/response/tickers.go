@@ -0,0 +1,31 @@
+package response
+
+// TickersResponse ответ /market/tickers
+type TickersResponse struct {
+	Status  string    `json:"status"`
+	ErrCode string    `json:"err-code"`
+	ErrMsg  string    `json:"err-msg"`
+	Data    []*Ticker `json:"data"`
+}
+
+type Ticker struct {
+	Symbol string  `json:"symbol"`
+	Close  float64 `json:"close"`
+	Amount float64 `json:"amount"`
+	// Vol объем за 24 часа в котируемой валюте
+	Vol float64 `json:"vol"`
+}
+
+// MergedResponse ответ /market/detail/merged
+type MergedResponse struct {
+	Status  string  `json:"status"`
+	ErrCode string  `json:"err-code"`
+	ErrMsg  string  `json:"err-msg"`
+	Tick    *Merged `json:"tick"`
+}
+
+type Merged struct {
+	Close float64   `json:"close"`
+	Bid   []float64 `json:"bid"`
+	Ask   []float64 `json:"ask"`
+}
@@ -0,0 +1,22 @@
+package response
+
+// WSMessage сообщение websocket: ping, ответ на подписку или данные канала bbo
+type WSMessage struct {
+	Ping    int64  `json:"ping"`
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	ErrCode string `json:"err-code"`
+	ErrMsg  string `json:"err-msg"`
+	Ch      string `json:"ch"`
+	Ts      int64  `json:"ts"`
+	Tick    *BBO   `json:"tick"`
+}
+
+type BBO struct {
+	Symbol  string  `json:"symbol"`
+	SeqID   int64   `json:"seqId"`
+	Bid     float64 `json:"bid"`
+	BidSize float64 `json:"bidSize"`
+	Ask     float64 `json:"ask"`
+	AskSize float64 `json:"askSize"`
+}
@@ -0,0 +1,568 @@
+package kraken
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/exchanges/kraken/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	assetPairsUri = "/0/public/AssetPairs"
+	tickerUri     = "/0/public/Ticker"
+	channel       = "spread"
+)
+
+var (
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "kraken_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
+
+	errNotFound = errors.New("not found")
+)
+
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "kraken",
+		Capabilities: exchanges.Capabilities{
+			Spot:  true,
+			Depth: true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewKraken(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols)
+		},
+	})
+}
+
+// Kraken получает лучшие цены из канала spread, символ пары имеет вид websocket имени BASE/QUOTE
+// с кодами валют биржи, например XBT/USD
+type Kraken struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+
+	client.Subscriptions
+}
+
+func NewKraken(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+) *Kraken {
+	httpClient := client.NewHTTPClient()
+
+	krakenLogger := log.Logger.With().Str("logger", "kraken").Logger()
+
+	kraken := &Kraken{
+		ctx:        ctx,
+		url:        cfg.URL,
+		logger:     &krakenLogger,
+		httpClient: httpClient,
+		calculator: calculator,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
+
+	for _, pair := range cfg.Pairs {
+		kraken.Register(pair)
+	}
+
+	// канал присылает лучшие цены целиком, снапшоты по REST не нужны
+	kraken.books = orderbook.NewManager(ctx, "kraken", 1, nil, kraken.save)
+	kraken.wsClient = client.NewWSClient("kraken", cfg.WsURL, client.WSHandler{
+		Subscribe: kraken.subscribe,
+		Handle:    kraken.handle,
+	}, cfg.Websocket)
+
+	go func() {
+		kraken.loadSymbols()
+		kraken.loadFees()
+		kraken.loadNetworks()
+
+		kraken.wsClient.Run(kraken.ctx)
+	}()
+
+	return kraken
+}
+
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *Kraken) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + "/" + quote
+		})
+	}
+
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+func (e *Kraken) loadFees() {
+	fees, _ := e.Fees(e.ctx)
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *Kraken) loadNetworks() {
+	networks, _ := e.Networks(e.ctx)
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *Kraken) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	var symbolList []string
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		symbolList = append(symbolList, symbol)
+	}
+
+	if len(symbolList) == 0 {
+		return nil
+	}
+
+	init := e.request("subscribe", symbolList...)
+
+	if err := conn.WriteJSON(init); err != nil {
+		logger.Error().Stack().Err(err).Msg("failed to write init message")
+		return err
+	}
+	logger.Debug().Msgf("init message %v successful sended", init)
+
+	return nil
+}
+
+// request сообщение подписки или отписки от лучших цен пар
+func (e *Kraken) request(event string, symbols ...string) interface{} {
+	return struct {
+		Event        string   `json:"event"`
+		Pair         []string `json:"pair"`
+		Subscription struct {
+			Name string `json:"name"`
+		} `json:"subscription"`
+	}{
+		Event: event,
+		Pair:  symbols,
+		Subscription: struct {
+			Name string `json:"name"`
+		}{
+			Name: channel,
+		},
+	}
+}
+
+// Subscribe подписывается на лучшие цены пары. Без соединения подписка отправится при подключении.
+func (e *Kraken) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	fee := e.fees.Get(pair)
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "kraken",
+		Pair:     pair,
+		Maker:    fee.Maker,
+		Taker:    fee.Taker,
+	})
+
+	if err := e.wsClient.WriteJSON(e.request("subscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от лучших цен пары
+func (e *Kraken) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("kraken", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("unsubscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Kraken) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	// данные каналов приходят массивом, служебные сообщения объектом
+	if len(message) > 0 && message[0] != '[' {
+		var event *response.WSEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			logger.Error().Stack().Err(err).Msgf("failed to read message")
+			return err
+		}
+
+		if event.Event == "subscriptionStatus" && event.Status == "error" {
+			// ошибка подписки одной пары не должна рвать поток остальных
+			logger.Error().Stack().Str("symbol", event.Pair).Msgf("failed on response message %s", event.ErrorMessage)
+
+			if pair, ok := e.symbols.Pair(event.Pair); ok {
+				e.Unregister(pair)
+			}
+		}
+
+		return nil
+	}
+
+	var spread response.WSSpread
+	if err := json.Unmarshal(message, &spread); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	if spread.Channel != channel {
+		return nil
+	}
+
+	pair, ok := e.symbols.Pair(spread.Pair)
+	if !ok {
+		logger.Warn().Str("symbol", spread.Pair).Msg("unknown symbol")
+		return nil
+	}
+
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	bid, ask, err := levels(spread.Spread)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse spread")
+		return nil
+	}
+
+	// неизменившиеся цены тоже подтверждают, что поток пары жив
+	e.health.Touch("kraken", pair, time.Now().UTC())
+
+	e.books.Snapshot(pair, &orderbook.Snapshot{
+		Bids: []domain.PriceLevel{bid},
+		Asks: []domain.PriceLevel{ask},
+	})
+
+	return nil
+}
+
+// save передает изменившиеся цены в шину рыночных данных
+func (e *Kraken) save(data *domain.Data) {
+	// цены, пришедшие до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+func levels(spread response.Spread) (domain.PriceLevel, domain.PriceLevel, error) {
+	var bid, ask domain.PriceLevel
+	var err error
+
+	if bid.Price, err = spread.Bid(); err != nil {
+		return bid, ask, err
+	}
+
+	if bid.Quantity, err = spread.BidVolume(); err != nil {
+		return bid, ask, err
+	}
+
+	if ask.Price, err = spread.Ask(); err != nil {
+		return bid, ask, err
+	}
+
+	if ask.Quantity, err = spread.AskVolume(); err != nil {
+		return bid, ask, err
+	}
+
+	return bid, ask, nil
+}
+
+func (e *Kraken) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает пары, доступные для торговли. Коды валют берутся из websocket имени:
+// в полях base и quote у части валют есть префиксы X и Z.
+func (e *Kraken) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	assetPairs, err := e.assetPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(assetPairs))
+	for _, assetPair := range assetPairs {
+		base, quote := split(assetPair.Wsname)
+		markets = append(markets, symbols.Symbol{
+			Native: assetPair.Wsname,
+			Base:   base,
+			Quote:  quote,
+		})
+	}
+
+	return markets, nil
+}
+
+func split(wsname string) (string, string) {
+	parts := strings.SplitN(wsname, "/", 2)
+	if len(parts) != 2 {
+		return wsname, ""
+	}
+
+	return parts[0], parts[1]
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера.
+// Объем в котируемой валюте считается по средневзвешенной цене.
+func (e *Kraken) Markets(ctx context.Context) ([]*domain.Market, error) {
+	assetPairs, err := e.assetPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, err := e.tickers(ctx, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]*domain.Market, 0, len(assetPairs))
+	for key, assetPair := range assetPairs {
+		base, quote := split(assetPair.Wsname)
+		minNotional, _ := strconv.ParseFloat(assetPair.Costmin, 64)
+
+		var volume float64
+		if ticker, ok := tickers[key]; ok && len(ticker.Volume) > 1 && len(ticker.VWAP) > 1 {
+			baseVolume, _ := strconv.ParseFloat(ticker.Volume[1], 64)
+			vwap, _ := strconv.ParseFloat(ticker.VWAP[1], 64)
+			volume = baseVolume * vwap
+		}
+
+		markets = append(markets, &domain.Market{
+			Exchange:    "kraken",
+			Pair:        e.symbols.Canonical(base, quote),
+			Base:        e.symbols.Asset(base),
+			Quote:       e.symbols.Asset(quote),
+			Volume:      volume,
+			MinNotional: minNotional,
+		})
+	}
+
+	return markets, nil
+}
+
+// assetPairs возвращает пары в статусе online с websocket именем по ключу пары биржи
+func (e *Kraken) assetPairs(ctx context.Context) (map[string]*response.AssetPair, error) {
+	resp, err := e.httpClient.Get(ctx, fmt.Sprintf("%s%s", e.url, assetPairsUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request asset pairs")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var assetPairsResponse response.AssetPairsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&assetPairsResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode asset pairs response")
+		return nil, err
+	}
+
+	if len(assetPairsResponse.Error) > 0 {
+		e.logger.Error().Stack().Msgf("failed on asset pairs response %s", strings.Join(assetPairsResponse.Error, ", "))
+		return nil, errors.New(assetPairsResponse.Error[0])
+	}
+
+	online := make(map[string]*response.AssetPair, len(assetPairsResponse.Result))
+	for key, assetPair := range assetPairsResponse.Result {
+		if assetPair.Status == "online" && assetPair.Wsname != "" {
+			online[key] = assetPair
+		}
+	}
+
+	return online, nil
+}
+
+// tickers возвращает тикеры по ключу пары биржи, без параметра pair возвращаются все пары
+func (e *Kraken) tickers(ctx context.Context, query url.Values) (map[string]*response.Ticker, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, tickerUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	u.RawQuery = query.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickerResponse response.TickerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickerResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	if len(tickerResponse.Error) > 0 {
+		e.logger.Error().Stack().Msgf("failed on tickers response %s", strings.Join(tickerResponse.Error, ", "))
+		return nil, errors.New(tickerResponse.Error[0])
+	}
+
+	return tickerResponse.Result, nil
+}
+
+func (e *Kraken) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	// REST принимает имя пары без разделителя
+	tickers, err := e.tickers(ctx, url.Values{"pair": {strings.Replace(symbol, "/", "", 1)}})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ticker := range tickers {
+		if len(ticker.Close) == 0 {
+			break
+		}
+
+		return strconv.ParseFloat(ticker.Close[0], 64)
+	}
+
+	return 0, errNotFound
+}
+
+// Fees возвращает комиссии из конфига: эндпоинт с комиссиями у Kraken требует подписи
+func (e *Kraken) Fees(_ context.Context) ([]*domain.Fee, error) {
+	pairs := e.SubscribedPairs()
+
+	fees := make([]*domain.Fee, 0, len(pairs))
+	for _, pair := range pairs {
+		fee := e.fees.Get(pair)
+		fees = append(fees, &domain.Fee{
+			Exchange: "kraken",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает сети из конфига: эндпоинт с сетями вывода у Kraken требует подписи
+func (e *Kraken) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "kraken",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func (e *Kraken) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("kraken", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
+}
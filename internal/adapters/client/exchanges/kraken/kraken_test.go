@@ -0,0 +1,56 @@
+package kraken
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/exchanges/exchangestest"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/services/bus"
+	"context"
+	"testing"
+)
+
+// streamQuotes котировки записи потока: повтор цен не публикуется
+var streamQuotes = []exchangestest.Quote{
+	{Exchange: "kraken", Pair: "BTC_USDT", Bid: 36510, BidQuantity: 1.02358107, Ask: 36510.1, AskQuantity: 0.36918453},
+	{Exchange: "kraken", Pair: "ETH_USDT", Bid: 2057.84, BidQuantity: 12.483106, Ask: 2057.85, AskQuantity: 3.152217},
+	{Exchange: "kraken", Pair: "BTC_USDT", Bid: 36510, BidQuantity: 0.80358107, Ask: 36510.1, AskQuantity: 0.36918453},
+	{Exchange: "kraken", Pair: "BTC_USDT", Bid: 36509.8, BidQuantity: 0.41, Ask: 36509.9, AskQuantity: 0.05},
+}
+
+// reconnectQuotes котировки после переподключения
+var reconnectQuotes = []exchangestest.Quote{
+	{Exchange: "kraken", Pair: "BTC_USDT", Bid: 36514.2, BidQuantity: 0.61734, Ask: 36514.3, AskQuantity: 0.2213},
+	{Exchange: "kraken", Pair: "ETH_USDT", Bid: 2058.1, BidQuantity: 7.3341, Ask: 2058.11, AskQuantity: 1.906},
+}
+
+func TestStream(t *testing.T) {
+	newAdapter := func(ctx context.Context, s *exchangestest.Server, cfg *config.ExchangeConfig, calc *exchangestest.Calculator, marketBus *bus.Bus) exchangestest.Adapter {
+		// алиасы как в конфиге: в websocket имени биржи биткоин называется XBT
+		return NewKraken(ctx, cfg, calc, marketBus, nil, symbols.NewRegistry(map[string]string{"XBT": "BTC"}))
+	}
+
+	exchangestest.Replay(t, newAdapter, []exchangestest.Case{
+		{
+			Name:     "stream",
+			Pairs:    []string{"BTC_USDT", "ETH_USDT", "SRM_USD"},
+			REST:     map[string]string{assetPairsUri: "testdata/asset_pairs.json"},
+			Sessions: []exchangestest.Session{{After: 1, Frames: "testdata/spread.jsonl"}},
+			Quotes:   streamQuotes,
+			Rejected: []string{"SRM_USD"},
+		},
+		{
+			// обрыв после первой сессии: адаптер переподключается и подписывается заново без отклоненной пары
+			Name:  "reconnect",
+			Pairs: []string{"BTC_USDT", "ETH_USDT", "SRM_USD"},
+			REST:  map[string]string{assetPairsUri: "testdata/asset_pairs.json"},
+			Sessions: []exchangestest.Session{
+				{After: 1, Frames: "testdata/spread.jsonl"},
+				{After: 1, Frames: "testdata/spread-reconnect.jsonl"},
+			},
+			Quotes:       append(append([]exchangestest.Quote(nil), streamQuotes...), reconnectQuotes...),
+			Rejected:     []string{"SRM_USD"},
+			Resubscribed: []string{"XBT/USDT", "ETH/USDT"},
+			Dropped:      []string{"SRM/USD"},
+		},
+	})
+}
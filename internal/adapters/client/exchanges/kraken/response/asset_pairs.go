@@ -0,0 +1,19 @@
+package response
+
+// AssetPairsResponse ответ /0/public/AssetPairs, пары по ключу биржи, например XXBTZUSD
+type AssetPairsResponse struct {
+	Error  []string              `json:"error"`
+	Result map[string]*AssetPair `json:"result"`
+}
+
+type AssetPair struct {
+	Altname string `json:"altname"`
+	// Wsname имя пары в websocket вида XBT/USD
+	Wsname   string `json:"wsname"`
+	Base     string `json:"base"`
+	Quote    string `json:"quote"`
+	Ordermin string `json:"ordermin"`
+	// Costmin минимальная сумма ордера в котируемой валюте
+	Costmin string `json:"costmin"`
+	Status  string `json:"status"`
+}
@@ -0,0 +1,16 @@
+package response
+
+// TickerResponse ответ /0/public/Ticker, тикеры по ключу пары биржи
+type TickerResponse struct {
+	Error  []string           `json:"error"`
+	Result map[string]*Ticker `json:"result"`
+}
+
+// Ticker значения в массивах: v и p за сегодня и за 24 часа, c последняя сделка [price, volume]
+type Ticker struct {
+	Ask    []string `json:"a"`
+	Bid    []string `json:"b"`
+	Close  []string `json:"c"`
+	Volume []string `json:"v"`
+	VWAP   []string `json:"p"`
+}
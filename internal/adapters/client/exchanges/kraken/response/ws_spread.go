@@ -0,0 +1,69 @@
+package response
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// WSEvent служебное сообщение websocket: heartbeat, systemStatus, subscriptionStatus, pong
+type WSEvent struct {
+	Event        string `json:"event"`
+	Status       string `json:"status"`
+	Pair         string `json:"pair"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// Spread лучшие цены в формате [bid, ask, timestamp, bidVolume, askVolume]
+type Spread []string
+
+func (s Spread) Bid() (float64, error) {
+	return s.parse(0)
+}
+
+func (s Spread) Ask() (float64, error) {
+	return s.parse(1)
+}
+
+func (s Spread) BidVolume() (float64, error) {
+	return s.parse(3)
+}
+
+func (s Spread) AskVolume() (float64, error) {
+	return s.parse(4)
+}
+
+func (s Spread) parse(i int) (float64, error) {
+	if len(s) <= i {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(s[i], 64)
+}
+
+// WSSpread сообщение канала spread в формате [channelID, spread, channelName, pair]
+type WSSpread struct {
+	Spread  Spread
+	Channel string
+	Pair    string
+}
+
+func (w *WSSpread) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) < 4 {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw[1], &w.Spread); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw[2], &w.Channel); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw[3], &w.Pair)
+}
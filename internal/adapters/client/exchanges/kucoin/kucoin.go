@@ -0,0 +1,631 @@
+package kucoin
+
+import (
+	"calc/common/config"
+	"calc/foundation/id"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/exchanges/kucoin/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	symbolsUri    = "/api/v2/symbols"
+	allTickersUri = "/api/v1/market/allTickers"
+	level1Uri     = "/api/v1/market/orderbook/level1"
+	bulletUri     = "/api/v1/bullet-public"
+	topic         = "/market/ticker:"
+	// chunkSize число пар в одном топике подписки
+	chunkSize = 100
+)
+
+var (
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "kucoin_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
+
+	errNotFound = errors.New("not found")
+)
+
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "kucoin",
+		Capabilities: exchanges.Capabilities{
+			Spot:  true,
+			Depth: true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewKuCoin(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols)
+		},
+	})
+}
+
+// KuCoin получает лучшие цены из топика /market/ticker, символ пары имеет вид BASE-QUOTE.
+// Адрес websocket с токеном выдается биржей перед каждым подключением.
+type KuCoin struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+	// requestID номер последнего сообщения подписки
+	requestID int64
+
+	client.Subscriptions
+}
+
+func NewKuCoin(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+) *KuCoin {
+	httpClient := client.NewHTTPClient()
+
+	kucoinLogger := log.Logger.With().Str("logger", "kucoin").Logger()
+
+	kucoin := &KuCoin{
+		ctx:        ctx,
+		url:        cfg.URL,
+		logger:     &kucoinLogger,
+		httpClient: httpClient,
+		calculator: calculator,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
+
+	for _, pair := range cfg.Pairs {
+		kucoin.Register(pair)
+	}
+
+	// топик присылает лучшие цены целиком, снапшоты по REST не нужны
+	kucoin.books = orderbook.NewManager(ctx, "kucoin", 1, nil, kucoin.save)
+	kucoin.wsClient = client.NewWSClient("kucoin", cfg.WsURL, client.WSHandler{
+		Subscribe: kucoin.subscribe,
+		Handle:    kucoin.handle,
+		Endpoint:  kucoin.endpoint,
+		// биржа не отвечает на ping фреймы и закрывает соединение без прикладного ping
+		Ping: func(conn client.WSConn) error {
+			return conn.WriteJSON(&struct {
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			}{
+				ID:   strconv.FormatInt(atomic.AddInt64(&kucoin.requestID, 1), 10),
+				Type: "ping",
+			})
+		},
+	}, cfg.Websocket)
+
+	go func() {
+		kucoin.loadSymbols()
+		kucoin.loadFees()
+		kucoin.loadNetworks()
+
+		kucoin.wsClient.Run(kucoin.ctx)
+	}()
+
+	return kucoin
+}
+
+// endpoint получает токен публичного websocket и возвращает адрес подключения с ним
+func (e *KuCoin) endpoint(ctx context.Context) (string, error) {
+	resp, err := e.httpClient.Post(ctx, fmt.Sprintf("%s%s", e.url, bulletUri), "application/json", nil)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request websocket token")
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	var bulletResponse response.BulletResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bulletResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode websocket token response")
+		return "", err
+	}
+
+	if bulletResponse.Code != "200000" || len(bulletResponse.Data.InstanceServers) == 0 {
+		e.logger.Error().Stack().Msgf("failed on websocket token response [%s] %s", bulletResponse.Code, bulletResponse.Msg)
+		return "", errors.New("websocket token is not issued")
+	}
+
+	u, err := url.Parse(bulletResponse.Data.InstanceServers[0].Endpoint)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse websocket endpoint")
+		return "", err
+	}
+
+	q := u.Query()
+	q.Add("token", bulletResponse.Data.Token)
+	q.Add("connectId", id.ULID().String())
+
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *KuCoin) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + "-" + quote
+		})
+	}
+
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+func (e *KuCoin) loadFees() {
+	fees, _ := e.Fees(e.ctx)
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *KuCoin) loadNetworks() {
+	networks, _ := e.Networks(e.ctx)
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *KuCoin) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	e.Reset()
+
+	var symbolList []string
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		symbolList = append(symbolList, symbol)
+	}
+
+	for start := 0; start < len(symbolList); start += chunkSize {
+		end := start + chunkSize
+		if end > len(symbolList) {
+			end = len(symbolList)
+		}
+
+		init := e.request("subscribe", symbolList[start:end]...)
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+// request сообщение подписки или отписки от лучших цен пар
+func (e *KuCoin) request(op string, symbols ...string) interface{} {
+	id := strconv.FormatInt(atomic.AddInt64(&e.requestID, 1), 10)
+
+	if op == "subscribe" {
+		pairs := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			if pair, ok := e.symbols.Pair(symbol); ok {
+				pairs = append(pairs, pair)
+			}
+		}
+
+		e.Track(id, pairs...)
+	}
+
+	return struct {
+		ID             string `json:"id"`
+		Type           string `json:"type"`
+		Topic          string `json:"topic"`
+		PrivateChannel bool   `json:"privateChannel"`
+		Response       bool   `json:"response"`
+	}{
+		ID:       id,
+		Type:     op,
+		Topic:    topic + strings.Join(symbols, ","),
+		Response: true,
+	}
+}
+
+// Subscribe подписывается на лучшие цены пары. Без соединения подписка отправится при подключении.
+func (e *KuCoin) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	fee := e.fees.Get(pair)
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "kucoin",
+		Pair:     pair,
+		Maker:    fee.Maker,
+		Taker:    fee.Taker,
+	})
+
+	if err := e.wsClient.WriteJSON(e.request("subscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от лучших цен пары
+func (e *KuCoin) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("kucoin", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("unsubscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *KuCoin) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	var msg *response.WSMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	switch msg.Type {
+	case "error":
+		var reason string
+		_ = json.Unmarshal(msg.Data, &reason)
+
+		// ошибка подписки одной пары не должна рвать поток остальных
+		rejected := e.Reject(msg.ID, reason, e.symbols.Native)
+		logger.Error().Stack().Strs("pairs", rejected).Msgf("failed on response message [%s] %s", msg.Code, reason)
+		return nil
+	case "ack":
+		e.Confirm(msg.ID)
+	}
+
+	// welcome, ack и pong
+	if msg.Type != "message" || !strings.HasPrefix(msg.Topic, topic) {
+		return nil
+	}
+
+	symbol := strings.TrimPrefix(msg.Topic, topic)
+
+	pair, ok := e.symbols.Pair(symbol)
+	if !ok {
+		logger.Warn().Str("symbol", symbol).Msg("unknown symbol")
+		return nil
+	}
+
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	var ticker *response.WSTicker
+	if err := json.Unmarshal(msg.Data, &ticker); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read ticker")
+		return err
+	}
+
+	bid, err := level(ticker.BestBid, ticker.BestBidSize)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse bid")
+		return nil
+	}
+
+	ask, err := level(ticker.BestAsk, ticker.BestAskSize)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse ask")
+		return nil
+	}
+
+	// неизменившиеся цены тоже подтверждают, что поток пары жив
+	e.health.Touch("kucoin", pair, time.Now().UTC())
+
+	e.books.Snapshot(pair, &orderbook.Snapshot{
+		Bids: []domain.PriceLevel{bid},
+		Asks: []domain.PriceLevel{ask},
+	})
+
+	return nil
+}
+
+// save передает изменившиеся цены в шину рыночных данных
+func (e *KuCoin) save(data *domain.Data) {
+	// цены, пришедшие до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+func level(price, quantity string) (domain.PriceLevel, error) {
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return domain.PriceLevel{}, err
+	}
+
+	q, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return domain.PriceLevel{}, err
+	}
+
+	return domain.PriceLevel{
+		Price:    p,
+		Quantity: q,
+	}, nil
+}
+
+func (e *KuCoin) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает символы, доступные для торговли
+func (e *KuCoin) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	symbolList, err := e.symbolList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(symbolList))
+	for _, symbol := range symbolList {
+		markets = append(markets, symbols.Symbol{
+			Native: symbol.Symbol,
+			Base:   symbol.BaseCurrency,
+			Quote:  symbol.QuoteCurrency,
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа и минимальной суммой ордера
+func (e *KuCoin) Markets(ctx context.Context) ([]*domain.Market, error) {
+	symbolList, err := e.symbolList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, err := e.tickers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		volumes[ticker.Symbol], _ = strconv.ParseFloat(ticker.VolValue, 64)
+	}
+
+	markets := make([]*domain.Market, 0, len(symbolList))
+	for _, symbol := range symbolList {
+		minNotional, _ := strconv.ParseFloat(symbol.MinFunds, 64)
+
+		markets = append(markets, &domain.Market{
+			Exchange:    "kucoin",
+			Pair:        e.symbols.Canonical(symbol.BaseCurrency, symbol.QuoteCurrency),
+			Base:        e.symbols.Asset(symbol.BaseCurrency),
+			Quote:       e.symbols.Asset(symbol.QuoteCurrency),
+			Volume:      volumes[symbol.Symbol],
+			MinNotional: minNotional,
+		})
+	}
+
+	return markets, nil
+}
+
+// symbolList возвращает символы с включенной торговлей
+func (e *KuCoin) symbolList(ctx context.Context) ([]*response.Symbol, error) {
+	resp, err := e.httpClient.Get(ctx, fmt.Sprintf("%s%s", e.url, symbolsUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request symbols")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var symbolsResponse response.SymbolsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&symbolsResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode symbols response")
+		return nil, err
+	}
+
+	if symbolsResponse.Code != "200000" {
+		e.logger.Error().Stack().Msgf("failed on symbols response [%s] %s", symbolsResponse.Code, symbolsResponse.Msg)
+		return nil, errors.New(symbolsResponse.Msg)
+	}
+
+	var enabled []*response.Symbol
+	for _, symbol := range symbolsResponse.Data {
+		if symbol.EnableTrading {
+			enabled = append(enabled, symbol)
+		}
+	}
+
+	return enabled, nil
+}
+
+func (e *KuCoin) tickers(ctx context.Context) ([]*response.Ticker, error) {
+	resp, err := e.httpClient.Get(ctx, fmt.Sprintf("%s%s", e.url, allTickersUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickersResponse response.AllTickersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickersResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	if tickersResponse.Code != "200000" {
+		e.logger.Error().Stack().Msgf("failed on tickers response [%s] %s", tickersResponse.Code, tickersResponse.Msg)
+		return nil, errors.New(tickersResponse.Msg)
+	}
+
+	return tickersResponse.Data.Ticker, nil
+}
+
+func (e *KuCoin) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, level1Uri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return 0, err
+	}
+
+	q := u.Query()
+	q.Add("symbol", symbol)
+
+	u.RawQuery = q.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request ticker")
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	var level1Response response.Level1Response
+	if err := json.NewDecoder(resp.Body).Decode(&level1Response); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode ticker response")
+		return 0, err
+	}
+
+	if level1Response.Data == nil {
+		return 0, errNotFound
+	}
+
+	return strconv.ParseFloat(level1Response.Data.Price, 64)
+}
+
+// Fees возвращает комиссии из конфига: эндпоинт с комиссиями у KuCoin требует подписи
+func (e *KuCoin) Fees(_ context.Context) ([]*domain.Fee, error) {
+	pairs := e.SubscribedPairs()
+
+	fees := make([]*domain.Fee, 0, len(pairs))
+	for _, pair := range pairs {
+		fee := e.fees.Get(pair)
+		fees = append(fees, &domain.Fee{
+			Exchange: "kucoin",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает сети из конфига: эндпоинт с сетями вывода у KuCoin требует подписи
+func (e *KuCoin) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "kucoin",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func (e *KuCoin) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("kucoin", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
+}
@@ -0,0 +1,56 @@
+package kucoin
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/exchanges/exchangestest"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/services/bus"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// streamQuotes котировки записи потока: повтор цен не публикуется
+var streamQuotes = []exchangestest.Quote{
+	{Exchange: "kucoin", Pair: "BTC_USDT", Bid: 36510, BidQuantity: 1.02358107, Ask: 36510.1, AskQuantity: 0.36918453},
+	{Exchange: "kucoin", Pair: "ETH_USDT", Bid: 2057.84, BidQuantity: 12.4831062, Ask: 2057.85, AskQuantity: 3.1522169},
+	{Exchange: "kucoin", Pair: "BTC_USDT", Bid: 36510, BidQuantity: 0.80358107, Ask: 36510.1, AskQuantity: 0.36918453},
+	{Exchange: "kucoin", Pair: "BTC_USDT", Bid: 36509.8, BidQuantity: 0.41, Ask: 36509.9, AskQuantity: 0.05},
+}
+
+// reconnectQuotes котировки после переподключения
+var reconnectQuotes = []exchangestest.Quote{
+	{Exchange: "kucoin", Pair: "BTC_USDT", Bid: 36514.2, BidQuantity: 0.61734, Ask: 36514.3, AskQuantity: 0.2213},
+	{Exchange: "kucoin", Pair: "ETH_USDT", Bid: 2058.1, BidQuantity: 7.3341, Ask: 2058.11, AskQuantity: 1.906},
+}
+
+func TestStream(t *testing.T) {
+	newAdapter := func(ctx context.Context, s *exchangestest.Server, cfg *config.ExchangeConfig, calc *exchangestest.Calculator, marketBus *bus.Bus) exchangestest.Adapter {
+		s.Handle(bulletUri, fmt.Sprintf(`{"code":"200000","data":{"token":"2neAiuYvAU61ZDXANAGAsiL4-iAExhsBXZxftpOeh_55i3Ysy2q2LEsEWU64mdzUOPusi34M_wGoSf7iNyEWJ4aBZXpWhrmY9jKtqkdWoFa75w3istPvPtiYB9J6i9GjsxUuhPw3BlrzazF6ghq4L_Eq6ljHZyNfkJ5fmIb6CLVmEwYHtn3hvhZ1TBq4PpXPYx0lv44kjMJHs3WuaRhMyk8Q==.0RmCDDwvGEg1Vxv6cn1Hhw==","instanceServers":[{"endpoint":%q,"encrypt":true,"protocol":"websocket","pingInterval":18000,"pingTimeout":10000}]}}`, s.WsURL()))
+
+		return NewKuCoin(ctx, cfg, calc, marketBus, nil, symbols.NewRegistry())
+	}
+
+	exchangestest.Replay(t, newAdapter, []exchangestest.Case{
+		{
+			Name:     "stream",
+			Pairs:    []string{"BTC_USDT", "ETH_USDT", "SRM_USDT"},
+			Sessions: []exchangestest.Session{{After: 1, Frames: "testdata/ticker.jsonl"}},
+			Quotes:   streamQuotes,
+			Rejected: []string{"SRM_USDT"},
+		},
+		{
+			// обрыв после первой сессии: адаптер переподключается и подписывается заново без отклоненной пары
+			Name:  "reconnect",
+			Pairs: []string{"BTC_USDT", "ETH_USDT", "SRM_USDT"},
+			Sessions: []exchangestest.Session{
+				{After: 1, Frames: "testdata/ticker.jsonl"},
+				{After: 1, Frames: "testdata/ticker-reconnect.jsonl"},
+			},
+			Quotes:       append(append([]exchangestest.Quote(nil), streamQuotes...), reconnectQuotes...),
+			Rejected:     []string{"SRM_USDT"},
+			Resubscribed: []string{"BTC-USDT", "ETH-USDT"},
+			Dropped:      []string{"SRM-USDT"},
+		},
+	})
+}
@@ -0,0 +1,18 @@
+package response
+
+// BulletResponse ответ /api/v1/bullet-public с токеном для публичного websocket
+type BulletResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Token           string            `json:"token"`
+		InstanceServers []*InstanceServer `json:"instanceServers"`
+	} `json:"data"`
+}
+
+type InstanceServer struct {
+	Endpoint     string `json:"endpoint"`
+	Protocol     string `json:"protocol"`
+	PingInterval int64  `json:"pingInterval"`
+	PingTimeout  int64  `json:"pingTimeout"`
+}
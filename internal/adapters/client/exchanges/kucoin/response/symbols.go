@@ -0,0 +1,17 @@
+package response
+
+// SymbolsResponse ответ /api/v2/symbols
+type SymbolsResponse struct {
+	Code string    `json:"code"`
+	Msg  string    `json:"msg"`
+	Data []*Symbol `json:"data"`
+}
+
+type Symbol struct {
+	Symbol        string `json:"symbol"`
+	BaseCurrency  string `json:"baseCurrency"`
+	QuoteCurrency string `json:"quoteCurrency"`
+	// MinFunds минимальная сумма ордера в котируемой валюте
+	MinFunds      string `json:"minFunds"`
+	EnableTrading bool   `json:"enableTrading"`
+}
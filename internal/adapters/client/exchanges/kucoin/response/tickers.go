@@ -0,0 +1,34 @@
+package response
+
+// AllTickersResponse ответ /api/v1/market/allTickers
+type AllTickersResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Time   int64     `json:"time"`
+		Ticker []*Ticker `json:"ticker"`
+	} `json:"data"`
+}
+
+type Ticker struct {
+	Symbol string `json:"symbol"`
+	Last   string `json:"last"`
+	Vol    string `json:"vol"`
+	// VolValue объем за 24 часа в котируемой валюте
+	VolValue string `json:"volValue"`
+}
+
+// Level1Response ответ /api/v1/market/orderbook/level1
+type Level1Response struct {
+	Code string  `json:"code"`
+	Msg  string  `json:"msg"`
+	Data *Level1 `json:"data"`
+}
+
+type Level1 struct {
+	Price       string `json:"price"`
+	BestBid     string `json:"bestBid"`
+	BestBidSize string `json:"bestBidSize"`
+	BestAsk     string `json:"bestAsk"`
+	BestAskSize string `json:"bestAskSize"`
+}
@@ -0,0 +1,24 @@
+package response
+
+import "encoding/json"
+
+// WSMessage сообщение websocket: welcome, ack, pong, error или message с данными топика
+type WSMessage struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic"`
+	Subject string          `json:"subject"`
+	Code    json.Number     `json:"code"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// WSTicker данные топика /market/ticker с лучшими ценами
+type WSTicker struct {
+	Sequence    string `json:"sequence"`
+	Price       string `json:"price"`
+	BestBid     string `json:"bestBid"`
+	BestBidSize string `json:"bestBidSize"`
+	BestAsk     string `json:"bestAsk"`
+	BestAskSize string `json:"bestAskSize"`
+	Time        int64  `json:"time"`
+}
@@ -0,0 +1,590 @@
+package okx
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/exchanges/okx/response"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	instrumentsUri = "/api/v5/public/instruments"
+	tickersUri     = "/api/v5/market/tickers"
+	tickerUri      = "/api/v5/market/ticker"
+	channel        = "bbo-tbt"
+	// chunkSize число пар в одном сообщении подписки
+	chunkSize = 100
+)
+
+var (
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "okx_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promRegister sync.Once
+
+	errNotFound = errors.New("not found")
+)
+
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "okx",
+		Capabilities: exchanges.Capabilities{
+			Spot:  true,
+			Depth: true,
+		},
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewOKX(ctx, cfg, deps.Calculator, deps.Bus, deps.Health, deps.Symbols)
+		},
+	})
+}
+
+// OKX получает лучшие цены из канала bbo-tbt, символ пары имеет вид BASE-QUOTE
+type OKX struct {
+	ctx        context.Context
+	url        string
+	logger     *zerolog.Logger
+	httpClient client.HTTPClient
+	wsClient   *client.WSClient
+	books      *orderbook.Manager
+	calculator calculator.CalculateService
+	bus        *bus.Bus
+	health     *health.Tracker
+	symbols    *symbols.Registry
+	fees       *config.Fees
+	assets     config.Assets
+	// requestID номер последнего сообщения подписки
+	requestID int64
+
+	client.Subscriptions
+}
+
+func NewOKX(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+	registry *symbols.Registry,
+) *OKX {
+	httpClient := client.NewHTTPClient()
+
+	okxLogger := log.Logger.With().Str("logger", "okx").Logger()
+
+	okx := &OKX{
+		ctx:        ctx,
+		url:        cfg.URL,
+		logger:     &okxLogger,
+		httpClient: httpClient,
+		calculator: calculator,
+		bus:        marketBus,
+		health:     healthTracker,
+		symbols:    registry,
+		fees:       cfg.Fees,
+		assets:     cfg.Assets,
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice)
+	})
+
+	for _, pair := range cfg.Pairs {
+		okx.Register(pair)
+	}
+
+	// канал присылает лучшие цены целиком, снапшоты по REST не нужны
+	okx.books = orderbook.NewManager(ctx, "okx", 1, nil, okx.save)
+	okx.wsClient = client.NewWSClient("okx", cfg.WsURL, client.WSHandler{
+		Subscribe: okx.subscribe,
+		Handle:    okx.handle,
+		// без прикладного ping биржа закрывает соединение через 30 секунд тишины
+		Ping: func(conn client.WSConn) error {
+			return conn.WriteMessage([]byte("ping"))
+		},
+	}, cfg.Websocket)
+
+	go func() {
+		okx.loadSymbols()
+		okx.loadFees()
+		okx.loadNetworks()
+
+		okx.wsClient.Run(okx.ctx)
+	}()
+
+	return okx
+}
+
+// loadSymbols загружает символы биржи и сообщает о парах из конфига, которых на бирже нет
+func (e *OKX) loadSymbols() {
+	markets, err := e.markets(e.ctx)
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to load symbols, using config pairs")
+		markets = symbols.FromPairs(e.SubscribedPairs(), func(base, quote string) string {
+			return base + "-" + quote
+		})
+	}
+
+	if duplicates := e.symbols.Load(markets); len(duplicates) > 0 {
+		e.logger.Warn().Strs("symbols", duplicates).Msg("symbols map to already known pairs and are ignored")
+	}
+
+	if unmapped := e.symbols.Unmapped(e.SubscribedPairs()); len(unmapped) > 0 {
+		e.logger.Warn().Strs("pairs", unmapped).Msg("pairs are not traded on exchange and are not subscribed")
+	}
+}
+
+func (e *OKX) loadFees() {
+	fees, _ := e.Fees(e.ctx)
+	for _, fee := range fees {
+		e.calculator.SetFee(fee)
+	}
+}
+
+func (e *OKX) loadNetworks() {
+	networks, _ := e.Networks(e.ctx)
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+func (e *OKX) subscribe(conn client.WSConn) error {
+	logger := e.logger.With().Str("method", "subscribe").Logger()
+
+	pairs := e.SubscribedPairs()
+
+	logger.Info().Msg(strings.Join(pairs, ","))
+
+	e.Reset()
+
+	var symbolList []string
+	for _, pair := range pairs {
+		symbol, err := e.symbols.Native(pair)
+		if err != nil {
+			continue
+		}
+
+		symbolList = append(symbolList, symbol)
+	}
+
+	for start := 0; start < len(symbolList); start += chunkSize {
+		end := start + chunkSize
+		if end > len(symbolList) {
+			end = len(symbolList)
+		}
+
+		init := e.request("subscribe", symbolList[start:end]...)
+
+		if err := conn.WriteJSON(init); err != nil {
+			logger.Error().Stack().Err(err).Msg("failed to write init message")
+			return err
+		}
+		logger.Debug().Msgf("init message %v successful sended", init)
+	}
+
+	return nil
+}
+
+// request сообщение подписки или отписки от лучших цен пар
+func (e *OKX) request(op string, symbols ...string) interface{} {
+	id := strconv.FormatInt(atomic.AddInt64(&e.requestID, 1), 10)
+
+	args := make([]*response.Arg, 0, len(symbols))
+	pairs := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		args = append(args, &response.Arg{
+			Channel: channel,
+			InstID:  symbol,
+		})
+
+		if pair, ok := e.symbols.Pair(symbol); ok {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if op == "subscribe" {
+		e.Track(id, pairs...)
+	}
+
+	return struct {
+		ID   string          `json:"id"`
+		Op   string          `json:"op"`
+		Args []*response.Arg `json:"args"`
+	}{
+		ID:   id,
+		Op:   op,
+		Args: args,
+	}
+}
+
+// Subscribe подписывается на лучшие цены пары. Без соединения подписка отправится при подключении.
+func (e *OKX) Subscribe(_ context.Context, pair string) error {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return err
+	}
+
+	if !e.Register(pair) {
+		return nil
+	}
+
+	fee := e.fees.Get(pair)
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "okx",
+		Pair:     pair,
+		Maker:    fee.Maker,
+		Taker:    fee.Taker,
+	})
+
+	if err := e.wsClient.WriteJSON(e.request("subscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+// Unsubscribe отписывается от лучших цен пары
+func (e *OKX) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.books.Remove(pair)
+	e.health.Forget("okx", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return nil
+	}
+
+	if err := e.wsClient.WriteJSON(e.request("unsubscribe", symbol)); err != nil && !errors.Is(err, client.ErrWSNotConnected) {
+		return err
+	}
+
+	return nil
+}
+
+func (e *OKX) handle(message []byte) error {
+	logger := e.logger.With().Str("method", "handle").Logger()
+
+	// ответ на прикладной ping
+	if string(message) == "pong" {
+		return nil
+	}
+
+	var msg *response.WSMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to read message")
+		return err
+	}
+
+	switch msg.Event {
+	case "error":
+		// ошибка подписки одной пары не должна рвать поток остальных
+		rejected := e.Reject(msg.ID, msg.Msg, e.symbols.Native)
+		logger.Error().Stack().Strs("pairs", rejected).Msgf("failed on response message [%s] %s", msg.Code, msg.Msg)
+		return nil
+	case "subscribe":
+		// биржа отвечает отдельно на каждую пару запроса
+		if msg.Arg != nil {
+			if pair, ok := e.symbols.Pair(msg.Arg.InstID); ok {
+				e.Confirm(msg.ID, pair)
+			}
+		}
+	}
+
+	if msg.Event != "" || msg.Arg == nil || len(msg.Data) == 0 {
+		return nil
+	}
+
+	pair, ok := e.symbols.Pair(msg.Arg.InstID)
+	if !ok {
+		logger.Warn().Str("symbol", msg.Arg.InstID).Msg("unknown symbol")
+		return nil
+	}
+
+	// сообщения, отправленные до отписки
+	if !e.Subscribed(pair) {
+		return nil
+	}
+
+	bbo := msg.Data[len(msg.Data)-1]
+
+	bids, err := levels(bbo.Bids)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse bids")
+		return nil
+	}
+
+	asks, err := levels(bbo.Asks)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msgf("failed to parse asks")
+		return nil
+	}
+
+	// неизменившиеся цены тоже подтверждают, что поток пары жив
+	e.health.Touch("okx", pair, time.Now().UTC())
+
+	e.books.Snapshot(pair, &orderbook.Snapshot{
+		Bids: bids,
+		Asks: asks,
+	})
+
+	return nil
+}
+
+// save передает изменившиеся цены в шину рыночных данных
+func (e *OKX) save(data *domain.Data) {
+	// цены, пришедшие до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+func levels(raw []response.PriceLevel) ([]domain.PriceLevel, error) {
+	result := make([]domain.PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := level.Price()
+		if err != nil {
+			return nil, err
+		}
+
+		quantity, err := level.Quantity()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, domain.PriceLevel{
+			Price:    price,
+			Quantity: quantity,
+		})
+	}
+
+	return result, nil
+}
+
+func (e *OKX) Pairs(ctx context.Context) ([]string, error) {
+	markets, err := e.markets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]string, 0, len(markets))
+	for _, market := range markets {
+		pairs = append(pairs, e.symbols.Canonical(market.Base, market.Quote))
+	}
+
+	return pairs, nil
+}
+
+// markets возвращает инструменты, доступные для спотовой торговли
+func (e *OKX) markets(ctx context.Context) ([]symbols.Symbol, error) {
+	instruments, err := e.instruments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]symbols.Symbol, 0, len(instruments))
+	for _, instrument := range instruments {
+		markets = append(markets, symbols.Symbol{
+			Native: instrument.InstID,
+			Base:   instrument.BaseCcy,
+			Quote:  instrument.QuoteCcy,
+		})
+	}
+
+	return markets, nil
+}
+
+// Markets возвращает торгуемые пары с объемом за 24 часа. Минимальная сумма ордера задана
+// в базовой валюте, поэтому не заполняется.
+func (e *OKX) Markets(ctx context.Context) ([]*domain.Market, error) {
+	instruments, err := e.instruments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers, err := e.tickers(ctx, tickersUri, url.Values{"instType": {"SPOT"}})
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		volumes[ticker.InstID], _ = strconv.ParseFloat(ticker.VolCcy24h, 64)
+	}
+
+	markets := make([]*domain.Market, 0, len(instruments))
+	for _, instrument := range instruments {
+		markets = append(markets, &domain.Market{
+			Exchange: "okx",
+			Pair:     e.symbols.Canonical(instrument.BaseCcy, instrument.QuoteCcy),
+			Base:     e.symbols.Asset(instrument.BaseCcy),
+			Quote:    e.symbols.Asset(instrument.QuoteCcy),
+			Volume:   volumes[instrument.InstID],
+		})
+	}
+
+	return markets, nil
+}
+
+// instruments возвращает спотовые инструменты в статусе live
+func (e *OKX) instruments(ctx context.Context) ([]*response.Instrument, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, instrumentsUri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Add("instType", "SPOT")
+
+	u.RawQuery = q.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request instruments")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var instrumentsResponse response.InstrumentsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&instrumentsResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode instruments response")
+		return nil, err
+	}
+
+	if instrumentsResponse.Code != "0" {
+		e.logger.Error().Stack().Msgf("failed on instruments response [%s] %s", instrumentsResponse.Code, instrumentsResponse.Msg)
+		return nil, errors.New(instrumentsResponse.Msg)
+	}
+
+	var live []*response.Instrument
+	for _, instrument := range instrumentsResponse.Data {
+		if instrument.State == "live" {
+			live = append(live, instrument)
+		}
+	}
+
+	return live, nil
+}
+
+func (e *OKX) tickers(ctx context.Context, uri string, query url.Values) ([]*response.Ticker, error) {
+	u, err := url.Parse(fmt.Sprintf("%s%s", e.url, uri))
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to parse url")
+		return nil, err
+	}
+
+	u.RawQuery = query.Encode()
+
+	resp, err := e.httpClient.Get(ctx, u.String())
+	if err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to request tickers")
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var tickersResponse response.TickersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tickersResponse); err != nil {
+		e.logger.Error().Stack().Err(err).Msg("failed to decode tickers response")
+		return nil, err
+	}
+
+	if tickersResponse.Code != "0" {
+		e.logger.Error().Stack().Msgf("failed on tickers response [%s] %s", tickersResponse.Code, tickersResponse.Msg)
+		return nil, errors.New(tickersResponse.Msg)
+	}
+
+	return tickersResponse.Data, nil
+}
+
+func (e *OKX) Price(ctx context.Context, pair string) (float64, error) {
+	symbol, err := e.symbols.Native(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	tickers, err := e.tickers(ctx, tickerUri, url.Values{"instId": {symbol}})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tickers) == 0 {
+		return 0, errNotFound
+	}
+
+	return strconv.ParseFloat(tickers[0].Last, 64)
+}
+
+// Fees возвращает комиссии из конфига: эндпоинт с комиссиями у OKX требует подписи
+func (e *OKX) Fees(_ context.Context) ([]*domain.Fee, error) {
+	pairs := e.SubscribedPairs()
+
+	fees := make([]*domain.Fee, 0, len(pairs))
+	for _, pair := range pairs {
+		fee := e.fees.Get(pair)
+		fees = append(fees, &domain.Fee{
+			Exchange: "okx",
+			Pair:     pair,
+			Maker:    fee.Maker,
+			Taker:    fee.Taker,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает сети из конфига: эндпоинт с сетями вывода у OKX требует подписи
+func (e *OKX) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "okx",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func (e *OKX) Health(_ context.Context) *domain.ExchangeHealth {
+	state := e.wsClient.State()
+
+	return e.health.Health("okx", e.SubscribedPairs(), state.String(), state == client.WSStateConnected, time.Now().UTC())
+}
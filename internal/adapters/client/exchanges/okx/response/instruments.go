@@ -0,0 +1,17 @@
+package response
+
+type InstrumentsResponse struct {
+	Code string        `json:"code"`
+	Msg  string        `json:"msg"`
+	Data []*Instrument `json:"data"`
+}
+
+type Instrument struct {
+	InstID   string `json:"instId"`
+	BaseCcy  string `json:"baseCcy"`
+	QuoteCcy string `json:"quoteCcy"`
+	State    string `json:"state"`
+	MinSz    string `json:"minSz"`
+	LotSz    string `json:"lotSz"`
+	TickSz   string `json:"tickSz"`
+}
@@ -0,0 +1,19 @@
+package response
+
+type TickersResponse struct {
+	Code string    `json:"code"`
+	Msg  string    `json:"msg"`
+	Data []*Ticker `json:"data"`
+}
+
+type Ticker struct {
+	InstID string `json:"instId"`
+	Last   string `json:"last"`
+	AskPx  string `json:"askPx"`
+	AskSz  string `json:"askSz"`
+	BidPx  string `json:"bidPx"`
+	BidSz  string `json:"bidSz"`
+	// VolCcy24h объем за 24 часа в валюте котировки для спота
+	VolCcy24h string `json:"volCcy24h"`
+	Vol24h    string `json:"vol24h"`
+}
@@ -0,0 +1,43 @@
+package response
+
+import "strconv"
+
+// WSMessage сообщение канала bbo-tbt с лучшими ценами или ответ на подписку
+type WSMessage struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Code  string `json:"code"`
+	Msg   string `json:"msg"`
+	Arg   *Arg   `json:"arg"`
+	Data  []*BBO `json:"data"`
+}
+
+type Arg struct {
+	Channel string `json:"channel"`
+	InstID  string `json:"instId"`
+}
+
+type BBO struct {
+	Asks []PriceLevel `json:"asks"`
+	Bids []PriceLevel `json:"bids"`
+	Ts   string       `json:"ts"`
+}
+
+// PriceLevel уровень стакана в формате [price, size, deprecated, orders]
+type PriceLevel []string
+
+func (l PriceLevel) Price() (float64, error) {
+	return l.parse(0)
+}
+
+func (l PriceLevel) Quantity() (float64, error) {
+	return l.parse(1)
+}
+
+func (l PriceLevel) parse(i int) (float64, error) {
+	if len(l) <= i {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(l[i], 64)
+}
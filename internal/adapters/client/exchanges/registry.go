@@ -0,0 +1,128 @@
+package exchanges
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/symbols"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = make(map[string]*Adapter)
+)
+
+// Capabilities возможности адаптера биржи
+type Capabilities struct {
+	Spot    bool `json:"spot"`
+	Futures bool `json:"futures"`
+	// Depth поток стакана
+	Depth bool `json:"depth"`
+	// Trades поток сделок
+	Trades bool `json:"trades"`
+	// Trading выставление ордеров
+	Trading bool `json:"trading"`
+	// Fees комиссии по API, иначе берутся из конфига
+	Fees bool `json:"fees"`
+}
+
+// Deps общие зависимости адаптеров
+type Deps struct {
+	Calculator calculator.CalculateService
+	// Bus шина, в которую адаптер публикует котировки
+	Bus     *bus.Bus
+	Health  *health.Tracker
+	Symbols *symbols.Registry
+	// FuturesSymbols отдельный реестр символов фьючерсов с теми же алиасами, что и Symbols
+	FuturesSymbols *symbols.Registry
+}
+
+// Adapter описание адаптера биржи. Пакет адаптера регистрирует его в init через Register.
+type Adapter struct {
+	Name         string
+	Capabilities Capabilities
+	// Validate проверяет конфиг биржи до запуска адаптеров, по умолчанию ValidateConfig
+	Validate func(cfg *config.ExchangeConfig) error
+	New      func(ctx context.Context, cfg *config.ExchangeConfig, deps *Deps) Exchange
+}
+
+// Register регистрирует адаптер, повторная регистрация имени - ошибка программиста
+func Register(adapter *Adapter) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+
+	if adapter == nil || adapter.New == nil {
+		panic("exchanges: register adapter without constructor")
+	}
+
+	if _, ok := adapters[adapter.Name]; ok {
+		panic(fmt.Sprintf("exchanges: adapter %s registered twice", adapter.Name))
+	}
+
+	adapters[adapter.Name] = adapter
+}
+
+// Adapters возвращает названия зарегистрированных адаптеров
+func Adapters() []string {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+
+	names := make([]string, 0, len(adapters))
+	for name := range adapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func lookup(name string) (*Adapter, bool) {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+
+	adapter, ok := adapters[name]
+
+	return adapter, ok
+}
+
+// ValidateConfig проверяет адреса API и формат пар BASE_QUOTE
+func ValidateConfig(cfg *config.ExchangeConfig) error {
+	if cfg == nil {
+		return errors.New("config is empty")
+	}
+
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if cfg.WsURL == "" {
+		return errors.New("ws_url is required")
+	}
+
+	if cfg.Futures != nil && cfg.Futures.Enabled {
+		if cfg.Futures.URL == "" {
+			return errors.New("futures url is required")
+		}
+
+		if cfg.Futures.WsURL == "" {
+			return errors.New("futures ws_url is required")
+		}
+	}
+
+	pairs := append(append([]string(nil), cfg.Pairs...), cfg.FuturesPairs()...)
+	for _, pair := range pairs {
+		assets := strings.Split(pair, "_")
+		if len(assets) != 2 || assets[0] == "" || assets[1] == "" {
+			return errors.Errorf("invalid pair %q, BASE_QUOTE is expected", pair)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package uniswap
+
+import (
+	"calc/internal/domain"
+	"encoding/hex"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// селекторы методов контрактов пулов и токенов
+const (
+	selectorToken0      = "0x0dfe1681"
+	selectorToken1      = "0xd21220a7"
+	selectorFee         = "0xddca3f43"
+	selectorGetReserves = "0x0902f1ac"
+	selectorSlot0       = "0x3850c7bd"
+	selectorLiquidity   = "0x1a686502"
+	selectorDecimals    = "0x313ce567"
+
+	versionV2 = "v2"
+	versionV3 = "v3"
+
+	wordSize = 32
+)
+
+var (
+	errShortResult = errors.New("short eth_call result")
+	errEmptyPool   = errors.New("pool has no liquidity")
+
+	q96 = new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 96))
+)
+
+// pool метаданные пула, читаются из контрактов один раз
+type pool struct {
+	address string
+	version string
+	// fee комиссия пула в процентах
+	fee          float64
+	decimals0    int
+	decimals1    int
+	baseIsToken0 bool
+}
+
+// reserves резервы пула в базовой и котируемой валюте пары. У пулов v3 это виртуальные резервы
+// текущего диапазона цен: выход за его границы при крупной сделке не учитывается.
+type reserves struct {
+	base  float64
+	quote float64
+}
+
+func (r reserves) mid() float64 {
+	return r.quote / r.base
+}
+
+// levels считает эффективные цены сделки объемом size в валюте котировки с учетом комиссии пула
+// и проскальзывания по формуле постоянного произведения x*y=k
+func (r reserves) levels(size, fee float64) (bid, ask domain.PriceLevel, err error) {
+	if r.base <= 0 || r.quote <= 0 {
+		return bid, ask, errEmptyPool
+	}
+
+	k := 1 - fee/100
+	mid := r.mid()
+
+	// покупка базовой валюты на size котируемой
+	baseOut := r.base * size * k / (r.quote + size*k)
+	ask = domain.PriceLevel{Price: size / baseOut, Quantity: baseOut}
+
+	// продажа базовой валюты на size котируемой по текущей цене
+	baseIn := size / mid
+	quoteOut := r.quote * baseIn * k / (r.base + baseIn*k)
+	bid = domain.PriceLevel{Price: quoteOut / baseIn, Quantity: baseIn}
+
+	return bid, ask, nil
+}
+
+// reservesV2 переводит ответ getReserves в резервы пары
+func (p *pool) reservesV2(result []byte) (reserves, error) {
+	if len(result) < 2*wordSize {
+		return reserves{}, errShortResult
+	}
+
+	return p.orient(
+		scale(new(big.Float).SetInt(word(result, 0)), p.decimals0),
+		scale(new(big.Float).SetInt(word(result, 1)), p.decimals1),
+	), nil
+}
+
+// reservesV3 считает виртуальные резервы по sqrtPriceX96 из slot0 и ликвидности: x = L/sqrtP, y = L*sqrtP
+func (p *pool) reservesV3(slot0, liquidity []byte) (reserves, error) {
+	if len(slot0) < wordSize || len(liquidity) < wordSize {
+		return reserves{}, errShortResult
+	}
+
+	sqrtPrice := new(big.Float).Quo(new(big.Float).SetInt(word(slot0, 0)), q96)
+	if sqrtPrice.Sign() == 0 {
+		return reserves{}, errEmptyPool
+	}
+
+	l := new(big.Float).SetInt(word(liquidity, 0))
+
+	return p.orient(
+		scale(new(big.Float).Quo(l, sqrtPrice), p.decimals0),
+		scale(new(big.Float).Mul(l, sqrtPrice), p.decimals1),
+	), nil
+}
+
+func (p *pool) orient(amount0, amount1 float64) reserves {
+	if p.baseIsToken0 {
+		return reserves{base: amount0, quote: amount1}
+	}
+
+	return reserves{base: amount1, quote: amount0}
+}
+
+// scale переводит сумму в минимальных единицах токена в сумму в токенах
+func scale(amount *big.Float, decimals int) float64 {
+	f, _ := amount.Float64()
+
+	return f / math.Pow10(decimals)
+}
+
+// word возвращает i-е 32-байтное слово результата eth_call
+func word(result []byte, i int) *big.Int {
+	return new(big.Int).SetBytes(result[i*wordSize : (i+1)*wordSize])
+}
+
+// address возвращает адрес из первого слова результата eth_call
+func address(result []byte) (string, error) {
+	if len(result) < wordSize {
+		return "", errShortResult
+	}
+
+	return "0x" + hex.EncodeToString(result[wordSize-20:wordSize]), nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(s, "0x")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+
+	return hex.DecodeString(s)
+}
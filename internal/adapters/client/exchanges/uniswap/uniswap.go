@@ -0,0 +1,586 @@
+package uniswap
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/jsonrpc"
+	"calc/internal/adapters/client/orderbook"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"calc/internal/services/calculator"
+	"calc/internal/services/health"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPollInterval = 12 * time.Second
+	defaultGasLimitV2   = 150000
+	defaultGasLimitV3   = 180000
+	// defaultFeeV2 комиссия пулов Uniswap v2 в процентах
+	defaultFeeV2 = 0.3
+	// nativeDecimals знаков после запятой у валюты газа, как у ETH
+	nativeDecimals = 18
+)
+
+var (
+	promPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "uniswap_price",
+		Help:      "pair price",
+	}, []string{"pair", "side"})
+	promGas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "uniswap_gas_fee",
+		Help:      "swap gas cost in percent of trade size",
+	}, []string{"pair"})
+	promRegister sync.Once
+
+	errPoolNotConfigured = errors.New("pool is not configured")
+)
+
+func init() {
+	exchanges.Register(&exchanges.Adapter{
+		Name: "uniswap",
+		Capabilities: exchanges.Capabilities{
+			Spot: true,
+		},
+		Validate: validate,
+		New: func(ctx context.Context, cfg *config.ExchangeConfig, deps *exchanges.Deps) exchanges.Exchange {
+			return NewUniswap(ctx, cfg, deps.Calculator, deps.Bus, deps.Health)
+		},
+	})
+}
+
+// validate проверяет адрес узла и пулы пар, ws_url не нужен: пулы опрашиваются по JSON-RPC
+func validate(cfg *config.ExchangeConfig) error {
+	if cfg == nil {
+		return errors.New("config is empty")
+	}
+
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if cfg.Dex == nil {
+		return errors.New("dex is required")
+	}
+
+	if cfg.Dex.TradeSize <= 0 {
+		return errors.New("dex trade_size must be positive")
+	}
+
+	for pair, p := range cfg.Dex.Pools {
+		assets := strings.Split(pair, "_")
+		if len(assets) != 2 || assets[0] == "" || assets[1] == "" {
+			return fmt.Errorf("invalid pair %q, BASE_QUOTE is expected", pair)
+		}
+
+		if p == nil || p.Address == "" || p.Base == "" {
+			return fmt.Errorf("pool %s: address and base are required", pair)
+		}
+
+		if p.Version != versionV2 && p.Version != versionV3 {
+			return fmt.Errorf("pool %s: unknown version %q", pair, p.Version)
+		}
+	}
+
+	for _, pair := range cfg.Pairs {
+		if _, ok := cfg.Dex.Pools[pair]; !ok {
+			return fmt.Errorf("pool for pair %s is not configured", pair)
+		}
+	}
+
+	return nil
+}
+
+// Uniswap читает состояние пулов AMM через JSON-RPC узла и передает в калькулятор эффективные цены сделки
+// объемом trade_size. Комиссия пула и проскальзывание входят в цены, стоимость газа - в комиссию тейкера.
+type Uniswap struct {
+	ctx          context.Context
+	logger       *zerolog.Logger
+	rpc          *jsonrpc.Client
+	books        *orderbook.Manager
+	calculator   calculator.CalculateService
+	bus          *bus.Bus
+	health       *health.Tracker
+	cfg          *config.Dex
+	pollInterval time.Duration
+	assets       config.Assets
+	// connected 1, если последний опрос узла прошел без ошибок
+	connected int32
+
+	// poolsMu защищает метаданные пулов
+	poolsMu sync.Mutex
+	pools   map[string]*pool
+
+	client.Subscriptions
+
+	// mu защищает комиссии за газ
+	mu  sync.RWMutex
+	gas map[string]float64
+}
+
+func NewUniswap(
+	ctx context.Context,
+	cfg *config.ExchangeConfig,
+	calculator calculator.CalculateService,
+	marketBus *bus.Bus,
+	healthTracker *health.Tracker,
+) *Uniswap {
+	uniswapLogger := log.Logger.With().Str("logger", "uniswap").Logger()
+
+	pollInterval := cfg.Dex.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	uniswap := &Uniswap{
+		ctx:          ctx,
+		logger:       &uniswapLogger,
+		rpc:          jsonrpc.NewClient(cfg.URL, client.NewHTTPClient()),
+		calculator:   calculator,
+		bus:          marketBus,
+		health:       healthTracker,
+		cfg:          cfg.Dex,
+		pollInterval: pollInterval,
+		assets:       cfg.Assets,
+		pools:        make(map[string]*pool),
+		gas:          make(map[string]float64),
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPrice, promGas)
+	})
+
+	for _, pair := range cfg.Pairs {
+		uniswap.Register(pair)
+	}
+
+	// пул отдает одну эффективную цену на сторону, снапшоты по REST не нужны
+	uniswap.books = orderbook.NewManager(ctx, "uniswap", 1, nil, uniswap.save)
+
+	go func() {
+		uniswap.loadNetworks()
+
+		uniswap.run()
+	}()
+
+	return uniswap
+}
+
+func (e *Uniswap) loadNetworks() {
+	networks, _ := e.Networks(e.ctx)
+	for _, network := range networks {
+		e.calculator.SetAssetNetwork(network)
+	}
+}
+
+// run опрашивает пулы раз в pollInterval, пока не закончится контекст
+func (e *Uniswap) run() {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		e.poll()
+
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll читает пулы подписанных пар и цену газа, обновляет котировки и комиссии
+func (e *Uniswap) poll() {
+	logger := e.logger.With().Str("method", "poll").Logger()
+
+	gasPrice, err := e.gasPrice(e.ctx)
+	if err != nil {
+		logger.Error().Stack().Err(err).Msg("failed to request gas price")
+		atomic.StoreInt32(&e.connected, 0)
+		return
+	}
+
+	type quote struct {
+		bid, ask domain.PriceLevel
+	}
+
+	connected := int32(1)
+	quotes := make(map[string]quote)
+	mids := make(map[string]float64)
+	for _, pair := range e.SubscribedPairs() {
+		p, err := e.pool(e.ctx, pair)
+		if err != nil {
+			logger.Error().Stack().Err(err).Str("pair", pair).Msg("failed to load pool")
+			connected = 0
+			continue
+		}
+
+		r, err := e.reserves(e.ctx, p)
+		if err != nil {
+			logger.Error().Stack().Err(err).Str("pair", pair).Msg("failed to read pool")
+			connected = 0
+			continue
+		}
+
+		bid, ask, err := r.levels(e.cfg.TradeSize, p.fee)
+		if err != nil {
+			logger.Error().Stack().Err(err).Str("pair", pair).Msg("failed to calculate prices")
+			continue
+		}
+
+		quotes[pair] = quote{bid: bid, ask: ask}
+		mids[pair] = r.mid()
+	}
+
+	// цены валюты газа нужны всем парам, поэтому котировки передаются после опроса всех пулов
+	for pair, q := range quotes {
+		// неизменившиеся цены тоже подтверждают, что пул опрашивается
+		e.health.Touch("uniswap", pair, time.Now().UTC())
+
+		// комиссия за газ обновляется до цен, чтобы калькулятор считал чистую прибыль с новой ценой газа
+		e.setGasFee(pair, e.gasFee(pair, gasPrice, mids))
+
+		e.books.Snapshot(pair, &orderbook.Snapshot{
+			Bids: []domain.PriceLevel{q.bid},
+			Asks: []domain.PriceLevel{q.ask},
+		})
+	}
+
+	atomic.StoreInt32(&e.connected, connected)
+}
+
+// gasFee возвращает стоимость газа на обмен в процентах от trade_size. Цена валюты газа берется из пула пары
+// или пула NATIVE_QUOTE, если его пара в подписке и уже опрошена. Без цены стоимость газа не учитывается.
+func (e *Uniswap) gasFee(pair string, gasPrice float64, mids map[string]float64) float64 {
+	gasLimit := e.cfg.GasLimit
+	if gasLimit == 0 {
+		gasLimit = defaultGasLimitV2
+		if e.cfg.Pools[pair].Version == versionV3 {
+			gasLimit = defaultGasLimitV3
+		}
+	}
+
+	gas := gasPrice * float64(gasLimit) / math.Pow10(nativeDecimals)
+
+	assets := strings.Split(pair, "_")
+	switch {
+	case assets[1] == e.cfg.NativeAsset:
+	case assets[0] == e.cfg.NativeAsset:
+		gas *= mids[pair]
+	default:
+		price, ok := mids[e.cfg.NativeAsset+"_"+assets[1]]
+		if !ok {
+			return 0
+		}
+		gas *= price
+	}
+
+	return gas / e.cfg.TradeSize * 100
+}
+
+func (e *Uniswap) setGasFee(pair string, fee float64) {
+	e.mu.Lock()
+	previous, ok := e.gas[pair]
+	e.gas[pair] = fee
+	e.mu.Unlock()
+
+	if ok && previous == fee {
+		return
+	}
+
+	e.calculator.SetFee(&domain.Fee{
+		Exchange: "uniswap",
+		Pair:     pair,
+		Maker:    fee,
+		Taker:    fee,
+	})
+
+	promGas.WithLabelValues(pair).Set(fee)
+}
+
+// gasPrice возвращает цену газа в минимальных единицах валюты газа
+func (e *Uniswap) gasPrice(ctx context.Context) (float64, error) {
+	var result string
+	if err := e.rpc.Call(ctx, "eth_gasPrice", &result); err != nil {
+		return 0, err
+	}
+
+	price, ok := new(big.Int).SetString(strings.TrimPrefix(result, "0x"), 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid gas price %q", result)
+	}
+
+	f, _ := new(big.Float).SetInt(price).Float64()
+
+	return f, nil
+}
+
+// reserves читает текущие резервы пула
+func (e *Uniswap) reserves(ctx context.Context, p *pool) (reserves, error) {
+	if p.version == versionV2 {
+		result, err := e.call(ctx, p.address, selectorGetReserves)
+		if err != nil {
+			return reserves{}, err
+		}
+
+		return p.reservesV2(result)
+	}
+
+	slot0, err := e.call(ctx, p.address, selectorSlot0)
+	if err != nil {
+		return reserves{}, err
+	}
+
+	liquidity, err := e.call(ctx, p.address, selectorLiquidity)
+	if err != nil {
+		return reserves{}, err
+	}
+
+	return p.reservesV3(slot0, liquidity)
+}
+
+// pool возвращает метаданные пула пары, при первом обращении читает токены, их знаки и комиссию пула
+func (e *Uniswap) pool(ctx context.Context, pair string) (*pool, error) {
+	e.poolsMu.Lock()
+	defer e.poolsMu.Unlock()
+
+	if p, ok := e.pools[pair]; ok {
+		return p, nil
+	}
+
+	cfg, ok := e.cfg.Pools[pair]
+	if !ok {
+		return nil, errPoolNotConfigured
+	}
+
+	p := &pool{
+		address: cfg.Address,
+		version: cfg.Version,
+		fee:     cfg.Fee,
+	}
+
+	token0, err := e.token(ctx, p.address, selectorToken0)
+	if err != nil {
+		return nil, err
+	}
+
+	token1, err := e.token(ctx, p.address, selectorToken1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.EqualFold(token0, cfg.Base):
+		p.baseIsToken0 = true
+	case strings.EqualFold(token1, cfg.Base):
+	default:
+		return nil, fmt.Errorf("pool %s does not contain base token %s", p.address, cfg.Base)
+	}
+
+	if p.decimals0, err = e.decimals(ctx, token0); err != nil {
+		return nil, err
+	}
+
+	if p.decimals1, err = e.decimals(ctx, token1); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.version == versionV3:
+		result, err := e.call(ctx, p.address, selectorFee)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result) < wordSize {
+			return nil, errShortResult
+		}
+
+		// комиссия v3 в сотых долях базисного пункта: 3000 = 0.3%
+		p.fee = float64(word(result, 0).Int64()) / 10000
+	case p.fee == 0:
+		p.fee = defaultFeeV2
+	}
+
+	e.pools[pair] = p
+
+	return p, nil
+}
+
+func (e *Uniswap) token(ctx context.Context, pool, selector string) (string, error) {
+	result, err := e.call(ctx, pool, selector)
+	if err != nil {
+		return "", err
+	}
+
+	return address(result)
+}
+
+func (e *Uniswap) decimals(ctx context.Context, token string) (int, error) {
+	result, err := e.call(ctx, token, selectorDecimals)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(result) < wordSize {
+		return 0, errShortResult
+	}
+
+	return int(word(result, 0).Int64()), nil
+}
+
+// call вызывает метод контракта без аргументов на последнем блоке
+func (e *Uniswap) call(ctx context.Context, to, data string) ([]byte, error) {
+	var result string
+	if err := e.rpc.Call(ctx, "eth_call", &result, map[string]string{"to": to, "data": data}, "latest"); err != nil {
+		return nil, err
+	}
+
+	return decodeHex(result)
+}
+
+// Subscribe подписывается на цены пары, пул пары должен быть в конфиге. Цены придут со следующим опросом.
+func (e *Uniswap) Subscribe(_ context.Context, pair string) error {
+	if _, ok := e.cfg.Pools[pair]; !ok {
+		return errPoolNotConfigured
+	}
+
+	e.Register(pair)
+
+	return nil
+}
+
+// Unsubscribe отписывается от цен пары
+func (e *Uniswap) Unsubscribe(_ context.Context, pair string) error {
+	if !e.Unregister(pair) {
+		return nil
+	}
+
+	e.mu.Lock()
+	delete(e.gas, pair)
+	e.mu.Unlock()
+
+	e.books.Remove(pair)
+	e.health.Forget("uniswap", pair)
+	promPrice.DeleteLabelValues(pair, "bid")
+	promPrice.DeleteLabelValues(pair, "ask")
+	promGas.DeleteLabelValues(pair)
+
+	return nil
+}
+
+// save передает изменившиеся цены в шину рыночных данных
+func (e *Uniswap) save(data *domain.Data) {
+	// цены, пришедшие до отписки
+	if !e.Subscribed(data.Pair) {
+		return
+	}
+
+	e.bus.Publish(data)
+
+	promPrice.WithLabelValues(data.Pair, "bid").Set(data.Bid)
+	promPrice.WithLabelValues(data.Pair, "ask").Set(data.Ask)
+}
+
+// Pairs возвращает пары пулов из конфига
+func (e *Uniswap) Pairs(_ context.Context) ([]string, error) {
+	pairs := make([]string, 0, len(e.cfg.Pools))
+	for pair := range e.cfg.Pools {
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
+
+// Markets возвращает пары пулов из конфига, объем торгов пулы не хранят
+func (e *Uniswap) Markets(_ context.Context) ([]*domain.Market, error) {
+	markets := make([]*domain.Market, 0, len(e.cfg.Pools))
+	for pair := range e.cfg.Pools {
+		assets := strings.Split(pair, "_")
+		markets = append(markets, &domain.Market{
+			Exchange: "uniswap",
+			Pair:     pair,
+			Base:     assets[0],
+			Quote:    assets[1],
+		})
+	}
+
+	return markets, nil
+}
+
+// Price возвращает текущую цену пула без комиссии и проскальзывания
+func (e *Uniswap) Price(ctx context.Context, pair string) (float64, error) {
+	p, err := e.pool(ctx, pair)
+	if err != nil {
+		return 0, err
+	}
+
+	r, err := e.reserves(ctx, p)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.mid(), nil
+}
+
+// Fees возвращает стоимость газа на обмен в процентах от trade_size по подписанным парам
+func (e *Uniswap) Fees(_ context.Context) ([]*domain.Fee, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	fees := make([]*domain.Fee, 0, len(e.gas))
+	for pair, fee := range e.gas {
+		fees = append(fees, &domain.Fee{
+			Exchange: "uniswap",
+			Pair:     pair,
+			Maker:    fee,
+			Taker:    fee,
+		})
+	}
+
+	return fees, nil
+}
+
+// Networks возвращает сети из конфига
+func (e *Uniswap) Networks(_ context.Context) ([]*domain.AssetNetwork, error) {
+	var networks []*domain.AssetNetwork
+	for asset, assetNetworks := range e.assets {
+		for _, network := range assetNetworks {
+			networks = append(networks, &domain.AssetNetwork{
+				Exchange:        "uniswap",
+				Asset:           asset,
+				Network:         network.Network,
+				WithdrawFee:     network.WithdrawFee,
+				MinWithdraw:     network.MinWithdraw,
+				DepositEnabled:  network.DepositEnabled,
+				WithdrawEnabled: network.WithdrawEnabled,
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func (e *Uniswap) Health(_ context.Context) *domain.ExchangeHealth {
+	state := "disconnected"
+	connected := atomic.LoadInt32(&e.connected) == 1
+	if connected {
+		state = "connected"
+	}
+
+	return e.health.Health("uniswap", e.SubscribedPairs(), state, connected, time.Now().UTC())
+}
@@ -0,0 +1,204 @@
+package uniswap
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/exchanges/exchangestest"
+	"calc/internal/domain"
+	"calc/internal/services/bus"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	poolV3 = "0x00000000000000000000000000000000000000a1"
+	poolV2 = "0x00000000000000000000000000000000000000a2"
+	usdc   = "0x00000000000000000000000000000000000000c1"
+	weth   = "0x00000000000000000000000000000000000000c2"
+	uni    = "0x00000000000000000000000000000000000000c3"
+
+	// gasPrice 20 gwei
+	gasPrice = "0x4a817c800"
+)
+
+// calls ответы eth_call по адресу контракта и селектору. Пул v3 WETH/USDC с ценой 2000 и комиссией 0.05%,
+// пул v2 UNI/USDC с резервами 100000 UNI и 500000 USDC.
+var calls = map[string]string{
+	poolV3 + selectorToken0: words(hexNumber(usdc)),
+	poolV3 + selectorToken1: words(hexNumber(weth)),
+	poolV3 + selectorFee:    words(big.NewInt(500)),
+	// sqrt(5e8) * 2^96: цена 1 USDC в WETH в минимальных единицах токенов
+	poolV3 + selectorSlot0:     words(number("1771595571142957102961017161607260"), big.NewInt(0)),
+	poolV3 + selectorLiquidity: words(number("1000000000000000000")),
+
+	poolV2 + selectorToken0:      words(hexNumber(uni)),
+	poolV2 + selectorToken1:      words(hexNumber(usdc)),
+	poolV2 + selectorGetReserves: words(number("100000000000000000000000"), number("500000000000"), big.NewInt(1700000000)),
+
+	usdc + selectorDecimals: words(big.NewInt(6)),
+	weth + selectorDecimals: words(big.NewInt(18)),
+	uni + selectorDecimals:  words(big.NewInt(18)),
+}
+
+// node узел EVM-сети: отвечает на eth_gasPrice и на eth_call из calls
+func node(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64             `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			return
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_gasPrice":
+			resp["result"] = gasPrice
+		case "eth_call":
+			var call struct {
+				To   string `json:"to"`
+				Data string `json:"data"`
+			}
+			if len(req.Params) > 0 {
+				_ = json.Unmarshal(req.Params[0], &call)
+			}
+
+			if result, ok := calls[strings.ToLower(call.To)+call.Data]; ok {
+				resp["result"] = result
+			} else {
+				resp["error"] = map[string]interface{}{"code": -32000, "message": "execution reverted"}
+			}
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newUniswap(ctx context.Context, t *testing.T, calculator *exchangestest.Calculator, marketBus *bus.Bus) *Uniswap {
+	return NewUniswap(ctx, &config.ExchangeConfig{
+		URL:   node(t).URL,
+		Pairs: []string{"WETH_USDC", "UNI_USDC"},
+		Dex: &config.Dex{
+			PollInterval: time.Hour,
+			TradeSize:    1000,
+			NativeAsset:  "WETH",
+			Pools: map[string]*config.Pool{
+				"WETH_USDC": {Address: poolV3, Version: versionV3, Base: weth},
+				"UNI_USDC":  {Address: poolV2, Version: versionV2, Base: uni},
+			},
+		},
+	}, calculator, marketBus, nil)
+}
+
+func TestPoll(t *testing.T) {
+	marketBus := bus.New(nil)
+	sub := marketBus.Subscribe("test", bus.Filter{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calculator := &exchangestest.Calculator{}
+	newUniswap(ctx, t, calculator, marketBus)
+
+	quotes := make(map[string]*domain.Data)
+	for i := 0; i < 2; i++ {
+		data := exchangestest.Next(t, sub)
+		quotes[data.Pair] = data
+	}
+
+	// v2: сделка на 1000 USDC с комиссией 0.3% и проскальзыванием по резервам
+	v2 := quotes["UNI_USDC"]
+	if v2 == nil {
+		t.Fatal("no UNI_USDC quote")
+	}
+	approx(t, "UNI_USDC bid", v2.Bid, 4.975079691095955)
+	approx(t, "UNI_USDC bid quantity", v2.BidQuantity, 200)
+	approx(t, "UNI_USDC ask", v2.Ask, 5.025045135406219)
+	approx(t, "UNI_USDC ask quantity", v2.AskQuantity, 199.00318764383819)
+
+	// v3: виртуальные резервы по sqrtPriceX96 и ликвидности, комиссия пула из контракта
+	v3 := quotes["WETH_USDC"]
+	if v3 == nil {
+		t.Fatal("no WETH_USDC quote")
+	}
+	approx(t, "WETH_USDC bid", v3.Bid, 1998.9553243491076)
+	approx(t, "WETH_USDC bid quantity", v3.BidQuantity, 0.5)
+	approx(t, "WETH_USDC ask", v3.Ask, 2001.0452216096751)
+	approx(t, "WETH_USDC ask quantity", v3.AskQuantity, 0.4997388310872769)
+
+	// газ 20 gwei: 180000 газа на обмен v3 и 150000 на обмен v2 по цене WETH 2000 USDC от сделки на 1000 USDC
+	for pair, want := range map[string]float64{"WETH_USDC": 0.72, "UNI_USDC": 0.6} {
+		fee, ok := calculator.Fee("uniswap", pair)
+		if !ok {
+			t.Errorf("no gas fee for %s", pair)
+			continue
+		}
+
+		approx(t, pair+" maker fee", fee.Maker, want)
+		approx(t, pair+" taker fee", fee.Taker, want)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := newUniswap(ctx, t, &exchangestest.Calculator{}, bus.New(nil))
+
+	for pair, want := range map[string]float64{"WETH_USDC": 2000, "UNI_USDC": 5} {
+		price, err := e.Price(ctx, pair)
+		if err != nil {
+			t.Fatalf("%s: %v", pair, err)
+		}
+
+		approx(t, pair+" price", price, want)
+	}
+
+	if _, err := e.Price(ctx, "LINK_USDC"); err != errPoolNotConfigured {
+		t.Errorf("err = %v, want %v", err, errPoolNotConfigured)
+	}
+}
+
+func approx(t *testing.T, name string, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+// words кодирует значения в результат eth_call по 32 байта на значение
+func words(values ...*big.Int) string {
+	var b strings.Builder
+	b.WriteString("0x")
+	for _, value := range values {
+		_, _ = fmt.Fprintf(&b, "%064x", value)
+	}
+
+	return b.String()
+}
+
+func number(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func hexNumber(s string) *big.Int {
+	n, _ := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	return n
+}
@@ -0,0 +1,83 @@
+// Package funding дополняет котировки бессрочных фьючерсов ставками финансирования.
+package funding
+
+import (
+	"calc/internal/domain"
+	"sync"
+	"time"
+)
+
+// Rate ставка финансирования пары. FundingRate за период Interval в процентах.
+type Rate struct {
+	MarkPrice       float64
+	FundingRate     float64
+	Interval        time.Duration
+	NextFundingTime time.Time
+}
+
+// Tracker хранит последние ставки финансирования и котировки фьючерсов биржи.
+// Котировки передаются в onData с типом рынка perp и текущей ставкой, вызовы onData последовательны.
+type Tracker struct {
+	onData func(data *domain.Data)
+
+	mu    sync.Mutex
+	rates map[string]*Rate
+	last  map[string]*domain.Data
+}
+
+func NewTracker(onData func(data *domain.Data)) *Tracker {
+	return &Tracker{
+		onData: onData,
+		rates:  make(map[string]*Rate),
+		last:   make(map[string]*domain.Data),
+	}
+}
+
+// Save дополняет котировку фьючерса ставкой финансирования, используется как onData менеджера стаканов
+func (t *Tracker) Save(data *domain.Data) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data.Market = domain.MarketPerp
+	apply(data, t.rates[data.Pair])
+	t.last[data.Pair] = data
+
+	t.onData(data)
+}
+
+// Update обновляет ставку пары. Если изменилась ставка или время следующего начисления,
+// последняя котировка передается повторно с новой ставкой.
+func (t *Tracker) Update(pair string, rate *Rate) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous := t.rates[pair]
+	t.rates[pair] = rate
+
+	if previous != nil && previous.FundingRate == rate.FundingRate && previous.NextFundingTime.Equal(rate.NextFundingTime) {
+		return
+	}
+
+	last, ok := t.last[pair]
+	if !ok {
+		return
+	}
+
+	data := *last
+	data.Time = time.Now().UTC()
+	apply(&data, rate)
+	t.last[pair] = &data
+
+	t.onData(&data)
+}
+
+func apply(data *domain.Data, rate *Rate) {
+	if rate == nil {
+		return
+	}
+
+	data.MarkPrice = rate.MarkPrice
+	data.FundingRate = rate.FundingRate
+	data.FundingInterval = rate.Interval
+	data.NextFundingTime = rate.NextFundingTime
+}
@@ -0,0 +1,92 @@
+// Package jsonrpc клиент JSON-RPC 2.0 поверх HTTP, например для узлов EVM-сетей.
+package jsonrpc
+
+import (
+	"bytes"
+	"calc/internal/adapters/client"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Error ошибка, которую вернул узел
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int64         `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+type Client struct {
+	url        string
+	httpClient client.HTTPClient
+	// requestID номер последнего запроса
+	requestID int64
+}
+
+func NewClient(url string, httpClient client.HTTPClient) *Client {
+	return &Client{
+		url:        url,
+		httpClient: httpClient,
+	}
+}
+
+// Call вызывает метод и декодирует результат в result
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(&request{
+		JSONRPC: "2.0",
+		ID:      atomic.AddInt64(&c.requestID, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Post(ctx, c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jsonrpc %s: unexpected status %d", method, resp.StatusCode)
+	}
+
+	var rpcResponse response
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResponse); err != nil {
+		return err
+	}
+
+	if rpcResponse.Error != nil {
+		return rpcResponse.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(rpcResponse.Result, result)
+}
@@ -0,0 +1,157 @@
+// Package orderbook ведет локальные стаканы бирж: применяет снапшоты и дельты,
+// проверяет непрерывность номеров обновлений и при расхождении
+// заново загружает снапшот по REST.
+package orderbook
+
+import (
+	"calc/internal/domain"
+	"errors"
+	"sort"
+)
+
+// maxLevels ограничивает число уровней на сторону: дельты добавляют уровни за пределами снапшота
+const maxLevels = 1000
+
+var (
+	errGap     = errors.New("update sequence gap")
+	errCrossed = errors.New("crossed book")
+)
+
+// Snapshot полный стакан, ID - номер последнего вошедшего в него обновления, 0 если биржа их не передает
+type Snapshot struct {
+	ID   uint64
+	Bids []domain.PriceLevel
+	Asks []domain.PriceLevel
+}
+
+// Update дельта стакана с номерами первого и последнего обновления.
+// Уровень с нулевым объемом удаляется.
+type Update struct {
+	FirstID uint64
+	LastID  uint64
+	Bids    []domain.PriceLevel
+	Asks    []domain.PriceLevel
+}
+
+// side уровни одной стороны стакана, отсортированные от лучшей цены к худшей
+type side struct {
+	levels []domain.PriceLevel
+	desc   bool
+}
+
+func (s *side) load(levels []domain.PriceLevel) {
+	s.levels = s.levels[:0]
+	for _, level := range levels {
+		s.set(level)
+	}
+}
+
+func (s *side) set(level domain.PriceLevel) {
+	i := sort.Search(len(s.levels), func(i int) bool {
+		if s.desc {
+			return s.levels[i].Price <= level.Price
+		}
+		return s.levels[i].Price >= level.Price
+	})
+
+	exists := i < len(s.levels) && s.levels[i].Price == level.Price
+	switch {
+	case level.Quantity == 0 && exists:
+		s.levels = append(s.levels[:i], s.levels[i+1:]...)
+	case level.Quantity == 0:
+	case exists:
+		s.levels[i].Quantity = level.Quantity
+	default:
+		s.levels = append(s.levels, domain.PriceLevel{})
+		copy(s.levels[i+1:], s.levels[i:])
+		s.levels[i] = level
+	}
+
+	if len(s.levels) > maxLevels {
+		s.levels = s.levels[:maxLevels]
+	}
+}
+
+// top возвращает копию лучших depth уровней
+func (s *side) top(depth int) []domain.PriceLevel {
+	if depth <= 0 || depth > len(s.levels) {
+		depth = len(s.levels)
+	}
+
+	return append([]domain.PriceLevel(nil), s.levels[:depth]...)
+}
+
+// book локальный стакан пары
+type book struct {
+	pair   string
+	bids   side
+	asks   side
+	lastID uint64
+	// synced стакан загружен и применяет дельты
+	synced bool
+	// syncing идет загрузка снапшота
+	syncing bool
+	// generation меняется при сбросе, чтобы отбросить результат загрузки, начатой до него
+	generation uint64
+	// pending дельты, пришедшие до загрузки снапшота
+	pending []*Update
+	// last последние отданные данные, чтобы не отдавать неизменившийся стакан
+	last *domain.Data
+	// out данные, которые еще не отданы в onData
+	out *domain.Data
+	// emitting горутина отдает данные стакана в onData и заберет out после текущего вызова
+	emitting bool
+}
+
+func newBook(pair string) *book {
+	return &book{
+		pair: pair,
+		bids: side{desc: true},
+		asks: side{},
+	}
+}
+
+func (b *book) load(snapshot *Snapshot) {
+	b.bids.load(snapshot.Bids)
+	b.asks.load(snapshot.Asks)
+	b.lastID = snapshot.ID
+}
+
+// apply применяет дельту. Дельты, целиком вошедшие в стакан, пропускаются.
+// Первая дельта после снапшота может начинаться раньше его номера, но должна его перекрывать.
+func (b *book) apply(u *Update) error {
+	if u.LastID != 0 {
+		if u.LastID <= b.lastID {
+			return nil
+		}
+
+		if u.FirstID > b.lastID+1 {
+			return errGap
+		}
+	}
+
+	for _, level := range u.Bids {
+		b.bids.set(level)
+	}
+	for _, level := range u.Asks {
+		b.asks.set(level)
+	}
+	b.lastID = u.LastID
+
+	if len(b.bids.levels) > 0 && len(b.asks.levels) > 0 && b.bids.levels[0].Price >= b.asks.levels[0].Price {
+		return errCrossed
+	}
+
+	return nil
+}
+
+// reset помечает стакан несинхронизированным и отменяет идущую загрузку
+func (b *book) reset() {
+	b.synced = false
+	b.syncing = false
+	b.generation++
+	b.pending = nil
+	b.bids.load(nil)
+	b.asks.load(nil)
+	b.lastID = 0
+}
@@ -0,0 +1,292 @@
+package orderbook
+
+import (
+	"calc/internal/domain"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPending ограничивает число дельт, накопленных за время загрузки снапшота
+	maxPending = 1000
+	// maxConcurrentSnapshots ограничивает число одновременных запросов снапшотов, чтобы не упереться в лимиты API
+	maxConcurrentSnapshots = 5
+	retryInterval          = time.Second
+)
+
+var (
+	promResyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "orderbook_resyncs_total",
+		Help:      "order book snapshot loads by reason: initial, gap, crossed",
+	}, []string{"exchange", "reason"})
+	promRegister sync.Once
+)
+
+// SnapshotFunc загружает снапшот стакана пары по REST
+type SnapshotFunc func(ctx context.Context, pair string) (*Snapshot, error)
+
+// Manager ведет стаканы пар одной биржи. Изменившиеся лучшие depth уровни отдаются в onData
+// без блокировки стаканов. Вызовы onData по одной паре последовательны, а уровни, изменившиеся
+// за время вызова, отдаются следующим вызовом только последними.
+type Manager struct {
+	ctx      context.Context
+	exchange string
+	depth    int
+	fetch    SnapshotFunc
+	onData   func(data *domain.Data)
+	logger   *zerolog.Logger
+	// snapshots семафор запросов снапшотов
+	snapshots chan struct{}
+
+	mu    sync.Mutex
+	books map[string]*book
+}
+
+func NewManager(
+	ctx context.Context,
+	exchange string,
+	depth int,
+	fetch SnapshotFunc,
+	onData func(data *domain.Data),
+) *Manager {
+	promRegister.Do(func() {
+		prometheus.MustRegister(promResyncs)
+	})
+
+	managerLogger := log.Logger.With().Str("logger", "orderbook").Str("exchange", exchange).Logger()
+
+	return &Manager{
+		ctx:       ctx,
+		exchange:  exchange,
+		depth:     depth,
+		fetch:     fetch,
+		onData:    onData,
+		logger:    &managerLogger,
+		snapshots: make(chan struct{}, maxConcurrentSnapshots),
+		books:     make(map[string]*book),
+	}
+}
+
+// Update применяет дельту стакана пары. До загрузки снапшота и после разрыва последовательности
+// дельты копятся, а снапшот загружается в фоне. Возвращает, синхронизирован ли стакан.
+func (m *Manager) Update(pair string, u *Update) bool {
+	m.mu.Lock()
+	b := m.book(pair)
+	synced := m.update(b, u)
+	emit := synced && m.emit(b)
+	m.mu.Unlock()
+
+	if emit {
+		m.deliver(b)
+	}
+
+	return synced
+}
+
+func (m *Manager) update(b *book, u *Update) bool {
+	if !b.synced {
+		m.enqueue(b, u)
+		if !b.syncing {
+			m.resync(b, "initial")
+		}
+		return false
+	}
+
+	if err := b.apply(u); err != nil {
+		m.logger.Warn().Err(err).Str("pair", b.pair).Uint64("last_id", b.lastID).Uint64("first_id", u.FirstID).Msg("resync order book")
+
+		b.reset()
+		m.enqueue(b, u)
+		m.resync(b, reason(err))
+		return false
+	}
+
+	return true
+}
+
+// Snapshot заменяет стакан пары снапшотом, для бирж, которые присылают стакан целиком
+func (m *Manager) Snapshot(pair string, snapshot *Snapshot) {
+	m.mu.Lock()
+	b := m.book(pair)
+	b.reset()
+	b.load(snapshot)
+	b.synced = true
+	emit := m.emit(b)
+	m.mu.Unlock()
+
+	if emit {
+		m.deliver(b)
+	}
+}
+
+// Reset сбрасывает все стаканы, вызывается при переподключении: номера обновлений нового соединения
+// не продолжают старые
+func (m *Manager) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, b := range m.books {
+		b.reset()
+	}
+}
+
+// Remove удаляет стакан пары после отписки, начатая загрузка снапшота будет отброшена
+func (m *Manager) Remove(pair string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.books[pair]; ok {
+		b.reset()
+		delete(m.books, pair)
+	}
+}
+
+func (m *Manager) book(pair string) *book {
+	b, ok := m.books[pair]
+	if !ok {
+		b = newBook(pair)
+		m.books[pair] = b
+	}
+
+	return b
+}
+
+func (m *Manager) enqueue(b *book, u *Update) {
+	if len(b.pending) >= maxPending {
+		b.pending = b.pending[1:]
+	}
+
+	b.pending = append(b.pending, u)
+}
+
+// resync загружает снапшот в фоне и применяет к нему накопленные дельты, повторяя загрузку до успеха
+func (m *Manager) resync(b *book, reason string) {
+	promResyncs.WithLabelValues(m.exchange, reason).Inc()
+
+	b.syncing = true
+	generation := b.generation
+
+	go func() {
+		for {
+			snapshot, err := m.loadSnapshot(b.pair)
+			if m.ctx.Err() != nil {
+				return
+			}
+
+			if err == nil && m.sync(b, generation, snapshot) {
+				return
+			}
+
+			if err != nil {
+				m.logger.Error().Stack().Err(err).Str("pair", b.pair).Msg("failed to load order book snapshot")
+			}
+
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+	}()
+}
+
+func (m *Manager) loadSnapshot(pair string) (*Snapshot, error) {
+	select {
+	case m.snapshots <- struct{}{}:
+	case <-m.ctx.Done():
+		return nil, m.ctx.Err()
+	}
+	defer func() { <-m.snapshots }()
+
+	return m.fetch(m.ctx, pair)
+}
+
+// sync применяет снапшот и накопленные дельты. Возвращает false, если снапшот старше накопленных дельт
+// и его нужно загрузить заново.
+func (m *Manager) sync(b *book, generation uint64, snapshot *Snapshot) bool {
+	m.mu.Lock()
+	done := m.apply(b, generation, snapshot)
+	emit := done && b.synced && m.emit(b)
+	m.mu.Unlock()
+
+	if emit {
+		m.deliver(b)
+	}
+
+	return done
+}
+
+func (m *Manager) apply(b *book, generation uint64, snapshot *Snapshot) bool {
+	// стакан сброшен во время загрузки, новую загрузку запустит следующая дельта
+	if b.generation != generation {
+		return true
+	}
+
+	b.load(snapshot)
+	for _, u := range b.pending {
+		if err := b.apply(u); err != nil {
+			m.logger.Warn().Err(err).Str("pair", b.pair).Uint64("snapshot_id", snapshot.ID).Msg("snapshot does not match pending updates")
+
+			promResyncs.WithLabelValues(m.exchange, reason(err)).Inc()
+			// накопленные дельты остаются: они понадобятся для следующего снапшота
+			return false
+		}
+	}
+
+	b.pending = nil
+	b.synced = true
+	b.syncing = false
+
+	return true
+}
+
+// emit запоминает изменившиеся лучшие уровни стакана для onData, вызывается под m.mu.
+// Возвращает true, если отдать их должен вызывающий через deliver: другая горутина уже отдает
+// данные стакана и сама заберет новые уровни.
+func (m *Manager) emit(b *book) bool {
+	data := domain.NewData(m.exchange, b.pair, b.bids.top(m.depth), b.asks.top(m.depth))
+	if data.Equal(b.last) {
+		return false
+	}
+	b.last = data
+	b.out = data
+
+	if b.emitting {
+		return false
+	}
+	b.emitting = true
+
+	return true
+}
+
+// deliver отдает в onData уровни стакана без m.mu, пока они меняются во время вызовов
+func (m *Manager) deliver(b *book) {
+	m.mu.Lock()
+	for b.out != nil {
+		data := b.out
+		b.out = nil
+		m.mu.Unlock()
+
+		m.onData(data)
+
+		m.mu.Lock()
+	}
+	b.emitting = false
+	m.mu.Unlock()
+}
+
+func reason(err error) string {
+	switch err {
+	case errGap:
+		return "gap"
+	case errCrossed:
+		return "crossed"
+	}
+
+	return "unknown"
+}
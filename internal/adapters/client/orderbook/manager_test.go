@@ -0,0 +1,166 @@
+package orderbook
+
+import (
+	"calc/internal/domain"
+	"context"
+	"testing"
+	"time"
+)
+
+const (
+	testPair    = "BTC_USDT"
+	testTimeout = 5 * time.Second
+)
+
+// harness отвечает на запросы снапшотов по команде теста и собирает отданные уровни
+type harness struct {
+	requests chan string
+	replies  chan *Snapshot
+	data     chan *domain.Data
+}
+
+func newHarness(t *testing.T) (*Manager, *harness) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	h := &harness{
+		requests: make(chan string, 10),
+		replies:  make(chan *Snapshot),
+		data:     make(chan *domain.Data, 10),
+	}
+
+	fetch := func(ctx context.Context, pair string) (*Snapshot, error) {
+		h.requests <- pair
+
+		select {
+		case snapshot := <-h.replies:
+			return snapshot, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	m := NewManager(ctx, "test", 1, fetch, func(data *domain.Data) {
+		h.data <- data
+	})
+
+	return m, h
+}
+
+// request ждет запроса снапшота
+func (h *harness) request(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-h.requests:
+	case <-time.After(testTimeout):
+		t.Fatal("no snapshot request")
+	}
+}
+
+// snapshot ждет запроса снапшота и отвечает на него
+func (h *harness) snapshot(t *testing.T, snapshot *Snapshot) {
+	t.Helper()
+
+	h.request(t)
+	h.replies <- snapshot
+}
+
+// next ждет следующих отданных уровней
+func (h *harness) next(t *testing.T) *domain.Data {
+	t.Helper()
+
+	select {
+	case data := <-h.data:
+		return data
+	case <-time.After(testTimeout):
+		t.Fatal("no data")
+		return nil
+	}
+}
+
+func level(price, quantity float64) []domain.PriceLevel {
+	return []domain.PriceLevel{{Price: price, Quantity: quantity}}
+}
+
+func TestManagerSync(t *testing.T) {
+	tests := []struct {
+		name string
+		// run доводит стакан до синхронизации, следующие отданные уровни сравниваются с want
+		run  func(t *testing.T, m *Manager, h *harness)
+		want [4]float64
+	}{
+		{
+			name: "deltas before snapshot",
+			run: func(t *testing.T, m *Manager, h *harness) {
+				m.Update(testPair, &Update{FirstID: 1, LastID: 1, Bids: level(99, 1)})
+				m.Update(testPair, &Update{FirstID: 2, LastID: 3, Asks: level(101, 2)})
+				m.Update(testPair, &Update{FirstID: 4, LastID: 4, Bids: level(100, 3)})
+
+				// дельта 1 уже вошла в снапшот, 2-3 перекрывает его номер
+				h.snapshot(t, &Snapshot{ID: 2, Bids: level(98, 1), Asks: level(102, 1)})
+			},
+			want: [4]float64{100, 3, 101, 2},
+		},
+		{
+			name: "snapshot older than deltas",
+			run: func(t *testing.T, m *Manager, h *harness) {
+				m.Update(testPair, &Update{FirstID: 10, LastID: 11, Bids: level(100, 1)})
+
+				// между снапшотом 5 и дельтой 10 пропущены обновления: снапшот загружается заново
+				h.snapshot(t, &Snapshot{ID: 5, Bids: level(97, 1), Asks: level(102, 1)})
+				h.snapshot(t, &Snapshot{ID: 10, Bids: level(99, 1), Asks: level(102, 1)})
+			},
+			want: [4]float64{100, 1, 102, 1},
+		},
+		{
+			name: "sequence gap",
+			run: func(t *testing.T, m *Manager, h *harness) {
+				m.Update(testPair, &Update{FirstID: 1, LastID: 1})
+				h.snapshot(t, &Snapshot{ID: 1, Bids: level(99, 1), Asks: level(101, 1)})
+				h.next(t)
+
+				if m.Update(testPair, &Update{FirstID: 5, LastID: 5, Bids: level(100, 2)}) {
+					t.Error("update after gap is applied")
+				}
+
+				h.snapshot(t, &Snapshot{ID: 4, Bids: level(98, 1), Asks: level(101, 1)})
+			},
+			want: [4]float64{100, 2, 101, 1},
+		},
+		{
+			name: "reset during resync",
+			run: func(t *testing.T, m *Manager, h *harness) {
+				m.Update(testPair, &Update{FirstID: 1, LastID: 1, Bids: level(99, 1)})
+				h.request(t)
+
+				// снапшот, загруженный до переподключения, отбрасывается
+				m.Reset()
+				h.replies <- &Snapshot{ID: 1, Bids: level(99, 1), Asks: level(101, 1)}
+
+				m.Update(testPair, &Update{FirstID: 2, LastID: 2, Bids: level(100, 1)})
+				h.snapshot(t, &Snapshot{ID: 1, Bids: level(98, 1), Asks: level(101, 1)})
+			},
+			want: [4]float64{100, 1, 101, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, h := newHarness(t)
+
+			tt.run(t, m, h)
+
+			data := h.next(t)
+			got := [4]float64{data.Bid, data.BidQuantity, data.Ask, data.AskQuantity}
+			if got != tt.want {
+				t.Errorf("got bid %v x %v, ask %v x %v, want bid %v x %v, ask %v x %v",
+					got[0], got[1], got[2], got[3], tt.want[0], tt.want[1], tt.want[2], tt.want[3])
+			}
+
+			if !m.Update(testPair, &Update{}) {
+				t.Error("book is not synced")
+			}
+		})
+	}
+}
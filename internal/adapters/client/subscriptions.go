@@ -0,0 +1,187 @@
+package client
+
+import (
+	"strings"
+	"sync"
+)
+
+// Subscriptions пары, на которые у адаптера биржи есть подписка, в порядке добавления.
+// Встраивается в адаптеры бирж, нулевое значение готово к работе.
+type Subscriptions struct {
+	mu    sync.RWMutex
+	pairs []string
+	// requests пары запросов подписки, на которые биржа еще не ответила, ключ - номер запроса
+	requests map[string][]string
+}
+
+// Register добавляет пару в подписку, возвращает false, если подписка уже есть
+func (s *Subscriptions) Register(pair string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.pairs {
+		if p == pair {
+			return false
+		}
+	}
+
+	s.pairs = append(s.pairs, pair)
+
+	return true
+}
+
+// Unregister убирает пару из подписки, возвращает false, если подписки не было
+func (s *Subscriptions) Unregister(pair string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.pairs {
+		if p == pair {
+			s.pairs = append(s.pairs[:i:i], s.pairs[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Subscriptions) Subscribed(pair string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.pairs {
+		if p == pair {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SubscribedPairs возвращает копию списка пар с подпиской
+func (s *Subscriptions) SubscribedPairs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.pairs...)
+}
+
+// Track запоминает пары запроса подписки до ответа биржи
+func (s *Subscriptions) Track(id string, pairs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.requests == nil {
+		s.requests = make(map[string][]string)
+	}
+
+	s.requests[id] = pairs
+}
+
+// Confirm забывает запрос подписки, на который биржа ответила успехом. Если переданы пары,
+// забываются только они: некоторые биржи отвечают отдельно на каждую пару запроса.
+func (s *Subscriptions) Confirm(id string, pairs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(pairs) == 0 {
+		delete(s.requests, id)
+		return
+	}
+
+	remaining := make([]string, 0, len(s.requests[id]))
+	for _, pair := range s.requests[id] {
+		if !containsPair(pairs, pair) {
+			remaining = append(remaining, pair)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(s.requests, id)
+		return
+	}
+
+	s.requests[id] = remaining
+}
+
+// Reject снимает с подписки пары отклоненного запроса и возвращает их. Если в тексте ошибки
+// назван символ пары отдельным словом, снимаются только названные пары, иначе все пары запроса.
+func (s *Subscriptions) Reject(id, reason string, native func(pair string) (string, error)) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pairs := s.requests[id]
+	delete(s.requests, id)
+
+	named := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		symbol, err := native(pair)
+		if err == nil && mentions(reason, symbol) {
+			named = append(named, pair)
+		}
+	}
+
+	if len(named) > 0 {
+		pairs = named
+	}
+
+	rejected := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		for i, p := range s.pairs {
+			if p == pair {
+				s.pairs = append(s.pairs[:i:i], s.pairs[i+1:]...)
+				rejected = append(rejected, pair)
+				break
+			}
+		}
+	}
+
+	return rejected
+}
+
+// Reset забывает запросы подписки прошлого соединения
+func (s *Subscriptions) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// mentions проверяет, что символ назван в тексте отдельным словом: символ BTCUSD не должен
+// находиться в тексте ошибки про BTCUSDT
+func mentions(text, symbol string) bool {
+	if symbol == "" {
+		return false
+	}
+
+	text, symbol = strings.ToLower(text), strings.ToLower(symbol)
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], symbol)
+		if i < 0 {
+			return false
+		}
+
+		start := offset + i
+		end := start + len(symbol)
+		if (start == 0 || !isSymbolChar(text[start-1])) && (end == len(text) || !isSymbolChar(text[end])) {
+			return true
+		}
+
+		offset = start + 1
+	}
+}
+
+// isSymbolChar буква или цифра, которые продолжают символ биржи
+func isSymbolChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+func containsPair(pairs []string, pair string) bool {
+	for _, p := range pairs {
+		if p == pair {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubscriptionsReject(t *testing.T) {
+	natives := map[string]string{
+		"BTC_USD":  "BTCUSD",
+		"BTC_USDT": "BTCUSDT",
+		"ETH_USDT": "ETHUSDT",
+	}
+
+	native := func(pair string) (string, error) {
+		if symbol, ok := natives[pair]; ok {
+			return symbol, nil
+		}
+
+		return "", errors.New("unknown pair")
+	}
+
+	tests := []struct {
+		name     string
+		reason   string
+		rejected []string
+	}{
+		{
+			name:     "named symbol",
+			reason:   "Invalid symbol :[orderbook.1.BTCUSDT]",
+			rejected: []string{"BTC_USDT"},
+		},
+		{
+			name:     "symbol is not a prefix of a longer symbol",
+			reason:   "invalid symbol btcusd",
+			rejected: []string{"BTC_USD"},
+		},
+		{
+			name:     "several named symbols",
+			reason:   "Invalid symbol :[orderbook.1.BTCUSD,orderbook.1.ETHUSDT]",
+			rejected: []string{"BTC_USD", "ETH_USDT"},
+		},
+		{
+			name:     "no symbol named",
+			reason:   "too many requests",
+			rejected: []string{"BTC_USD", "BTC_USDT", "ETH_USDT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Subscriptions
+			for _, pair := range []string{"BTC_USD", "BTC_USDT", "ETH_USDT"} {
+				s.Register(pair)
+			}
+
+			s.Track("1", "BTC_USD", "BTC_USDT", "ETH_USDT")
+
+			if got := s.Reject("1", tt.reason, native); !reflect.DeepEqual(got, tt.rejected) {
+				t.Errorf("rejected = %v, want %v", got, tt.rejected)
+			}
+
+			for _, pair := range tt.rejected {
+				if s.Subscribed(pair) {
+					t.Errorf("pair %s is still subscribed", pair)
+				}
+			}
+
+			if n := len(s.SubscribedPairs()); n != 3-len(tt.rejected) {
+				t.Errorf("subscribed pairs = %s", strings.Join(s.SubscribedPairs(), ","))
+			}
+		})
+	}
+}
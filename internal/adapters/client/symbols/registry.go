@@ -0,0 +1,159 @@
+// Package symbols сопоставляет канонические пары BASE_QUOTE с символами бирж.
+package symbols
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownPair = errors.New("pair is not traded on exchange")
+
+// Symbol символ биржи с ее кодами базовой валюты и валюты котировки
+type Symbol struct {
+	Native string
+	Base   string
+	Quote  string
+}
+
+// Registry символы одной биржи. Коды валют приводятся к каноническим по алиасам, например XBT - BTC.
+type Registry struct {
+	aliases map[string]string
+
+	mu sync.RWMutex
+	// native символ биржи по канонической паре
+	native map[string]string
+	// canonical каноническая пара по символу биржи
+	canonical map[string]string
+}
+
+// NewRegistry создает реестр, алиасы из следующих наборов переопределяют предыдущие
+func NewRegistry(aliases ...map[string]string) *Registry {
+	r := &Registry{
+		aliases:   make(map[string]string),
+		native:    make(map[string]string),
+		canonical: make(map[string]string),
+	}
+
+	for _, set := range aliases {
+		for alias, asset := range set {
+			r.aliases[strings.ToUpper(alias)] = strings.ToUpper(asset)
+		}
+	}
+
+	return r
+}
+
+// Asset возвращает канонический код валюты
+func (r *Registry) Asset(asset string) string {
+	asset = strings.ToUpper(asset)
+	if canonical, ok := r.aliases[asset]; ok {
+		return canonical
+	}
+
+	return asset
+}
+
+// Canonical возвращает каноническую пару по кодам валют биржи
+func (r *Registry) Canonical(base, quote string) string {
+	return r.Asset(base) + "_" + r.Asset(quote)
+}
+
+// Load заменяет символы биржи. Если несколько символов дают одну каноническую пару, остается первый.
+// Возвращает символы, отброшенные из-за совпадения.
+func (r *Registry) Load(symbols []Symbol) []string {
+	native := make(map[string]string, len(symbols))
+	canonical := make(map[string]string, len(symbols))
+
+	var duplicates []string
+	for _, s := range symbols {
+		pair := r.Canonical(s.Base, s.Quote)
+		if _, ok := native[pair]; ok {
+			duplicates = append(duplicates, s.Native)
+			continue
+		}
+
+		native[pair] = s.Native
+		canonical[s.Native] = pair
+	}
+
+	r.mu.Lock()
+	r.native = native
+	r.canonical = canonical
+	r.mu.Unlock()
+
+	return duplicates
+}
+
+// Pair возвращает каноническую пару по символу биржи
+func (r *Registry) Pair(native string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pair, ok := r.canonical[native]
+
+	return pair, ok
+}
+
+// Native возвращает символ биржи по канонической паре
+func (r *Registry) Native(pair string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	native, ok := r.native[pair]
+	if !ok {
+		return "", ErrUnknownPair
+	}
+
+	return native, nil
+}
+
+// Pairs возвращает все канонические пары биржи
+func (r *Registry) Pairs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pairs := make([]string, 0, len(r.native))
+	for pair := range r.native {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	return pairs
+}
+
+// Unmapped возвращает пары, которых нет на бирже
+func (r *Registry) Unmapped(pairs []string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var unmapped []string
+	for _, pair := range pairs {
+		if _, ok := r.native[pair]; !ok {
+			unmapped = append(unmapped, pair)
+		}
+	}
+
+	return unmapped
+}
+
+// FromPairs строит символы из канонических пар по правилу именования биржи,
+// используется, если биржа не отдала список символов
+func FromPairs(pairs []string, native func(base, quote string) string) []Symbol {
+	symbols := make([]Symbol, 0, len(pairs))
+	for _, pair := range pairs {
+		assets := strings.Split(pair, "_")
+		if len(assets) != 2 {
+			continue
+		}
+
+		symbols = append(symbols, Symbol{
+			Native: native(assets[0], assets[1]),
+			Base:   assets[0],
+			Quote:  assets[1],
+		})
+	}
+
+	return symbols
+}
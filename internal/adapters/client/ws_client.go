@@ -0,0 +1,339 @@
+package client
+
+import (
+	"calc/common/config"
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPingInterval = 15 * time.Second
+	defaultReadTimeout  = 30 * time.Second
+	defaultMinBackoff   = time.Second
+	defaultMaxBackoff   = time.Minute
+	wsWriteTimeout      = 10 * time.Second
+)
+
+var (
+	ErrWSNotConnected = errors.New("websocket is not connected")
+
+	promWSState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "ws_state",
+		Help:      "websocket connection state: 0 idle, 1 connecting, 2 subscribing, 3 connected, 4 reconnecting, 5 closed",
+	}, []string{"exchange"})
+	promWSReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "ws_reconnects_total",
+		Help:      "websocket reconnects",
+	}, []string{"exchange"})
+	promWSRegister sync.Once
+)
+
+// WSState состояние соединения
+type WSState int32
+
+const (
+	WSStateIdle WSState = iota
+	WSStateConnecting
+	WSStateSubscribing
+	WSStateConnected
+	WSStateReconnecting
+	WSStateClosed
+)
+
+func (s WSState) String() string {
+	switch s {
+	case WSStateIdle:
+		return "idle"
+	case WSStateConnecting:
+		return "connecting"
+	case WSStateSubscribing:
+		return "subscribing"
+	case WSStateConnected:
+		return "connected"
+	case WSStateReconnecting:
+		return "reconnecting"
+	case WSStateClosed:
+		return "closed"
+	}
+
+	return "unknown"
+}
+
+// WSConn соединение, через которое отправляются подписки
+type WSConn interface {
+	WriteJSON(v interface{}) error
+	// WriteMessage отправляет текстовое сообщение как есть
+	WriteMessage(data []byte) error
+}
+
+// WSHandler обработчики соединения биржи
+type WSHandler struct {
+	// Subscribe отправляет подписки после каждого подключения
+	Subscribe func(conn WSConn) error
+	// Handle разбирает сообщение, ошибка приводит к переподключению
+	Handle func(message []byte) error
+	// Endpoint возвращает адрес для очередного подключения, если он выдается биржей на время, например с токеном.
+	// Если не задан, используется url клиента.
+	Endpoint func(ctx context.Context) (string, error)
+	// Ping отправляет прикладной ping вместе с ping фреймом, для бирж, которые требуют его в сообщениях
+	Ping func(conn WSConn) error
+}
+
+// WSClient держит websocket соединение с биржей: переподключается с экспоненциальной задержкой и джиттером,
+// отправляет ping, разрывает соединение без сообщений дольше таймаута чтения и заново подписывается
+type WSClient struct {
+	name         string
+	url          string
+	handler      WSHandler
+	logger       *zerolog.Logger
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	state        int32
+
+	// mu защищает conn и запись в него
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func NewWSClient(name, url string, handler WSHandler, cfg *config.Websocket) *WSClient {
+	if cfg == nil {
+		cfg = &config.Websocket{}
+	}
+
+	promWSRegister.Do(func() {
+		prometheus.MustRegister(promWSState, promWSReconnects)
+	})
+
+	wsLogger := log.Logger.With().Str("logger", "ws_client").Str("exchange", name).Logger()
+
+	return &WSClient{
+		name:         name,
+		url:          url,
+		handler:      handler,
+		logger:       &wsLogger,
+		pingInterval: durationOr(cfg.PingInterval, defaultPingInterval),
+		readTimeout:  durationOr(cfg.ReadTimeout, defaultReadTimeout),
+		minBackoff:   durationOr(cfg.MinBackoff, defaultMinBackoff),
+		maxBackoff:   durationOr(cfg.MaxBackoff, defaultMaxBackoff),
+	}
+}
+
+func (c *WSClient) State() WSState {
+	return WSState(atomic.LoadInt32(&c.state))
+}
+
+// Run держит соединение до отмены контекста
+func (c *WSClient) Run(ctx context.Context) {
+	defer c.setState(WSStateClosed)
+
+	attempt := 0
+	for {
+		if attempt > 0 {
+			c.setState(WSStateReconnecting)
+			promWSReconnects.WithLabelValues(c.name).Inc()
+
+			if !sleep(ctx, c.backoff(attempt)) {
+				return
+			}
+		}
+
+		received, err := c.connect(ctx)
+		if ctx.Err() != nil {
+			c.logger.Info().Msgf("connection closed %v", ctx.Err())
+			return
+		}
+
+		c.logger.Error().Stack().Err(err).Int("attempt", attempt).Msg("connection lost")
+
+		// задержка растет только пока соединение не получает сообщений
+		if received {
+			attempt = 1
+		} else {
+			attempt++
+		}
+	}
+}
+
+// WriteJSON отправляет сообщение в текущее соединение
+func (c *WSClient) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return ErrWSNotConnected
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+
+	return c.conn.WriteJSON(v)
+}
+
+// WriteMessage отправляет текстовое сообщение в текущее соединение
+func (c *WSClient) WriteMessage(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return ErrWSNotConnected
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// connect подключается, подписывается и читает сообщения до ошибки.
+// Возвращает, было ли получено хотя бы одно сообщение.
+func (c *WSClient) connect(ctx context.Context) (bool, error) {
+	c.setState(WSStateConnecting)
+
+	endpoint := c.url
+	if c.handler.Endpoint != nil {
+		var err error
+		if endpoint, err = c.handler.Endpoint(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	if err := conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+		return false, err
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	})
+
+	c.setState(WSStateSubscribing)
+
+	if err := c.handler.Subscribe(c); err != nil {
+		return false, err
+	}
+
+	c.setState(WSStateConnected)
+
+	go c.keepalive(connCtx, conn)
+
+	received := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		if err := conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return received, err
+		}
+
+		if err := c.handler.Handle(message); err != nil {
+			return received, err
+		}
+	}
+}
+
+// keepalive отправляет ping и закрывает соединение при отмене контекста, чтобы прервать чтение
+func (c *WSClient) keepalive(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			c.mu.Unlock()
+
+			_ = conn.Close()
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			c.mu.Unlock()
+
+			if err == nil && c.handler.Ping != nil {
+				err = c.handler.Ping(c)
+			}
+
+			if err != nil {
+				c.logger.Error().Stack().Err(err).Msg("failed to send ping")
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// backoff экспоненциальная задержка перед попыткой attempt со случайной половиной
+func (c *WSClient) backoff(attempt int) time.Duration {
+	d := c.minBackoff
+	for i := 1; i < attempt && d < c.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+
+	half := int64(d / 2)
+
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func (c *WSClient) setState(state WSState) {
+	atomic.StoreInt32(&c.state, int32(state))
+	promWSState.WithLabelValues(c.name).Set(float64(state))
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func durationOr(value, def time.Duration) time.Duration {
+	if value <= 0 {
+		return def
+	}
+
+	return value
+}
@@ -16,4 +16,9 @@ type DB interface {
 	PhoneConfirmation() PhoneConfirmationRepo
 	RefreshToken() RefreshTokenRepo
 	Arbitrage() ArbitrageRepo
+	TriangularArbitrage() TriangularArbitrageRepo
+	Carry() CarryRepo
+	Route() RouteRepo
+	Opportunity() OpportunityRepo
+	Candle() CandleRepo
 }
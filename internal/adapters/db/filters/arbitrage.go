@@ -1,13 +1,274 @@
 package filters
 
+import (
+	"calc/internal/domain"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type ArbitrageSortBy string
 
 const (
-	ArbitrageSortByProfit ArbitrageSortBy = "profit"
+	ArbitrageSortByProfit    ArbitrageSortBy = "profit"
+	ArbitrageSortByNetProfit ArbitrageSortBy = "net_profit"
+	ArbitrageSortByVolume    ArbitrageSortBy = "max_volume"
+	ArbitrageSortByUpdatedAt ArbitrageSortBy = "updated_at"
+	ArbitrageSortByPair      ArbitrageSortBy = "pair"
 )
 
+// NewArbitrageSortBy transforms string => ArbitrageSortBy with enum value check
+func NewArbitrageSortBy(str string) (ArbitrageSortBy, error) {
+	sb := ArbitrageSortBy(str)
+	switch sb {
+	case ArbitrageSortByProfit, ArbitrageSortByNetProfit, ArbitrageSortByVolume, ArbitrageSortByUpdatedAt, ArbitrageSortByPair:
+		return sb, nil
+	}
+
+	return "", fmt.Errorf("invalid ArbitrageSortBy enum %q", str)
+}
+
 type ArbitrageParams struct {
-	Limit   uint
+	Limit  uint
+	Offset uint
+	Pair   string
+	// Base и Quote базовая валюта и валюта котировки пары
+	Base  string
+	Quote string
+	// Exchange биржа покупки или продажи
+	Exchange string
+	// Exchanges биржи, на которых должны быть и покупка, и продажа
+	Exchanges []string
+	// ExcludeExchanges биржи, которых не должно быть ни в покупке, ни в продаже
+	ExcludeExchanges []string
+	// MinProfit и MaxProfit границы чистой прибыли в процентах, nil - без ограничения
+	MinProfit *float64
+	MaxProfit *float64
+	// MinVolume минимальный прибыльный объем в базовой валюте, nil - без ограничения
+	MinVolume *float64
+	// UpdatedSince комбинации, пересчитанные не раньше этого времени, нулевое - без ограничения
+	UpdatedSince time.Time
+	// After курсор: комбинации после последней комбинации предыдущей страницы
+	After   *ArbitrageCursor
 	SortBy  ArbitrageSortBy
 	SortDir SortDirection
 }
+
+// Match подходит ли комбинация под условия фильтра и находится ли она после курсора, без учета Offset и Limit
+func (p ArbitrageParams) Match(arbitrage *domain.Arbitrage) bool {
+	if p.Pair != "" && arbitrage.Pair != p.Pair {
+		return false
+	}
+
+	if p.Base != "" && !strings.HasPrefix(arbitrage.Pair, p.Base+"_") {
+		return false
+	}
+
+	if p.Quote != "" && !strings.HasSuffix(arbitrage.Pair, "_"+p.Quote) {
+		return false
+	}
+
+	if p.Exchange != "" && arbitrage.BuyExchange != p.Exchange && arbitrage.SellExchange != p.Exchange {
+		return false
+	}
+
+	if len(p.Exchanges) > 0 && (!contains(p.Exchanges, arbitrage.BuyExchange) || !contains(p.Exchanges, arbitrage.SellExchange)) {
+		return false
+	}
+
+	if contains(p.ExcludeExchanges, arbitrage.BuyExchange) || contains(p.ExcludeExchanges, arbitrage.SellExchange) {
+		return false
+	}
+
+	if p.MinProfit != nil && arbitrage.NetProfit < *p.MinProfit {
+		return false
+	}
+
+	if p.MaxProfit != nil && arbitrage.NetProfit > *p.MaxProfit {
+		return false
+	}
+
+	if p.MinVolume != nil && arbitrage.MaxVolume < *p.MinVolume {
+		return false
+	}
+
+	if !p.UpdatedSince.IsZero() && arbitrage.UpdatedAt.Before(p.UpdatedSince) {
+		return false
+	}
+
+	if p.After != nil && p.CompareCursor(arbitrage, p.After) <= 0 {
+		return false
+	}
+
+	return true
+}
+
+// Less идет ли комбинация a раньше b в порядке сортировки.
+// При равенстве поля сортировки порядок определяют пара, биржа покупки и биржа продажи.
+func (p ArbitrageParams) Less(a, b *domain.Arbitrage) bool {
+	return p.Compare(a, b) < 0
+}
+
+// Compare сравнивает позиции комбинаций в порядке сортировки: -1 - a раньше b, 0 - одна и та же комбинация
+func (p ArbitrageParams) Compare(a, b *domain.Arbitrage) int {
+	return direct(p.SortDir, CompareArbitrage(p.SortBy, a, b))
+}
+
+// CompareCursor сравнивает позицию комбинации с курсором: -1 - комбинация раньше курсора
+func (p ArbitrageParams) CompareCursor(arbitrage *domain.Arbitrage, c *ArbitrageCursor) int {
+	return direct(p.SortDir, CompareArbitrageCursor(p.SortBy, arbitrage, c))
+}
+
+// CompareArbitrage сравнивает комбинации по возрастанию поля sortBy, при равенстве - пары и бирж
+func CompareArbitrage(sortBy ArbitrageSortBy, a, b *domain.Arbitrage) int {
+	return compareKeys(sortBy, newArbitrageKey(sortBy, a), newArbitrageKey(sortBy, b))
+}
+
+// CompareArbitrageCursor сравнивает комбинацию с курсором по возрастанию поля sortBy
+func CompareArbitrageCursor(sortBy ArbitrageSortBy, arbitrage *domain.Arbitrage, c *ArbitrageCursor) int {
+	return compareKeys(sortBy, newArbitrageKey(sortBy, arbitrage), arbitrageKey{
+		value:        c.Value,
+		updatedAt:    c.UpdatedAt,
+		pair:         c.Pair,
+		buyExchange:  c.BuyExchange,
+		sellExchange: c.SellExchange,
+	})
+}
+
+// Cursor возвращает курсор, указывающий на комбинацию в порядке сортировки
+func (p ArbitrageParams) Cursor(arbitrage *domain.Arbitrage) *ArbitrageCursor {
+	k := newArbitrageKey(p.SortBy, arbitrage)
+
+	return &ArbitrageCursor{
+		SortBy:       p.SortBy,
+		SortDir:      p.SortDir,
+		Value:        k.value,
+		UpdatedAt:    k.updatedAt,
+		Pair:         k.pair,
+		BuyExchange:  k.buyExchange,
+		SellExchange: k.sellExchange,
+	}
+}
+
+// arbitrageKey поля комбинации, определяющие ее позицию. Передается по значению,
+// чтобы сравнение не выделяло память.
+type arbitrageKey struct {
+	value        float64
+	updatedAt    time.Time
+	pair         string
+	buyExchange  string
+	sellExchange string
+}
+
+func newArbitrageKey(sortBy ArbitrageSortBy, arbitrage *domain.Arbitrage) arbitrageKey {
+	k := arbitrageKey{
+		pair:         arbitrage.Pair,
+		buyExchange:  arbitrage.BuyExchange,
+		sellExchange: arbitrage.SellExchange,
+	}
+
+	switch sortBy {
+	case ArbitrageSortByProfit:
+		k.value = arbitrage.Profit
+	case ArbitrageSortByNetProfit:
+		k.value = arbitrage.NetProfit
+	case ArbitrageSortByVolume:
+		k.value = arbitrage.MaxVolume
+	case ArbitrageSortByUpdatedAt:
+		k.updatedAt = arbitrage.UpdatedAt
+	}
+
+	return k
+}
+
+// compareKeys сравнивает позиции по возрастанию поля sortBy: -1 - a раньше b
+func compareKeys(sortBy ArbitrageSortBy, a, b arbitrageKey) int {
+	result := 0
+	switch sortBy {
+	case ArbitrageSortByUpdatedAt:
+		switch {
+		case a.updatedAt.Before(b.updatedAt):
+			result = -1
+		case a.updatedAt.After(b.updatedAt):
+			result = 1
+		}
+	case ArbitrageSortByPair:
+	default:
+		switch {
+		case a.value < b.value:
+			result = -1
+		case a.value > b.value:
+			result = 1
+		}
+	}
+
+	if result == 0 {
+		result = strings.Compare(a.pair, b.pair)
+	}
+	if result == 0 {
+		result = strings.Compare(a.buyExchange, b.buyExchange)
+	}
+	if result == 0 {
+		result = strings.Compare(a.sellExchange, b.sellExchange)
+	}
+
+	return result
+}
+
+// direct разворачивает результат сравнения по возрастанию для сортировки по убыванию
+func direct(dir SortDirection, result int) int {
+	if dir == Desc {
+		return -result
+	}
+
+	return result
+}
+
+// ArbitrageCursor позиция последней комбинации страницы. Value - значение поля сортировки
+// для числовых полей, UpdatedAt - для сортировки по времени пересчета.
+type ArbitrageCursor struct {
+	SortBy       ArbitrageSortBy `json:"s"`
+	SortDir      SortDirection   `json:"d"`
+	Value        float64         `json:"v,omitempty"`
+	UpdatedAt    time.Time       `json:"u,omitempty"`
+	Pair         string          `json:"p"`
+	BuyExchange  string          `json:"b"`
+	SellExchange string          `json:"e"`
+}
+
+// Encode кодирует курсор в строку для передачи клиенту
+func (c *ArbitrageCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeArbitrageCursor разбирает курсор и проверяет, что он выдан для той же сортировки
+func DecodeArbitrageCursor(str string, sortBy ArbitrageSortBy, sortDir SortDirection) (*ArbitrageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	var c ArbitrageCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	if c.SortBy != sortBy || c.SortDir != sortDir {
+		return nil, fmt.Errorf("cursor was issued for sort %s %s", c.SortBy, c.SortDir)
+	}
+
+	return &c, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,15 @@
+package filters
+
+import (
+	"calc/internal/domain"
+	"time"
+)
+
+type CandleParams struct {
+	Pair       string
+	Resolution domain.Resolution
+	From       time.Time
+	To         time.Time
+	// Limit наибольшее число свечей, 0 - без ограничения
+	Limit uint
+}
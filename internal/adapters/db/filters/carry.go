@@ -0,0 +1,15 @@
+package filters
+
+type CarrySortBy string
+
+const (
+	CarrySortByAnnualizedCarry CarrySortBy = "annualized_carry"
+)
+
+type CarryParams struct {
+	Limit   uint
+	Kind    string
+	Pair    string
+	SortBy  CarrySortBy
+	SortDir SortDirection
+}
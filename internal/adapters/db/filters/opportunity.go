@@ -0,0 +1,22 @@
+package filters
+
+import "time"
+
+type OpportunitySortBy string
+
+const (
+	OpportunitySortByOpenedAt   OpportunitySortBy = "opened_at"
+	OpportunitySortByPeakProfit OpportunitySortBy = "peak_profit"
+)
+
+type OpportunityParams struct {
+	Limit uint
+	// Active true - только активные возможности, false - только закрытые
+	Active   bool
+	Pair     string
+	Exchange string
+	// MinDuration минимальная длительность, для активных считается на момент запроса
+	MinDuration time.Duration
+	SortBy      OpportunitySortBy
+	SortDir     SortDirection
+}
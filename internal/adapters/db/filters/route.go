@@ -0,0 +1,13 @@
+package filters
+
+type RouteSortBy string
+
+const (
+	RouteSortByNetProfit RouteSortBy = "net_profit"
+)
+
+type RouteParams struct {
+	Limit   uint
+	SortBy  RouteSortBy
+	SortDir SortDirection
+}
@@ -0,0 +1,14 @@
+package filters
+
+type TriangularArbitrageSortBy string
+
+const (
+	TriangularArbitrageSortByNetProfit TriangularArbitrageSortBy = "net_profit"
+)
+
+type TriangularArbitrageParams struct {
+	Limit    uint
+	Exchange string
+	SortBy   TriangularArbitrageSortBy
+	SortDir  SortDirection
+}
@@ -0,0 +1,122 @@
+// Package memory хранит результаты расчетов в памяти процесса.
+// Используется там, где база не нужна, например при воспроизведении записанных котировок.
+package memory
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"sort"
+	"sync"
+)
+
+type ArbitrageRepo struct {
+	mu         sync.RWMutex
+	arbitrages map[string]*domain.Arbitrage
+}
+
+func NewArbitrageRepo() *ArbitrageRepo {
+	return &ArbitrageRepo{
+		arbitrages: make(map[string]*domain.Arbitrage),
+	}
+}
+
+func arbitrageKey(arbitrage *domain.Arbitrage) string {
+	return arbitrage.Pair + ":" + arbitrage.BuyExchange + ">" + arbitrage.SellExchange
+}
+
+func (r *ArbitrageRepo) Save(ctx context.Context, arbitrage *domain.Arbitrage) (*domain.Arbitrage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.arbitrages[arbitrageKey(arbitrage)] = arbitrage
+
+	return arbitrage, nil
+}
+
+func (r *ArbitrageRepo) Create(ctx context.Context, arbitrage *domain.Arbitrage) (*domain.Arbitrage, error) {
+	return r.Save(ctx, arbitrage)
+}
+
+func (r *ArbitrageRepo) FindAllByFilter(ctx context.Context, filter filters.ArbitrageParams) ([]*domain.Arbitrage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if filter.SortBy == "" {
+		filter.SortBy, filter.SortDir = filters.ArbitrageSortByNetProfit, filters.Desc
+	}
+
+	var arbitrages []*domain.Arbitrage
+	for _, arbitrage := range r.arbitrages {
+		if filter.Match(arbitrage) {
+			arbitrages = append(arbitrages, arbitrage)
+		}
+	}
+
+	sort.Slice(arbitrages, func(i, j int) bool {
+		return filter.Less(arbitrages[i], arbitrages[j])
+	})
+
+	if filter.Offset >= uint(len(arbitrages)) {
+		return nil, nil
+	}
+	arbitrages = arbitrages[filter.Offset:]
+
+	if filter.Limit > 0 && uint(len(arbitrages)) > filter.Limit {
+		arbitrages = arbitrages[:filter.Limit]
+	}
+
+	return arbitrages, nil
+}
+
+func (r *ArbitrageRepo) FindByID(ctx context.Context, id uint64) (*domain.Arbitrage, error) {
+	return nil, nil
+}
+
+func (r *ArbitrageRepo) FindByPair(ctx context.Context, pair string) ([]*domain.Arbitrage, error) {
+	return r.FindAllByFilter(ctx, filters.ArbitrageParams{Pair: pair})
+}
+
+func (r *ArbitrageRepo) Update(ctx context.Context, arbitrage *domain.Arbitrage) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := arbitrageKey(arbitrage)
+	if _, ok := r.arbitrages[key]; !ok {
+		return 0, nil
+	}
+	r.arbitrages[key] = arbitrage
+
+	return 1, nil
+}
+
+func (r *ArbitrageRepo) Delete(ctx context.Context, arbitrage *domain.Arbitrage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.arbitrages, arbitrageKey(arbitrage))
+
+	return nil
+}
+
+func (r *ArbitrageRepo) SaveBatch(ctx context.Context, arbitrages []*domain.Arbitrage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, arbitrage := range arbitrages {
+		r.arbitrages[arbitrageKey(arbitrage)] = arbitrage
+	}
+
+	return nil
+}
+
+func (r *ArbitrageRepo) DeleteBatch(ctx context.Context, arbitrages []*domain.Arbitrage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, arbitrage := range arbitrages {
+		delete(r.arbitrages, arbitrageKey(arbitrage))
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"sort"
+	"sync"
+)
+
+type CarryRepo struct {
+	mu      sync.RWMutex
+	carries map[string]*domain.Carry
+}
+
+func NewCarryRepo() *CarryRepo {
+	return &CarryRepo{
+		carries: make(map[string]*domain.Carry),
+	}
+}
+
+func (r *CarryRepo) Save(ctx context.Context, carry *domain.Carry) (*domain.Carry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.carries[carryKey(carry)] = carry
+
+	return carry, nil
+}
+
+func (r *CarryRepo) Delete(ctx context.Context, carry *domain.Carry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.carries, carryKey(carry))
+
+	return nil
+}
+
+func (r *CarryRepo) FindAllByFilter(ctx context.Context, filter filters.CarryParams) ([]*domain.Carry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var carries []*domain.Carry
+	for _, carry := range r.carries {
+		if (filter.Kind == "" || carry.Kind == filter.Kind) && (filter.Pair == "" || carry.Pair == filter.Pair) {
+			carries = append(carries, carry)
+		}
+	}
+
+	sort.Slice(carries, func(i, j int) bool {
+		return carries[i].AnnualizedCarry > carries[j].AnnualizedCarry
+	})
+
+	if filter.Limit > 0 && uint(len(carries)) > filter.Limit {
+		carries = carries[:filter.Limit]
+	}
+
+	return carries, nil
+}
+
+func carryKey(carry *domain.Carry) string {
+	return carry.Kind + ":" + carry.Pair + ":" + carry.LongExchange + ">" + carry.ShortExchange
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"sync"
+	"time"
+)
+
+type OpportunityRepo struct {
+	mu            sync.RWMutex
+	opportunities []*domain.Opportunity
+	// active индексы активных возможностей по паре и биржам
+	active map[string]int
+}
+
+func NewOpportunityRepo() *OpportunityRepo {
+	return &OpportunityRepo{
+		active: make(map[string]int),
+	}
+}
+
+func opportunityKey(opportunity *domain.Opportunity) string {
+	return opportunity.Pair + ":" + opportunity.BuyExchange + ">" + opportunity.SellExchange
+}
+
+func (r *OpportunityRepo) Create(ctx context.Context, opportunity *domain.Opportunity) (*domain.Opportunity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *opportunity
+	stored.ID = uint64(len(r.opportunities) + 1)
+	opportunity.ID = stored.ID
+
+	r.opportunities = append(r.opportunities, &stored)
+	if stored.ClosedAt == nil {
+		r.active[opportunityKey(&stored)] = len(r.opportunities) - 1
+	}
+
+	return opportunity, nil
+}
+
+func (r *OpportunityRepo) Update(ctx context.Context, opportunity *domain.Opportunity) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := opportunityKey(opportunity)
+	i, ok := r.active[key]
+	if !ok {
+		return 0, nil
+	}
+
+	stored := *opportunity
+	stored.ID = r.opportunities[i].ID
+	r.opportunities[i] = &stored
+
+	if stored.ClosedAt != nil {
+		delete(r.active, key)
+	}
+
+	return 1, nil
+}
+
+func (r *OpportunityRepo) CloseActive(ctx context.Context, closedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, i := range r.active {
+		closed := closedAt
+		r.opportunities[i].ClosedAt = &closed
+		delete(r.active, key)
+	}
+
+	return nil
+}
+
+func (r *OpportunityRepo) FindAllByFilter(ctx context.Context, filter filters.OpportunityParams) ([]*domain.Opportunity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now().UTC()
+
+	var opportunities []*domain.Opportunity
+	for _, o := range r.opportunities {
+		if filter.Active != (o.ClosedAt == nil) {
+			continue
+		}
+		if filter.Pair != "" && o.Pair != filter.Pair {
+			continue
+		}
+		if filter.Exchange != "" && o.BuyExchange != filter.Exchange && o.SellExchange != filter.Exchange {
+			continue
+		}
+		if o.Duration(now) < filter.MinDuration {
+			continue
+		}
+
+		opportunity := *o
+		opportunities = append(opportunities, &opportunity)
+	}
+
+	if filter.Limit > 0 && uint(len(opportunities)) > filter.Limit {
+		opportunities = opportunities[:filter.Limit]
+	}
+
+	return opportunities, nil
+}
+
+// All возвращает копии всех возможностей в порядке открытия
+func (r *OpportunityRepo) All() []*domain.Opportunity {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	opportunities := make([]*domain.Opportunity, 0, len(r.opportunities))
+	for _, o := range r.opportunities {
+		opportunity := *o
+		opportunities = append(opportunities, &opportunity)
+	}
+
+	return opportunities
+}
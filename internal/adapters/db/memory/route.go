@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"sort"
+	"sync"
+)
+
+type RouteRepo struct {
+	mu     sync.RWMutex
+	routes map[string]*domain.Route
+}
+
+func NewRouteRepo() *RouteRepo {
+	return &RouteRepo{
+		routes: make(map[string]*domain.Route),
+	}
+}
+
+func (r *RouteRepo) Save(ctx context.Context, route *domain.Route) (*domain.Route, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes[route.Route] = route
+
+	return route, nil
+}
+
+func (r *RouteRepo) FindAllByFilter(ctx context.Context, filter filters.RouteParams) ([]*domain.Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]*domain.Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].NetProfit > routes[j].NetProfit
+	})
+
+	if filter.Limit > 0 && uint(len(routes)) > filter.Limit {
+		routes = routes[:filter.Limit]
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"sort"
+	"sync"
+)
+
+type TriangularArbitrageRepo struct {
+	mu         sync.RWMutex
+	arbitrages map[string]*domain.TriangularArbitrage
+}
+
+func NewTriangularArbitrageRepo() *TriangularArbitrageRepo {
+	return &TriangularArbitrageRepo{
+		arbitrages: make(map[string]*domain.TriangularArbitrage),
+	}
+}
+
+func (r *TriangularArbitrageRepo) Save(ctx context.Context, arbitrage *domain.TriangularArbitrage) (*domain.TriangularArbitrage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.arbitrages[arbitrage.Exchange+":"+arbitrage.Route] = arbitrage
+
+	return arbitrage, nil
+}
+
+func (r *TriangularArbitrageRepo) FindAllByFilter(ctx context.Context, filter filters.TriangularArbitrageParams) ([]*domain.TriangularArbitrage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var arbitrages []*domain.TriangularArbitrage
+	for _, arbitrage := range r.arbitrages {
+		if filter.Exchange == "" || arbitrage.Exchange == filter.Exchange {
+			arbitrages = append(arbitrages, arbitrage)
+		}
+	}
+
+	sort.Slice(arbitrages, func(i, j int) bool {
+		return arbitrages[i].NetProfit > arbitrages[j].NetProfit
+	})
+
+	if filter.Limit > 0 && uint(len(arbitrages)) > filter.Limit {
+		arbitrages = arbitrages[:filter.Limit]
+	}
+
+	return arbitrages, nil
+}
@@ -10,50 +10,144 @@ import (
 	"github.com/jackc/pgconn"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"strings"
 	"time"
 )
 
 const arbitragesTable = "arbitrages"
 
 type Arbitrage struct {
-	Pair         string    `db:"pair"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
-	BuyExchange  string    `db:"buy_exchange"`
-	SellExchange string    `db:"sell_exchange"`
-	BuyPrice     float64   `db:"buy_price"`
-	SellPrice    float64   `db:"sell_price"`
-	Profit       float64   `db:"profit"`
+	Pair              string    `db:"pair"`
+	CreatedAt         time.Time `db:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at"`
+	BuyExchange       string    `db:"buy_exchange"`
+	SellExchange      string    `db:"sell_exchange"`
+	BuyPrice          float64   `db:"buy_price"`
+	BuyQuantity       float64   `db:"buy_quantity"`
+	SellPrice         float64   `db:"sell_price"`
+	SellQuantity      float64   `db:"sell_quantity"`
+	BuyFee            float64   `db:"buy_fee"`
+	SellFee           float64   `db:"sell_fee"`
+	TransferNetwork   string    `db:"transfer_network"`
+	TransferFee       float64   `db:"transfer_fee"`
+	Profit            float64   `db:"profit"`
+	NetProfit         float64   `db:"net_profit"`
+	MaxVolume         float64   `db:"max_volume"`
+	BuyVWAP           float64   `db:"buy_vwap"`
+	SellVWAP          float64   `db:"sell_vwap"`
+	ProfitAtVolume    float64   `db:"profit_at_volume"`
+	InsufficientDepth bool      `db:"insufficient_depth"`
+}
+
+func (a *Arbitrage) toDomain() *domain.Arbitrage {
+	return &domain.Arbitrage{
+		Pair:              a.Pair,
+		BuyExchange:       a.BuyExchange,
+		SellExchange:      a.SellExchange,
+		BuyPrice:          a.BuyPrice,
+		BuyQuantity:       a.BuyQuantity,
+		SellPrice:         a.SellPrice,
+		SellQuantity:      a.SellQuantity,
+		BuyFee:            a.BuyFee,
+		SellFee:           a.SellFee,
+		TransferNetwork:   a.TransferNetwork,
+		TransferFee:       a.TransferFee,
+		Transferable:      true,
+		Profit:            a.Profit,
+		NetProfit:         a.NetProfit,
+		MaxVolume:         a.MaxVolume,
+		BuyVWAP:           a.BuyVWAP,
+		SellVWAP:          a.SellVWAP,
+		ProfitAtVolume:    a.ProfitAtVolume,
+		InsufficientDepth: a.InsufficientDepth,
+		UpdatedAt:         a.UpdatedAt,
+	}
+}
+
+// arbitrageColumns изменяемые колонки комбинации в порядке вставки
+var arbitrageColumns = []string{
+	"buy_exchange", "sell_exchange", "buy_price", "buy_quantity", "sell_price", "sell_quantity", "buy_fee", "sell_fee",
+	"transfer_network", "transfer_fee", "profit", "net_profit", "max_volume", "buy_vwap", "sell_vwap", "profit_at_volume",
+	"insufficient_depth",
+}
+
+func arbitrageClauses(arbitrage *domain.Arbitrage) map[string]interface{} {
+	return map[string]interface{}{
+		"buy_exchange":       arbitrage.BuyExchange,
+		"sell_exchange":      arbitrage.SellExchange,
+		"buy_price":          arbitrage.BuyPrice,
+		"buy_quantity":       arbitrage.BuyQuantity,
+		"sell_price":         arbitrage.SellPrice,
+		"sell_quantity":      arbitrage.SellQuantity,
+		"buy_fee":            arbitrage.BuyFee,
+		"sell_fee":           arbitrage.SellFee,
+		"transfer_network":   arbitrage.TransferNetwork,
+		"transfer_fee":       arbitrage.TransferFee,
+		"profit":             arbitrage.Profit,
+		"net_profit":         arbitrage.NetProfit,
+		"max_volume":         arbitrage.MaxVolume,
+		"buy_vwap":           arbitrage.BuyVWAP,
+		"sell_vwap":          arbitrage.SellVWAP,
+		"profit_at_volume":   arbitrage.ProfitAtVolume,
+		"insufficient_depth": arbitrage.InsufficientDepth,
+	}
 }
 
 type ArbitrageRepo struct {
 	db *DB
 }
 
+// Save вставляет комбинацию или обновляет существующую одним запросом
 func (r *ArbitrageRepo) Save(ctx context.Context, arbitrage *domain.Arbitrage) (*domain.Arbitrage, error) {
-	n, err := r.Update(ctx, arbitrage)
-	if err != nil {
+	if err := r.SaveBatch(ctx, []*domain.Arbitrage{arbitrage}); err != nil {
 		log.Error().Stack().Err(err).Msg("failed to exec `Save`")
 
 		return nil, err
 	}
 
-	if n == 0 {
-		return r.Create(ctx, arbitrage)
+	return arbitrage, nil
+}
+
+// SaveBatch вставляет или обновляет комбинации одним многострочным запросом.
+// Повторы комбинации в пакете схлопываются в последнюю, иначе ON CONFLICT отклоняет запрос.
+func (r *ArbitrageRepo) SaveBatch(ctx context.Context, arbitrages []*domain.Arbitrage) error {
+	if len(arbitrages) == 0 {
+		return nil
 	}
 
-	return arbitrage, nil
+	ib := r.db.Sq.Insert(arbitragesTable).Columns(append([]string{"pair"}, arbitrageColumns...)...)
+	for _, arbitrage := range uniqueArbitrages(arbitrages) {
+		clauses := arbitrageClauses(arbitrage)
+
+		values := make([]interface{}, 0, len(arbitrageColumns)+1)
+		values = append(values, arbitrage.Pair)
+		for _, column := range arbitrageColumns {
+			values = append(values, clauses[column])
+		}
+
+		ib = ib.Values(values...)
+	}
+
+	excluded := make([]string, 0, len(arbitrageColumns))
+	for _, column := range arbitrageColumns {
+		excluded = append(excluded, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+
+	q, args, err := ib.Suffix("ON CONFLICT (pair, buy_exchange, sell_exchange) DO UPDATE SET " + strings.Join(excluded, ", ")).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build query `SaveBatch`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return errors.Wrap(err, "failed to exec query `SaveBatch`")
+	}
+
+	return nil
 }
 
 func (r *ArbitrageRepo) Create(ctx context.Context, arbitrage *domain.Arbitrage) (*domain.Arbitrage, error) {
-	clauses := map[string]interface{}{
-		"pair":          arbitrage.Pair,
-		"buy_exchange":  arbitrage.BuyExchange,
-		"sell_exchange": arbitrage.SellExchange,
-		"buy_price":     arbitrage.BuyPrice,
-		"sell_price":    arbitrage.SellPrice,
-		"profit":        arbitrage.Profit,
-	}
+	clauses := arbitrageClauses(arbitrage)
+	clauses["pair"] = arbitrage.Pair
 
 	q, args, err := r.db.Sq.Insert(arbitragesTable).SetMap(clauses).ToSql()
 	if err != nil {
@@ -77,10 +171,120 @@ func (r *ArbitrageRepo) Create(ctx context.Context, arbitrage *domain.Arbitrage)
 	return arbitrage, nil
 }
 
+// arbitrageSortColumns выражения сортировки по полям фильтра. Числовые колонки приводятся к float8,
+// чтобы значение курсора, прочитанное в float64, сравнивалось с ними без потери точности.
+var arbitrageSortColumns = map[filters.ArbitrageSortBy]string{
+	filters.ArbitrageSortByProfit:    "profit::float8",
+	filters.ArbitrageSortByNetProfit: "net_profit::float8",
+	filters.ArbitrageSortByVolume:    "max_volume::float8",
+	filters.ArbitrageSortByUpdatedAt: "updated_at",
+	filters.ArbitrageSortByPair:      "",
+}
+
+// arbitrageOrder возвращает колонки сортировки с направлением и условие курсора.
+// Поле и направление проверяются по перечислениям, в запрос попадают только известные выражения.
+func arbitrageOrder(filter filters.ArbitrageParams) ([]string, squirrel.Sqlizer, error) {
+	column, ok := arbitrageSortColumns[filter.SortBy]
+	if !ok {
+		return nil, nil, errors.Errorf("unknown arbitrage sort %q", filter.SortBy)
+	}
+
+	dir, err := filters.NewSortDirection(string(filter.SortDir))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := []string{"pair", "buy_exchange", "sell_exchange"}
+	if column != "" {
+		columns = append([]string{column}, columns...)
+	}
+
+	orderBy := make([]string, 0, len(columns))
+	for _, c := range columns {
+		orderBy = append(orderBy, fmt.Sprintf("%s %s", c, strings.ToUpper(string(dir))))
+	}
+
+	if filter.After == nil {
+		return orderBy, nil, nil
+	}
+
+	values := []interface{}{filter.After.Pair, filter.After.BuyExchange, filter.After.SellExchange}
+	switch filter.SortBy {
+	case filters.ArbitrageSortByUpdatedAt:
+		values = append([]interface{}{filter.After.UpdatedAt}, values...)
+	case filters.ArbitrageSortByPair:
+	default:
+		values = append([]interface{}{filter.After.Value}, values...)
+	}
+
+	op := ">"
+	if dir == filters.Desc {
+		op = "<"
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	after := squirrel.Expr(fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, placeholders), values...)
+
+	return orderBy, after, nil
+}
+
 func (r *ArbitrageRepo) FindAllByFilter(ctx context.Context, filter filters.ArbitrageParams) ([]*domain.Arbitrage, error) {
-	sb := r.db.Sq.Select("*").From(arbitragesTable).Limit(uint64(filter.Limit))
+	orderBy, after, err := arbitrageOrder(filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `FindAllByFilter`")
+	}
+
+	sb := r.db.Sq.Select("*").From(arbitragesTable).OrderBy(orderBy...).Offset(uint64(filter.Offset))
+	if filter.Limit > 0 {
+		sb = sb.Limit(uint64(filter.Limit))
+	}
+
+	if filter.Pair != "" {
+		sb = sb.Where(squirrel.Eq{"pair": filter.Pair})
+	}
+
+	if filter.Base != "" {
+		sb = sb.Where("split_part(pair, '_', 1) = ?", filter.Base)
+	}
+
+	if filter.Quote != "" {
+		sb = sb.Where("split_part(pair, '_', 2) = ?", filter.Quote)
+	}
+
+	if filter.Exchange != "" {
+		sb = sb.Where(squirrel.Or{
+			squirrel.Eq{"buy_exchange": filter.Exchange},
+			squirrel.Eq{"sell_exchange": filter.Exchange},
+		})
+	}
+
+	if len(filter.Exchanges) > 0 {
+		sb = sb.Where(squirrel.Eq{"buy_exchange": filter.Exchanges, "sell_exchange": filter.Exchanges})
+	}
+
+	if len(filter.ExcludeExchanges) > 0 {
+		sb = sb.Where(squirrel.NotEq{"buy_exchange": filter.ExcludeExchanges, "sell_exchange": filter.ExcludeExchanges})
+	}
+
+	if filter.MinProfit != nil {
+		sb = sb.Where(squirrel.GtOrEq{"net_profit": *filter.MinProfit})
+	}
 
-	sb = sb.OrderBy(fmt.Sprintf("%s %s", filter.SortBy, filter.SortDir))
+	if filter.MaxProfit != nil {
+		sb = sb.Where(squirrel.LtOrEq{"net_profit": *filter.MaxProfit})
+	}
+
+	if filter.MinVolume != nil {
+		sb = sb.Where(squirrel.GtOrEq{"max_volume": *filter.MinVolume})
+	}
+
+	if !filter.UpdatedSince.IsZero() {
+		sb = sb.Where(squirrel.GtOrEq{"updated_at": filter.UpdatedSince})
+	}
+
+	if after != nil {
+		sb = sb.Where(after)
+	}
 
 	q, args, err := sb.ToSql()
 	if err != nil {
@@ -97,14 +301,7 @@ func (r *ArbitrageRepo) FindAllByFilter(ctx context.Context, filter filters.Arbi
 
 	var arbitrages []*domain.Arbitrage
 	for _, arbitrage := range dbArbitrage {
-		arbitrages = append(arbitrages, &domain.Arbitrage{
-			Pair:         arbitrage.Pair,
-			BuyExchange:  arbitrage.BuyExchange,
-			SellExchange: arbitrage.SellExchange,
-			BuyPrice:     arbitrage.BuyPrice,
-			SellPrice:    arbitrage.SellPrice,
-			Profit:       arbitrage.Profit,
-		})
+		arbitrages = append(arbitrages, arbitrage.toDomain())
 	}
 
 	return arbitrages, nil
@@ -123,49 +320,32 @@ func (r *ArbitrageRepo) FindByID(ctx context.Context, id uint64) (*domain.Arbitr
 		return nil, errors.Wrap(err, "failed to exec query `FindByID`")
 	}
 
-	return &domain.Arbitrage{
-		Pair:         dbArbitrage.Pair,
-		BuyExchange:  dbArbitrage.BuyExchange,
-		SellExchange: dbArbitrage.SellExchange,
-		BuyPrice:     dbArbitrage.BuyPrice,
-		SellPrice:    dbArbitrage.SellPrice,
-		Profit:       dbArbitrage.Profit,
-	}, nil
+	return dbArbitrage.toDomain(), nil
 }
 
-func (r *ArbitrageRepo) FindByPair(ctx context.Context, pair string) (*domain.Arbitrage, error) {
-	q, args, err := r.db.Sq.Select("*").From(arbitragesTable).Where(squirrel.Eq{"pair": pair}).ToSql()
+func (r *ArbitrageRepo) FindByPair(ctx context.Context, pair string) ([]*domain.Arbitrage, error) {
+	q, args, err := r.db.Sq.Select("*").From(arbitragesTable).Where(squirrel.Eq{"pair": pair}).OrderBy("net_profit DESC").ToSql()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build query `FindByPair`")
 	}
 
-	var dbArbitrage Arbitrage
+	var dbArbitrage []Arbitrage
 
-	err = r.db.GetContext(ctx, &dbArbitrage, q, args)
+	err = r.db.SelectContext(ctx, q, &dbArbitrage, args)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to exec query `FindByPair`")
 	}
 
-	return &domain.Arbitrage{
-		Pair:         dbArbitrage.Pair,
-		BuyExchange:  dbArbitrage.BuyExchange,
-		SellExchange: dbArbitrage.SellExchange,
-		BuyPrice:     dbArbitrage.BuyPrice,
-		SellPrice:    dbArbitrage.SellPrice,
-		Profit:       dbArbitrage.Profit,
-	}, nil
+	var arbitrages []*domain.Arbitrage
+	for _, arbitrage := range dbArbitrage {
+		arbitrages = append(arbitrages, arbitrage.toDomain())
+	}
+
+	return arbitrages, nil
 }
 
 func (r *ArbitrageRepo) Update(ctx context.Context, arbitrage *domain.Arbitrage) (int64, error) {
-	clauses := map[string]interface{}{
-		"buy_exchange":  arbitrage.BuyExchange,
-		"sell_exchange": arbitrage.SellExchange,
-		"buy_price":     arbitrage.BuyPrice,
-		"sell_price":    arbitrage.SellPrice,
-		"profit":        arbitrage.Profit,
-	}
-
-	q, args, err := r.db.Sq.Update(arbitragesTable).SetMap(clauses).Where(squirrel.Eq{"pair": arbitrage.Pair}).ToSql()
+	q, args, err := r.db.Sq.Update(arbitragesTable).SetMap(arbitrageClauses(arbitrage)).Where(arbitrageKey(arbitrage)).ToSql()
 	if err != nil {
 		return 0, errors.Wrap(err, "error build query `Update`")
 	}
@@ -177,3 +357,66 @@ func (r *ArbitrageRepo) Update(ctx context.Context, arbitrage *domain.Arbitrage)
 
 	return result.RowsAffected()
 }
+
+func (r *ArbitrageRepo) Delete(ctx context.Context, arbitrage *domain.Arbitrage) error {
+	q, args, err := r.db.Sq.Delete(arbitragesTable).Where(arbitrageKey(arbitrage)).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error build query `Delete`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return errors.Wrap(err, "failed to exec query `Delete`")
+	}
+
+	return nil
+}
+
+// DeleteBatch удаляет комбинации одним запросом
+func (r *ArbitrageRepo) DeleteBatch(ctx context.Context, arbitrages []*domain.Arbitrage) error {
+	if len(arbitrages) == 0 {
+		return nil
+	}
+
+	keys := make(squirrel.Or, 0, len(arbitrages))
+	for _, arbitrage := range arbitrages {
+		keys = append(keys, arbitrageKey(arbitrage))
+	}
+
+	q, args, err := r.db.Sq.Delete(arbitragesTable).Where(keys).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error build query `DeleteBatch`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return errors.Wrap(err, "failed to exec query `DeleteBatch`")
+	}
+
+	return nil
+}
+
+// uniqueArbitrages оставляет последнее вхождение каждой комбинации, сохраняя порядок
+func uniqueArbitrages(arbitrages []*domain.Arbitrage) []*domain.Arbitrage {
+	index := make(map[string]int, len(arbitrages))
+	unique := make([]*domain.Arbitrage, 0, len(arbitrages))
+	for _, arbitrage := range arbitrages {
+		key := arbitrage.Pair + ":" + arbitrage.BuyExchange + ">" + arbitrage.SellExchange
+		if i, ok := index[key]; ok {
+			unique[i] = arbitrage
+			continue
+		}
+
+		index[key] = len(unique)
+		unique = append(unique, arbitrage)
+	}
+
+	return unique
+}
+
+// arbitrageKey условие на строку комбинации бирж покупки и продажи по паре
+func arbitrageKey(arbitrage *domain.Arbitrage) squirrel.Eq {
+	return squirrel.Eq{
+		"pair":          arbitrage.Pair,
+		"buy_exchange":  arbitrage.BuyExchange,
+		"sell_exchange": arbitrage.SellExchange,
+	}
+}
@@ -0,0 +1,307 @@
+package postgres
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	quoteCandlesTable  = "quote_candles"
+	spreadCandlesTable = "spread_candles"
+
+	// candlesBatchSize ограничивает число строк в одном INSERT, чтобы не упереться в лимит параметров
+	candlesBatchSize = 1000
+)
+
+type QuoteCandle struct {
+	Exchange   string    `db:"exchange"`
+	Pair       string    `db:"pair"`
+	Resolution string    `db:"resolution"`
+	Time       time.Time `db:"time"`
+	Open       float64   `db:"open"`
+	High       float64   `db:"high"`
+	Low        float64   `db:"low"`
+	Close      float64   `db:"close"`
+	Bid        float64   `db:"bid"`
+	Ask        float64   `db:"ask"`
+}
+
+type SpreadCandle struct {
+	Pair         string    `db:"pair"`
+	BuyExchange  string    `db:"buy_exchange"`
+	SellExchange string    `db:"sell_exchange"`
+	Resolution   string    `db:"resolution"`
+	Time         time.Time `db:"time"`
+	Open         float64   `db:"open"`
+	High         float64   `db:"high"`
+	Low          float64   `db:"low"`
+	Close        float64   `db:"close"`
+}
+
+type CandleRepo struct {
+	db *DB
+}
+
+// SaveQuotes пакетно сохраняет свечи котировок, существующие свечи дополняются
+func (r *CandleRepo) SaveQuotes(ctx context.Context, candles []*domain.QuoteCandle) error {
+	candles = uniqueQuoteCandles(candles)
+
+	for start := 0; start < len(candles); start += candlesBatchSize {
+		end := start + candlesBatchSize
+		if end > len(candles) {
+			end = len(candles)
+		}
+
+		ib := r.db.Sq.Insert(quoteCandlesTable).
+			Columns("exchange", "pair", "resolution", "time", "open", "high", "low", "close", "bid", "ask")
+		for _, c := range candles[start:end] {
+			ib = ib.Values(c.Exchange, c.Pair, c.Resolution, c.Time, c.Open, c.High, c.Low, c.Close, c.Bid, c.Ask)
+		}
+
+		q, args, err := ib.Suffix(`ON CONFLICT (pair, resolution, time, exchange) DO UPDATE SET
+			high = GREATEST(quote_candles.high, EXCLUDED.high),
+			low = LEAST(quote_candles.low, EXCLUDED.low),
+			close = EXCLUDED.close,
+			bid = EXCLUDED.bid,
+			ask = EXCLUDED.ask`).ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build query `SaveQuotes`")
+		}
+
+		if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+			return errors.Wrap(err, "failed to exec query `SaveQuotes`")
+		}
+	}
+
+	return nil
+}
+
+// SaveSpreads пакетно сохраняет свечи спредов, существующие свечи дополняются
+func (r *CandleRepo) SaveSpreads(ctx context.Context, candles []*domain.SpreadCandle) error {
+	candles = uniqueSpreadCandles(candles)
+
+	for start := 0; start < len(candles); start += candlesBatchSize {
+		end := start + candlesBatchSize
+		if end > len(candles) {
+			end = len(candles)
+		}
+
+		ib := r.db.Sq.Insert(spreadCandlesTable).
+			Columns("pair", "buy_exchange", "sell_exchange", "resolution", "time", "open", "high", "low", "close")
+		for _, c := range candles[start:end] {
+			ib = ib.Values(c.Pair, c.BuyExchange, c.SellExchange, c.Resolution, c.Time, c.Open, c.High, c.Low, c.Close)
+		}
+
+		q, args, err := ib.Suffix(`ON CONFLICT (pair, resolution, time, buy_exchange, sell_exchange) DO UPDATE SET
+			high = GREATEST(spread_candles.high, EXCLUDED.high),
+			low = LEAST(spread_candles.low, EXCLUDED.low),
+			close = EXCLUDED.close`).ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build query `SaveSpreads`")
+		}
+
+		if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+			return errors.Wrap(err, "failed to exec query `SaveSpreads`")
+		}
+	}
+
+	return nil
+}
+
+// uniqueQuoteCandles объединяет свечи с одним ключом конфликта: иначе ON CONFLICT отклоняет весь запрос.
+// Такие свечи появляются, когда котировка приходит с опозданием в уже закрытый интервал.
+func uniqueQuoteCandles(candles []*domain.QuoteCandle) []*domain.QuoteCandle {
+	index := make(map[string]int, len(candles))
+	unique := make([]*domain.QuoteCandle, 0, len(candles))
+	for _, c := range candles {
+		key := fmt.Sprintf("%s:%s:%s:%d", c.Pair, c.Resolution, c.Exchange, c.Time.UnixNano())
+		if i, ok := index[key]; ok {
+			merged := *unique[i]
+			merged.Merge(c.Candle)
+			merged.Bid, merged.Ask = c.Bid, c.Ask
+			unique[i] = &merged
+			continue
+		}
+
+		index[key] = len(unique)
+		unique = append(unique, c)
+	}
+
+	return unique
+}
+
+// uniqueSpreadCandles объединяет свечи спредов с одним ключом конфликта
+func uniqueSpreadCandles(candles []*domain.SpreadCandle) []*domain.SpreadCandle {
+	index := make(map[string]int, len(candles))
+	unique := make([]*domain.SpreadCandle, 0, len(candles))
+	for _, c := range candles {
+		key := fmt.Sprintf("%s:%s:%s>%s:%d", c.Pair, c.Resolution, c.BuyExchange, c.SellExchange, c.Time.UnixNano())
+		if i, ok := index[key]; ok {
+			merged := *unique[i]
+			merged.Merge(c.Candle)
+			unique[i] = &merged
+			continue
+		}
+
+		index[key] = len(unique)
+		unique = append(unique, c)
+	}
+
+	return unique
+}
+
+// Rollup пересчитывает свечи интервала to из свечей интервала from, начиная с интервала, в который попадает since
+func (r *CandleRepo) Rollup(ctx context.Context, from, to domain.Resolution, since time.Time) error {
+	unit, err := truncUnit(to)
+	if err != nil {
+		return err
+	}
+
+	quotes := fmt.Sprintf(`INSERT INTO %[1]s (exchange, pair, resolution, time, open, high, low, close, bid, ask)
+		SELECT exchange, pair, $1, date_trunc('%[2]s', time) AS bucket,
+			(array_agg(open ORDER BY time))[1], MAX(high), MIN(low), (array_agg(close ORDER BY time DESC))[1],
+			(array_agg(bid ORDER BY time DESC))[1], (array_agg(ask ORDER BY time DESC))[1]
+		FROM %[1]s
+		WHERE resolution = $2 AND time >= date_trunc('%[2]s', $3::timestamp)
+		GROUP BY exchange, pair, bucket
+		ON CONFLICT (pair, resolution, time, exchange) DO UPDATE SET
+			open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close,
+			bid = EXCLUDED.bid, ask = EXCLUDED.ask`, quoteCandlesTable, unit)
+
+	if _, err := r.db.ExecContext(ctx, quotes, []interface{}{to, from, since}, true); err != nil {
+		return errors.Wrap(err, "failed to exec query `Rollup` quotes")
+	}
+
+	spreads := fmt.Sprintf(`INSERT INTO %[1]s (pair, buy_exchange, sell_exchange, resolution, time, open, high, low, close)
+		SELECT pair, buy_exchange, sell_exchange, $1, date_trunc('%[2]s', time) AS bucket,
+			(array_agg(open ORDER BY time))[1], MAX(high), MIN(low), (array_agg(close ORDER BY time DESC))[1]
+		FROM %[1]s
+		WHERE resolution = $2 AND time >= date_trunc('%[2]s', $3::timestamp)
+		GROUP BY pair, buy_exchange, sell_exchange, bucket
+		ON CONFLICT (pair, resolution, time, buy_exchange, sell_exchange) DO UPDATE SET
+			open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close`, spreadCandlesTable, unit)
+
+	if _, err := r.db.ExecContext(ctx, spreads, []interface{}{to, from, since}, true); err != nil {
+		return errors.Wrap(err, "failed to exec query `Rollup` spreads")
+	}
+
+	return nil
+}
+
+// DeleteBefore удаляет свечи интервала resolution старше before
+func (r *CandleRepo) DeleteBefore(ctx context.Context, resolution domain.Resolution, before time.Time) error {
+	for _, table := range []string{quoteCandlesTable, spreadCandlesTable} {
+		q, args, err := r.db.Sq.Delete(table).Where(squirrel.And{
+			squirrel.Eq{"resolution": resolution},
+			squirrel.Lt{"time": before},
+		}).ToSql()
+		if err != nil {
+			return errors.Wrap(err, "failed to build query `DeleteBefore`")
+		}
+
+		if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+			return errors.Wrap(err, "failed to exec query `DeleteBefore`")
+		}
+	}
+
+	return nil
+}
+
+func (r *CandleRepo) FindQuotes(ctx context.Context, filter filters.CandleParams) ([]*domain.QuoteCandle, error) {
+	sb := r.db.Sq.Select("*").From(quoteCandlesTable).Where(candleWhere(filter)).OrderBy("time", "exchange")
+	if filter.Limit > 0 {
+		sb = sb.Limit(uint64(filter.Limit))
+	}
+
+	q, args, err := sb.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `FindQuotes`")
+	}
+
+	var dbCandles []QuoteCandle
+	if err := r.db.SelectContext(ctx, q, &dbCandles, args); err != nil {
+		return nil, errors.Wrap(err, "failed to exec query `FindQuotes`")
+	}
+
+	candles := make([]*domain.QuoteCandle, 0, len(dbCandles))
+	for _, c := range dbCandles {
+		candles = append(candles, &domain.QuoteCandle{
+			Candle: domain.Candle{
+				Time:  c.Time,
+				Open:  c.Open,
+				High:  c.High,
+				Low:   c.Low,
+				Close: c.Close,
+			},
+			Exchange:   c.Exchange,
+			Pair:       c.Pair,
+			Resolution: domain.Resolution(c.Resolution),
+			Bid:        c.Bid,
+			Ask:        c.Ask,
+		})
+	}
+
+	return candles, nil
+}
+
+func (r *CandleRepo) FindSpreads(ctx context.Context, filter filters.CandleParams) ([]*domain.SpreadCandle, error) {
+	sb := r.db.Sq.Select("*").From(spreadCandlesTable).Where(candleWhere(filter)).OrderBy("time", "buy_exchange", "sell_exchange")
+	if filter.Limit > 0 {
+		sb = sb.Limit(uint64(filter.Limit))
+	}
+
+	q, args, err := sb.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `FindSpreads`")
+	}
+
+	var dbCandles []SpreadCandle
+	if err := r.db.SelectContext(ctx, q, &dbCandles, args); err != nil {
+		return nil, errors.Wrap(err, "failed to exec query `FindSpreads`")
+	}
+
+	candles := make([]*domain.SpreadCandle, 0, len(dbCandles))
+	for _, c := range dbCandles {
+		candles = append(candles, &domain.SpreadCandle{
+			Candle: domain.Candle{
+				Time:  c.Time,
+				Open:  c.Open,
+				High:  c.High,
+				Low:   c.Low,
+				Close: c.Close,
+			},
+			Pair:         c.Pair,
+			BuyExchange:  c.BuyExchange,
+			SellExchange: c.SellExchange,
+			Resolution:   domain.Resolution(c.Resolution),
+		})
+	}
+
+	return candles, nil
+}
+
+func candleWhere(filter filters.CandleParams) squirrel.And {
+	return squirrel.And{
+		squirrel.Eq{"pair": filter.Pair, "resolution": filter.Resolution},
+		squirrel.GtOrEq{"time": filter.From},
+		squirrel.LtOrEq{"time": filter.To},
+	}
+}
+
+func truncUnit(resolution domain.Resolution) (string, error) {
+	switch resolution {
+	case domain.ResolutionSecond:
+		return "second", nil
+	case domain.ResolutionMinute:
+		return "minute", nil
+	case domain.ResolutionHour:
+		return "hour", nil
+	}
+
+	return "", domain.ErrUnknownResolution
+}
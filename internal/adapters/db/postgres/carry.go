@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const carriesTable = "carries"
+
+type Carry struct {
+	Kind            string     `db:"kind"`
+	Pair            string     `db:"pair"`
+	LongExchange    string     `db:"long_exchange"`
+	ShortExchange   string     `db:"short_exchange"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+	LongMarket      string     `db:"long_market"`
+	LongPrice       float64    `db:"long_price"`
+	ShortPrice      float64    `db:"short_price"`
+	LongFunding     float64    `db:"long_funding"`
+	ShortFunding    float64    `db:"short_funding"`
+	Basis           float64    `db:"basis"`
+	Fees            float64    `db:"fees"`
+	AnnualizedCarry float64    `db:"annualized_carry"`
+	NextFundingTime *time.Time `db:"next_funding_time"`
+}
+
+func (c *Carry) toDomain() *domain.Carry {
+	carry := &domain.Carry{
+		Kind:            c.Kind,
+		Pair:            c.Pair,
+		LongExchange:    c.LongExchange,
+		LongMarket:      c.LongMarket,
+		ShortExchange:   c.ShortExchange,
+		LongPrice:       c.LongPrice,
+		ShortPrice:      c.ShortPrice,
+		LongFunding:     c.LongFunding,
+		ShortFunding:    c.ShortFunding,
+		Basis:           c.Basis,
+		Fees:            c.Fees,
+		AnnualizedCarry: c.AnnualizedCarry,
+	}
+
+	if c.NextFundingTime != nil {
+		carry.NextFundingTime = *c.NextFundingTime
+	}
+
+	return carry
+}
+
+type CarryRepo struct {
+	db *DB
+}
+
+func (r *CarryRepo) Save(ctx context.Context, carry *domain.Carry) (*domain.Carry, error) {
+	var nextFundingTime *time.Time
+	if !carry.NextFundingTime.IsZero() {
+		nextFundingTime = &carry.NextFundingTime
+	}
+
+	clauses := map[string]interface{}{
+		"kind":              carry.Kind,
+		"pair":              carry.Pair,
+		"long_exchange":     carry.LongExchange,
+		"short_exchange":    carry.ShortExchange,
+		"long_market":       carry.LongMarket,
+		"long_price":        carry.LongPrice,
+		"short_price":       carry.ShortPrice,
+		"long_funding":      carry.LongFunding,
+		"short_funding":     carry.ShortFunding,
+		"basis":             carry.Basis,
+		"fees":              carry.Fees,
+		"annualized_carry":  carry.AnnualizedCarry,
+		"next_funding_time": nextFundingTime,
+	}
+
+	q, args, err := r.db.Sq.Insert(carriesTable).SetMap(clauses).
+		Suffix("ON CONFLICT (kind, pair, long_exchange, short_exchange) DO UPDATE SET " +
+			"long_market = EXCLUDED.long_market, long_price = EXCLUDED.long_price, short_price = EXCLUDED.short_price, " +
+			"long_funding = EXCLUDED.long_funding, short_funding = EXCLUDED.short_funding, basis = EXCLUDED.basis, " +
+			"fees = EXCLUDED.fees, annualized_carry = EXCLUDED.annualized_carry, next_funding_time = EXCLUDED.next_funding_time").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `Save`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return nil, errors.Wrap(err, "failed to exec query `Save`")
+	}
+
+	return carry, nil
+}
+
+func (r *CarryRepo) Delete(ctx context.Context, carry *domain.Carry) error {
+	q, args, err := r.db.Sq.Delete(carriesTable).Where(squirrel.Eq{
+		"kind":           carry.Kind,
+		"pair":           carry.Pair,
+		"long_exchange":  carry.LongExchange,
+		"short_exchange": carry.ShortExchange,
+	}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error build query `Delete`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return errors.Wrap(err, "failed to exec query `Delete`")
+	}
+
+	return nil
+}
+
+func (r *CarryRepo) FindAllByFilter(ctx context.Context, filter filters.CarryParams) ([]*domain.Carry, error) {
+	sb := r.db.Sq.Select("*").From(carriesTable).Limit(uint64(filter.Limit))
+
+	if filter.Kind != "" {
+		sb = sb.Where(squirrel.Eq{"kind": filter.Kind})
+	}
+
+	if filter.Pair != "" {
+		sb = sb.Where(squirrel.Eq{"pair": filter.Pair})
+	}
+
+	sb = sb.OrderBy(fmt.Sprintf("%s %s", filter.SortBy, filter.SortDir))
+
+	q, args, err := sb.ToSql()
+	if err != nil {
+		log.Error().Stack().Err(err).Msg("failed to build query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to build query `FindAllByFilter`")
+	}
+
+	var dbCarries []Carry
+	if err := r.db.SelectContext(ctx, q, &dbCarries, args); err != nil {
+		log.Error().Stack().Err(err).Msg("failed to exec query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to exec query `FindAllByFilter`")
+	}
+
+	carries := make([]*domain.Carry, 0, len(dbCarries))
+	for _, dbCarry := range dbCarries {
+		carries = append(carries, dbCarry.toDomain())
+	}
+
+	return carries, nil
+}
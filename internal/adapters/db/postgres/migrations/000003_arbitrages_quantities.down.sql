@@ -0,0 +1,3 @@
+ALTER TABLE arbitrages
+    DROP COLUMN buy_quantity,
+    DROP COLUMN sell_quantity;
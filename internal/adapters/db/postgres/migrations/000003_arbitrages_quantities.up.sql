@@ -0,0 +1,3 @@
+ALTER TABLE arbitrages
+    ADD COLUMN buy_quantity  DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN sell_quantity DECIMAL NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+DROP INDEX arbitrages__net_profit_idx;
+
+ALTER TABLE arbitrages
+    DROP COLUMN buy_fee,
+    DROP COLUMN sell_fee,
+    DROP COLUMN net_profit;
@@ -0,0 +1,6 @@
+ALTER TABLE arbitrages
+    ADD COLUMN buy_fee    DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN sell_fee   DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN net_profit DECIMAL NOT NULL DEFAULT 0;
+
+CREATE INDEX arbitrages__net_profit_idx ON arbitrages (net_profit);
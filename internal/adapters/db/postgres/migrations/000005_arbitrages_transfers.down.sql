@@ -0,0 +1,3 @@
+ALTER TABLE arbitrages
+    DROP COLUMN transfer_network,
+    DROP COLUMN transfer_fee;
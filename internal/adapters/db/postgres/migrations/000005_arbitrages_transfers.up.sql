@@ -0,0 +1,3 @@
+ALTER TABLE arbitrages
+    ADD COLUMN transfer_network VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN transfer_fee     DECIMAL NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+ALTER TABLE arbitrages
+    DROP COLUMN max_volume,
+    DROP COLUMN buy_vwap,
+    DROP COLUMN sell_vwap,
+    DROP COLUMN profit_at_volume;
@@ -0,0 +1,5 @@
+ALTER TABLE arbitrages
+    ADD COLUMN max_volume       DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN buy_vwap         DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN sell_vwap        DECIMAL NOT NULL DEFAULT 0,
+    ADD COLUMN profit_at_volume DECIMAL NOT NULL DEFAULT 0;
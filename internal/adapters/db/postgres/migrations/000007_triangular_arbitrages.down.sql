@@ -0,0 +1,3 @@
+DROP INDEX triangular_arbitrages__net_profit_idx;
+
+DROP TABLE triangular_arbitrages;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS triangular_arbitrages
+(
+    exchange   VARCHAR(150) NOT NULL,
+    route      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    legs       JSONB NOT NULL,
+    profit     DECIMAL NOT NULL,
+    net_profit DECIMAL NOT NULL,
+
+    PRIMARY KEY (exchange, route)
+);
+
+CREATE INDEX triangular_arbitrages__net_profit_idx ON triangular_arbitrages (net_profit);
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE
+    ON triangular_arbitrages
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
@@ -0,0 +1,3 @@
+DROP INDEX routes__net_profit_idx;
+
+DROP TABLE routes;
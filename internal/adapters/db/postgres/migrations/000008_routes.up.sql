@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS routes
+(
+    route      VARCHAR(500) PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    legs       JSONB NOT NULL,
+    net_profit DECIMAL NOT NULL
+);
+
+CREATE INDEX routes__net_profit_idx ON routes (net_profit);
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE
+    ON routes
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
@@ -0,0 +1,6 @@
+DELETE FROM arbitrages a USING arbitrages b
+WHERE a.pair = b.pair AND (a.net_profit, a.ctid) < (b.net_profit, b.ctid);
+
+ALTER TABLE arbitrages DROP CONSTRAINT arbitrages_pkey;
+
+ALTER TABLE arbitrages ADD PRIMARY KEY (pair);
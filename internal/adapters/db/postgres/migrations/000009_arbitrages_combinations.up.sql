@@ -0,0 +1,3 @@
+ALTER TABLE arbitrages DROP CONSTRAINT arbitrages_pkey;
+
+ALTER TABLE arbitrages ADD PRIMARY KEY (pair, buy_exchange, sell_exchange);
@@ -0,0 +1,5 @@
+DROP INDEX opportunities__opened_at_idx;
+DROP INDEX opportunities__pair_idx;
+DROP INDEX opportunities__active_idx;
+
+DROP TABLE opportunities;
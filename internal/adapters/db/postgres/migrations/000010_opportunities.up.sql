@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS opportunities
+(
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    pair          VARCHAR(64) NOT NULL,
+    buy_exchange  VARCHAR(150) NOT NULL,
+    sell_exchange VARCHAR(150) NOT NULL,
+    opened_at     TIMESTAMP NOT NULL,
+    closed_at     TIMESTAMP,
+    peak_profit   DECIMAL NOT NULL,
+    avg_profit    DECIMAL NOT NULL,
+    last_profit   DECIMAL NOT NULL,
+    updates       INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX opportunities__active_idx ON opportunities (pair, buy_exchange, sell_exchange) WHERE closed_at IS NULL;
+CREATE INDEX opportunities__pair_idx ON opportunities (pair);
+CREATE INDEX opportunities__opened_at_idx ON opportunities (opened_at);
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE
+    ON opportunities
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
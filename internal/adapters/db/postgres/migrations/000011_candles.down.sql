@@ -0,0 +1,5 @@
+DROP INDEX spread_candles__resolution_time_idx;
+DROP INDEX quote_candles__resolution_time_idx;
+
+DROP TABLE spread_candles;
+DROP TABLE quote_candles;
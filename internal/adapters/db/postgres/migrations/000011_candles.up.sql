@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS quote_candles
+(
+    exchange   VARCHAR(150) NOT NULL,
+    pair       VARCHAR(64) NOT NULL,
+    resolution VARCHAR(8) NOT NULL,
+    time       TIMESTAMP NOT NULL,
+    open       DECIMAL NOT NULL,
+    high       DECIMAL NOT NULL,
+    low        DECIMAL NOT NULL,
+    close      DECIMAL NOT NULL,
+    bid        DECIMAL NOT NULL,
+    ask        DECIMAL NOT NULL,
+
+    PRIMARY KEY (pair, resolution, time, exchange)
+);
+
+CREATE TABLE IF NOT EXISTS spread_candles
+(
+    pair          VARCHAR(64) NOT NULL,
+    buy_exchange  VARCHAR(150) NOT NULL,
+    sell_exchange VARCHAR(150) NOT NULL,
+    resolution    VARCHAR(8) NOT NULL,
+    time          TIMESTAMP NOT NULL,
+    open          DECIMAL NOT NULL,
+    high          DECIMAL NOT NULL,
+    low           DECIMAL NOT NULL,
+    close         DECIMAL NOT NULL,
+
+    PRIMARY KEY (pair, resolution, time, buy_exchange, sell_exchange)
+);
+
+CREATE INDEX quote_candles__resolution_time_idx ON quote_candles (resolution, time);
+CREATE INDEX spread_candles__resolution_time_idx ON spread_candles (resolution, time);
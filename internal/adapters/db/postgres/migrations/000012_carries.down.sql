@@ -0,0 +1,3 @@
+DROP INDEX carries__annualized_carry_idx;
+
+DROP TABLE carries;
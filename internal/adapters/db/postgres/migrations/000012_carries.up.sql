@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS carries
+(
+    kind              VARCHAR(32) NOT NULL,
+    pair              VARCHAR(64) NOT NULL,
+    long_exchange     VARCHAR(150) NOT NULL,
+    short_exchange    VARCHAR(150) NOT NULL,
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    long_market       VARCHAR(32) NOT NULL,
+    long_price        DECIMAL NOT NULL,
+    short_price       DECIMAL NOT NULL,
+    long_funding      DECIMAL NOT NULL,
+    short_funding     DECIMAL NOT NULL,
+    basis             DECIMAL NOT NULL,
+    fees              DECIMAL NOT NULL,
+    annualized_carry  DECIMAL NOT NULL,
+    next_funding_time TIMESTAMP,
+
+    PRIMARY KEY (kind, pair, long_exchange, short_exchange)
+);
+
+CREATE INDEX carries__annualized_carry_idx ON carries (annualized_carry);
+
+CREATE TRIGGER set_timestamp
+    BEFORE UPDATE
+    ON carries
+    FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
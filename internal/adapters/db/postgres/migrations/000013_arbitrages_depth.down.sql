@@ -0,0 +1,2 @@
+ALTER TABLE arbitrages
+    DROP COLUMN insufficient_depth;
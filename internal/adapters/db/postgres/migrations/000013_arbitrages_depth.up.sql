@@ -0,0 +1,2 @@
+ALTER TABLE arbitrages
+    ADD COLUMN insufficient_depth BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const opportunitiesTable = "opportunities"
+
+type Opportunity struct {
+	ID           uint64     `db:"id"`
+	CreatedAt    time.Time  `db:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at"`
+	Pair         string     `db:"pair"`
+	BuyExchange  string     `db:"buy_exchange"`
+	SellExchange string     `db:"sell_exchange"`
+	OpenedAt     time.Time  `db:"opened_at"`
+	ClosedAt     *time.Time `db:"closed_at"`
+	PeakProfit   float64    `db:"peak_profit"`
+	AvgProfit    float64    `db:"avg_profit"`
+	LastProfit   float64    `db:"last_profit"`
+	Updates      int        `db:"updates"`
+}
+
+func (o *Opportunity) toDomain() *domain.Opportunity {
+	return &domain.Opportunity{
+		ID:           o.ID,
+		Pair:         o.Pair,
+		BuyExchange:  o.BuyExchange,
+		SellExchange: o.SellExchange,
+		OpenedAt:     o.OpenedAt,
+		ClosedAt:     o.ClosedAt,
+		PeakProfit:   o.PeakProfit,
+		AvgProfit:    o.AvgProfit,
+		LastProfit:   o.LastProfit,
+		Updates:      o.Updates,
+	}
+}
+
+type OpportunityRepo struct {
+	db *DB
+}
+
+func (r *OpportunityRepo) Create(ctx context.Context, opportunity *domain.Opportunity) (*domain.Opportunity, error) {
+	clauses := map[string]interface{}{
+		"pair":          opportunity.Pair,
+		"buy_exchange":  opportunity.BuyExchange,
+		"sell_exchange": opportunity.SellExchange,
+		"opened_at":     opportunity.OpenedAt,
+		"closed_at":     opportunity.ClosedAt,
+		"peak_profit":   opportunity.PeakProfit,
+		"avg_profit":    opportunity.AvgProfit,
+		"last_profit":   opportunity.LastProfit,
+		"updates":       opportunity.Updates,
+	}
+
+	q, args, err := r.db.Sq.Insert(opportunitiesTable).SetMap(clauses).Suffix("RETURNING id").ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `Create`")
+	}
+
+	if err := r.db.GetContext(ctx, &opportunity.ID, q, args); err != nil {
+		return nil, errors.Wrap(err, "failed to exec query `Create`")
+	}
+
+	return opportunity, nil
+}
+
+// Update обновляет активную возможность по паре и биржам
+func (r *OpportunityRepo) Update(ctx context.Context, opportunity *domain.Opportunity) (int64, error) {
+	clauses := map[string]interface{}{
+		"closed_at":   opportunity.ClosedAt,
+		"peak_profit": opportunity.PeakProfit,
+		"avg_profit":  opportunity.AvgProfit,
+		"last_profit": opportunity.LastProfit,
+		"updates":     opportunity.Updates,
+	}
+
+	q, args, err := r.db.Sq.Update(opportunitiesTable).SetMap(clauses).Where(squirrel.Eq{
+		"pair":          opportunity.Pair,
+		"buy_exchange":  opportunity.BuyExchange,
+		"sell_exchange": opportunity.SellExchange,
+		"closed_at":     nil,
+	}).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "error build query `Update`")
+	}
+
+	result, err := r.db.ExecContext(ctx, q, args, true)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to exec query `Update`")
+	}
+
+	return result.RowsAffected()
+}
+
+// CloseActive закрывает все активные возможности, например оставшиеся после перезапуска
+func (r *OpportunityRepo) CloseActive(ctx context.Context, closedAt time.Time) error {
+	q, args, err := r.db.Sq.Update(opportunitiesTable).Set("closed_at", closedAt).Where(squirrel.Eq{"closed_at": nil}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "error build query `CloseActive`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args); err != nil {
+		return errors.Wrap(err, "failed to exec query `CloseActive`")
+	}
+
+	return nil
+}
+
+func (r *OpportunityRepo) FindAllByFilter(ctx context.Context, filter filters.OpportunityParams) ([]*domain.Opportunity, error) {
+	sb := r.db.Sq.Select("*").From(opportunitiesTable).Limit(uint64(filter.Limit))
+
+	if filter.Active {
+		sb = sb.Where(squirrel.Eq{"closed_at": nil})
+	} else {
+		sb = sb.Where(squirrel.NotEq{"closed_at": nil})
+	}
+
+	if filter.Pair != "" {
+		sb = sb.Where(squirrel.Eq{"pair": filter.Pair})
+	}
+
+	if filter.Exchange != "" {
+		sb = sb.Where(squirrel.Or{
+			squirrel.Eq{"buy_exchange": filter.Exchange},
+			squirrel.Eq{"sell_exchange": filter.Exchange},
+		})
+	}
+
+	if filter.MinDuration > 0 {
+		sb = sb.Where(
+			"EXTRACT(EPOCH FROM COALESCE(closed_at, ?) - opened_at) >= ?",
+			time.Now().UTC(), filter.MinDuration.Seconds(),
+		)
+	}
+
+	sb = sb.OrderBy(fmt.Sprintf("%s %s", filter.SortBy, filter.SortDir))
+
+	q, args, err := sb.ToSql()
+	if err != nil {
+		log.Error().Stack().Err(err).Msg("failed to build query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to build query `FindAllByFilter`")
+	}
+
+	var dbOpportunities []Opportunity
+	if err := r.db.SelectContext(ctx, q, &dbOpportunities, args); err != nil {
+		log.Error().Stack().Err(err).Msg("failed to exec query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to exec query `FindAllByFilter`")
+	}
+
+	opportunities := make([]*domain.Opportunity, 0, len(dbOpportunities))
+	for _, opportunity := range dbOpportunities {
+		opportunities = append(opportunities, opportunity.toDomain())
+	}
+
+	return opportunities, nil
+}
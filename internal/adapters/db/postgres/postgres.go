@@ -30,6 +30,11 @@ type DB struct {
 	phoneConfirmationRepo db.PhoneConfirmationRepo
 	jwtKeeperRepo         db.RefreshTokenRepo
 	arbitrageRepo         db.ArbitrageRepo
+	triangularRepo        db.TriangularArbitrageRepo
+	carryRepo             db.CarryRepo
+	routeRepo             db.RouteRepo
+	opportunityRepo       db.OpportunityRepo
+	candleRepo            db.CandleRepo
 }
 
 func NewDB(config *Config) (db.DB, error) {
@@ -194,3 +199,63 @@ func (r *DB) Arbitrage() db.ArbitrageRepo {
 
 	return r.arbitrageRepo
 }
+
+func (r *DB) TriangularArbitrage() db.TriangularArbitrageRepo {
+	if r.triangularRepo != nil {
+		return r.triangularRepo
+	}
+
+	r.triangularRepo = &TriangularArbitrageRepo{
+		db: r,
+	}
+
+	return r.triangularRepo
+}
+
+func (r *DB) Carry() db.CarryRepo {
+	if r.carryRepo != nil {
+		return r.carryRepo
+	}
+
+	r.carryRepo = &CarryRepo{
+		db: r,
+	}
+
+	return r.carryRepo
+}
+
+func (r *DB) Route() db.RouteRepo {
+	if r.routeRepo != nil {
+		return r.routeRepo
+	}
+
+	r.routeRepo = &RouteRepo{
+		db: r,
+	}
+
+	return r.routeRepo
+}
+
+func (r *DB) Opportunity() db.OpportunityRepo {
+	if r.opportunityRepo != nil {
+		return r.opportunityRepo
+	}
+
+	r.opportunityRepo = &OpportunityRepo{
+		db: r,
+	}
+
+	return r.opportunityRepo
+}
+
+func (r *DB) Candle() db.CandleRepo {
+	if r.candleRepo != nil {
+		return r.candleRepo
+	}
+
+	r.candleRepo = &CandleRepo{
+		db: r,
+	}
+
+	return r.candleRepo
+}
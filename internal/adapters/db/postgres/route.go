@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const routesTable = "routes"
+
+type Route struct {
+	Route     string    `db:"route"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Legs      []byte    `db:"legs"`
+	NetProfit float64   `db:"net_profit"`
+}
+
+func (r *Route) toDomain() (*domain.Route, error) {
+	var legs []*domain.RouteLeg
+	if err := json.Unmarshal(r.Legs, &legs); err != nil {
+		return nil, err
+	}
+
+	return &domain.Route{
+		Route:     r.Route,
+		Legs:      legs,
+		NetProfit: r.NetProfit,
+	}, nil
+}
+
+type RouteRepo struct {
+	db *DB
+}
+
+func (r *RouteRepo) Save(ctx context.Context, route *domain.Route) (*domain.Route, error) {
+	legs, err := json.Marshal(route.Legs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal legs `Save`")
+	}
+
+	clauses := map[string]interface{}{
+		"route":      route.Route,
+		"legs":       string(legs),
+		"net_profit": route.NetProfit,
+	}
+
+	q, args, err := r.db.Sq.Insert(routesTable).SetMap(clauses).
+		Suffix("ON CONFLICT (route) DO UPDATE SET legs = EXCLUDED.legs, net_profit = EXCLUDED.net_profit").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `Save`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return nil, errors.Wrap(err, "failed to exec query `Save`")
+	}
+
+	return route, nil
+}
+
+func (r *RouteRepo) FindAllByFilter(ctx context.Context, filter filters.RouteParams) ([]*domain.Route, error) {
+	sb := r.db.Sq.Select("*").From(routesTable).Limit(uint64(filter.Limit))
+
+	sb = sb.OrderBy(fmt.Sprintf("%s %s", filter.SortBy, filter.SortDir))
+
+	q, args, err := sb.ToSql()
+	if err != nil {
+		log.Error().Stack().Err(err).Msg("failed to build query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to build query `FindAllByFilter`")
+	}
+
+	var dbRoutes []Route
+	if err := r.db.SelectContext(ctx, q, &dbRoutes, args); err != nil {
+		log.Error().Stack().Err(err).Msg("failed to exec query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to exec query `FindAllByFilter`")
+	}
+
+	routes := make([]*domain.Route, 0, len(dbRoutes))
+	for _, dbRoute := range dbRoutes {
+		route, err := dbRoute.toDomain()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal legs `FindAllByFilter`")
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
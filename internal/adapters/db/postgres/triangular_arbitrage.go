@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const triangularArbitragesTable = "triangular_arbitrages"
+
+type TriangularArbitrage struct {
+	Exchange  string    `db:"exchange"`
+	Route     string    `db:"route"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Legs      []byte    `db:"legs"`
+	Profit    float64   `db:"profit"`
+	NetProfit float64   `db:"net_profit"`
+}
+
+func (a *TriangularArbitrage) toDomain() (*domain.TriangularArbitrage, error) {
+	var legs []*domain.TriangularLeg
+	if err := json.Unmarshal(a.Legs, &legs); err != nil {
+		return nil, err
+	}
+
+	return &domain.TriangularArbitrage{
+		Exchange:  a.Exchange,
+		Route:     a.Route,
+		Legs:      legs,
+		Profit:    a.Profit,
+		NetProfit: a.NetProfit,
+	}, nil
+}
+
+type TriangularArbitrageRepo struct {
+	db *DB
+}
+
+func (r *TriangularArbitrageRepo) Save(ctx context.Context, arbitrage *domain.TriangularArbitrage) (*domain.TriangularArbitrage, error) {
+	legs, err := json.Marshal(arbitrage.Legs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal legs `Save`")
+	}
+
+	clauses := map[string]interface{}{
+		"exchange":   arbitrage.Exchange,
+		"route":      arbitrage.Route,
+		"legs":       string(legs),
+		"profit":     arbitrage.Profit,
+		"net_profit": arbitrage.NetProfit,
+	}
+
+	q, args, err := r.db.Sq.Insert(triangularArbitragesTable).SetMap(clauses).
+		Suffix("ON CONFLICT (exchange, route) DO UPDATE SET legs = EXCLUDED.legs, profit = EXCLUDED.profit, net_profit = EXCLUDED.net_profit").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query `Save`")
+	}
+
+	if _, err := r.db.ExecContext(ctx, q, args, true); err != nil {
+		return nil, errors.Wrap(err, "failed to exec query `Save`")
+	}
+
+	return arbitrage, nil
+}
+
+func (r *TriangularArbitrageRepo) FindAllByFilter(ctx context.Context, filter filters.TriangularArbitrageParams) ([]*domain.TriangularArbitrage, error) {
+	sb := r.db.Sq.Select("*").From(triangularArbitragesTable).Limit(uint64(filter.Limit))
+
+	if filter.Exchange != "" {
+		sb = sb.Where(squirrel.Eq{"exchange": filter.Exchange})
+	}
+
+	sb = sb.OrderBy(fmt.Sprintf("%s %s", filter.SortBy, filter.SortDir))
+
+	q, args, err := sb.ToSql()
+	if err != nil {
+		log.Error().Stack().Err(err).Msg("failed to build query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to build query `FindAllByFilter`")
+	}
+
+	var dbArbitrages []TriangularArbitrage
+	if err := r.db.SelectContext(ctx, q, &dbArbitrages, args); err != nil {
+		log.Error().Stack().Err(err).Msg("failed to exec query `FindAllByFilter`")
+		return nil, errors.Wrap(err, "failed to exec query `FindAllByFilter`")
+	}
+
+	arbitrages := make([]*domain.TriangularArbitrage, 0, len(dbArbitrages))
+	for _, dbArbitrage := range dbArbitrages {
+		arbitrage, err := dbArbitrage.toDomain()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal legs `FindAllByFilter`")
+		}
+
+		arbitrages = append(arbitrages, arbitrage)
+	}
+
+	return arbitrages, nil
+}
@@ -5,6 +5,7 @@ import (
 	"calc/internal/domain"
 	"context"
 	"github.com/oklog/ulid/v2"
+	"time"
 )
 
 type RefreshTokenRepo interface {
@@ -33,6 +34,42 @@ type ArbitrageRepo interface {
 	Create(ctx context.Context, arbitrage *domain.Arbitrage) (*domain.Arbitrage, error)
 	FindAllByFilter(ctx context.Context, filter filters.ArbitrageParams) ([]*domain.Arbitrage, error)
 	FindByID(ctx context.Context, id uint64) (*domain.Arbitrage, error)
-	FindByPair(ctx context.Context, pair string) (*domain.Arbitrage, error)
+	FindByPair(ctx context.Context, pair string) ([]*domain.Arbitrage, error)
 	Update(ctx context.Context, arbitrage *domain.Arbitrage) (int64, error)
+	Delete(ctx context.Context, arbitrage *domain.Arbitrage) error
+	// SaveBatch и DeleteBatch сохраняют и удаляют пакет комбинаций за один запрос
+	SaveBatch(ctx context.Context, arbitrages []*domain.Arbitrage) error
+	DeleteBatch(ctx context.Context, arbitrages []*domain.Arbitrage) error
+}
+
+type TriangularArbitrageRepo interface {
+	Save(ctx context.Context, arbitrage *domain.TriangularArbitrage) (*domain.TriangularArbitrage, error)
+	FindAllByFilter(ctx context.Context, filter filters.TriangularArbitrageParams) ([]*domain.TriangularArbitrage, error)
+}
+
+type CarryRepo interface {
+	Save(ctx context.Context, carry *domain.Carry) (*domain.Carry, error)
+	Delete(ctx context.Context, carry *domain.Carry) error
+	FindAllByFilter(ctx context.Context, filter filters.CarryParams) ([]*domain.Carry, error)
+}
+
+type RouteRepo interface {
+	Save(ctx context.Context, route *domain.Route) (*domain.Route, error)
+	FindAllByFilter(ctx context.Context, filter filters.RouteParams) ([]*domain.Route, error)
+}
+
+type OpportunityRepo interface {
+	Create(ctx context.Context, opportunity *domain.Opportunity) (*domain.Opportunity, error)
+	Update(ctx context.Context, opportunity *domain.Opportunity) (int64, error)
+	CloseActive(ctx context.Context, closedAt time.Time) error
+	FindAllByFilter(ctx context.Context, filter filters.OpportunityParams) ([]*domain.Opportunity, error)
+}
+
+type CandleRepo interface {
+	SaveQuotes(ctx context.Context, candles []*domain.QuoteCandle) error
+	SaveSpreads(ctx context.Context, candles []*domain.SpreadCandle) error
+	Rollup(ctx context.Context, from, to domain.Resolution, since time.Time) error
+	DeleteBefore(ctx context.Context, resolution domain.Resolution, before time.Time) error
+	FindQuotes(ctx context.Context, filter filters.CandleParams) ([]*domain.QuoteCandle, error)
+	FindSpreads(ctx context.Context, filter filters.CandleParams) ([]*domain.SpreadCandle, error)
 }
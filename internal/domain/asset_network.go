@@ -0,0 +1,12 @@
+package domain
+
+// AssetNetwork условия ввода и вывода актива с биржи через конкретную сеть
+type AssetNetwork struct {
+	Exchange        string
+	Asset           string
+	Network         string
+	WithdrawFee     float64
+	MinWithdraw     float64
+	DepositEnabled  bool
+	WithdrawEnabled bool
+}
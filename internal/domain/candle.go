@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+var (
+	ErrUnknownResolution = errors.New("unknown resolution")
+)
+
+// Resolution интервал свечей истории
+type Resolution string
+
+const (
+	ResolutionSecond Resolution = "1s"
+	ResolutionMinute Resolution = "1m"
+	ResolutionHour   Resolution = "1h"
+)
+
+// Resolutions интервалы от мелкого к крупному, каждый следующий строится из предыдущего
+var Resolutions = []Resolution{ResolutionSecond, ResolutionMinute, ResolutionHour}
+
+func NewResolution(str string) (Resolution, error) {
+	r := Resolution(str)
+	for _, resolution := range Resolutions {
+		if r == resolution {
+			return r, nil
+		}
+	}
+
+	return "", ErrUnknownResolution
+}
+
+func (r Resolution) Duration() time.Duration {
+	switch r {
+	case ResolutionSecond:
+		return time.Second
+	case ResolutionMinute:
+		return time.Minute
+	case ResolutionHour:
+		return time.Hour
+	}
+
+	return 0
+}
+
+// MaxSpan наибольший период запроса истории с интервалом r, около 10000 свечей на биржу
+func (r Resolution) MaxSpan() time.Duration {
+	switch r {
+	case ResolutionSecond:
+		return 3 * time.Hour
+	case ResolutionMinute:
+		return 7 * 24 * time.Hour
+	case ResolutionHour:
+		return 365 * 24 * time.Hour
+	}
+
+	return 0
+}
+
+// Candle OHLC за интервал, Time - начало интервала
+type Candle struct {
+	Time  time.Time
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
+}
+
+func NewCandle(t time.Time, value float64) Candle {
+	return Candle{
+		Time:  t,
+		Open:  value,
+		High:  value,
+		Low:   value,
+		Close: value,
+	}
+}
+
+// Put добавляет значение в свечу
+func (c *Candle) Put(value float64) {
+	if value > c.High {
+		c.High = value
+	}
+	if value < c.Low {
+		c.Low = value
+	}
+	c.Close = value
+}
+
+// Merge дополняет свечу более поздней свечей того же интервала
+func (c *Candle) Merge(later Candle) {
+	if later.High > c.High {
+		c.High = later.High
+	}
+	if later.Low < c.Low {
+		c.Low = later.Low
+	}
+	c.Close = later.Close
+}
+
+// QuoteCandle свеча средней цены пары на бирже и последние bid/ask интервала
+type QuoteCandle struct {
+	Candle
+	Exchange   string
+	Pair       string
+	Resolution Resolution
+	Bid        float64
+	Ask        float64
+}
+
+// SpreadCandle свеча чистой прибыли комбинации бирж покупки и продажи
+type SpreadCandle struct {
+	Candle
+	Pair         string
+	BuyExchange  string
+	SellExchange string
+	Resolution   Resolution
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+const (
+	// CarryBasis покупка спота и продажа бессрочного фьючерса
+	CarryBasis = "basis"
+	// CarryFunding покупка бессрочного фьючерса с меньшей ставкой финансирования и продажа с большей
+	CarryFunding = "funding"
+)
+
+// Carry позиция, которая зарабатывает на сходимости цен и финансировании бессрочного фьючерса
+type Carry struct {
+	Kind          string
+	Pair          string
+	LongExchange  string
+	LongMarket    string
+	ShortExchange string
+	LongPrice     float64
+	ShortPrice    float64
+	// LongFunding и ShortFunding годовые ставки финансирования в процентах, у спота 0
+	LongFunding  float64
+	ShortFunding float64
+	// Basis разница цены продажи и цены покупки в процентах от цены покупки
+	Basis float64
+	// Fees комиссии тейкера за открытие и закрытие обеих сторон в процентах
+	Fees float64
+	// AnnualizedCarry годовая доходность при удержании позиции на горизонте расчета после комиссий в процентах
+	AnnualizedCarry float64
+	NextFundingTime time.Time
+}
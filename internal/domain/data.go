@@ -1,15 +1,97 @@
 package domain
 
-import "github.com/pkg/errors"
+import (
+	"github.com/pkg/errors"
+	"time"
+)
 
 var (
 	ErrNotEqualPairs = errors.New("not equal pairs")
 )
 
-type Data struct {
-	Exchange string
-	Pair     string
+const (
+	MarketSpot = "spot"
+	// MarketPerp бессрочный фьючерс с маржой в валюте котировки (USDⓈ-M)
+	MarketPerp = "perp"
+)
+
+// PriceLevel уровень стакана
+type PriceLevel struct {
 	Price    float64
+	Quantity float64
+}
+
+type Data struct {
+	Exchange    string
+	Pair        string
+	Bid         float64
+	BidQuantity float64
+	Ask         float64
+	AskQuantity float64
+	// Bids и Asks стакан, отсортированный от лучшей цены к худшей
+	Bids []PriceLevel
+	Asks []PriceLevel
+	// Time время получения стакана в UTC
+	Time time.Time
+	// Market тип рынка, пустой у спота
+	Market string
+	// MarkPrice, FundingRate и NextFundingTime заполняются у бессрочных фьючерсов.
+	// FundingRate ставка финансирования за период FundingInterval в процентах.
+	MarkPrice       float64
+	FundingRate     float64
+	FundingInterval time.Duration
+	NextFundingTime time.Time
+}
+
+// NewData создает данные по стакану, лучшие цены берутся из первых уровней
+func NewData(exchange, pair string, bids, asks []PriceLevel) *Data {
+	data := &Data{
+		Exchange: exchange,
+		Pair:     pair,
+		Bids:     bids,
+		Asks:     asks,
+		Time:     time.Now().UTC(),
+	}
+
+	if len(bids) > 0 {
+		data.Bid = bids[0].Price
+		data.BidQuantity = bids[0].Quantity
+	}
+
+	if len(asks) > 0 {
+		data.Ask = asks[0].Price
+		data.AskQuantity = asks[0].Quantity
+	}
+
+	return data
+}
+
+// Perpetual возвращает, относится ли стакан к бессрочному фьючерсу
+func (d *Data) Perpetual() bool {
+	return d.Market == MarketPerp
+}
+
+// Equal сравнивает стаканы без учета биржи и пары
+func (d *Data) Equal(other *Data) bool {
+	if other == nil {
+		return false
+	}
+
+	return equalLevels(d.Bids, other.Bids) && equalLevels(d.Asks, other.Asks)
+}
+
+func equalLevels(a, b []PriceLevel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
 }
 
 type Arbitrage struct {
@@ -17,6 +99,27 @@ type Arbitrage struct {
 	BuyExchange  string
 	SellExchange string
 	BuyPrice     float64
+	BuyQuantity  float64
 	SellPrice    float64
+	SellQuantity float64
+	BuyFee       float64
+	SellFee      float64
+	// TransferNetwork сеть для перевода актива с биржи покупки на биржу продажи
+	TransferNetwork string
+	// TransferFee стоимость перевода актива в процентах от объема сделки
+	TransferFee  float64
+	Transferable bool
 	Profit       float64
+	NetProfit    float64
+	// MaxVolume максимальный объем в базовой валюте, при котором сделка остается прибыльной
+	MaxVolume float64
+	// BuyVWAP и SellVWAP средневзвешенные цены покупки и продажи на объем сделки
+	BuyVWAP        float64
+	SellVWAP       float64
+	ProfitAtVolume float64
+	// InsufficientDepth стакана не хватает на объем сделки: VWAP посчитаны по доступному объему,
+	// ProfitAtVolume нулевая
+	InsufficientDepth bool
+	// UpdatedAt время расчета комбинации в UTC
+	UpdatedAt time.Time
 }
@@ -0,0 +1,11 @@
+package domain
+
+// Fee комиссия биржи по паре в процентах
+type Fee struct {
+	Exchange string
+	Pair     string
+	// Market тип рынка, пустой у спота
+	Market string
+	Maker  float64
+	Taker  float64
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// HealthStatus состояние потока котировок биржи
+type HealthStatus string
+
+const (
+	// HealthConnected соединение установлено, все пары обновляются
+	HealthConnected HealthStatus = "connected"
+	// HealthDegraded соединение установлено, часть пар устарела
+	HealthDegraded HealthStatus = "degraded"
+	// HealthStale соединение установлено, но ни одна пара не обновляется
+	HealthStale HealthStatus = "stale"
+	// HealthDown соединения нет
+	HealthDown HealthStatus = "down"
+)
+
+// Usable можно ли использовать котировки биржи
+func (s HealthStatus) Usable() bool {
+	return s == HealthConnected || s == HealthDegraded
+}
+
+type ExchangeHealth struct {
+	Exchange string
+	Status   HealthStatus
+	// Connection состояние websocket соединения
+	Connection string
+	// LastUpdate время последнего обновления любой пары, нулевое если обновлений не было
+	LastUpdate time.Time
+	StaleAfter time.Duration
+	Pairs      []*PairHealth
+}
+
+type PairHealth struct {
+	Pair       string
+	LastUpdate time.Time
+	Stale      bool
+}
@@ -0,0 +1,21 @@
+package domain
+
+// Market торгуемая пара биржи с параметрами для отбора общих пар
+type Market struct {
+	Exchange string
+	Pair     string
+	Base     string
+	Quote    string
+	// Volume объем торгов за 24 часа в валюте котировки
+	Volume float64
+	// MinNotional минимальная сумма ордера в валюте котировки, 0 - не ограничена
+	MinNotional float64
+}
+
+// CommonPair пара, торгуемая на нескольких биржах
+type CommonPair struct {
+	Pair  string
+	Quote string
+	// Volumes объем за 24 часа в валюте котировки по биржам
+	Volumes map[string]float64
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// Opportunity арбитражная возможность по комбинации бирж: открывается, когда чистая прибыль превышает порог,
+// и закрывается, когда опускается ниже порога закрытия
+type Opportunity struct {
+	ID           uint64
+	Pair         string
+	BuyExchange  string
+	SellExchange string
+	OpenedAt     time.Time
+	// ClosedAt nil, пока возможность активна
+	ClosedAt   *time.Time
+	PeakProfit float64
+	AvgProfit  float64
+	LastProfit float64
+	Updates    int
+}
+
+// Duration длительность возможности, для активной - на момент now
+func (o *Opportunity) Duration(now time.Time) time.Duration {
+	if o.ClosedAt != nil {
+		return o.ClosedAt.Sub(o.OpenedAt)
+	}
+
+	return now.Sub(o.OpenedAt)
+}
@@ -0,0 +1,29 @@
+package domain
+
+const (
+	LegTrade    = "trade"
+	LegTransfer = "transfer"
+)
+
+// Route цикл обменов и переводов между биржами, начинающийся и заканчивающийся в одном активе на одной бирже
+type Route struct {
+	// Route последовательность узлов биржа:актив, например binance:BTC>binance:USDT>gate:USDT>gate:BTC>binance:BTC
+	Route     string
+	Legs      []*RouteLeg
+	NetProfit float64
+}
+
+// RouteLeg шаг маршрута: сделка по паре на бирже или перевод актива на другую биржу
+type RouteLeg struct {
+	Type     string `json:"type"`
+	Exchange string `json:"exchange"`
+	Asset    string `json:"asset"`
+	// Pair, Side и Price заполняются для сделки
+	Pair  string  `json:"pair,omitempty"`
+	Side  string  `json:"side,omitempty"`
+	Price float64 `json:"price,omitempty"`
+	// ToExchange и Network заполняются для перевода
+	ToExchange string  `json:"to_exchange,omitempty"`
+	Network    string  `json:"network,omitempty"`
+	Fee        float64 `json:"fee"`
+}
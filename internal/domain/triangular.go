@@ -0,0 +1,23 @@
+package domain
+
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// TriangularArbitrage цикл обменов внутри одной биржи, начинающийся и заканчивающийся в одном активе
+type TriangularArbitrage struct {
+	Exchange string
+	// Route последовательность активов, например BTC>ETH>USDT>BTC
+	Route     string
+	Legs      []*TriangularLeg
+	Profit    float64
+	NetProfit float64
+}
+
+type TriangularLeg struct {
+	Pair  string  `json:"pair"`
+	Side  string  `json:"side"`
+	Price float64 `json:"price"`
+	Fee   float64 `json:"fee"`
+}
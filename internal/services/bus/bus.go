@@ -0,0 +1,190 @@
+// Package bus шина рыночных данных: адаптеры бирж публикуют котировки один раз,
+// калькулятор, история и websocket-клиенты получают их через собственные очереди.
+package bus
+
+import (
+	"calc/common/config"
+	"calc/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+const (
+	// EventQuote котировка спота
+	EventQuote = "quote"
+	// EventPerp котировка бессрочного фьючерса со ставкой финансирования
+	EventPerp = "perp"
+
+	defaultBuffer = 1024
+	defaultPolicy = PolicyDropOldest
+)
+
+var (
+	promPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "bus_published_total",
+		Help:      "market data messages published to the bus",
+	}, []string{"event"})
+	promDelivered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "bus_delivered_total",
+		Help:      "market data messages delivered to subscribers",
+	}, []string{"subscriber"})
+	promDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "bus_dropped_total",
+		Help:      "market data messages dropped or replaced in subscriber queues",
+	}, []string{"subscriber", "reason"})
+	promQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "bus_queued",
+		Help:      "market data messages waiting in subscriber queues",
+	}, []string{"subscriber"})
+	promSubscribers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "bus_subscribers",
+		Help:      "active bus subscribers",
+	}, []string{"subscriber"})
+	promRegister sync.Once
+)
+
+// Event возвращает тип события котировки
+func Event(data *domain.Data) string {
+	if data.Perpetual() {
+		return EventPerp
+	}
+
+	return EventQuote
+}
+
+// Filter отбирает сообщения подписки, пустые поля подходят под любое значение
+type Filter struct {
+	Exchange string
+	Pair     string
+	Event    string
+}
+
+func (f Filter) match(data *domain.Data, event string) bool {
+	return (f.Exchange == "" || f.Exchange == data.Exchange) &&
+		(f.Pair == "" || f.Pair == data.Pair) &&
+		(f.Event == "" || f.Event == event)
+}
+
+// Option задает очередь подписчика по умолчанию, настройки подписчика в конфиге имеют приоритет
+type Option func(s *Subscription)
+
+func WithBuffer(size int) Option {
+	return func(s *Subscription) {
+		s.size = size
+	}
+}
+
+func WithPolicy(policy Policy) Option {
+	return func(s *Subscription) {
+		s.policy = policy
+	}
+}
+
+// Bus рассылает опубликованные котировки подписчикам, чьи фильтры им соответствуют.
+// Публикация ждет только подписчиков с политикой block, остальные теряют или схлопывают сообщения
+// при переполнении своей очереди и не задерживают поток биржи.
+type Bus struct {
+	cfg *config.Bus
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func New(cfg *config.Bus) *Bus {
+	promRegister.Do(func() {
+		prometheus.MustRegister(promPublished, promDelivered, promDropped, promQueued, promSubscribers)
+	})
+
+	if cfg == nil {
+		cfg = &config.Bus{}
+	}
+
+	return &Bus{
+		cfg:  cfg,
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish передает котировку подписчикам
+func (b *Bus) Publish(data *domain.Data) {
+	event := Event(data)
+	promPublished.WithLabelValues(event).Inc()
+
+	b.mu.RLock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		if s.filter.match(data, event) {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		s.push(data)
+	}
+}
+
+// Subscribe создает подписку. Имя подписчика используется в метриках и для поиска его настроек в конфиге,
+// поэтому однотипные подписчики, например websocket-клиенты, подписываются под одним именем.
+func (b *Bus) Subscribe(name string, filter Filter, options ...Option) *Subscription {
+	s := &Subscription{
+		name:   name,
+		filter: filter,
+		size:   b.cfg.Buffer,
+		policy: Policy(b.cfg.Policy),
+		bus:    b,
+		ready:  make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		drain:  make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	if cfg, ok := b.cfg.Subscribers[name]; ok && cfg != nil {
+		if cfg.Buffer > 0 {
+			s.size = cfg.Buffer
+		}
+
+		if cfg.Policy != "" {
+			s.policy = Policy(cfg.Policy)
+		}
+	}
+
+	if s.size <= 0 {
+		s.size = defaultBuffer
+	}
+
+	if !s.policy.valid() {
+		if s.policy != "" {
+			log.Warn().Str("subscriber", name).Str("policy", string(s.policy)).Msg("bus: unknown policy, using default")
+		}
+		s.policy = defaultPolicy
+	}
+
+	if s.policy == PolicyConflate {
+		s.latest = make(map[string]*domain.Data)
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	promSubscribers.WithLabelValues(name).Inc()
+
+	return s
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
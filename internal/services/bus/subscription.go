@@ -0,0 +1,251 @@
+package bus
+
+import (
+	"calc/internal/domain"
+	"context"
+	"sync"
+)
+
+// Policy поведение очереди подписчика при переполнении
+type Policy string
+
+const (
+	// PolicyBlock публикация ждет места в очереди
+	PolicyBlock Policy = "block"
+	// PolicyDropNewest новое сообщение отбрасывается
+	PolicyDropNewest Policy = "drop_newest"
+	// PolicyDropOldest из очереди вытесняется самое старое сообщение
+	PolicyDropOldest Policy = "drop_oldest"
+	// PolicyConflate в очереди хранится только последняя котировка по бирже, рынку и паре,
+	// при переполнении вытесняется самая старая из них
+	PolicyConflate Policy = "conflate"
+)
+
+func (p Policy) valid() bool {
+	switch p {
+	case PolicyBlock, PolicyDropNewest, PolicyDropOldest, PolicyConflate:
+		return true
+	}
+
+	return false
+}
+
+// Subscription очередь подписчика шины ограниченного размера
+type Subscription struct {
+	name   string
+	filter Filter
+	size   int
+	policy Policy
+	bus    *Bus
+
+	mu    sync.Mutex
+	queue []*domain.Data
+	// keys и latest заменяют queue при политике conflate: порядок ключей и последняя котировка по ключу
+	keys   []string
+	latest map[string]*domain.Data
+	closed bool
+
+	// ready сигнал о новых сообщениях, space - об освободившемся месте для политики block
+	ready     chan struct{}
+	space     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// drain закрывается, когда подписка перестает принимать сообщения и дочитывает очередь
+	drain     chan struct{}
+	drainOnce sync.Once
+}
+
+// Next ждет следующее сообщение, false - контекст закончился, подписка закрыта
+// или очередь дочитана после Drain
+func (s *Subscription) Next(ctx context.Context) (*domain.Data, bool) {
+	for {
+		s.mu.Lock()
+		data, ok := s.pop()
+		s.mu.Unlock()
+
+		if ok {
+			notify(s.space)
+			promQueued.WithLabelValues(s.name).Dec()
+			promDelivered.WithLabelValues(s.name).Inc()
+			return data, true
+		}
+
+		select {
+		case <-s.drain:
+			return nil, false
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-s.done:
+			return nil, false
+		case <-s.drain:
+		case <-s.ready:
+		}
+	}
+}
+
+// Drain прекращает прием сообщений: Next отдает оставшиеся в очереди и затем возвращает false
+func (s *Subscription) Drain() {
+	s.drainOnce.Do(func() {
+		s.mu.Lock()
+		close(s.drain)
+		s.mu.Unlock()
+	})
+}
+
+// Consume передает сообщения подписки в handle, пока не закончится контекст или подписка, затем закрывает ее
+func (s *Subscription) Consume(ctx context.Context, handle func(data *domain.Data)) {
+	defer s.Close()
+
+	for {
+		data, ok := s.Next(ctx)
+		if !ok {
+			return
+		}
+
+		handle(data)
+	}
+}
+
+// Close отписывается от шины, сообщения в очереди отбрасываются
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.bus.unsubscribe(s)
+
+		s.mu.Lock()
+		s.closed = true
+		queued := s.len()
+		s.queue, s.keys, s.latest = nil, nil, nil
+		s.mu.Unlock()
+
+		close(s.done)
+
+		promQueued.WithLabelValues(s.name).Sub(float64(queued))
+		promSubscribers.WithLabelValues(s.name).Dec()
+	})
+}
+
+func (s *Subscription) push(data *domain.Data) {
+	for {
+		s.mu.Lock()
+		if s.closed || s.draining() {
+			s.mu.Unlock()
+			return
+		}
+
+		if s.len() < s.size || s.policy != PolicyBlock {
+			queued, dropped := s.put(data)
+			s.mu.Unlock()
+
+			if queued {
+				promQueued.WithLabelValues(s.name).Inc()
+				notify(s.ready)
+			}
+			if dropped != "" {
+				promDropped.WithLabelValues(s.name, dropped).Inc()
+			}
+
+			return
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.space:
+		case <-s.done:
+			return
+		case <-s.drain:
+			return
+		}
+	}
+}
+
+func (s *Subscription) draining() bool {
+	select {
+	case <-s.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// put кладет сообщение в очередь по политике подписчика. Возвращает, выросла ли очередь,
+// и причину потери сообщения: full - отброшено при переполнении, conflated - заменено более новым.
+func (s *Subscription) put(data *domain.Data) (queued bool, dropped string) {
+	if s.policy == PolicyConflate {
+		key := data.Exchange + ":" + Event(data) + ":" + data.Pair
+		if _, ok := s.latest[key]; ok {
+			s.latest[key] = data
+			return false, "conflated"
+		}
+
+		if len(s.keys) >= s.size {
+			delete(s.latest, s.keys[0])
+			s.keys = s.keys[1:]
+			queued, dropped = false, "full"
+		} else {
+			queued = true
+		}
+
+		s.keys = append(s.keys, key)
+		s.latest[key] = data
+
+		return queued, dropped
+	}
+
+	if len(s.queue) < s.size {
+		s.queue = append(s.queue, data)
+		return true, ""
+	}
+
+	if s.policy == PolicyDropNewest {
+		return false, "full"
+	}
+
+	s.queue = append(s.queue[1:], data)
+
+	return false, "full"
+}
+
+func (s *Subscription) pop() (*domain.Data, bool) {
+	if s.policy == PolicyConflate {
+		if len(s.keys) == 0 {
+			return nil, false
+		}
+
+		key := s.keys[0]
+		s.keys = s.keys[1:]
+		data := s.latest[key]
+		delete(s.latest, key)
+
+		return data, true
+	}
+
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+
+	data := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	return data, true
+}
+
+func (s *Subscription) len() int {
+	if s.policy == PolicyConflate {
+		return len(s.keys)
+	}
+
+	return len(s.queue)
+}
+
+// notify отправляет сигнал, не дожидаясь получателя: непрочитанный сигнал уже разбудит его
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -2,55 +2,189 @@ package calculator
 
 import (
 	"calc/internal/domain"
-	"math"
+	"calc/internal/services/health"
+	"sort"
+	"time"
 )
 
+// calculator считает комбинации бирж одной пары. Не потокобезопасен: пара принадлежит одному обработчику pipeline.
 type calculator struct {
-	pair      string
-	arbitrage domain.Arbitrage
+	pair string
+	// tradeSize объем сделки в валюте котировки
+	tradeSize float64
+	// limit число лучших комбинаций бирж, 0 - без ограничения
+	limit     int
+	fees      *feeSchedule
+	transfers *transferSchedule
+	health    *health.Tracker
+	// quotes последние котировки пары по биржам
+	quotes map[string]*domain.Data
+	// top комбинации бирж, вошедшие в лучшие при последнем пересчете
+	top map[string]*domain.Arbitrage
 }
 
-func NewCalculator(pair string) *calculator {
+func NewCalculator(
+	pair string,
+	tradeSize float64,
+	limit int,
+	fees *feeSchedule,
+	transfers *transferSchedule,
+	healthTracker *health.Tracker,
+) *calculator {
 	return &calculator{
-		pair: pair,
-		arbitrage: domain.Arbitrage{
-			Pair:      pair,
-			SellPrice: -1,
-			BuyPrice:  math.MaxFloat64,
-		},
+		pair:      pair,
+		tradeSize: tradeSize,
+		limit:     limit,
+		fees:      fees,
+		transfers: transfers,
+		health:    healthTracker,
+		quotes:    make(map[string]*domain.Data),
+		top:       make(map[string]*domain.Arbitrage),
 	}
 }
 
-func (c *calculator) Put(data *domain.Data) *domain.Arbitrage {
-	if c.pair != data.Pair {
-		return nil
+// Put обновляет котировку биржи и пересчитывает все комбинации бирж покупки и продажи по последним котировкам
+// с учетом комиссии тейкера на каждой бирже и стоимости перевода актива между ними.
+// Возвращает изменившиеся комбинации из лучших limit и комбинации, выбывшие из лучших.
+func (c *calculator) Put(data *domain.Data) (updated []*domain.Arbitrage, removed []*domain.Arbitrage) {
+	if c.pair != data.Pair || data.Bid <= 0 || data.Ask <= 0 {
+		return nil, nil
 	}
 
-	if (c.arbitrage.SellExchange == data.Exchange) || (c.arbitrage.SellPrice < data.Price && c.arbitrage.BuyExchange != data.Exchange) {
-		c.calcSell(data)
-		return &c.arbitrage
+	c.quotes[data.Exchange] = data
+
+	return c.rank(data.Time, data.Exchange)
+}
+
+// Expire пересчитывает комбинации без новой котировки, чтобы убрать из лучших комбинации с устаревшими котировками
+func (c *calculator) Expire(now time.Time) (updated []*domain.Arbitrage, removed []*domain.Arbitrage) {
+	return c.rank(now, "")
+}
+
+// Clear удаляет котировки пары и возвращает комбинации, бывшие в лучших
+func (c *calculator) Clear() (removed []*domain.Arbitrage) {
+	for _, arbitrage := range c.top {
+		removed = append(removed, arbitrage)
+	}
+
+	c.quotes = make(map[string]*domain.Data)
+	c.top = make(map[string]*domain.Arbitrage)
+
+	return removed
+}
+
+// rank пересчитывает комбинации по свежим на момент now котировкам.
+// Комбинации с биржей exchange считаются изменившимися.
+func (c *calculator) rank(now time.Time, exchange string) (updated []*domain.Arbitrage, removed []*domain.Arbitrage) {
+	var combinations []*domain.Arbitrage
+	for _, buy := range c.quotes {
+		if !c.health.Fresh(buy.Exchange, c.pair, now) {
+			continue
+		}
+
+		for _, sell := range c.quotes {
+			if buy.Exchange == sell.Exchange || !c.health.Fresh(sell.Exchange, c.pair, now) {
+				continue
+			}
+
+			arbitrage := c.calc(buy, sell)
+			arbitrage.UpdatedAt = now
+			if arbitrage.Transferable {
+				combinations = append(combinations, arbitrage)
+			}
+		}
+	}
+
+	sort.Slice(combinations, func(i, j int) bool {
+		return combinations[i].NetProfit > combinations[j].NetProfit
+	})
+
+	if c.limit > 0 && len(combinations) > c.limit {
+		combinations = combinations[:c.limit]
+	}
+
+	top := make(map[string]*domain.Arbitrage, len(combinations))
+	for _, arbitrage := range combinations {
+		key := combinationKey(arbitrage)
+		top[key] = arbitrage
+
+		_, ok := c.top[key]
+		if !ok || arbitrage.BuyExchange == exchange || arbitrage.SellExchange == exchange {
+			updated = append(updated, arbitrage)
+		}
 	}
 
-	if (c.arbitrage.BuyExchange == data.Exchange) || (c.arbitrage.BuyPrice > data.Price && c.arbitrage.SellExchange != data.Exchange) {
-		c.calcBuy(data)
-		return &c.arbitrage
+	for key, arbitrage := range c.top {
+		if _, ok := top[key]; !ok {
+			removed = append(removed, arbitrage)
+		}
 	}
 
-	return nil
+	c.top = top
+
+	return updated, removed
 }
 
-func (c *calculator) calcBuy(data *domain.Data) {
-	c.arbitrage.BuyPrice = data.Price
-	c.arbitrage.BuyExchange = data.Exchange
-	c.calcProfit()
+func (c *calculator) calc(buy, sell *domain.Data) *domain.Arbitrage {
+	arbitrage := &domain.Arbitrage{
+		Pair:         c.pair,
+		BuyExchange:  buy.Exchange,
+		SellExchange: sell.Exchange,
+		BuyPrice:     buy.Ask,
+		BuyQuantity:  buy.AskQuantity,
+		SellPrice:    sell.Bid,
+		SellQuantity: sell.BidQuantity,
+		BuyFee:       c.fees.taker(buy.Exchange, c.pair),
+		SellFee:      c.fees.taker(sell.Exchange, c.pair),
+	}
+
+	c.calcProfit(arbitrage)
+	c.calcVolume(arbitrage, buy.Asks, sell.Bids)
+
+	return arbitrage
+}
+
+func (c *calculator) calcProfit(arbitrage *domain.Arbitrage) {
+	sellNet, buyNet := netBid(arbitrage.SellPrice, arbitrage.SellFee), netAsk(arbitrage.BuyPrice, arbitrage.BuyFee)
+
+	arbitrage.Profit = (arbitrage.SellPrice - arbitrage.BuyPrice) / arbitrage.SellPrice * 100
+	arbitrage.NetProfit = (sellNet - buyNet) / sellNet * 100
+
+	t := c.transfers.cheapest(c.pair, arbitrage.BuyExchange, arbitrage.SellExchange, c.tradeSize, arbitrage.BuyPrice, arbitrage.SellPrice)
+	arbitrage.Transferable = !t.impossible
+	arbitrage.TransferNetwork = t.network
+	arbitrage.TransferFee = t.fee
+	arbitrage.NetProfit -= t.fee
 }
 
-func (c *calculator) calcSell(data *domain.Data) {
-	c.arbitrage.SellPrice = data.Price
-	c.arbitrage.SellExchange = data.Exchange
-	c.calcProfit()
+// calcVolume считает максимальный прибыльный объем и прибыль по средневзвешенным ценам на объем сделки.
+// Если объем сделки не задан, прибыль считается на максимальный прибыльный объем. Если глубины одного
+// из стаканов на объем сделки не хватает, прибыль на объем не считается, комбинация помечается InsufficientDepth.
+func (c *calculator) calcVolume(arbitrage *domain.Arbitrage, asks, bids []domain.PriceLevel) {
+	arbitrage.MaxVolume = maxVolume(asks, bids, arbitrage.BuyFee, arbitrage.SellFee)
+
+	volume := arbitrage.MaxVolume
+	if c.tradeSize > 0 {
+		volume = c.tradeSize / arbitrage.BuyPrice
+	}
+
+	var buyFilled, sellFilled bool
+	arbitrage.BuyVWAP, buyFilled = vwap(asks, volume)
+	arbitrage.SellVWAP, sellFilled = vwap(bids, volume)
+
+	if arbitrage.BuyVWAP == 0 || arbitrage.SellVWAP == 0 {
+		return
+	}
+
+	if !buyFilled || !sellFilled {
+		arbitrage.InsufficientDepth = true
+		return
+	}
+
+	sell, buy := netBid(arbitrage.SellVWAP, arbitrage.SellFee), netAsk(arbitrage.BuyVWAP, arbitrage.BuyFee)
+	arbitrage.ProfitAtVolume = (sell-buy)/sell*100 - arbitrage.TransferFee
 }
 
-func (c *calculator) calcProfit() {
-	c.arbitrage.Profit = (c.arbitrage.SellPrice - c.arbitrage.BuyPrice) / c.arbitrage.SellPrice * 100
+func combinationKey(arbitrage *domain.Arbitrage) string {
+	return arbitrage.BuyExchange + ">" + arbitrage.SellExchange
 }
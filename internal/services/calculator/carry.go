@@ -0,0 +1,224 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"calc/internal/services/health"
+	"sync"
+	"time"
+)
+
+const (
+	year = 365 * 24 * time.Hour
+	// defaultFundingInterval период финансирования, если биржа его не передала
+	defaultFundingInterval = 8 * time.Hour
+	defaultHolding         = 30 * 24 * time.Hour
+)
+
+// carry ищет базис спот - бессрочный фьючерс и разницу ставок финансирования фьючерсов между биржами.
+// Доходность считается для удержания позиции в течение holding: базис закрывается при сходимости цен,
+// финансирование начисляется короткой стороне при положительной ставке и платится длинной.
+type carry struct {
+	mu       sync.Mutex
+	fees     *feeSchedule
+	holding  time.Duration
+	minCarry float64
+	health   *health.Tracker
+	// spot и perp последние котировки по парам и биржам
+	spot map[string]map[string]*domain.Data
+	perp map[string]map[string]*domain.Data
+	// profitable прибыльность позиций при последнем сохранении по ключу позиции
+	profitable map[string]bool
+}
+
+func newCarry(holding time.Duration, minCarry float64, fees *feeSchedule, healthTracker *health.Tracker) *carry {
+	if holding <= 0 {
+		holding = defaultHolding
+	}
+
+	return &carry{
+		fees:       fees,
+		holding:    holding,
+		minCarry:   minCarry,
+		health:     healthTracker,
+		spot:       make(map[string]map[string]*domain.Data),
+		perp:       make(map[string]map[string]*domain.Data),
+		profitable: make(map[string]bool),
+	}
+}
+
+// Put обновляет котировку и пересчитывает позиции пары с ее участием. Возвращает позиции, которые нужно сохранить:
+// с доходностью выше порога и те, что перестали его проходить, и бывшие прибыльными позиции с устаревшей
+// котировкой одной из сторон: их нужно удалить из хранилища.
+func (c *carry) Put(data *domain.Data) (saved []*domain.Carry, removed []*domain.Carry) {
+	if data.Bid <= 0 || data.Ask <= 0 {
+		return nil, nil
+	}
+
+	now := data.Time
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	quotes := c.spot
+	if data.Perpetual() {
+		quotes = c.perp
+	}
+
+	if _, ok := quotes[data.Pair]; !ok {
+		quotes[data.Pair] = make(map[string]*domain.Data)
+	}
+	quotes[data.Pair][data.Exchange] = data
+
+	// позиции с устаревшей второй стороной не считаются, ее котировка забывается до следующего обновления
+	for _, legs := range []map[string]*domain.Data{c.spot[data.Pair], c.perp[data.Pair]} {
+		for exchange, quote := range legs {
+			if quote != data && !c.health.Fresh(health.Source(quote.Exchange, quote.Market), quote.Pair, now) {
+				removed = append(removed, c.removeQuote(quote)...)
+				delete(legs, exchange)
+			}
+		}
+	}
+
+	var positions []*domain.Carry
+	for _, short := range c.perp[data.Pair] {
+		for _, long := range c.spot[data.Pair] {
+			if long == data || short == data {
+				positions = append(positions, c.calc(domain.CarryBasis, long, short))
+			}
+		}
+
+		for _, long := range c.perp[data.Pair] {
+			if long.Exchange != short.Exchange && (long == data || short == data) {
+				positions = append(positions, c.calc(domain.CarryFunding, long, short))
+			}
+		}
+	}
+
+	for _, position := range positions {
+		key := carryKey(position)
+
+		profitable := position.AnnualizedCarry > c.minCarry
+		if profitable || c.profitable[key] {
+			saved = append(saved, position)
+		}
+		c.profitable[key] = profitable
+	}
+
+	return saved, removed
+}
+
+// removeQuote возвращает бывшие прибыльными позиции с котировкой quote, вызывается под c.mu
+func (c *carry) removeQuote(quote *domain.Data) []*domain.Carry {
+	var removed []*domain.Carry
+	if !quote.Perpetual() {
+		for _, short := range c.perp[quote.Pair] {
+			removed = c.appendProfitable(removed, c.calc(domain.CarryBasis, quote, short))
+		}
+
+		return removed
+	}
+
+	for _, long := range c.spot[quote.Pair] {
+		removed = c.appendProfitable(removed, c.calc(domain.CarryBasis, long, quote))
+	}
+
+	for _, other := range c.perp[quote.Pair] {
+		if other.Exchange != quote.Exchange {
+			removed = c.appendProfitable(removed, c.calc(domain.CarryFunding, other, quote))
+			removed = c.appendProfitable(removed, c.calc(domain.CarryFunding, quote, other))
+		}
+	}
+
+	return removed
+}
+
+// Remove удаляет котировки пары и возвращает позиции, бывшие прибыльными: их нужно удалить из хранилища
+func (c *carry) Remove(pair string) []*domain.Carry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var removed []*domain.Carry
+	for _, short := range c.perp[pair] {
+		for _, long := range c.spot[pair] {
+			removed = c.appendProfitable(removed, c.calc(domain.CarryBasis, long, short))
+		}
+
+		for _, long := range c.perp[pair] {
+			if long.Exchange != short.Exchange {
+				removed = c.appendProfitable(removed, c.calc(domain.CarryFunding, long, short))
+			}
+		}
+	}
+
+	delete(c.spot, pair)
+	delete(c.perp, pair)
+
+	return removed
+}
+
+func (c *carry) appendProfitable(positions []*domain.Carry, position *domain.Carry) []*domain.Carry {
+	key := carryKey(position)
+	if !c.profitable[key] {
+		return positions
+	}
+
+	delete(c.profitable, key)
+
+	return append(positions, position)
+}
+
+// calc считает доходность покупки long по ask и продажи фьючерса short по bid
+func (c *carry) calc(kind string, long, short *domain.Data) *domain.Carry {
+	holding := float64(c.holding) / float64(year)
+
+	position := &domain.Carry{
+		Kind:            kind,
+		Pair:            short.Pair,
+		LongExchange:    long.Exchange,
+		LongMarket:      market(long),
+		ShortExchange:   short.Exchange,
+		LongPrice:       long.Ask,
+		ShortPrice:      short.Bid,
+		ShortFunding:    annualFunding(short),
+		Basis:           (short.Bid - long.Ask) / long.Ask * 100,
+		NextFundingTime: short.NextFundingTime,
+	}
+
+	if long.Perpetual() {
+		position.LongFunding = annualFunding(long)
+	}
+
+	longFee := c.fees.marketTaker(long.Exchange, long.Market, long.Pair)
+	shortFee := c.fees.marketTaker(short.Exchange, short.Market, short.Pair)
+	position.Fees = 2 * (longFee + shortFee)
+
+	funding := (position.ShortFunding - position.LongFunding) * holding
+	position.AnnualizedCarry = (position.Basis + funding - position.Fees) / holding
+
+	return position
+}
+
+// annualFunding переводит ставку финансирования за период в годовую
+func annualFunding(data *domain.Data) float64 {
+	interval := data.FundingInterval
+	if interval <= 0 {
+		interval = defaultFundingInterval
+	}
+
+	return data.FundingRate * float64(year) / float64(interval)
+}
+
+func market(data *domain.Data) string {
+	if data.Market == "" {
+		return domain.MarketSpot
+	}
+
+	return data.Market
+}
+
+func carryKey(position *domain.Carry) string {
+	return position.Kind + ":" + position.Pair + ":" + position.LongExchange + ">" + position.ShortExchange
+}
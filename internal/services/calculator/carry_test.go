@@ -0,0 +1,80 @@
+package calculator
+
+import (
+	"calc/common/config"
+	"calc/internal/domain"
+	"calc/internal/services/health"
+	"testing"
+	"time"
+)
+
+func TestCarryFreshness(t *testing.T) {
+	start := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	spot := func(at time.Duration) *domain.Data {
+		return &domain.Data{Exchange: "binance", Pair: "BTC_USDT", Bid: 36509.9, Ask: 36510, Time: start.Add(at)}
+	}
+
+	perp := func(at time.Duration) *domain.Data {
+		return &domain.Data{
+			Exchange:        "bybit",
+			Market:          domain.MarketPerp,
+			Pair:            "BTC_USDT",
+			Bid:             36620,
+			Ask:             36620.1,
+			FundingRate:     0.01,
+			FundingInterval: 8 * time.Hour,
+			Time:            start.Add(at),
+		}
+	}
+
+	type step struct {
+		data    *domain.Data
+		saved   int
+		removed int
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "fresh legs",
+			steps: []step{{data: spot(0)}, {data: perp(time.Second), saved: 1}},
+		},
+		{
+			name:  "stale spot leg",
+			steps: []step{{data: spot(0)}, {data: perp(31 * time.Second)}},
+		},
+		{
+			name:  "stale perp leg",
+			steps: []step{{data: perp(0)}, {data: spot(31 * time.Second)}},
+		},
+		{
+			// позиция была прибыльной, ее нужно удалить из хранилища, а устаревшую котировку забыть
+			name: "leg goes stale",
+			steps: []step{
+				{data: spot(0)},
+				{data: perp(time.Second), saved: 1},
+				{data: perp(40 * time.Second), removed: 1},
+				{data: perp(41 * time.Second)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := health.NewTracker(&config.Config{})
+			c := newCarry(0, 10, newFeeSchedule(), tracker)
+
+			for i, s := range tt.steps {
+				tracker.Touch(health.Source(s.data.Exchange, s.data.Market), s.data.Pair, s.data.Time)
+
+				saved, removed := c.Put(s.data)
+				if len(saved) != s.saved || len(removed) != s.removed {
+					t.Errorf("step %d: saved %d, removed %d, want %d, %d", i, len(saved), len(removed), s.saved, s.removed)
+				}
+			}
+		})
+	}
+}
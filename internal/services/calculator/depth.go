@@ -0,0 +1,70 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"math"
+)
+
+// depthTolerance относительная погрешность, с которой объем стакана считается покрывающим объем сделки
+const depthTolerance = 1e-9
+
+// maxVolume считает объем в базовой валюте, который можно купить по asks и продать по bids,
+// пока цена покупки с комиссией остается ниже цены продажи с комиссией
+func maxVolume(asks, bids []domain.PriceLevel, buyFee, sellFee float64) float64 {
+	if len(asks) == 0 || len(bids) == 0 {
+		return 0
+	}
+
+	i, j := 0, 0
+	askQuantity, bidQuantity := asks[0].Quantity, bids[0].Quantity
+
+	var volume float64
+	for i < len(asks) && j < len(bids) {
+		if netAsk(asks[i].Price, buyFee) >= netBid(bids[j].Price, sellFee) {
+			break
+		}
+
+		quantity := math.Min(askQuantity, bidQuantity)
+		volume += quantity
+		askQuantity -= quantity
+		bidQuantity -= quantity
+
+		if askQuantity <= 0 {
+			i++
+			if i < len(asks) {
+				askQuantity = asks[i].Quantity
+			}
+		}
+
+		if bidQuantity <= 0 {
+			j++
+			if j < len(bids) {
+				bidQuantity = bids[j].Quantity
+			}
+		}
+	}
+
+	return volume
+}
+
+// vwap считает средневзвешенную цену исполнения объема volume по уровням стакана.
+// Если глубины стакана не хватает, цена считается по доступному объему и filled false.
+func vwap(levels []domain.PriceLevel, volume float64) (price float64, filled bool) {
+	var done, cost float64
+	for _, level := range levels {
+		if done >= volume {
+			break
+		}
+
+		quantity := math.Min(level.Quantity, volume-done)
+		done += quantity
+		cost += quantity * level.Price
+	}
+
+	if done == 0 {
+		return 0, false
+	}
+
+	// накопленная сумма может отличаться от volume на ошибку округления
+	return cost / done, done >= volume*(1-depthTolerance)
+}
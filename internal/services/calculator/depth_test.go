@@ -0,0 +1,43 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"testing"
+)
+
+func TestCalcVolumeDepth(t *testing.T) {
+	asks := []domain.PriceLevel{{Price: 100, Quantity: 1}, {Price: 101, Quantity: 1}}
+	bids := []domain.PriceLevel{{Price: 103, Quantity: 1}, {Price: 102, Quantity: 0.5}}
+
+	tests := []struct {
+		name      string
+		tradeSize float64
+		// insufficient стакана продажи не хватает на объем сделки
+		insufficient bool
+	}{
+		{name: "covered", tradeSize: 100},
+		{name: "whole book", tradeSize: 150},
+		{name: "thin bids", tradeSize: 200, insufficient: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &calculator{tradeSize: tt.tradeSize}
+			arbitrage := &domain.Arbitrage{BuyPrice: 100, SellPrice: 103}
+
+			c.calcVolume(arbitrage, asks, bids)
+
+			if arbitrage.InsufficientDepth != tt.insufficient {
+				t.Fatalf("insufficient depth = %v, want %v", arbitrage.InsufficientDepth, tt.insufficient)
+			}
+
+			if tt.insufficient && arbitrage.ProfitAtVolume != 0 {
+				t.Errorf("profit at volume = %v on a thin book, want 0", arbitrage.ProfitAtVolume)
+			}
+
+			if !tt.insufficient && arbitrage.ProfitAtVolume <= 0 {
+				t.Errorf("profit at volume = %v, want positive", arbitrage.ProfitAtVolume)
+			}
+		})
+	}
+}
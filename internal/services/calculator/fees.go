@@ -0,0 +1,58 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"sync"
+)
+
+// feeSchedule хранит комиссии тейкера по биржам и парам, комиссии фьючерсов хранятся отдельно от спота
+type feeSchedule struct {
+	mu   sync.RWMutex
+	fees map[string]map[string]float64
+}
+
+func newFeeSchedule() *feeSchedule {
+	return &feeSchedule{
+		fees: make(map[string]map[string]float64),
+	}
+}
+
+func (s *feeSchedule) set(fee *domain.Fee) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := marketKey(fee.Exchange, fee.Market)
+	if _, ok := s.fees[key]; !ok {
+		s.fees[key] = make(map[string]float64)
+	}
+
+	s.fees[key][fee.Pair] = fee.Taker
+}
+
+// taker возвращает комиссию тейкера на споте
+func (s *feeSchedule) taker(exchange, pair string) float64 {
+	return s.marketTaker(exchange, "", pair)
+}
+
+func (s *feeSchedule) marketTaker(exchange, market, pair string) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fees[marketKey(exchange, market)][pair]
+}
+
+func marketKey(exchange, market string) string {
+	if market == "" || market == domain.MarketSpot {
+		return exchange
+	}
+
+	return exchange + ":" + market
+}
+
+func netBid(bid, fee float64) float64 {
+	return bid * (1 - fee/100)
+}
+
+func netAsk(ask, fee float64) float64 {
+	return ask * (1 + fee/100)
+}
@@ -0,0 +1,93 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"sync"
+	"time"
+)
+
+// opportunityTracker отслеживает жизненный цикл возможностей по комбинациям бирж
+type opportunityTracker struct {
+	mu             sync.Mutex
+	openThreshold  float64
+	closeThreshold float64
+	active         map[string]*domain.Opportunity
+}
+
+func newOpportunityTracker(openThreshold, closeThreshold float64) *opportunityTracker {
+	return &opportunityTracker{
+		openThreshold:  openThreshold,
+		closeThreshold: closeThreshold,
+		active:         make(map[string]*domain.Opportunity),
+	}
+}
+
+// track открывает, обновляет или закрывает возможность по расчету комбинации.
+// Возвращает копию возможности либо nil, если возможности нет.
+func (t *opportunityTracker) track(arbitrage *domain.Arbitrage, now time.Time) *domain.Opportunity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := opportunityKey(arbitrage)
+	profit := arbitrage.NetProfit
+
+	o, ok := t.active[key]
+	if !ok {
+		if profit < t.openThreshold {
+			return nil
+		}
+
+		o = &domain.Opportunity{
+			Pair:         arbitrage.Pair,
+			BuyExchange:  arbitrage.BuyExchange,
+			SellExchange: arbitrage.SellExchange,
+			OpenedAt:     now,
+			PeakProfit:   profit,
+			AvgProfit:    profit,
+			LastProfit:   profit,
+			Updates:      1,
+		}
+		t.active[key] = o
+
+		opportunity := *o
+		return &opportunity
+	}
+
+	if profit < t.closeThreshold {
+		return t.closeLocked(key, now)
+	}
+
+	o.Updates++
+	o.AvgProfit += (profit - o.AvgProfit) / float64(o.Updates)
+	o.LastProfit = profit
+	if profit > o.PeakProfit {
+		o.PeakProfit = profit
+	}
+
+	opportunity := *o
+	return &opportunity
+}
+
+// close закрывает активную возможность по комбинации, выбывшей из расчета
+func (t *opportunityTracker) close(arbitrage *domain.Arbitrage, now time.Time) *domain.Opportunity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.closeLocked(opportunityKey(arbitrage), now)
+}
+
+func (t *opportunityTracker) closeLocked(key string, now time.Time) *domain.Opportunity {
+	o, ok := t.active[key]
+	if !ok {
+		return nil
+	}
+
+	delete(t.active, key)
+	o.ClosedAt = &now
+
+	return o
+}
+
+func opportunityKey(arbitrage *domain.Arbitrage) string {
+	return arbitrage.Pair + ":" + combinationKey(arbitrage)
+}
@@ -0,0 +1,110 @@
+package calculator
+
+import (
+	"context"
+	"runtime"
+)
+
+const defaultShardQueue = 1024
+
+// shardTask выполняется горутиной обработчика и получает его калькуляторы пар
+type shardTask func(pairs map[string]*calculator)
+
+// shard обработчик части пар. Калькуляторы пар принадлежат горутине обработчика,
+// поэтому котировки одной пары считаются последовательно и без блокировок.
+type shard struct {
+	queue *queue
+	pairs map[string]*calculator
+}
+
+// pipeline распределяет пары между обработчиками по хешу названия пары
+type pipeline struct {
+	ctx    context.Context
+	shards []*shard
+}
+
+// newPipeline запускает shards обработчиков с очередями размера queue.
+// block - котировки ждут места в очереди вместо замены ждущей котировки пары.
+func newPipeline(ctx context.Context, shards, queue int, block bool) *pipeline {
+	if shards <= 0 {
+		shards = runtime.NumCPU()
+	}
+
+	p := &pipeline{
+		ctx:    ctx,
+		shards: make([]*shard, shards),
+	}
+
+	for i := range p.shards {
+		p.shards[i] = &shard{
+			queue: newQueue(ctx, queue, block),
+			pairs: make(map[string]*calculator),
+		}
+
+		go p.shards[i].queue.run()
+	}
+
+	return p
+}
+
+// put ставит задачу котировки в очередь обработчика пары, не дожидаясь места:
+// при заполненной очереди она заменяет ждущую задачу с тем же ключом
+func (p *pipeline) put(pair, key string, task shardTask) {
+	s := p.shards[shardIndex(pair, len(p.shards))]
+	s.queue.put(key, func() {
+		task(s.pairs)
+	})
+}
+
+// do ставит задачу в очередь обработчика пары. При заполненной очереди ждет места, пока не закончится контекст.
+func (p *pipeline) do(pair string, task shardTask) {
+	s := p.shards[shardIndex(pair, len(p.shards))]
+	s.queue.push(func() {
+		task(s.pairs)
+	})
+}
+
+// shardIndex номер обработчика пары из shards по хешу FNV-1a ее названия
+func shardIndex(pair string, shards int) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(pair); i++ {
+		h ^= uint32(pair[i])
+		h *= 16777619
+	}
+
+	return int(h % uint32(shards))
+}
+
+// each ставит задачу в очереди всех обработчиков
+func (p *pipeline) each(task shardTask) {
+	for _, s := range p.shards {
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		s := s
+		s.queue.push(func() {
+			task(s.pairs)
+		})
+	}
+}
+
+// wait ждет выполнения задач, поставленных в очереди до вызова
+func (p *pipeline) wait(ctx context.Context) error {
+	done := make(chan struct{}, len(p.shards))
+	p.each(func(map[string]*calculator) {
+		done <- struct{}{}
+	})
+
+	for range p.shards {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-done:
+		}
+	}
+
+	return nil
+}
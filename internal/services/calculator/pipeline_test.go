@@ -0,0 +1,186 @@
+package calculator
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/db/memory"
+	"calc/internal/domain"
+	"calc/internal/services/health"
+	"calc/internal/services/history"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	benchmarkPairs = 5000
+	// benchmarkMinQuotes нижняя граница пропускной способности конвейера, котировок в секунду
+	benchmarkMinQuotes = 5000
+)
+
+var benchmarkExchanges = []string{"binance", "bybit", "okx"}
+
+// benchmarkPairList пары USDT и каждая десятая пара к BTC, чтобы у треугольников и маршрутов были циклы
+func benchmarkPairList() []string {
+	pairs := []string{"BTC_USDT"}
+	for i := 0; i < benchmarkPairs; i++ {
+		pairs = append(pairs, fmt.Sprintf("A%04d_USDT", i))
+		if i%10 == 0 {
+			pairs = append(pairs, fmt.Sprintf("A%04d_BTC", i))
+		}
+	}
+
+	return pairs
+}
+
+// benchmarkQuotes котировки всех пар на всех биржах, цены бирж немного расходятся, чтобы появлялись комбинации
+func benchmarkQuotes(pairs []string) []*domain.Data {
+	now := time.Now().UTC()
+
+	quotes := make([]*domain.Data, 0, len(pairs)*len(benchmarkExchanges)*2)
+	for round := 0; round < 2; round++ {
+		for i, pair := range pairs {
+			for j, exchange := range benchmarkExchanges {
+				price := 100 + float64((i+j+round)%7)/10
+				if strings.HasSuffix(pair, "_BTC") {
+					price /= 50000
+				} else if pair == "BTC_USDT" {
+					price *= 500
+				}
+
+				quotes = append(quotes, domain.NewData(exchange, pair,
+					[]domain.PriceLevel{{Price: price, Quantity: 10}},
+					[]domain.PriceLevel{{Price: price * 1.0005, Quantity: 10}},
+				))
+				quotes[len(quotes)-1].Time = now
+			}
+		}
+	}
+
+	return quotes
+}
+
+// benchmarkConfig настройки расчета как в common/config.yml: треугольники, маршруты и возможности включены
+func benchmarkConfig(pairs []string, shards int) *config.Config {
+	configs := make(map[string]*config.ExchangeConfig, len(benchmarkExchanges))
+	for _, exchange := range benchmarkExchanges {
+		configs[exchange] = &config.ExchangeConfig{Pairs: pairs}
+	}
+
+	return &config.Config{
+		Exchanges: &config.Exchange{
+			Pairs:     pairs,
+			TradeSize: map[string]float64{"USDT": 100},
+			// в бенчмарке нет данных о сетях, без этого межбиржевые комбинации не считались бы
+			AllowUnknownTransfers: true,
+			Triangular:            &config.Triangular{Enabled: true, MaxLength: 3},
+			Routes:                &config.Routes{Enabled: true, MaxLength: 6},
+			Opportunity: &config.Opportunity{
+				OpenThreshold:  0.1,
+				CloseThreshold: 0,
+			},
+			Pipeline: &config.Pipeline{
+				Shards:        shards,
+				Queue:         1024,
+				FlushInterval: 500 * time.Millisecond,
+			},
+			Configs: configs,
+		},
+	}
+}
+
+// TestQueueOverflow задачи сверх очереди выполняются после нее в порядке приема,
+// ждущая задача котировки заменяется новой задачей того же ключа
+func TestQueueOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := newQueue(ctx, 1, false)
+
+	var got []string
+	record := func(name string) func() {
+		return func() {
+			got = append(got, name)
+		}
+	}
+
+	q.put("okx:BTC_USDT", record("btc 1"))
+	q.put("okx:BTC_USDT", record("btc 2"))
+	q.put("okx:ETH_USDT", record("eth 1"))
+	q.put("okx:BTC_USDT", record("btc 3"))
+
+	done := make(chan struct{})
+	q.push(func() {
+		close(done)
+	})
+
+	go q.run()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("queue did not run tasks")
+	}
+
+	want := []string{"btc 1", "btc 3", "eth 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// BenchmarkPipeline считает котировки от Save до окончания расчета всех пар и проверяет,
+// что пропускная способность не ниже benchmarkMinQuotes. При переполнении очереди
+// ждущие котировки пары заменяются новыми, как в работе сервиса.
+func BenchmarkPipeline(b *testing.B) {
+	pairs := benchmarkPairList()
+
+	for _, shards := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// котировки с текущим временем: за время прошлых запусков они устарели бы для трекера
+			quotes := benchmarkQuotes(pairs)
+			cfg := benchmarkConfig(pairs, shards)
+			healthTracker := health.NewTracker(cfg)
+
+			s := NewCalculateService(
+				ctx,
+				cfg,
+				memory.NewArbitrageRepo(),
+				memory.NewTriangularArbitrageRepo(),
+				memory.NewCarryRepo(),
+				memory.NewRouteRepo(),
+				memory.NewOpportunityRepo(),
+				history.NewService(ctx, &config.Config{}, nil),
+				healthTracker,
+			)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				data := quotes[i%len(quotes)]
+				healthTracker.Touch(data.Exchange, data.Pair, data.Time)
+
+				if err := s.Save(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			if err := s.Flush(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			perSecond := float64(b.N) / time.Since(start).Seconds()
+			b.ReportMetric(perSecond, "quotes/s")
+
+			if b.N >= len(quotes) && perSecond < benchmarkMinQuotes {
+				b.Errorf("throughput %.0f quotes/s, want at least %d", perSecond, benchmarkMinQuotes)
+			}
+		})
+	}
+}
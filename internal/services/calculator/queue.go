@@ -0,0 +1,129 @@
+package calculator
+
+import (
+	"context"
+	"sync"
+)
+
+// queueEntry задача сверх очереди, key пустой у задач, которые нельзя заменить
+type queueEntry struct {
+	key  string
+	task func()
+}
+
+// queue очередь задач одной горутины. Задача котировки не ждет места в заполненной очереди:
+// она попадает в переполнение, где заменяет ждущую задачу того же ключа, потому что для расчета
+// важна только последняя цена пары. Пока переполнение не разобрано, в него попадают и остальные задачи,
+// поэтому задачи выполняются в порядке приема.
+type queue struct {
+	ctx   context.Context
+	tasks chan func()
+	// block задачи котировок ждут места, как остальные: воспроизведению записи нужна каждая котировка
+	block bool
+
+	mu       sync.Mutex
+	overflow []queueEntry
+	// keys позиции задач котировок в overflow по ключу
+	keys map[string]int
+	// ready будит горутину очереди после добавления в overflow
+	ready chan struct{}
+}
+
+func newQueue(ctx context.Context, size int, block bool) *queue {
+	if size <= 0 {
+		size = defaultShardQueue
+	}
+
+	return &queue{
+		ctx:   ctx,
+		tasks: make(chan func(), size),
+		block: block,
+		keys:  make(map[string]int),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// put ставит задачу котировки с ключом key, при заполненной очереди заменяет ждущую задачу ключа
+func (q *queue) put(key string, task func()) {
+	if q.block {
+		q.push(task)
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.overflow) == 0 {
+		select {
+		case q.tasks <- task:
+			return
+		default:
+		}
+	}
+
+	if i, ok := q.keys[key]; ok {
+		q.overflow[i].task = task
+		return
+	}
+
+	q.keys[key] = len(q.overflow)
+	q.add(queueEntry{key: key, task: task})
+}
+
+// push ставит задачу, которую нельзя заменить. При заполненной очереди ждет места, пока не закончится контекст.
+func (q *queue) push(task func()) {
+	q.mu.Lock()
+	if len(q.overflow) > 0 {
+		q.add(queueEntry{task: task})
+		q.mu.Unlock()
+
+		return
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.ctx.Done():
+	case q.tasks <- task:
+	}
+}
+
+// add дописывает задачу в переполнение, вызывается под q.mu
+func (q *queue) add(entry queueEntry) {
+	q.overflow = append(q.overflow, entry)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run выполняет задачи, пока не закончится контекст
+func (q *queue) run() {
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case task := <-q.tasks:
+			task()
+		case <-q.ready:
+		}
+
+		// переполнение разбирается после очереди: его задачи приняты позже, а новые задачи
+		// до его разбора попадают в него же
+		if len(q.tasks) > 0 {
+			continue
+		}
+
+		q.mu.Lock()
+		overflow := q.overflow
+		q.overflow = nil
+		if len(overflow) > 0 {
+			q.keys = make(map[string]int)
+		}
+		q.mu.Unlock()
+
+		for _, entry := range overflow {
+			entry.task()
+		}
+	}
+}
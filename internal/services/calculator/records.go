@@ -0,0 +1,157 @@
+package calculator
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+var (
+	promRecordsPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "record_writes_pending",
+		Help:      "triangular arbitrage, carry and opportunity writes waiting for flush",
+	})
+	promRecordsFlushErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "record_flush_errors_total",
+		Help:      "failed triangular arbitrage, carry and opportunity writes",
+	})
+	promRecordsRegister sync.Once
+)
+
+// recordWriter пишет треугольные комбинации, базис и возможности в базу в отдельной горутине,
+// чтобы медленная база не задерживала расчет котировок. Записи выполняются в порядке первого
+// появления ключа, из нескольких записей одного ключа между сбросами выполняется только последняя.
+type recordWriter struct {
+	interval  time.Duration
+	batchSize int
+	// full сигнал о накоплении batchSize записей
+	full chan struct{}
+
+	mu      sync.Mutex
+	keys    []string
+	pending map[string]func(ctx context.Context) error
+	// flushMu не дает двум сбросам идти одновременно, иначе старая запись может выполниться после новой
+	flushMu sync.Mutex
+}
+
+func newRecordWriter(interval time.Duration, batchSize int) *recordWriter {
+	promRecordsRegister.Do(func() {
+		prometheus.MustRegister(promRecordsPending, promRecordsFlushErrors)
+	})
+
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &recordWriter{
+		interval:  interval,
+		batchSize: batchSize,
+		full:      make(chan struct{}, 1),
+		pending:   make(map[string]func(ctx context.Context) error),
+	}
+}
+
+// Put ставит запись объекта key в очередь, заменяя еще не выполненную запись того же объекта
+func (w *recordWriter) Put(key string, write func(ctx context.Context) error) {
+	w.mu.Lock()
+	_, coalesced := w.pending[key]
+	if !coalesced {
+		w.keys = append(w.keys, key)
+	}
+	w.pending[key] = write
+	size := len(w.keys)
+	w.mu.Unlock()
+
+	if coalesced {
+		return
+	}
+
+	promRecordsPending.Inc()
+
+	if size >= w.batchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run выполняет накопленные записи раз в interval или по накоплении batchSize, пока не закончится контекст
+func (w *recordWriter) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+
+		if err := w.Flush(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to flush records")
+		}
+	}
+}
+
+// Flush выполняет накопленные записи по порядку. При ошибке невыполненные записи возвращаются
+// в начало очереди, если их не заменили более новые.
+func (w *recordWriter) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	keys, pending := w.keys, w.pending
+	w.keys, w.pending = nil, make(map[string]func(ctx context.Context) error, len(pending))
+	w.mu.Unlock()
+
+	for i, key := range keys {
+		if err := pending[key](ctx); err != nil {
+			promRecordsFlushErrors.Inc()
+			promRecordsPending.Sub(float64(i))
+			w.retry(keys[i:], pending)
+			return err
+		}
+	}
+
+	promRecordsPending.Sub(float64(len(keys)))
+
+	return nil
+}
+
+// retry возвращает невыполненные записи в начало очереди
+func (w *recordWriter) retry(keys []string, pending map[string]func(ctx context.Context) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	retried := make([]string, 0, len(keys)+len(w.keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := w.pending[key]; ok {
+			// более новая запись уже в очереди, она займет место старой
+			promRecordsPending.Dec()
+		} else {
+			w.pending[key] = pending[key]
+		}
+
+		retried = append(retried, key)
+		seen[key] = struct{}{}
+	}
+
+	for _, key := range w.keys {
+		if _, ok := seen[key]; !ok {
+			retried = append(retried, key)
+		}
+	}
+
+	w.keys = retried
+}
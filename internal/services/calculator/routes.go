@@ -0,0 +1,568 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"calc/internal/services/health"
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minRouteLength     = 3
+	maxRouteLength     = 8
+	defaultRouteLength = 6
+	// routeEpsilon отсекает циклы, прибыльность которых объясняется погрешностью вычислений
+	routeEpsilon = 1e-9
+)
+
+type assetNode struct {
+	exchange string
+	asset    string
+}
+
+func (n assetNode) String() string {
+	return n.exchange + ":" + n.asset
+}
+
+// edge ребро графа: сделка по паре на бирже или перевод актива между биржами.
+// Вес ребра - минус логарифм курса обмена с учетом комиссий, поэтому прибыльному циклу
+// соответствует цикл отрицательного веса.
+type edge struct {
+	from, to int
+	weight   float64
+	// pair и sell заполняются для сделки
+	pair string
+	sell bool
+	// network заполняется для перевода
+	network string
+	price   float64
+	fee     float64
+}
+
+func (e *edge) transfer() bool {
+	return e.pair == ""
+}
+
+// cycleRoute найденный прибыльный маршрут, ребра начинаются с минимального по названию узла
+type cycleRoute struct {
+	key   string
+	edges []*edge
+}
+
+// routeGraph ищет прибыльные циклы в графе, узлы которого - активы на биржах, а ребра - сделки и переводы.
+// Поиск инкрементальный: после обновления котировки проверяются только циклы, проходящие через изменившиеся ребра.
+type routeGraph struct {
+	mu         sync.Mutex
+	fees       *feeSchedule
+	transfers  *transferSchedule
+	health     *health.Tracker
+	tradeSizes map[string]float64
+	maxLength  int
+
+	nodes []assetNode
+	index map[assetNode]int
+	// assets узлы актива на разных биржах, между ними проводятся ребра переводов
+	assets map[string][]int
+	adj    [][]*edge
+	// trades ребра сделок по бирже и паре: продажа базового актива и его покупка
+	trades map[string]map[string][2]*edge
+	// transfersOut ребра переводов, выходящие из узла
+	transfersOut map[int][]*edge
+	quotes       map[string]map[string]*domain.Data
+
+	dirty  map[*edge]struct{}
+	notify chan struct{}
+	// active маршруты, сохраненные как прибыльные
+	active map[string]*cycleRoute
+
+	// буферы поиска, переиспользуются между вызовами
+	dist    [][]float64
+	parents [][]*edge
+}
+
+func newRouteGraph(
+	exchangePairs map[string][]string,
+	maxLength int,
+	tradeSizes map[string]float64,
+	fees *feeSchedule,
+	transfers *transferSchedule,
+	healthTracker *health.Tracker,
+) *routeGraph {
+	if maxLength == 0 {
+		maxLength = defaultRouteLength
+	}
+	if maxLength < minRouteLength {
+		maxLength = minRouteLength
+	}
+	if maxLength > maxRouteLength {
+		maxLength = maxRouteLength
+	}
+
+	g := &routeGraph{
+		fees:         fees,
+		transfers:    transfers,
+		health:       healthTracker,
+		tradeSizes:   tradeSizes,
+		maxLength:    maxLength,
+		index:        make(map[assetNode]int),
+		assets:       make(map[string][]int),
+		trades:       make(map[string]map[string][2]*edge),
+		transfersOut: make(map[int][]*edge),
+		quotes:       make(map[string]map[string]*domain.Data),
+		dirty:        make(map[*edge]struct{}),
+		notify:       make(chan struct{}, 1),
+		active:       make(map[string]*cycleRoute),
+	}
+
+	g.dist = make([][]float64, maxLength)
+	g.parents = make([][]*edge, maxLength)
+
+	exchanges := make([]string, 0, len(exchangePairs))
+	for exchange := range exchangePairs {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	for _, exchange := range exchanges {
+		for _, pair := range exchangePairs[exchange] {
+			g.addPair(exchange, pair)
+		}
+	}
+
+	return g
+}
+
+// AddPair добавляет в граф ребра сделки по паре на бирже, веса появятся с первой котировкой
+func (g *routeGraph) AddPair(exchange, pair string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.addPair(exchange, pair)
+}
+
+// RemovePair убирает из графа ребра сделки по паре на бирже, маршруты через них
+// перестают быть прибыльными при следующем поиске
+func (g *routeGraph) RemovePair(exchange, pair string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges, ok := g.trades[exchange][pair]
+	if !ok {
+		return
+	}
+
+	delete(g.trades[exchange], pair)
+	delete(g.quotes[exchange], pair)
+
+	sell, buy := edges[0], edges[1]
+	for _, e := range edges {
+		g.setWeight(e, math.Inf(1), 0, 0)
+		g.removeEdge(e)
+	}
+
+	g.updateTransfers(sell.from)
+	g.updateTransfers(buy.from)
+
+	select {
+	case g.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (g *routeGraph) addPair(exchange, pair string) {
+	assets := strings.Split(pair, "_")
+	if len(assets) != 2 {
+		return
+	}
+
+	if _, ok := g.trades[exchange]; !ok {
+		g.trades[exchange] = make(map[string][2]*edge)
+		g.quotes[exchange] = make(map[string]*domain.Data)
+	}
+
+	if _, ok := g.trades[exchange][pair]; ok {
+		return
+	}
+
+	base, quote := g.node(exchange, assets[0]), g.node(exchange, assets[1])
+	g.trades[exchange][pair] = [2]*edge{
+		g.addEdge(&edge{from: base, to: quote, pair: pair, sell: true}),
+		g.addEdge(&edge{from: quote, to: base, pair: pair, sell: false}),
+	}
+}
+
+// node возвращает узел актива на бирже. Новый узел соединяется ребрами переводов
+// с узлами того же актива на остальных биржах.
+func (g *routeGraph) node(exchange, asset string) int {
+	n := assetNode{exchange, asset}
+	if i, ok := g.index[n]; ok {
+		return i
+	}
+
+	i := len(g.nodes)
+	g.index[n] = i
+	g.nodes = append(g.nodes, n)
+	g.adj = append(g.adj, nil)
+
+	for k := range g.dist {
+		g.dist[k] = append(g.dist[k], math.Inf(1))
+		g.parents[k] = append(g.parents[k], nil)
+	}
+
+	for _, other := range g.assets[asset] {
+		out := g.addEdge(&edge{from: i, to: other})
+		in := g.addEdge(&edge{from: other, to: i})
+		g.transfersOut[i] = append(g.transfersOut[i], out)
+		g.transfersOut[other] = append(g.transfersOut[other], in)
+	}
+	g.assets[asset] = append(g.assets[asset], i)
+
+	return i
+}
+
+func (g *routeGraph) addEdge(e *edge) *edge {
+	e.weight = math.Inf(1)
+	g.adj[e.from] = append(g.adj[e.from], e)
+
+	return e
+}
+
+func (g *routeGraph) removeEdge(e *edge) {
+	edges := g.adj[e.from]
+	for i, out := range edges {
+		if out == e {
+			g.adj[e.from] = append(edges[:i:i], edges[i+1:]...)
+			return
+		}
+	}
+}
+
+// Put обновляет веса ребер сделки по паре и переводов ее активов с биржи и будит поиск
+func (g *routeGraph) Put(data *domain.Data) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges, ok := g.trades[data.Exchange][data.Pair]
+	if !ok || data.Bid <= 0 || data.Ask <= 0 {
+		return
+	}
+
+	g.quotes[data.Exchange][data.Pair] = data
+
+	fee := g.fees.taker(data.Exchange, data.Pair)
+	sell, buy := edges[0], edges[1]
+	g.setWeight(sell, -math.Log(netBid(data.Bid, fee)), data.Bid, fee)
+	g.setWeight(buy, math.Log(netAsk(data.Ask, fee)), data.Ask, fee)
+
+	g.updateTransfers(sell.from)
+	g.updateTransfers(sell.to)
+
+	select {
+	case g.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (g *routeGraph) setWeight(e *edge, weight, price, fee float64) {
+	if e.weight == weight && e.price == price && e.fee == fee {
+		return
+	}
+
+	e.weight, e.price, e.fee = weight, price, fee
+	g.dirty[e] = struct{}{}
+}
+
+// updateTransfers пересчитывает стоимость перевода актива узла на остальные биржи для объема сделки
+func (g *routeGraph) updateTransfers(from int) {
+	n := g.nodes[from]
+	amount := g.amount(n)
+
+	for _, e := range g.transfersOut[from] {
+		t := g.transfers.network(n.asset, n.exchange, g.nodes[e.to].exchange, amount)
+		if t.impossible || t.fee >= 100 {
+			g.setWeight(e, math.Inf(1), 0, 0)
+			continue
+		}
+
+		e.network = t.network
+		g.setWeight(e, -math.Log(1-t.fee/100), 0, t.fee)
+	}
+}
+
+// amount объем сделки в единицах актива: из настроек для валюты котировки
+// или через цену актива на бирже в валюте котировки с настроенным объемом
+func (g *routeGraph) amount(n assetNode) float64 {
+	if size := g.tradeSizes[n.asset]; size > 0 {
+		return size
+	}
+
+	// пары актива ищутся по валютам котировки с объемом сделки, их намного меньше, чем котировок биржи
+	for quote, size := range g.tradeSizes {
+		if data, ok := g.quotes[n.exchange][n.asset+"_"+quote]; ok && size > 0 {
+			return size / data.Ask
+		}
+	}
+
+	return 0
+}
+
+// Run ищет циклы после обновлений котировок и передает в save новые прибыльные маршруты
+// и маршруты, переставшие быть прибыльными. Обновления, пришедшие во время поиска, схлопываются.
+// Без обновлений поиск раз в expireInterval убирает маршруты с устаревшими котировками.
+func (g *routeGraph) Run(ctx context.Context, save func(route *domain.Route)) {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.notify:
+		case <-ticker.C:
+		}
+
+		for _, route := range g.search(time.Now().UTC()) {
+			save(route)
+		}
+	}
+}
+
+// evict закрывает ребра сделок по парам, котировки которых устарели к now
+func (g *routeGraph) evict(now time.Time) {
+	for exchange, quotes := range g.quotes {
+		for pair := range quotes {
+			if g.health.Fresh(exchange, pair, now) {
+				continue
+			}
+
+			delete(quotes, pair)
+
+			sell, buy := g.trades[exchange][pair][0], g.trades[exchange][pair][1]
+			g.setWeight(sell, math.Inf(1), 0, 0)
+			g.setWeight(buy, math.Inf(1), 0, 0)
+
+			g.updateTransfers(sell.from)
+			g.updateTransfers(sell.to)
+		}
+	}
+}
+
+// search ищет циклы через ребра, изменившиеся после прошлого поиска. Блокировка берется
+// на каждое ребро отдельно, чтобы Put не ждал окончания поиска по всем ребрам.
+func (g *routeGraph) search(now time.Time) []*domain.Route {
+	g.mu.Lock()
+	g.evict(now)
+
+	var result []*domain.Route
+
+	dirty := g.dirty
+	g.dirty = make(map[*edge]struct{})
+
+	found := make(map[string]*cycleRoute)
+	for key, r := range g.active {
+		if weight := cycleWeight(r.edges); weight > -routeEpsilon {
+			result = append(result, g.toDomain(r, weight))
+			delete(g.active, key)
+			continue
+		}
+
+		for _, e := range r.edges {
+			if _, ok := dirty[e]; ok {
+				found[key] = r
+				break
+			}
+		}
+	}
+	g.mu.Unlock()
+
+	for e := range dirty {
+		g.mu.Lock()
+		r := g.bestCycle(e)
+		g.mu.Unlock()
+
+		if r != nil {
+			found[r.key] = r
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for key, r := range found {
+		// веса могли измениться после поиска цикла: неприбыльный цикл проверится при следующем поиске
+		weight := cycleWeight(r.edges)
+		if weight > -routeEpsilon {
+			continue
+		}
+
+		g.active[key] = r
+		result = append(result, g.toDomain(r, weight))
+	}
+
+	return result
+}
+
+// bestCycle ищет самый прибыльный цикл через ребро e: кратчайший путь из e.to в e.from
+// не длиннее maxLength-1 ребер по Беллману-Форду, на каждом шаге релаксируются только
+// ребра узлов, расстояние до которых изменилось на предыдущем шаге. Кратчайший путь может
+// повторять узел, тогда берется лучший простой цикл другой длины.
+func (g *routeGraph) bestCycle(e *edge) *cycleRoute {
+	if math.IsInf(e.weight, 1) {
+		return nil
+	}
+
+	g.dist[0][e.to] = 0
+	frontier := []int{e.to}
+
+	// levels узлы, расстояния до которых заданы на каждом шаге: после поиска сбрасываются только они,
+	// а не буферы всего графа
+	levels := [][]int{frontier}
+	defer func() {
+		g.reset(levels)
+	}()
+
+	// levelWeights веса прибыльных циклов по числу ребер пути
+	levelWeights := make(map[int]float64)
+	for k := 1; k < g.maxLength && len(frontier) > 0; k++ {
+		var next []int
+		for _, from := range frontier {
+			for _, out := range g.adj[from] {
+				if math.IsInf(out.weight, 1) {
+					continue
+				}
+
+				dist := g.dist[k-1][from] + out.weight
+				if dist < g.dist[k][out.to] {
+					if math.IsInf(g.dist[k][out.to], 1) {
+						next = append(next, out.to)
+					}
+					g.dist[k][out.to] = dist
+					g.parents[k][out.to] = out
+				}
+			}
+		}
+		frontier = next
+		levels = append(levels, next)
+
+		if weight := g.dist[k][e.from] + e.weight; weight < -routeEpsilon {
+			levelWeights[k] = weight
+		}
+	}
+
+	candidates := make([]int, 0, len(levelWeights))
+	for k := range levelWeights {
+		candidates = append(candidates, k)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return levelWeights[candidates[i]] < levelWeights[candidates[j]]
+	})
+
+	for _, length := range candidates {
+		if r := g.newCycleRoute(g.cycleEdges(e, length)); r != nil {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// cycleEdges восстанавливает по родителям цикл из ребра e и кратчайшего пути из length ребер
+func (g *routeGraph) cycleEdges(e *edge, length int) []*edge {
+	edges := make([]*edge, length+1)
+	edges[0] = e
+	at := e.from
+	for k := length; k > 0; k-- {
+		parent := g.parents[k][at]
+		edges[k] = parent
+		at = parent.from
+	}
+
+	return edges
+}
+
+// reset возвращает буферам поиска начальные значения для узлов levels
+func (g *routeGraph) reset(levels [][]int) {
+	for k, nodes := range levels {
+		for _, i := range nodes {
+			g.dist[k][i] = math.Inf(1)
+			g.parents[k][i] = nil
+		}
+	}
+}
+
+// newCycleRoute проверяет, что цикл простой, и поворачивает его так,
+// чтобы он начинался с минимального по названию узла
+func (g *routeGraph) newCycleRoute(edges []*edge) *cycleRoute {
+	visited := make(map[int]bool, len(edges))
+	start := 0
+	for i, e := range edges {
+		if visited[e.from] {
+			return nil
+		}
+		visited[e.from] = true
+
+		if g.nodes[e.from].String() < g.nodes[edges[start].from].String() {
+			start = i
+		}
+	}
+
+	rotated := append(append([]*edge{}, edges[start:]...), edges[:start]...)
+
+	route := make([]string, 0, len(rotated)+1)
+	for _, e := range rotated {
+		route = append(route, g.nodes[e.from].String())
+	}
+	route = append(route, route[0])
+
+	return &cycleRoute{
+		key:   strings.Join(route, ">"),
+		edges: rotated,
+	}
+}
+
+func (g *routeGraph) toDomain(r *cycleRoute, weight float64) *domain.Route {
+	legs := make([]*domain.RouteLeg, 0, len(r.edges))
+	for _, e := range r.edges {
+		from := g.nodes[e.from]
+		leg := &domain.RouteLeg{
+			Exchange: from.exchange,
+			Asset:    from.asset,
+			Fee:      e.fee,
+		}
+
+		if e.transfer() {
+			leg.Type = domain.LegTransfer
+			leg.ToExchange = g.nodes[e.to].exchange
+			leg.Network = e.network
+		} else {
+			leg.Type = domain.LegTrade
+			leg.Pair = e.pair
+			leg.Price = e.price
+			leg.Side = domain.SideBuy
+			if e.sell {
+				leg.Side = domain.SideSell
+			}
+		}
+
+		legs = append(legs, leg)
+	}
+
+	return &domain.Route{
+		Route:     r.key,
+		Legs:      legs,
+		NetProfit: (math.Exp(-weight) - 1) * 100,
+	}
+}
+
+func cycleWeight(edges []*edge) float64 {
+	var weight float64
+	for _, e := range edges {
+		weight += e.weight
+	}
+
+	return weight
+}
@@ -0,0 +1,77 @@
+package calculator
+
+import (
+	"calc/common/config"
+	"calc/internal/domain"
+	"calc/internal/services/health"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBestSimpleCycle(t *testing.T) {
+	now := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+
+	quote := func(pair string, bid, ask float64) *domain.Data {
+		return &domain.Data{Exchange: "binance", Pair: pair, Bid: bid, Ask: ask, Time: now}
+	}
+
+	// triangle прибыльный цикл USDT>BTC>ETH>USDT, самый выгодный путь из шести ребер обходит его дважды
+	triangle := []*domain.Data{
+		quote("BTC_USDT", 36509.9, 36510),
+		quote("ETH_USDT", 2056.9, 2057),
+		quote("ETH_BTC", 0.0549, 0.055),
+	}
+	// loop прибыльный цикл BTC>SOL>XRP>BTC: вставленный в triangle, он дает самый выгодный путь
+	// из шести ребер, который дважды проходит через BTC
+	loop := []*domain.Data{
+		quote("SOL_BTC", 0.00139, 0.0014),
+		quote("SOL_XRP", 93.44, 93.45),
+		quote("XRP_BTC", 0.000016708, 0.000016709),
+	}
+
+	tests := []struct {
+		name   string
+		quotes []*domain.Data
+		routes []string
+	}{
+		{
+			name:   "cycle repeated",
+			quotes: triangle,
+			routes: []string{"binance:BTC>binance:ETH>binance:USDT>binance:BTC"},
+		},
+		{
+			name:   "inner cycle",
+			quotes: append(append([]*domain.Data{}, triangle...), loop...),
+			routes: []string{
+				"binance:BTC>binance:ETH>binance:USDT>binance:BTC",
+				"binance:BTC>binance:SOL>binance:XRP>binance:BTC",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := health.NewTracker(&config.Config{})
+			g := newRouteGraph(map[string][]string{
+				"binance": {"BTC_USDT", "ETH_USDT", "ETH_BTC", "SOL_BTC", "SOL_XRP", "XRP_BTC"},
+			}, defaultRouteLength, nil, newFeeSchedule(), newTransferSchedule(false), tracker)
+
+			for _, data := range tt.quotes {
+				tracker.Touch(health.Source(data.Exchange, data.Market), data.Pair, data.Time)
+				g.Put(data)
+			}
+
+			var routes []string
+			for _, r := range g.search(now) {
+				routes = append(routes, r.Route)
+			}
+			sort.Strings(routes)
+
+			if !reflect.DeepEqual(routes, tt.routes) {
+				t.Errorf("routes = %v, want %v", routes, tt.routes)
+			}
+		})
+	}
+}
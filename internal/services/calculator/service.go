@@ -3,40 +3,544 @@ package calculator
 import (
 	"calc/common/config"
 	"calc/internal/adapters/db"
+	"calc/internal/adapters/db/filters"
 	"calc/internal/domain"
+	"calc/internal/services/health"
+	"calc/internal/services/history"
 	"context"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+const expireInterval = time.Second
+
 type CalculateService interface {
 	Save(data *domain.Data) error
+	SetFee(fee *domain.Fee)
+	SetAssetNetwork(network *domain.AssetNetwork)
+	// AddPair начинает расчет арбитража по паре
+	AddPair(pair string)
+	// RemovePair прекращает расчет арбитража по паре и удаляет ее комбинации
+	RemovePair(pair string) error
+	// Flush ждет расчета принятых котировок и записывает накопленные комбинации в базу.
+	// Вызывается после остановки источников котировок и до отмены контекста сервиса.
+	Flush(ctx context.Context) error
+	// Top возвращает текущий рейтинг комбинаций по убыванию чистой прибыли, false - рейтинг еще не собран
+	Top(filter filters.ArbitrageParams) ([]*domain.Arbitrage, bool)
 }
 
 type calculateService struct {
-	ctx           context.Context
-	arbitrageRepo db.ArbitrageRepo
-	pairs         map[string]*calculator
+	ctx                     context.Context
+	triangularArbitrageRepo db.TriangularArbitrageRepo
+	carryRepo               db.CarryRepo
+	routeRepo               db.RouteRepo
+	opportunityRepo         db.OpportunityRepo
+	history                 *history.Service
+	fees                    *feeSchedule
+	transfers               *transferSchedule
+	tradeSizes              map[string]float64
+	combinations            int
+	health                  *health.Tracker
+
+	// pipeline считает пары в обработчиках, калькуляторы пар хранятся в них
+	pipeline *pipeline
+	// writer пишет комбинации в базу пакетами
+	writer *arbitrageWriter
+	// records пишет треугольные комбинации, базис и возможности в базу в отдельной горутине
+	records *recordWriter
+	// top рейтинг комбинаций в памяти, поделен на части так же, как пары между обработчиками
+	top *Top
+
+	// graphMu защищает пары расчета и графов: обработчики графов бирж и RemovePair работают в разных горутинах
+	graphMu sync.Mutex
+	// pairs пары, по которым идет расчет
+	pairs map[string]struct{}
+	// graphPairs пары бирж, входящие в треугольники и граф маршрутов
+	graphPairs map[string]map[string]struct{}
+	// triangulars поиск циклов внутри биржи, ключ - название биржи. Треугольник биржи меняется
+	// только в горутине ее обработчика графов.
+	triangulars map[string]*triangular
+	routes      *routeGraph
+	// graphWorkers обработчики графов по биржам: обновляют треугольник биржи и граф маршрутов
+	// вне Save и обработчиков пар
+	graphWorkers map[string]*queue
+	workersMu    sync.Mutex
+	queueSize    int
+	block        bool
+	// carry nil, если поиск базиса и арбитража ставок финансирования выключен
+	carry *carry
+	// opportunities nil, если пороги возможностей не настроены
+	opportunities *opportunityTracker
 }
 
-func NewCalculateService(ctx context.Context, cfg *config.Config, arbitrageRepo db.ArbitrageRepo) CalculateService {
-	pairs := make(map[string]*calculator)
+func NewCalculateService(
+	ctx context.Context,
+	cfg *config.Config,
+	arbitrageRepo db.ArbitrageRepo,
+	triangularArbitrageRepo db.TriangularArbitrageRepo,
+	carryRepo db.CarryRepo,
+	routeRepo db.RouteRepo,
+	opportunityRepo db.OpportunityRepo,
+	historyService *history.Service,
+	healthTracker *health.Tracker,
+) CalculateService {
+	fees := newFeeSchedule()
+	transfers := newTransferSchedule(cfg.Exchanges.AllowUnknownTransfers)
+
+	exchangePairs := make(map[string][]string)
+	graphPairs := make(map[string]map[string]struct{})
+	for exchange, exchangeConfig := range cfg.Exchanges.Configs {
+		exchangePairs[exchange] = exchangeConfig.Pairs
+
+		graphPairs[exchange] = make(map[string]struct{}, len(exchangeConfig.Pairs))
+		for _, pair := range exchangeConfig.Pairs {
+			graphPairs[exchange][pair] = struct{}{}
+		}
+	}
+
+	triangulars := make(map[string]*triangular)
+	if cfg.Exchanges.Triangular != nil && cfg.Exchanges.Triangular.Enabled {
+		for exchange, pairs := range exchangePairs {
+			triangulars[exchange] = newTriangular(exchange, pairs, cfg.Exchanges.Triangular.MaxLength, fees, healthTracker)
+		}
+	}
+
+	pipelineConfig := cfg.Exchanges.Pipeline
+	if pipelineConfig == nil {
+		pipelineConfig = &config.Pipeline{}
+	}
+
+	s := &calculateService{
+		ctx:                     ctx,
+		triangularArbitrageRepo: triangularArbitrageRepo,
+		carryRepo:               carryRepo,
+		routeRepo:               routeRepo,
+		opportunityRepo:         opportunityRepo,
+		history:                 historyService,
+		fees:                    fees,
+		transfers:               transfers,
+		tradeSizes:              cfg.Exchanges.TradeSize,
+		combinations:            cfg.Exchanges.Combinations,
+		health:                  healthTracker,
+		pairs:                   make(map[string]struct{}),
+		graphPairs:              graphPairs,
+		triangulars:             triangulars,
+		graphWorkers:            make(map[string]*queue),
+		queueSize:               pipelineConfig.Queue,
+		block:                   pipelineConfig.Block,
+		top:                     NewTop(pipelineConfig.Shards),
+	}
+
+	s.pipeline = newPipeline(ctx, pipelineConfig.Shards, pipelineConfig.Queue, pipelineConfig.Block)
+	s.writer = newArbitrageWriter(arbitrageRepo, pipelineConfig.FlushInterval, pipelineConfig.BatchSize)
+	go s.writer.Run(ctx)
+	s.records = newRecordWriter(pipelineConfig.FlushInterval, pipelineConfig.BatchSize)
+	go s.records.Run(ctx)
+
 	for _, pair := range cfg.Exchanges.Pairs {
-		pairs[pair] = NewCalculator(pair)
+		s.AddPair(pair)
+	}
+
+	if cfg.Exchanges.Routes != nil && cfg.Exchanges.Routes.Enabled {
+		s.routes = newRouteGraph(exchangePairs, cfg.Exchanges.Routes.MaxLength, cfg.Exchanges.TradeSize, fees, transfers, healthTracker)
+		go s.routes.Run(ctx, s.saveRoute)
+	}
+
+	if cfg.Exchanges.Carry != nil && cfg.Exchanges.Carry.Enabled {
+		s.carry = newCarry(cfg.Exchanges.Carry.Holding, cfg.Exchanges.Carry.MinCarry, fees, healthTracker)
+	}
+
+	if cfg.Exchanges.Opportunity != nil {
+		if err := opportunityRepo.CloseActive(ctx, time.Now().UTC()); err != nil {
+			log.Error().Err(err).Msg("failed to close active opportunities")
+		}
+
+		s.opportunities = newOpportunityTracker(cfg.Exchanges.Opportunity.OpenThreshold, cfg.Exchanges.Opportunity.CloseThreshold)
 	}
 
-	return &calculateService{
-		ctx:           ctx,
-		arbitrageRepo: arbitrageRepo,
-		pairs:         pairs,
+	if healthTracker != nil {
+		go s.expire(ctx)
 	}
+
+	return s
 }
 
 func (s *calculateService) Save(data *domain.Data) error {
-	arbitrage := s.pairs[data.Pair].Put(data)
-	if arbitrage != nil && arbitrage.SellExchange != "" && arbitrage.BuyExchange != "" {
-		if _, err := s.arbitrageRepo.Save(s.ctx, arbitrage); err != nil {
-			return err
+	s.saveCarry(data)
+
+	// котировки фьючерсов участвуют только в расчете базиса и ставок финансирования
+	if data.Perpetual() {
+		return nil
+	}
+
+	// Save не ждет расчета: графы и пары считаются в своих горутинах, а при заполненной очереди
+	// котировка заменяет ждущую котировку той же пары биржи
+	if w := s.graphWorker(data.Exchange); w != nil {
+		w.put(data.Pair, func() {
+			s.saveGraphs(data)
+		})
+	}
+
+	s.pipeline.put(data.Pair, data.Exchange+":"+data.Pair, func(pairs map[string]*calculator) {
+		c, ok := pairs[data.Pair]
+		if !ok {
+			return
+		}
+
+		updated, removed := c.Put(data)
+		// время берется из данных, чтобы при воспроизведении записи длительности совпадали с исходными
+		now := data.Time
+		if now.IsZero() {
+			now = time.Now().UTC()
+		}
+
+		s.apply(updated, removed, now)
+	})
+
+	return nil
+}
+
+// graphWorker возвращает обработчик графов биржи, nil - биржа не входит ни в треугольники, ни в маршруты
+func (s *calculateService) graphWorker(exchange string) *queue {
+	if _, ok := s.triangulars[exchange]; !ok && s.routes == nil {
+		return nil
+	}
+
+	s.workersMu.Lock()
+	defer s.workersMu.Unlock()
+
+	w, ok := s.graphWorkers[exchange]
+	if !ok {
+		w = newQueue(s.ctx, s.queueSize, s.block)
+		s.graphWorkers[exchange] = w
+		go w.run()
+	}
+
+	return w
+}
+
+// saveGraphs обновляет треугольник биржи котировки и граф маршрутов, выполняется в обработчике графов биржи
+func (s *calculateService) saveGraphs(data *domain.Data) {
+	s.graphMu.Lock()
+	s.extendGraphs(data)
+	s.graphMu.Unlock()
+
+	if s.routes != nil {
+		s.routes.Put(data)
+	}
+
+	s.saveTriangular(data)
+}
+
+// waitGraphs ждет выполнения задач, поставленных обработчикам графов до вызова
+func (s *calculateService) waitGraphs(ctx context.Context) error {
+	s.workersMu.Lock()
+	workers := make([]*queue, 0, len(s.graphWorkers))
+	for _, w := range s.graphWorkers {
+		workers = append(workers, w)
+	}
+	s.workersMu.Unlock()
+
+	done := make(chan struct{}, len(workers))
+	for _, w := range workers {
+		w.push(func() {
+			done <- struct{}{}
+		})
+	}
+
+	for range workers {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-done:
 		}
 	}
 
 	return nil
 }
+
+// expire периодически убирает комбинации с котировками, переставшими обновляться:
+// без новых котировок по паре пересчет в Save не происходит
+func (s *calculateService) expire(ctx context.Context) {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			now = now.UTC()
+
+			s.pipeline.each(func(pairs map[string]*calculator) {
+				for _, c := range pairs {
+					updated, removed := c.Expire(now)
+					s.apply(updated, removed, now)
+				}
+			})
+		}
+	}
+}
+
+// apply передает изменившиеся и выбывшие комбинации на запись и обновляет арбитражные возможности
+func (s *calculateService) apply(updated, removed []*domain.Arbitrage, now time.Time) {
+	for _, arbitrage := range removed {
+		s.top.Remove(arbitrage)
+		s.writer.Delete(arbitrage)
+
+		if s.opportunities != nil {
+			s.saveOpportunity(s.opportunities.close(arbitrage, now))
+		}
+	}
+
+	for _, arbitrage := range updated {
+		s.history.RecordSpread(arbitrage)
+
+		s.top.Put(arbitrage)
+		s.writer.Save(arbitrage)
+
+		if s.opportunities != nil {
+			s.saveOpportunity(s.opportunities.track(arbitrage, now))
+		}
+	}
+}
+
+// saveOpportunity ставит в очередь последнее состояние возможности. Ключ включает время открытия,
+// поэтому закрытие прежней возможности по комбинации записывается раньше открытия следующей.
+// Запись обновляет активную возможность, а если ее еще нет в базе - создает.
+func (s *calculateService) saveOpportunity(opportunity *domain.Opportunity) {
+	if opportunity == nil {
+		return
+	}
+
+	key := "opportunity:" + opportunity.Pair + ":" + opportunity.BuyExchange + ">" + opportunity.SellExchange +
+		":" + strconv.FormatInt(opportunity.OpenedAt.UnixNano(), 10)
+
+	s.records.Put(key, func(ctx context.Context) error {
+		updated, err := s.opportunityRepo.Update(ctx, opportunity)
+		if err != nil || updated > 0 {
+			return err
+		}
+
+		_, err = s.opportunityRepo.Create(ctx, opportunity)
+		return err
+	})
+}
+
+func (s *calculateService) saveTriangular(data *domain.Data) {
+	t, ok := s.triangulars[data.Exchange]
+	if !ok {
+		return
+	}
+
+	for _, arbitrage := range t.Put(data) {
+		arbitrage := arbitrage
+		s.records.Put("triangular:"+arbitrage.Exchange+":"+arbitrage.Route, func(ctx context.Context) error {
+			_, err := s.triangularArbitrageRepo.Save(ctx, arbitrage)
+			return err
+		})
+	}
+}
+
+func (s *calculateService) saveCarry(data *domain.Data) {
+	if s.carry == nil {
+		return
+	}
+
+	saved, removed := s.carry.Put(data)
+	for _, carry := range saved {
+		carry := carry
+		s.records.Put(carryRecordKey(carry), func(ctx context.Context) error {
+			_, err := s.carryRepo.Save(ctx, carry)
+			return err
+		})
+	}
+
+	for _, carry := range removed {
+		s.deleteCarry(carry)
+	}
+}
+
+func (s *calculateService) deleteCarry(carry *domain.Carry) {
+	s.records.Put(carryRecordKey(carry), func(ctx context.Context) error {
+		return s.carryRepo.Delete(ctx, carry)
+	})
+}
+
+func carryRecordKey(carry *domain.Carry) string {
+	return "carry:" + carry.Kind + ":" + carry.Pair + ":" + carry.LongExchange + ">" + carry.ShortExchange
+}
+
+func (s *calculateService) saveRoute(route *domain.Route) {
+	if _, err := s.routeRepo.Save(s.ctx, route); err != nil {
+		log.Error().Err(err).Str("route", route.Route).Msg("failed to save route")
+	}
+}
+
+func (s *calculateService) Top(filter filters.ArbitrageParams) ([]*domain.Arbitrage, bool) {
+	return s.top.Get(filter)
+}
+
+func (s *calculateService) SetFee(fee *domain.Fee) {
+	s.fees.set(fee)
+}
+
+func (s *calculateService) SetAssetNetwork(network *domain.AssetNetwork) {
+	s.transfers.set(network)
+}
+
+// extendGraphs добавляет в треугольник и граф маршрутов биржи пару, добавленную в расчет после запуска,
+// по первой котировке с этой биржи: биржи пары становятся известны только после подписки
+func (s *calculateService) extendGraphs(data *domain.Data) {
+	if _, ok := s.pairs[data.Pair]; !ok {
+		return
+	}
+
+	pairs, ok := s.graphPairs[data.Exchange]
+	if !ok {
+		pairs = make(map[string]struct{})
+		s.graphPairs[data.Exchange] = pairs
+	}
+
+	if _, ok := pairs[data.Pair]; ok {
+		return
+	}
+	pairs[data.Pair] = struct{}{}
+
+	if t, ok := s.triangulars[data.Exchange]; ok {
+		t.SetPairs(pairList(pairs))
+	}
+
+	if s.routes != nil {
+		s.routes.AddPair(data.Exchange, data.Pair)
+	}
+}
+
+// removeFromGraphs убирает пару из пар графов и графа маршрутов всех бирж и возвращает биржи,
+// треугольники которых нужно пересобрать в их обработчиках графов
+func (s *calculateService) removeFromGraphs(pair string) []string {
+	var exchanges []string
+	for exchange, pairs := range s.graphPairs {
+		if _, ok := pairs[pair]; !ok {
+			continue
+		}
+		delete(pairs, pair)
+
+		if _, ok := s.triangulars[exchange]; ok {
+			exchanges = append(exchanges, exchange)
+		}
+
+		if s.routes != nil {
+			s.routes.RemovePair(exchange, pair)
+		}
+	}
+
+	return exchanges
+}
+
+// resetTriangular пересобирает треугольник биржи по текущим парам графов в ее обработчике графов
+func (s *calculateService) resetTriangular(exchange string) {
+	s.graphWorker(exchange).push(func() {
+		s.graphMu.Lock()
+		defer s.graphMu.Unlock()
+
+		s.triangulars[exchange].SetPairs(pairList(s.graphPairs[exchange]))
+	})
+}
+
+func (s *calculateService) AddPair(pair string) {
+	s.graphMu.Lock()
+	s.pairs[pair] = struct{}{}
+	s.graphMu.Unlock()
+
+	s.pipeline.do(pair, func(pairs map[string]*calculator) {
+		if _, ok := pairs[pair]; ok {
+			return
+		}
+
+		pairs[pair] = NewCalculator(pair, tradeSize(s.tradeSizes, pair), s.combinations, s.fees, s.transfers, s.health)
+	})
+}
+
+func (s *calculateService) RemovePair(pair string) error {
+	s.graphMu.Lock()
+	delete(s.pairs, pair)
+	exchanges := s.removeFromGraphs(pair)
+	s.graphMu.Unlock()
+
+	// обработчик графов может ждать graphMu, поэтому задача ставится после его освобождения
+	for _, exchange := range exchanges {
+		s.resetTriangular(exchange)
+	}
+
+	if s.carry != nil {
+		for _, carry := range s.carry.Remove(pair) {
+			s.deleteCarry(carry)
+		}
+	}
+
+	done := make(chan struct{})
+	s.pipeline.do(pair, func(pairs map[string]*calculator) {
+		defer close(done)
+
+		c, ok := pairs[pair]
+		if !ok {
+			return
+		}
+
+		delete(pairs, pair)
+		s.apply(nil, c.Clear(), time.Now().UTC())
+	})
+
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+func (s *calculateService) Flush(ctx context.Context) error {
+	// после остановки сервиса обработчики уже не работают, остается записать накопленное
+	if s.ctx.Err() == nil {
+		if err := s.waitGraphs(ctx); err != nil {
+			return err
+		}
+
+		if err := s.pipeline.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writer.Flush(ctx); err != nil {
+		return err
+	}
+
+	return s.records.Flush(ctx)
+}
+
+func pairList(pairs map[string]struct{}) []string {
+	list := make([]string, 0, len(pairs))
+	for pair := range pairs {
+		list = append(list, pair)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// tradeSize возвращает объем сделки для пары по ее валюте котировки
+func tradeSize(sizes map[string]float64, pair string) float64 {
+	assets := strings.Split(pair, "_")
+	if len(assets) != 2 {
+		return 0
+	}
+
+	return sizes[assets[1]]
+}
@@ -1,168 +1,391 @@
 package calculator
 
 import (
+	"calc/internal/adapters/db/filters"
 	"calc/internal/domain"
+	"math/rand"
+	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
-type node struct {
-	value  *domain.Arbitrage
-	next   *node
-	before *node
+// topMaxLevel число уровней списка с пропусками, хватает на миллионы комбинаций
+const topMaxLevel = 24
+
+// topSorts поля сортировки, по которым Top держит индексы
+var topSorts = []filters.ArbitrageSortBy{
+	filters.ArbitrageSortByProfit,
+	filters.ArbitrageSortByNetProfit,
+	filters.ArbitrageSortByVolume,
+	filters.ArbitrageSortByUpdatedAt,
+	filters.ArbitrageSortByPair,
 }
 
-func (n *node) isTail() bool {
-	return n.next == nil
+type topNode struct {
+	value *domain.Arbitrage
+	next  []*topNode
+	prev  *topNode
 }
 
-func (n *node) isHead() bool {
-	return n.before == nil
+// topIndex список с пропусками, упорядоченный по возрастанию одного поля сортировки.
+// Порядок по убыванию - обратный обход: при равенстве поля filters.ArbitrageParams
+// меняет направление и у пары с биржами.
+type topIndex struct {
+	sortBy filters.ArbitrageSortBy
+	head   *topNode
+	tail   *topNode
+	level  int
+	rnd    *rand.Rand
 }
 
-func (n *node) insertBefore(node *node) {
-	node.next = n
-	node.before = n.before
-	if n.before != nil {
-		n.before.next = node
+func newTopIndex(sortBy filters.ArbitrageSortBy) *topIndex {
+	return &topIndex{
+		sortBy: sortBy,
+		head:   &topNode{next: make([]*topNode, topMaxLevel)},
+		level:  1,
+		rnd:    rand.New(rand.NewSource(1)),
 	}
-	n.before = node
 }
 
-func (n *node) insertAfter(node *node) {
-	node.next = n.next
-	node.before = n
-	if n.next != nil {
-		n.next.before = node
+func (x *topIndex) randomLevel() int {
+	level := 1
+	for level < topMaxLevel && x.rnd.Int63()&3 == 0 {
+		level++
 	}
-	n.next = node
-}
 
-// Top это двусвязный список для хранения в отсортированном виде результатов расчета по Box strategy
-type Top struct {
-	mu     sync.RWMutex
-	names  map[string]*node
-	head   *node
-	tail   *node
-	length int
+	return level
 }
 
-func NewTop() *Top {
-	return &Top{
-		names: make(map[string]*node),
+// predecessors заполняет update последними узлами каждого уровня, идущими раньше v
+func (x *topIndex) predecessors(v *domain.Arbitrage, update *[topMaxLevel]*topNode) {
+	n := x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && filters.CompareArbitrage(x.sortBy, n.next[i].value, v) < 0 {
+			n = n.next[i]
+		}
+		update[i] = n
 	}
 }
 
-func (o *Top) search(head *node, tail *node, percent float64) *node {
-	for head.value.Profit > percent && tail.value.Profit < percent {
-		head = head.next
-		tail = tail.before
+// insert вставляет узел на место по его значению, n.next задает число уровней узла
+func (x *topIndex) insert(n *topNode) {
+	var update [topMaxLevel]*topNode
+	x.predecessors(n.value, &update)
+
+	level := len(n.next)
+	for ; x.level < level; x.level++ {
+		update[x.level] = x.head
+	}
+
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
 	}
-	if head.value.Profit <= percent {
-		return head
+
+	n.prev = nil
+	if update[0] != x.head {
+		n.prev = update[0]
+	}
+
+	if n.next[0] != nil {
+		n.next[0].prev = n
+	} else {
+		x.tail = n
 	}
-	return tail
 }
 
-func (o *Top) remove(node *node) {
-	if !node.isHead() {
-		node.before.next = node.next
+// remove убирает узел значения v и возвращает его для повторной вставки, nil - значения нет
+func (x *topIndex) remove(v *domain.Arbitrage) *topNode {
+	var update [topMaxLevel]*topNode
+	x.predecessors(v, &update)
+
+	n := update[0].next[0]
+	if n == nil || filters.CompareArbitrage(x.sortBy, n.value, v) != 0 {
+		return nil
+	}
+
+	for i := 0; i < len(n.next); i++ {
+		update[i].next[i] = n.next[i]
+	}
+
+	if n.next[0] != nil {
+		n.next[0].prev = n.prev
 	} else {
-		if !node.isTail() {
-			node.next.before = nil
+		x.tail = n.prev
+	}
+
+	for x.level > 1 && x.head.next[x.level-1] == nil {
+		x.level--
+	}
+
+	return n
+}
+
+// first возвращает первый узел в направлении dir, идущий после курсора, если он задан
+func (x *topIndex) first(dir filters.SortDirection, after *filters.ArbitrageCursor) *topNode {
+	if after == nil {
+		if dir == filters.Desc {
+			return x.tail
 		}
-		o.head = node.next
+
+		return x.head.next[0]
 	}
-	if !node.isTail() {
-		node.next.before = node.before
-	} else {
-		if !node.isHead() {
-			node.before.next = nil
+
+	// последний узел, идущий в порядке возрастания раньше курсора или на нем
+	n := x.head
+	for i := x.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && filters.CompareArbitrageCursor(x.sortBy, n.next[i].value, after) < 0 {
+			n = n.next[i]
 		}
-		o.tail = node.before
 	}
+
+	if dir == filters.Desc {
+		if n == x.head {
+			return nil
+		}
+
+		return n
+	}
+
+	n = n.next[0]
+	if n != nil && filters.CompareArbitrageCursor(x.sortBy, n.value, after) == 0 {
+		n = n.next[0]
+	}
+
+	return n
 }
 
-func (o *Top) Put(v *domain.Arbitrage) {
+// fits остается ли узел на своем месте, если его значением станет v
+func (x *topIndex) fits(n *topNode, v *domain.Arbitrage) bool {
+	return (n.prev == nil || filters.CompareArbitrage(x.sortBy, n.prev.value, v) < 0) &&
+		(n.next[0] == nil || filters.CompareArbitrage(x.sortBy, v, n.next[0].value) < 0)
+}
+
+// topEntry комбинация и ее узлы в индексах в порядке topSorts
+type topEntry struct {
+	value *domain.Arbitrage
+	nodes []*topNode
+}
+
+// topShard часть рейтинга с парами одного обработчика конвейера
+type topShard struct {
+	mu sync.RWMutex
+	// entries комбинации по паре и комбинации бирж
+	entries map[string]*topEntry
+	// indexes индексы в порядке topSorts
+	indexes []*topIndex
+}
+
+func newTopShard() *topShard {
+	indexes := make([]*topIndex, 0, len(topSorts))
+	for _, sortBy := range topSorts {
+		indexes = append(indexes, newTopIndex(sortBy))
+	}
+
+	return &topShard{
+		entries: make(map[string]*topEntry),
+		indexes: indexes,
+	}
+}
+
+// put добавляет комбинацию или переставляет ее на место по новым значениям.
+// Узел, который остается между соседями, только меняет значение.
+func (o *topShard) put(v *domain.Arbitrage) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	if o.head == nil {
-		n := &node{
-			value: v,
+	key := topKey(v)
+	e, ok := o.entries[key]
+	if !ok {
+		e = &topEntry{nodes: make([]*topNode, len(o.indexes))}
+		for i, index := range o.indexes {
+			e.nodes[i] = &topNode{value: v, next: make([]*topNode, index.randomLevel())}
+			index.insert(e.nodes[i])
 		}
-		o.head = n
-		o.tail = n
-		o.length++
 
-		o.names[v.Pair] = n
+		e.value = v
+		o.entries[key] = e
 
 		return
 	}
 
-	if foundNode, ok := o.names[v.Pair]; ok {
-		head := o.head
-		if foundNode.value.Profit > v.Profit {
-			head = foundNode
+	for i, index := range o.indexes {
+		n := e.nodes[i]
+		if index.fits(n, v) {
+			n.value = v
+			continue
 		}
-		currentNode := o.search(head, o.tail, v.Profit)
-		foundNode.value = v
-
-		if currentNode != foundNode {
-			o.remove(foundNode)
-			if currentNode.value.Profit > v.Profit {
-				currentNode.insertAfter(foundNode)
-				if currentNode == o.tail {
-					o.tail = foundNode
-				}
-			} else {
-				currentNode.insertBefore(foundNode)
-				if currentNode == o.head {
-					o.head = foundNode
+
+		index.remove(e.value)
+		n.value = v
+		index.insert(n)
+	}
+
+	e.value = v
+}
+
+func (o *topShard) remove(v *domain.Arbitrage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := topKey(v)
+	e, ok := o.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(o.entries, key)
+
+	for _, index := range o.indexes {
+		index.remove(e.value)
+	}
+}
+
+// Top рейтинг комбинаций с индексами по всем полям сортировки.
+// Калькулятор обновляет его при каждом пересчете, поэтому рейтинг читается из памяти без запросов в базу.
+// Пары делятся между частями так же, как между обработчиками конвейера, чтобы обработчики не ждали друг друга.
+type Top struct {
+	shards []*topShard
+	// warm 1 после первого изменения: до него рейтинг еще не собран
+	warm int32
+}
+
+// NewTop создает рейтинг из shards частей, 0 - по числу процессоров
+func NewTop(shards int) *Top {
+	if shards <= 0 {
+		shards = runtime.NumCPU()
+	}
+
+	o := &Top{shards: make([]*topShard, shards)}
+	for i := range o.shards {
+		o.shards[i] = newTopShard()
+	}
+
+	return o
+}
+
+func topKey(arbitrage *domain.Arbitrage) string {
+	return arbitrage.Pair + ":" + combinationKey(arbitrage)
+}
+
+func (o *Top) shard(pair string) *topShard {
+	return o.shards[shardIndex(pair, len(o.shards))]
+}
+
+// Put добавляет комбинацию или переставляет ее на место по новым значениям
+func (o *Top) Put(v *domain.Arbitrage) {
+	atomic.StoreInt32(&o.warm, 1)
+	o.shard(v.Pair).put(v)
+}
+
+// Remove убирает комбинацию из рейтинга
+func (o *Top) Remove(v *domain.Arbitrage) {
+	atomic.StoreInt32(&o.warm, 1)
+	o.shard(v.Pair).remove(v)
+}
+
+// indexOf возвращает номер индекса поля сортировки в topSorts
+func indexOf(sortBy filters.ArbitrageSortBy) (int, bool) {
+	for i, s := range topSorts {
+		if s == sortBy {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// Get возвращает комбинации, подходящие под фильтр, в порядке его сортировки с учетом курсора, Offset и Limit.
+// Индексы частей обходятся слиянием от курсора до набора Offset+Limit комбинаций.
+// false - рейтинг еще не собран после запуска.
+func (o *Top) Get(filter filters.ArbitrageParams) ([]*domain.Arbitrage, bool) {
+	resp := make([]*domain.Arbitrage, 0)
+	if atomic.LoadInt32(&o.warm) == 0 {
+		return resp, false
+	}
+
+	for _, shard := range o.shards {
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+
+	i, ok := indexOf(filter.SortBy)
+	if !ok {
+		// неизвестная сортировка: порядок filters.ArbitrageParams по умолчанию
+		for _, shard := range o.shards {
+			for _, e := range shard.entries {
+				if filter.Match(e.value) {
+					resp = append(resp, e.value)
 				}
 			}
 		}
-	} else {
-		if v.Profit < o.tail.value.Profit {
-			n := &node{
-				value:  v,
-				before: o.tail,
+
+		sort.Slice(resp, func(i, j int) bool {
+			return filter.Less(resp[i], resp[j])
+		})
+
+		return page(resp, filter.Offset, filter.Limit), true
+	}
+
+	// heads текущие узлы обхода индексов частей
+	heads := make([]*topNode, len(o.shards))
+	for j, shard := range o.shards {
+		heads[j] = shard.indexes[i].first(filter.SortDir, filter.After)
+	}
+
+	for {
+		if filter.Limit > 0 && uint(len(resp)) >= filter.Offset+filter.Limit {
+			break
+		}
+
+		// next часть со следующей по порядку комбинацией
+		next := -1
+		for j, n := range heads {
+			if n == nil {
+				continue
 			}
-			o.tail.next = n
-			o.tail = n
 
-			o.names[v.Pair] = n
-		} else {
-			n := &node{
-				value: v,
+			if next < 0 {
+				next = j
+				continue
 			}
 
-			currentNode := o.search(o.head, o.tail, v.Profit)
-			if currentNode.value.Profit > v.Profit {
-				currentNode.insertAfter(n)
-				if currentNode == o.tail {
-					o.tail = n
-				}
-			} else {
-				currentNode.insertBefore(n)
-				if currentNode == o.head {
-					o.head = n
-				}
+			c := filters.CompareArbitrage(filter.SortBy, n.value, heads[next].value)
+			if c < 0 && filter.SortDir != filters.Desc || c > 0 && filter.SortDir == filters.Desc {
+				next = j
 			}
+		}
+
+		if next < 0 {
+			break
+		}
 
-			o.names[v.Pair] = n
+		n := heads[next]
+		if filter.Match(n.value) {
+			resp = append(resp, n.value)
+		}
+
+		if filter.SortDir == filters.Desc {
+			heads[next] = n.prev
+		} else {
+			heads[next] = n.next[0]
 		}
-		o.length++
 	}
+
+	return page(resp, filter.Offset, filter.Limit), true
 }
 
-func (o *Top) Get() []*domain.Arbitrage {
-	resp := make([]*domain.Arbitrage, 0)
+// page возвращает часть комбинаций с учетом offset и limit, limit 0 - без ограничения
+func page(arbitrages []*domain.Arbitrage, offset, limit uint) []*domain.Arbitrage {
+	if offset >= uint(len(arbitrages)) {
+		return make([]*domain.Arbitrage, 0)
+	}
+	arbitrages = arbitrages[offset:]
 
-	currentNode := o.head
-	for currentNode != nil {
-		resp = append(resp, currentNode.value)
-		currentNode = currentNode.next
+	if limit > 0 && uint(len(arbitrages)) > limit {
+		arbitrages = arbitrages[:limit]
 	}
 
-	return resp
+	return arbitrages
 }
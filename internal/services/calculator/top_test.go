@@ -0,0 +1,311 @@
+package calculator
+
+import (
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func arbitrage(pair, buy, sell string, netProfit float64) *domain.Arbitrage {
+	return &domain.Arbitrage{
+		Pair:         pair,
+		BuyExchange:  buy,
+		SellExchange: sell,
+		NetProfit:    netProfit,
+	}
+}
+
+func TestTopGet(t *testing.T) {
+	byProfit := filters.ArbitrageParams{SortBy: filters.ArbitrageSortByNetProfit, SortDir: filters.Desc}
+
+	tests := []struct {
+		name   string
+		put    []*domain.Arbitrage
+		remove []*domain.Arbitrage
+		filter filters.ArbitrageParams
+		want   []string
+		wantOK bool
+	}{
+		{
+			name:   "cold start",
+			filter: byProfit,
+			want:   []string{},
+			wantOK: false,
+		},
+		{
+			name: "ordered by net profit",
+			put: []*domain.Arbitrage{
+				arbitrage("BTC_USDT", "binance", "okx", 1),
+				arbitrage("ETH_USDT", "binance", "okx", 3),
+				arbitrage("SOL_USDT", "binance", "okx", 2),
+			},
+			filter: byProfit,
+			want:   []string{"ETH_USDT:binance>okx", "SOL_USDT:binance>okx", "BTC_USDT:binance>okx"},
+			wantOK: true,
+		},
+		{
+			name: "profit change moves combination",
+			put: []*domain.Arbitrage{
+				arbitrage("BTC_USDT", "binance", "okx", 1),
+				arbitrage("ETH_USDT", "binance", "okx", 3),
+				arbitrage("SOL_USDT", "binance", "okx", 2),
+				arbitrage("BTC_USDT", "binance", "okx", 5),
+				arbitrage("ETH_USDT", "binance", "okx", 0.5),
+			},
+			filter: byProfit,
+			want:   []string{"BTC_USDT:binance>okx", "SOL_USDT:binance>okx", "ETH_USDT:binance>okx"},
+			wantOK: true,
+		},
+		{
+			name: "equal profit ordered by pair and exchanges",
+			put: []*domain.Arbitrage{
+				arbitrage("ETH_USDT", "okx", "binance", 1),
+				arbitrage("BTC_USDT", "okx", "binance", 1),
+				arbitrage("ETH_USDT", "binance", "okx", 1),
+			},
+			filter: byProfit,
+			want:   []string{"ETH_USDT:okx>binance", "ETH_USDT:binance>okx", "BTC_USDT:okx>binance"},
+			wantOK: true,
+		},
+		{
+			name: "remove",
+			put: []*domain.Arbitrage{
+				arbitrage("BTC_USDT", "binance", "okx", 1),
+				arbitrage("ETH_USDT", "binance", "okx", 3),
+				arbitrage("SOL_USDT", "binance", "okx", 2),
+			},
+			remove: []*domain.Arbitrage{
+				arbitrage("SOL_USDT", "binance", "okx", 0),
+				arbitrage("XRP_USDT", "binance", "okx", 0),
+			},
+			filter: byProfit,
+			want:   []string{"ETH_USDT:binance>okx", "BTC_USDT:binance>okx"},
+			wantOK: true,
+		},
+		{
+			name: "remove last combination keeps top warm",
+			put: []*domain.Arbitrage{
+				arbitrage("BTC_USDT", "binance", "okx", 1),
+			},
+			remove: []*domain.Arbitrage{
+				arbitrage("BTC_USDT", "binance", "okx", 1),
+			},
+			filter: byProfit,
+			want:   []string{},
+			wantOK: true,
+		},
+		{
+			name: "limit and offset",
+			put: []*domain.Arbitrage{
+				arbitrage("A_USDT", "binance", "okx", 5),
+				arbitrage("B_USDT", "binance", "okx", 4),
+				arbitrage("C_USDT", "binance", "okx", 3),
+				arbitrage("D_USDT", "binance", "okx", 2),
+				arbitrage("E_USDT", "binance", "okx", 1),
+			},
+			filter: filters.ArbitrageParams{
+				Limit:   2,
+				Offset:  1,
+				SortBy:  filters.ArbitrageSortByNetProfit,
+				SortDir: filters.Desc,
+			},
+			want:   []string{"B_USDT:binance>okx", "C_USDT:binance>okx"},
+			wantOK: true,
+		},
+		{
+			name: "offset past the end",
+			put: []*domain.Arbitrage{
+				arbitrage("A_USDT", "binance", "okx", 5),
+				arbitrage("B_USDT", "binance", "okx", 4),
+			},
+			filter: filters.ArbitrageParams{
+				Limit:   2,
+				Offset:  2,
+				SortBy:  filters.ArbitrageSortByNetProfit,
+				SortDir: filters.Desc,
+			},
+			want:   []string{},
+			wantOK: true,
+		},
+		{
+			name: "limit applies after filter",
+			put: []*domain.Arbitrage{
+				arbitrage("A_USDT", "binance", "okx", 5),
+				arbitrage("B_USDT", "gate", "okx", 4),
+				arbitrage("C_USDT", "binance", "okx", 3),
+				arbitrage("D_USDT", "binance", "okx", 2),
+			},
+			filter: filters.ArbitrageParams{
+				Limit:    2,
+				Exchange: "binance",
+				SortBy:   filters.ArbitrageSortByNetProfit,
+				SortDir:  filters.Desc,
+			},
+			want:   []string{"A_USDT:binance>okx", "C_USDT:binance>okx"},
+			wantOK: true,
+		},
+		{
+			name: "other sort",
+			put: []*domain.Arbitrage{
+				arbitrage("C_USDT", "binance", "okx", 5),
+				arbitrage("A_USDT", "binance", "okx", 4),
+				arbitrage("B_USDT", "binance", "okx", 3),
+			},
+			filter: filters.ArbitrageParams{
+				Limit:   2,
+				SortBy:  filters.ArbitrageSortByPair,
+				SortDir: filters.Asc,
+			},
+			want:   []string{"A_USDT:binance>okx", "B_USDT:binance>okx"},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top := NewTop(1)
+			for _, a := range tt.put {
+				top.Put(a)
+			}
+			for _, a := range tt.remove {
+				top.Remove(a)
+			}
+
+			got, ok := top.Get(tt.filter)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			keys := make([]string, 0, len(got))
+			for _, a := range got {
+				keys = append(keys, topKey(a))
+			}
+
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("got %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopGetAfterCursor(t *testing.T) {
+	top := NewTop(1)
+	for i, pair := range []string{"A_USDT", "B_USDT", "C_USDT", "D_USDT", "E_USDT"} {
+		top.Put(arbitrage(pair, "binance", "okx", float64(5-i)))
+	}
+
+	filter := filters.ArbitrageParams{
+		Limit:   2,
+		SortBy:  filters.ArbitrageSortByNetProfit,
+		SortDir: filters.Desc,
+	}
+
+	var pages [][]string
+	for {
+		got, _ := top.Get(filter)
+		if len(got) == 0 {
+			break
+		}
+
+		var keys []string
+		for _, a := range got {
+			keys = append(keys, a.Pair)
+		}
+		pages = append(pages, keys)
+
+		filter.After = filter.Cursor(got[len(got)-1])
+	}
+
+	want := [][]string{{"A_USDT", "B_USDT"}, {"C_USDT", "D_USDT"}, {"E_USDT"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got %v, want %v", pages, want)
+	}
+}
+
+// TestTopIndexes сверяет страницы всех сортировок с сортировкой всех комбинаций после случайных изменений
+func TestTopIndexes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	exchanges := []string{"binance", "bybit", "okx"}
+
+	// рейтинг из нескольких частей: страницы собираются слиянием их индексов
+	top := NewTop(4)
+	all := make(map[string]*domain.Arbitrage)
+	for i := 0; i < 3000; i++ {
+		a := arbitrage(fmt.Sprintf("A%03d_USDT", rnd.Intn(200)), exchanges[rnd.Intn(3)], exchanges[rnd.Intn(3)], float64(rnd.Intn(50))/10)
+		a.Profit = float64(rnd.Intn(50)) / 10
+		a.MaxVolume = float64(rnd.Intn(20))
+		a.UpdatedAt = start.Add(time.Duration(rnd.Intn(100)) * time.Second)
+
+		if rnd.Intn(5) == 0 {
+			top.Remove(a)
+			delete(all, topKey(a))
+			continue
+		}
+
+		top.Put(a)
+		all[topKey(a)] = a
+	}
+
+	for _, sortBy := range topSorts {
+		for _, sortDir := range []filters.SortDirection{filters.Asc, filters.Desc} {
+			t.Run(fmt.Sprintf("%s %s", sortBy, sortDir), func(t *testing.T) {
+				filter := filters.ArbitrageParams{Exchange: "okx", Limit: 7, SortBy: sortBy, SortDir: sortDir}
+
+				var want []string
+				for _, a := range all {
+					if filter.Match(a) {
+						want = append(want, topKey(a))
+					}
+				}
+				sort.Slice(want, func(i, j int) bool {
+					return filter.Less(all[want[i]], all[want[j]])
+				})
+
+				var got []string
+				for {
+					page, _ := top.Get(filter)
+					if len(page) == 0 {
+						break
+					}
+
+					for _, a := range page {
+						got = append(got, topKey(a))
+					}
+					filter.After = filter.Cursor(page[len(page)-1])
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got %d combinations, want %d\ngot  %v\nwant %v", len(got), len(want), got, want)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTopPut(b *testing.B) {
+	exchanges := []string{"binance", "bybit", "okx"}
+
+	top := NewTop(1)
+	for i := 0; i < benchmarkPairs; i++ {
+		for _, buy := range exchanges {
+			for _, sell := range exchanges {
+				if buy != sell {
+					top.Put(arbitrage(fmt.Sprintf("A%04d_USDT", i), buy, sell, float64(i%100)/10))
+				}
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		top.Put(arbitrage(fmt.Sprintf("A%04d_USDT", i%benchmarkPairs), "binance", "okx", float64(i%97)/10))
+	}
+}
@@ -0,0 +1,113 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"strings"
+	"sync"
+)
+
+// transferSchedule хранит сети ввода и вывода активов по биржам
+type transferSchedule struct {
+	// allowUnknown перевод без данных о сетях одной из бирж считается бесплатным, иначе невозможным
+	allowUnknown bool
+
+	mu       sync.RWMutex
+	networks map[string]map[string]map[string]*domain.AssetNetwork
+}
+
+// transfer лучший способ перевести актив между биржами
+type transfer struct {
+	network string
+	// fee стоимость перевода в процентах от объема сделки
+	fee        float64
+	impossible bool
+}
+
+func newTransferSchedule(allowUnknown bool) *transferSchedule {
+	return &transferSchedule{
+		allowUnknown: allowUnknown,
+		networks:     make(map[string]map[string]map[string]*domain.AssetNetwork),
+	}
+}
+
+func (s *transferSchedule) set(network *domain.AssetNetwork) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.networks[network.Exchange]; !ok {
+		s.networks[network.Exchange] = make(map[string]map[string]*domain.AssetNetwork)
+	}
+
+	if _, ok := s.networks[network.Exchange][network.Asset]; !ok {
+		s.networks[network.Exchange][network.Asset] = make(map[string]*domain.AssetNetwork)
+	}
+
+	s.networks[network.Exchange][network.Asset][network.Network] = network
+}
+
+// cheapest подбирает самую дешевую сеть для перевода базового актива пары с from на to.
+// Если хотя бы по одной из бирж нет данных о сетях, перевод невозможен, пока это не разрешено настройкой.
+func (s *transferSchedule) cheapest(pair, from, to string, tradeSize, buyPrice, sellPrice float64) transfer {
+	base := strings.Split(pair, "_")[0]
+
+	return s.best(base, from, to, func(withdrawal *domain.AssetNetwork) (float64, bool) {
+		if tradeSize <= 0 {
+			return 0, true
+		}
+
+		if tradeSize/buyPrice < withdrawal.MinWithdraw {
+			return 0, false
+		}
+
+		return withdrawal.WithdrawFee * sellPrice / tradeSize * 100, true
+	})
+}
+
+// network подбирает самую дешевую сеть для перевода amount единиц актива с from на to
+func (s *transferSchedule) network(asset, from, to string, amount float64) transfer {
+	return s.best(asset, from, to, func(withdrawal *domain.AssetNetwork) (float64, bool) {
+		if amount <= 0 {
+			return 0, true
+		}
+
+		if amount < withdrawal.MinWithdraw {
+			return 0, false
+		}
+
+		return withdrawal.WithdrawFee / amount * 100, true
+	})
+}
+
+// best перебирает общие для бирж сети актива, cost возвращает стоимость перевода в процентах
+// и false, если сеть не подходит
+func (s *transferSchedule) best(asset, from, to string, cost func(withdrawal *domain.AssetNetwork) (float64, bool)) transfer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	withdrawals, deposits := s.networks[from][asset], s.networks[to][asset]
+	if len(withdrawals) == 0 || len(deposits) == 0 {
+		return transfer{impossible: !s.allowUnknown}
+	}
+
+	best := transfer{impossible: true}
+	for name, withdrawal := range withdrawals {
+		deposit, ok := deposits[name]
+		if !ok || !withdrawal.WithdrawEnabled || !deposit.DepositEnabled {
+			continue
+		}
+
+		fee, ok := cost(withdrawal)
+		if !ok {
+			continue
+		}
+
+		if best.impossible || fee < best.fee {
+			best = transfer{
+				network: name,
+				fee:     fee,
+			}
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,64 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"testing"
+)
+
+func TestTransferUnknownNetworks(t *testing.T) {
+	network := func(exchange, name string, fee float64) *domain.AssetNetwork {
+		return &domain.AssetNetwork{
+			Exchange:        exchange,
+			Asset:           "ETH",
+			Network:         name,
+			WithdrawEnabled: true,
+			DepositEnabled:  true,
+			WithdrawFee:     fee,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		allowUnknown bool
+		networks     []*domain.AssetNetwork
+		want         transfer
+	}{
+		{
+			name: "unknown networks",
+			want: transfer{impossible: true},
+		},
+		{
+			name:         "unknown networks allowed",
+			allowUnknown: true,
+			want:         transfer{},
+		},
+		{
+			name:     "deposit networks unknown",
+			networks: []*domain.AssetNetwork{network("binance", "ERC20", 0.001)},
+			want:     transfer{impossible: true},
+		},
+		{
+			name: "cheapest common network",
+			networks: []*domain.AssetNetwork{
+				network("binance", "ERC20", 0.001),
+				network("binance", "ARBITRUM", 0.0001),
+				network("okx", "ERC20", 0),
+				network("okx", "ARBITRUM", 0),
+			},
+			want: transfer{network: "ARBITRUM", fee: 0.0001 * 2000 / 100 * 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTransferSchedule(tt.allowUnknown)
+			for _, n := range tt.networks {
+				s.set(n)
+			}
+
+			if got := s.cheapest("ETH_USDT", "binance", "okx", 100, 2000, 2000); got != tt.want {
+				t.Errorf("transfer = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
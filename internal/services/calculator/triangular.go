@@ -0,0 +1,244 @@
+package calculator
+
+import (
+	"calc/internal/domain"
+	"calc/internal/services/health"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	minCycleLength = 3
+	maxCycleLength = 4
+)
+
+// leg обмен одного актива на другой через пару: sell продает базовую валюту по bid, иначе покупает ее по ask
+type leg struct {
+	pair string
+	sell bool
+}
+
+type cycle struct {
+	route string
+	legs  []leg
+	// profitable прибыльность цикла при последнем сохранении
+	profitable bool
+}
+
+// triangular ищет прибыльные циклы обмена внутри одной биржи
+type triangular struct {
+	exchange  string
+	maxLength int
+	fees      *feeSchedule
+	health    *health.Tracker
+	quotes    map[string]*domain.Data
+	// cycles циклы, в которые входит пара
+	cycles map[string][]*cycle
+}
+
+func newTriangular(exchange string, pairs []string, maxLength int, fees *feeSchedule, healthTracker *health.Tracker) *triangular {
+	t := &triangular{
+		exchange:  exchange,
+		maxLength: maxLength,
+		fees:      fees,
+		health:    healthTracker,
+		quotes:    make(map[string]*domain.Data),
+	}
+
+	t.SetPairs(pairs)
+
+	return t
+}
+
+// SetPairs перестраивает циклы по новому списку пар биржи. Котировки оставшихся пар
+// и прибыльность оставшихся циклов сохраняются.
+func (t *triangular) SetPairs(pairs []string) {
+	profitable := make(map[string]bool)
+	for _, cycles := range t.cycles {
+		for _, c := range cycles {
+			if c.profitable {
+				profitable[c.route] = true
+			}
+		}
+	}
+
+	t.cycles = make(map[string][]*cycle)
+	for _, c := range findCycles(pairs, t.maxLength) {
+		c.profitable = profitable[c.route]
+		for _, l := range c.legs {
+			t.cycles[l.pair] = append(t.cycles[l.pair], c)
+		}
+	}
+
+	for pair := range t.quotes {
+		if _, ok := t.cycles[pair]; !ok {
+			delete(t.quotes, pair)
+		}
+	}
+}
+
+// Put пересчитывает циклы, в которые входит пара, и возвращает те, что нужно сохранить:
+// прибыльные и те, что перестали быть прибыльными. Циклы с устаревшими котировками не считаются.
+func (t *triangular) Put(data *domain.Data) []*domain.TriangularArbitrage {
+	cycles, ok := t.cycles[data.Pair]
+	if !ok || data.Bid <= 0 || data.Ask <= 0 {
+		return nil
+	}
+
+	t.quotes[data.Pair] = data
+
+	now := data.Time
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	var result []*domain.TriangularArbitrage
+	for _, c := range cycles {
+		arbitrage := t.calc(c, now)
+		if arbitrage == nil {
+			continue
+		}
+
+		profitable := arbitrage.NetProfit > 0
+		if profitable || c.profitable {
+			result = append(result, arbitrage)
+		}
+		c.profitable = profitable
+	}
+
+	return result
+}
+
+// calc считает цикл по последним котировкам, nil - котировки одной из пар нет или она устарела
+func (t *triangular) calc(c *cycle, now time.Time) *domain.TriangularArbitrage {
+	gross, net := 1.0, 1.0
+	legs := make([]*domain.TriangularLeg, 0, len(c.legs))
+	for _, l := range c.legs {
+		quote, ok := t.quotes[l.pair]
+		if !ok {
+			return nil
+		}
+
+		if !t.health.Fresh(t.exchange, l.pair, now) {
+			delete(t.quotes, l.pair)
+			return nil
+		}
+
+		fee := t.fees.taker(t.exchange, l.pair)
+		if l.sell {
+			gross *= quote.Bid
+			net *= netBid(quote.Bid, fee)
+			legs = append(legs, &domain.TriangularLeg{Pair: l.pair, Side: domain.SideSell, Price: quote.Bid, Fee: fee})
+		} else {
+			gross /= quote.Ask
+			net /= netAsk(quote.Ask, fee)
+			legs = append(legs, &domain.TriangularLeg{Pair: l.pair, Side: domain.SideBuy, Price: quote.Ask, Fee: fee})
+		}
+	}
+
+	return &domain.TriangularArbitrage{
+		Exchange:  t.exchange,
+		Route:     c.route,
+		Legs:      legs,
+		Profit:    (gross - 1) * 100,
+		NetProfit: (net - 1) * 100,
+	}
+}
+
+// findCycles строит граф валют по парам и возвращает все простые циклы длиной от 3 до maxLength
+// в обоих направлениях. Каждый цикл начинается с минимального по алфавиту актива.
+func findCycles(pairs []string, maxLength int) []*cycle {
+	if maxLength < minCycleLength {
+		maxLength = minCycleLength
+	}
+	if maxLength > maxCycleLength {
+		maxLength = maxCycleLength
+	}
+
+	edges := make(map[string]map[string]leg)
+	addEdge := func(from, to string, l leg) {
+		if _, ok := edges[from]; !ok {
+			edges[from] = make(map[string]leg)
+		}
+		edges[from][to] = l
+	}
+
+	for _, pair := range pairs {
+		assets := strings.Split(pair, "_")
+		if len(assets) != 2 {
+			continue
+		}
+
+		addEdge(assets[0], assets[1], leg{pair: pair, sell: true})
+		addEdge(assets[1], assets[0], leg{pair: pair, sell: false})
+	}
+
+	nodes := make([]string, 0, len(edges))
+	for node := range edges {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	var cycles []*cycle
+	var walk func(start string, path []string)
+	walk = func(start string, path []string) {
+		last := path[len(path)-1]
+
+		if len(path) >= minCycleLength {
+			if _, ok := edges[last][start]; ok {
+				cycles = append(cycles, newCycle(append(path, start), edges))
+			}
+		}
+
+		if len(path) == maxLength {
+			return
+		}
+
+		// последний шаг цикла возвращается в start: кандидаты - соседи start, их обычно меньше, чем у last
+		candidates := edges[last]
+		if len(path) == maxLength-1 {
+			candidates = edges[start]
+		}
+
+		for next := range candidates {
+			if next <= start || contains(path, next) {
+				continue
+			}
+
+			if _, ok := edges[last][next]; !ok {
+				continue
+			}
+
+			walk(start, append(append([]string{}, path...), next))
+		}
+	}
+
+	for _, node := range nodes {
+		walk(node, []string{node})
+	}
+
+	return cycles
+}
+
+func newCycle(route []string, edges map[string]map[string]leg) *cycle {
+	legs := make([]leg, 0, len(route)-1)
+	for i := 0; i < len(route)-1; i++ {
+		legs = append(legs, edges[route[i]][route[i+1]])
+	}
+
+	return &cycle{
+		route: strings.Join(route, ">"),
+		legs:  legs,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,229 @@
+package calculator
+
+import (
+	"calc/internal/adapters/db"
+	"calc/internal/domain"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFlushInterval = 500 * time.Millisecond
+	defaultBatchSize     = 1000
+)
+
+var (
+	promCoalesced = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "arbitrage_writes_coalesced_total",
+		Help:      "arbitrage changes replaced by a newer change of the same combination before flush",
+	})
+	promPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "calc",
+		Name:      "arbitrage_writes_pending",
+		Help:      "arbitrage changes waiting for flush",
+	})
+	promFlushDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "calc",
+		Name:      "arbitrage_flush_duration_seconds",
+		Help:      "duration of writing accumulated arbitrage changes to the database",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+	promFlushed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "arbitrage_flushed_total",
+		Help:      "arbitrage changes written to the database",
+	}, []string{"operation"})
+	promFlushErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "calc",
+		Name:      "arbitrage_flush_errors_total",
+		Help:      "failed arbitrage flushes",
+	})
+	promWriterRegister sync.Once
+)
+
+// pendingArbitrage последнее изменение комбинации: сохранение или удаление
+type pendingArbitrage struct {
+	arbitrage *domain.Arbitrage
+	deleted   bool
+}
+
+// arbitrageWriter копит изменения комбинаций и пишет их в базу пакетами в отдельной горутине.
+// Из нескольких изменений одной комбинации между записями в базу попадает только последнее.
+type arbitrageWriter struct {
+	repo      db.ArbitrageRepo
+	interval  time.Duration
+	batchSize int
+	// full сигнал о накоплении batchSize изменений
+	full chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]*pendingArbitrage
+	// flushMu не дает двум записям в базу идти одновременно, иначе старое изменение может записаться после нового
+	flushMu sync.Mutex
+}
+
+func newArbitrageWriter(repo db.ArbitrageRepo, interval time.Duration, batchSize int) *arbitrageWriter {
+	promWriterRegister.Do(func() {
+		prometheus.MustRegister(promCoalesced, promPending, promFlushDuration, promFlushed, promFlushErrors)
+	})
+
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &arbitrageWriter{
+		repo:      repo,
+		interval:  interval,
+		batchSize: batchSize,
+		full:      make(chan struct{}, 1),
+		pending:   make(map[string]*pendingArbitrage),
+	}
+}
+
+func (w *arbitrageWriter) Save(arbitrage *domain.Arbitrage) {
+	w.put(arbitrage, false)
+}
+
+func (w *arbitrageWriter) Delete(arbitrage *domain.Arbitrage) {
+	w.put(arbitrage, true)
+}
+
+func (w *arbitrageWriter) put(arbitrage *domain.Arbitrage, deleted bool) {
+	key := arbitrage.Pair + ":" + combinationKey(arbitrage)
+
+	w.mu.Lock()
+	_, coalesced := w.pending[key]
+	w.pending[key] = &pendingArbitrage{arbitrage: arbitrage, deleted: deleted}
+	size := len(w.pending)
+	w.mu.Unlock()
+
+	if coalesced {
+		promCoalesced.Inc()
+		return
+	}
+
+	promPending.Inc()
+
+	if size >= w.batchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run пишет накопленные изменения раз в interval или по накоплении batchSize, пока не закончится контекст
+func (w *arbitrageWriter) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+
+		if err := w.Flush(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to flush arbitrages")
+		}
+	}
+}
+
+// Flush пишет накопленные изменения в базу пакетами не больше batchSize: удаления и сохранения
+// многострочными запросами. Изменения, которые не удалось записать, возвращаются в очередь,
+// если их не заменили более новые.
+func (w *arbitrageWriter) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[string]*pendingArbitrage, len(pending))
+	w.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	promPending.Sub(float64(len(pending)))
+
+	start := time.Now()
+	defer func() {
+		promFlushDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	var saved, deleted []string
+	for key, p := range pending {
+		if p.deleted {
+			deleted = append(deleted, key)
+		} else {
+			saved = append(saved, key)
+		}
+	}
+
+	if err := w.flush(ctx, pending, deleted, "delete", w.repo.DeleteBatch); err != nil {
+		return err
+	}
+
+	return w.flush(ctx, pending, saved, "save", w.repo.SaveBatch)
+}
+
+// flush пишет изменения по ключам пакетами через write и убирает записанные из pending
+func (w *arbitrageWriter) flush(
+	ctx context.Context,
+	pending map[string]*pendingArbitrage,
+	keys []string,
+	operation string,
+	write func(ctx context.Context, arbitrages []*domain.Arbitrage) error,
+) error {
+	for start := 0; start < len(keys); start += w.batchSize {
+		end := start + w.batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		batch := make([]*domain.Arbitrage, 0, end-start)
+		for _, key := range keys[start:end] {
+			batch = append(batch, pending[key].arbitrage)
+		}
+
+		if err := write(ctx, batch); err != nil {
+			promFlushErrors.Inc()
+			w.retry(pending)
+			return err
+		}
+
+		promFlushed.WithLabelValues(operation).Add(float64(len(batch)))
+
+		for _, key := range keys[start:end] {
+			delete(pending, key)
+		}
+	}
+
+	return nil
+}
+
+// retry возвращает незаписанные изменения в очередь
+func (w *arbitrageWriter) retry(pending map[string]*pendingArbitrage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, p := range pending {
+		if _, ok := w.pending[key]; ok {
+			continue
+		}
+
+		w.pending[key] = p
+		promPending.Inc()
+	}
+}
@@ -9,10 +9,15 @@ var (
 		ErrCode: baseCode + 1,
 		Message: "db not ready",
 	}
+	ErrExchangesNotReady = &berrors.BusinessError{
+		ErrCode: baseCode + 2,
+		Message: "exchanges not ready",
+	}
 )
 
 func Errors() []*berrors.BusinessError {
 	return []*berrors.BusinessError{
 		ErrDBNotReady,
+		ErrExchangesNotReady,
 	}
 }
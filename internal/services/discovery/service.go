@@ -0,0 +1,357 @@
+package discovery
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/domain"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval     = time.Hour
+	defaultMinExchanges = 2
+	defaultCacheTTL     = 10 * time.Minute
+	// startDelay задержка первого поиска: адаптеры загружают символы бирж при запуске
+	startDelay = 30 * time.Second
+)
+
+var promPairs = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "calc",
+	Name:      "discovery_pairs",
+	Help:      "pairs common to several exchanges",
+})
+
+// Source биржи, среди которых ищутся общие пары
+type Source interface {
+	List() []string
+	Get(exchange string) (exchanges.Exchange, error)
+}
+
+// Subscriber применяет изменения списка пар
+type Subscriber interface {
+	AddPair(ctx context.Context, pair string) ([]string, error)
+	RemovePair(ctx context.Context, pair string) error
+}
+
+// Service периодически ищет пары, которые торгуются на нескольких биржах и проходят фильтры
+// по валюте котировки, объему за 24 часа и минимальной сумме ордера, и подписывается на них
+type Service struct {
+	source       Source
+	subscriber   Subscriber
+	logger       *zerolog.Logger
+	enabled      bool
+	interval     time.Duration
+	minExchanges int
+	cacheTTL     time.Duration
+	quotes       map[string]bool
+	minVolume    map[string]float64
+	tradeSize    map[string]float64
+
+	mu        sync.RWMutex
+	common    []*domain.CommonPair
+	updatedAt time.Time
+	// subscribed пары, на которые есть подписка
+	subscribed map[string]bool
+	// pinned пары из настроек и добавленные вручную, поиск не отписывается от них
+	pinned map[string]bool
+	// excluded пары, удаленные вручную, поиск не подписывается на них, пока их не добавят снова
+	excluded map[string]bool
+
+	// findMu не дает искать пары одновременно: поиск запрашивает рынки всех бирж
+	findMu sync.Mutex
+	// markets последние полученные рынки бирж, используются, если биржа не ответила. Доступ под findMu.
+	markets map[string][]*domain.Market
+}
+
+func NewService(cfg *config.Config, source Source, subscriber Subscriber) *Service {
+	discoveryLogger := log.Logger.With().Str("logger", "discovery").Logger()
+
+	s := &Service{
+		source:       source,
+		subscriber:   subscriber,
+		logger:       &discoveryLogger,
+		interval:     defaultInterval,
+		minExchanges: defaultMinExchanges,
+		cacheTTL:     defaultCacheTTL,
+		quotes:       make(map[string]bool),
+		tradeSize:    cfg.Exchanges.TradeSize,
+		subscribed:   make(map[string]bool),
+		pinned:       make(map[string]bool),
+		excluded:     make(map[string]bool),
+		markets:      make(map[string][]*domain.Market),
+	}
+
+	for _, pair := range cfg.Exchanges.Pairs {
+		s.subscribed[pair] = true
+		s.pinned[pair] = true
+	}
+
+	discovery := cfg.Exchanges.Discovery
+	if discovery == nil {
+		return s
+	}
+
+	s.enabled = discovery.Enabled
+	s.minVolume = discovery.MinVolume
+	for _, quote := range discovery.Quotes {
+		s.quotes[quote] = true
+	}
+	if discovery.Interval > 0 {
+		s.interval = discovery.Interval
+	}
+	if discovery.MinExchanges > 0 {
+		s.minExchanges = discovery.MinExchanges
+	}
+	if discovery.CacheTTL > 0 {
+		s.cacheTTL = discovery.CacheTTL
+	}
+
+	if s.enabled {
+		prometheus.MustRegister(promPairs)
+	}
+
+	return s
+}
+
+func (s *Service) Enabled() bool {
+	return s.enabled
+}
+
+// Run обновляет список пар до отмены контекста
+func (s *Service) Run(ctx context.Context) {
+	timer := time.NewTimer(startDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.Error().Stack().Err(err).Msg("failed to refresh common pairs")
+			}
+
+			timer.Reset(s.interval)
+		}
+	}
+}
+
+// Refresh ищет общие пары и подписывается на новые и отписывается от выбывших.
+// Вместо рынков биржи, которая не ответила, берутся последние полученные. Пока у какой-то биржи
+// рынков нет совсем, поиск только подписывается: иначе пропали бы все ее пары.
+func (s *Service) Refresh(ctx context.Context) error {
+	s.findMu.Lock()
+	common, complete := s.find(ctx)
+	s.findMu.Unlock()
+
+	s.mu.Lock()
+	s.common = common
+	s.updatedAt = time.Now().UTC()
+	s.mu.Unlock()
+
+	promPairs.Set(float64(len(common)))
+
+	found := make(map[string]bool, len(common))
+	for _, pair := range common {
+		found[pair.Pair] = true
+	}
+
+	added, removed := s.diff(found)
+	if !complete {
+		removed = nil
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	s.logger.Info().Int("pairs", len(common)).Bool("complete", complete).Strs("added", added).Strs("removed", removed).Msg("common pairs refreshed")
+
+	for _, pair := range added {
+		// пара, на которую не удалось подписаться, будет добавлена при следующем обновлении
+		if _, err := s.subscriber.AddPair(ctx, pair); err != nil {
+			s.logger.Error().Stack().Err(err).Str("pair", pair).Msg("failed to add pair")
+			continue
+		}
+
+		s.setSubscribed(pair, true)
+	}
+
+	for _, pair := range removed {
+		if err := s.subscriber.RemovePair(ctx, pair); err != nil {
+			s.logger.Error().Stack().Err(err).Str("pair", pair).Msg("failed to remove pair")
+			continue
+		}
+
+		s.setSubscribed(pair, false)
+	}
+
+	return nil
+}
+
+// Common возвращает найденные общие пары и время поиска. Если поиск выключен, пары ищутся при запросе
+// и кэшируются на cacheTTL.
+func (s *Service) Common(ctx context.Context) ([]*domain.CommonPair, time.Time, error) {
+	if s.enabled {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		return s.common, s.updatedAt, nil
+	}
+
+	s.findMu.Lock()
+	defer s.findMu.Unlock()
+
+	s.mu.RLock()
+	common, updatedAt := s.common, s.updatedAt
+	s.mu.RUnlock()
+
+	if !updatedAt.IsZero() && time.Since(updatedAt) < s.cacheTTL {
+		return common, updatedAt, nil
+	}
+
+	common, _ = s.find(ctx)
+	updatedAt = time.Now().UTC()
+
+	s.mu.Lock()
+	s.common = common
+	s.updatedAt = updatedAt
+	s.mu.Unlock()
+
+	return common, updatedAt, nil
+}
+
+// find пересекает торгуемые пары бирж и оставляет те, что проходят фильтры хотя бы на minExchanges биржах.
+// complete false - у какой-то биржи нет ни свежих, ни прошлых рынков. Вызывается под findMu.
+func (s *Service) find(ctx context.Context) (common []*domain.CommonPair, complete bool) {
+	complete = true
+	byPair := make(map[string]*domain.CommonPair)
+	for _, name := range s.source.List() {
+		markets, ok := s.loadMarkets(ctx, name)
+		if !ok {
+			complete = false
+			continue
+		}
+
+		for _, market := range markets {
+			if !s.accept(market) {
+				continue
+			}
+
+			pair, ok := byPair[market.Pair]
+			if !ok {
+				pair = &domain.CommonPair{
+					Pair:    market.Pair,
+					Quote:   market.Quote,
+					Volumes: make(map[string]float64),
+				}
+				byPair[market.Pair] = pair
+			}
+
+			pair.Volumes[name] = market.Volume
+		}
+	}
+
+	common = make([]*domain.CommonPair, 0, len(byPair))
+	for _, pair := range byPair {
+		if len(pair.Volumes) >= s.minExchanges {
+			common = append(common, pair)
+		}
+	}
+
+	sort.Slice(common, func(i, j int) bool {
+		return common[i].Pair < common[j].Pair
+	})
+
+	return common, complete
+}
+
+// loadMarkets запрашивает рынки биржи, при ошибке возвращает последние полученные. Вызывается под findMu.
+func (s *Service) loadMarkets(ctx context.Context, name string) ([]*domain.Market, bool) {
+	exchange, err := s.source.Get(name)
+	if err == nil {
+		var markets []*domain.Market
+		if markets, err = exchange.Markets(ctx); err == nil {
+			s.markets[name] = markets
+			return markets, true
+		}
+	}
+
+	markets, ok := s.markets[name]
+	s.logger.Error().Stack().Err(err).Str("exchange", name).Bool("cached", ok).Msg("failed to load markets")
+
+	return markets, ok
+}
+
+// accept проверяет валюту котировки, объем и то, что минимальный ордер не больше объема сделки
+func (s *Service) accept(market *domain.Market) bool {
+	if len(s.quotes) > 0 && !s.quotes[market.Quote] {
+		return false
+	}
+
+	if market.Volume < s.minVolume[market.Quote] {
+		return false
+	}
+
+	if size := s.tradeSize[market.Quote]; size > 0 && market.MinNotional > size {
+		return false
+	}
+
+	return true
+}
+
+// Pin отмечает пару, добавленную вручную: поиск не отписывается от нее
+func (s *Service) Pin(pair string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribed[pair] = true
+	s.pinned[pair] = true
+	delete(s.excluded, pair)
+}
+
+// Exclude отмечает пару, удаленную вручную: поиск не подписывается на нее, пока ее не добавят снова
+func (s *Service) Exclude(pair string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribed, pair)
+	delete(s.pinned, pair)
+	s.excluded[pair] = true
+}
+
+// diff возвращает найденные пары без подписки и пары с подпиской, которые больше не найдены,
+// без пар, удаленных вручную, и закрепленных пар
+func (s *Service) diff(found map[string]bool) ([]string, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var added, removed []string
+	for pair := range found {
+		if !s.subscribed[pair] && !s.excluded[pair] {
+			added = append(added, pair)
+		}
+	}
+	for pair := range s.subscribed {
+		if !found[pair] && !s.pinned[pair] {
+			removed = append(removed, pair)
+		}
+	}
+
+	return added, removed
+}
+
+func (s *Service) setSubscribed(pair string, subscribed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subscribed {
+		s.subscribed[pair] = true
+	} else {
+		delete(s.subscribed, pair)
+	}
+}
@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/client/exchanges"
+	"calc/internal/domain"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// venue биржа, которая отдает заданные рынки или ошибку и считает запросы
+type venue struct {
+	exchanges.Exchange
+	markets []*domain.Market
+	err     error
+	calls   int
+}
+
+func (v *venue) Markets(context.Context) ([]*domain.Market, error) {
+	v.calls++
+	return v.markets, v.err
+}
+
+type source map[string]*venue
+
+func (s source) List() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s source) Get(name string) (exchanges.Exchange, error) {
+	return s[name], nil
+}
+
+type subscriber struct {
+	added, removed []string
+}
+
+func (s *subscriber) AddPair(_ context.Context, pair string) ([]string, error) {
+	s.added = append(s.added, pair)
+	return nil, nil
+}
+
+func (s *subscriber) RemovePair(_ context.Context, pair string) error {
+	s.removed = append(s.removed, pair)
+	return nil
+}
+
+func markets(pairs ...string) []*domain.Market {
+	result := make([]*domain.Market, 0, len(pairs))
+	for _, pair := range pairs {
+		result = append(result, &domain.Market{Pair: pair, Quote: "USDT", Volume: 1e6})
+	}
+
+	return result
+}
+
+func newTestService(src source, sub *subscriber) *Service {
+	return NewService(&config.Config{
+		Exchanges: &config.Exchange{
+			Pairs:     []string{"BTC_USDT"},
+			Discovery: &config.Discovery{CacheTTL: time.Minute},
+		},
+	}, src, sub)
+}
+
+func pairs(common []*domain.CommonPair) []string {
+	result := make([]string, 0, len(common))
+	for _, pair := range common {
+		result = append(result, pair.Pair)
+	}
+
+	return result
+}
+
+func TestCommonCache(t *testing.T) {
+	src := source{
+		"binance": {markets: markets("BTC_USDT", "ETH_USDT")},
+		"okx":     {markets: markets("BTC_USDT", "ETH_USDT")},
+	}
+	s := newTestService(src, &subscriber{})
+
+	for i := 0; i < 3; i++ {
+		common, _, err := s.Common(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := pairs(common); !reflect.DeepEqual(got, []string{"BTC_USDT", "ETH_USDT"}) {
+			t.Fatalf("common = %v", got)
+		}
+	}
+
+	for name, v := range src {
+		if v.calls != 1 {
+			t.Errorf("%s markets requested %d times, want 1", name, v.calls)
+		}
+	}
+}
+
+func TestRefreshFailedVenue(t *testing.T) {
+	tests := []struct {
+		name string
+		// first рынки okx при первом обновлении, nil - биржа не ответила
+		first []*domain.Market
+		// subscribed пары, найденные раньше
+		subscribed []string
+		common     []string
+		added      []string
+		removed    []string
+	}{
+		{
+			name:    "last good markets",
+			first:   markets("BTC_USDT", "ETH_USDT", "SOL_USDT"),
+			common:  []string{"BTC_USDT", "ETH_USDT", "SOL_USDT"},
+			added:   []string{"ETH_USDT", "SOL_USDT"},
+			removed: nil,
+		},
+		{
+			// ETH_USDT без okx торгуется на одной бирже, но без рынков okx от нее не отписываются
+			name:       "no markets yet",
+			subscribed: []string{"ETH_USDT"},
+			common:     []string{"SOL_USDT"},
+			added:      []string{"SOL_USDT"},
+			removed:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			okx := &venue{markets: tt.first}
+			if tt.first == nil {
+				okx.err = errors.New("timeout")
+			}
+
+			src := source{
+				"binance": {markets: markets("BTC_USDT", "ETH_USDT", "SOL_USDT")},
+				"bybit":   {markets: markets("SOL_USDT")},
+				"okx":     okx,
+			}
+			sub := &subscriber{}
+			s := newTestService(src, sub)
+			for _, pair := range tt.subscribed {
+				s.setSubscribed(pair, true)
+			}
+
+			if err := s.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			// биржа перестает отвечать, ее пары не должны пропасть
+			okx.err = errors.New("timeout")
+			if err := s.Refresh(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			common, _, _ := s.Common(context.Background())
+			if got := pairs(common); !reflect.DeepEqual(got, tt.common) {
+				t.Errorf("common = %v, want %v", got, tt.common)
+			}
+
+			if !reflect.DeepEqual(sub.added, tt.added) {
+				t.Errorf("added = %v, want %v", sub.added, tt.added)
+			}
+
+			if !reflect.DeepEqual(sub.removed, tt.removed) {
+				t.Errorf("removed = %v, want %v", sub.removed, tt.removed)
+			}
+		})
+	}
+}
@@ -9,4 +9,12 @@ var (
 		ErrCode: baseCode + 1,
 		Message: "cannot connect to exchange",
 	}
+	ErrPairNotTraded = &berrors.BusinessError{
+		ErrCode: baseCode + 2,
+		Message: "pair is not traded on any exchange",
+	}
+	ErrInvalidCursor = &berrors.BusinessError{
+		ErrCode: baseCode + 3,
+		Message: "invalid cursor",
+	}
 )
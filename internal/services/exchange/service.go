@@ -3,27 +3,73 @@ package exchange
 import (
 	"calc/common/config"
 	"calc/internal/adapters/client/exchanges"
+	"calc/internal/adapters/client/symbols"
 	"calc/internal/adapters/db"
 	"calc/internal/adapters/db/filters"
+	"calc/internal/berrors"
 	"calc/internal/domain"
+	"calc/internal/services/bus"
 	"calc/internal/services/calculator"
+	"calc/internal/services/discovery"
+	"calc/internal/services/health"
+	"calc/internal/services/history"
 	"context"
+	"github.com/pkg/errors"
+	"sort"
+	"time"
 )
 
+const minReadyExchanges = 2
+
 type Service struct {
-	arbitrageRepo    db.ArbitrageRepo
-	exchangeFactory  *exchanges.ExchangeFactory
-	calculateService calculator.CalculateService
+	arbitrageRepo           db.ArbitrageRepo
+	triangularArbitrageRepo db.TriangularArbitrageRepo
+	carryRepo               db.CarryRepo
+	routeRepo               db.RouteRepo
+	opportunityRepo         db.OpportunityRepo
+	historyService          *history.Service
+	exchangeFactory         *exchanges.ExchangeFactory
+	calculateService        calculator.CalculateService
+	bus                     *bus.Bus
+	discovery               *discovery.Service
 }
 
-func NewService(ctx context.Context, cfg *config.Config, arbitrageRepo db.ArbitrageRepo) *Service {
-	calculateService := calculator.NewCalculateService(ctx, cfg, arbitrageRepo)
+func NewService(
+	ctx context.Context,
+	cfg *config.Config,
+	arbitrageRepo db.ArbitrageRepo,
+	triangularArbitrageRepo db.TriangularArbitrageRepo,
+	carryRepo db.CarryRepo,
+	routeRepo db.RouteRepo,
+	opportunityRepo db.OpportunityRepo,
+	historyService *history.Service,
+	healthTracker *health.Tracker,
+	calculateService calculator.CalculateService,
+	marketBus *bus.Bus,
+) (*Service, error) {
+	exchangeFactory, err := exchanges.NewExchangeFactory(ctx, cfg, calculateService, marketBus, healthTracker)
+	if err != nil {
+		return nil, err
+	}
 
-	return &Service{
-		exchangeFactory:  exchanges.NewExchangeFactory(ctx, cfg, calculateService),
-		calculateService: calculateService,
-		arbitrageRepo:    arbitrageRepo,
+	s := &Service{
+		exchangeFactory:         exchangeFactory,
+		calculateService:        calculateService,
+		bus:                     marketBus,
+		arbitrageRepo:           arbitrageRepo,
+		triangularArbitrageRepo: triangularArbitrageRepo,
+		carryRepo:               carryRepo,
+		routeRepo:               routeRepo,
+		opportunityRepo:         opportunityRepo,
+		historyService:          historyService,
 	}
+
+	s.discovery = discovery.NewService(cfg, s.exchangeFactory, discoverySubscriber{s})
+	if s.discovery.Enabled() {
+		go s.discovery.Run(ctx)
+	}
+
+	return s, nil
 }
 
 type SignUpArgs struct {
@@ -44,6 +90,99 @@ func (s *Service) Pairs(ctx context.Context, exchange string) ([]string, error)
 	return e.Pairs(ctx)
 }
 
+// CommonPairs возвращает пары, общие для нескольких бирж, и время их поиска
+func (s *Service) CommonPairs(ctx context.Context) ([]*domain.CommonPair, time.Time, error) {
+	return s.discovery.Common(ctx)
+}
+
+// AddPair начинает расчет арбитража по паре и подписывается на нее на всех биржах, где она торгуется.
+// Возвращает биржи, на которых есть подписка. Поиск общих пар не отписывается от добавленной пары.
+func (s *Service) AddPair(ctx context.Context, pair string) ([]string, error) {
+	subscribed, err := s.addPair(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	s.discovery.Pin(pair)
+
+	return subscribed, nil
+}
+
+// RemovePair отписывается от пары на всех биржах и прекращает расчет арбитража по ней.
+// Поиск общих пар не подписывается на удаленную пару, пока ее не добавят снова.
+func (s *Service) RemovePair(ctx context.Context, pair string) error {
+	if err := s.removePair(ctx, pair); err != nil {
+		return err
+	}
+
+	s.discovery.Exclude(pair)
+
+	return nil
+}
+
+func (s *Service) addPair(ctx context.Context, pair string) ([]string, error) {
+	s.calculateService.AddPair(pair)
+
+	names := s.exchangeFactory.List()
+	sort.Strings(names)
+
+	var subscribed []string
+	for _, name := range names {
+		e, err := s.exchangeFactory.Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := e.Subscribe(ctx, pair); err != nil {
+			if errors.Is(err, symbols.ErrUnknownPair) {
+				continue
+			}
+
+			return nil, errors.Wrapf(err, "failed to subscribe %s on %s", pair, name)
+		}
+
+		subscribed = append(subscribed, name)
+	}
+
+	if len(subscribed) == 0 {
+		if err := s.calculateService.RemovePair(pair); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrPairNotTraded
+	}
+
+	return subscribed, nil
+}
+
+func (s *Service) removePair(ctx context.Context, pair string) error {
+	for _, name := range s.exchangeFactory.List() {
+		e, err := s.exchangeFactory.Get(name)
+		if err != nil {
+			return err
+		}
+
+		if err := e.Unsubscribe(ctx, pair); err != nil {
+			return errors.Wrapf(err, "failed to unsubscribe %s on %s", pair, name)
+		}
+	}
+
+	return s.calculateService.RemovePair(pair)
+}
+
+// discoverySubscriber применяет изменения, найденные поиском общих пар, без ручного закрепления и исключения пар
+type discoverySubscriber struct {
+	s *Service
+}
+
+func (d discoverySubscriber) AddPair(ctx context.Context, pair string) ([]string, error) {
+	return d.s.addPair(ctx, pair)
+}
+
+func (d discoverySubscriber) RemovePair(ctx context.Context, pair string) error {
+	return d.s.removePair(ctx, pair)
+}
+
 func (s *Service) Price(ctx context.Context, exchange string, pair string) (float64, error) {
 	e, err := s.exchangeFactory.Get(exchange)
 	if err != nil {
@@ -53,32 +192,196 @@ func (s *Service) Price(ctx context.Context, exchange string, pair string) (floa
 	return e.Price(ctx, pair)
 }
 
-func (s *Service) Top(ctx context.Context, limit uint) ([]*domain.Arbitrage, error) {
-	return s.arbitrageRepo.FindAllByFilter(ctx, filters.ArbitrageParams{
+type TopArgs struct {
+	Limit            uint
+	Offset           uint
+	Pair             string
+	Base             string
+	Quote            string
+	Exchange         string
+	Exchanges        []string
+	ExcludeExchanges []string
+	// MinProfit, MaxProfit и MinVolume nil - без ограничения
+	MinProfit    *float64
+	MaxProfit    *float64
+	MinVolume    *float64
+	UpdatedSince time.Time
+	SortBy       string
+	SortDir      string
+	// Cursor курсор следующей страницы из предыдущего ответа
+	Cursor string
+}
+
+// Top возвращает комбинации из рейтинга калькулятора и курсор следующей страницы, пустой на последней странице.
+// Пока рейтинг не собран после запуска, комбинации читаются из базы.
+func (s *Service) Top(ctx context.Context, args TopArgs) ([]*domain.Arbitrage, string, error) {
+	sortBy, err := filters.NewArbitrageSortBy(args.SortBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sortDir, err := filters.NewSortDirection(args.SortDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	filter := filters.ArbitrageParams{
+		Limit:            args.Limit,
+		Offset:           args.Offset,
+		Pair:             args.Pair,
+		Base:             args.Base,
+		Quote:            args.Quote,
+		Exchange:         args.Exchange,
+		Exchanges:        args.Exchanges,
+		ExcludeExchanges: args.ExcludeExchanges,
+		MinProfit:        args.MinProfit,
+		MaxProfit:        args.MaxProfit,
+		MinVolume:        args.MinVolume,
+		UpdatedSince:     args.UpdatedSince,
+		SortBy:           sortBy,
+		SortDir:          sortDir,
+	}
+
+	if args.Cursor != "" {
+		after, err := filters.DecodeArbitrageCursor(args.Cursor, sortBy, sortDir)
+		if err != nil {
+			return nil, "", berrors.WrapWithError(ErrInvalidCursor, err)
+		}
+
+		filter.After = after
+	}
+
+	top, ok := s.calculateService.Top(filter)
+	if !ok {
+		if top, err = s.arbitrageRepo.FindAllByFilter(ctx, filter); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var next string
+	if args.Limit > 0 && uint(len(top)) == args.Limit {
+		next = filter.Cursor(top[len(top)-1]).Encode()
+	}
+
+	return top, next, nil
+}
+
+func (s *Service) TopTriangular(ctx context.Context, limit uint, exchange string) ([]*domain.TriangularArbitrage, error) {
+	return s.triangularArbitrageRepo.FindAllByFilter(ctx, filters.TriangularArbitrageParams{
+		Limit:    limit,
+		Exchange: exchange,
+		SortBy:   filters.TriangularArbitrageSortByNetProfit,
+		SortDir:  filters.Desc,
+	})
+}
+
+func (s *Service) TopCarry(ctx context.Context, limit uint, kind, pair string) ([]*domain.Carry, error) {
+	return s.carryRepo.FindAllByFilter(ctx, filters.CarryParams{
+		Limit:   limit,
+		Kind:    kind,
+		Pair:    pair,
+		SortBy:  filters.CarrySortByAnnualizedCarry,
+		SortDir: filters.Desc,
+	})
+}
+
+func (s *Service) TopRoutes(ctx context.Context, limit uint) ([]*domain.Route, error) {
+	return s.routeRepo.FindAllByFilter(ctx, filters.RouteParams{
 		Limit:   limit,
-		SortBy:  filters.ArbitrageSortByProfit,
+		SortBy:  filters.RouteSortByNetProfit,
 		SortDir: filters.Desc,
 	})
 }
 
-func (s *Service) WSPrice(ctx context.Context, exchange string, pair string, ch chan<- float64) error {
+type OpportunitiesArgs struct {
+	Limit       uint
+	Active      bool
+	Pair        string
+	Exchange    string
+	MinDuration time.Duration
+}
+
+func (s *Service) Opportunities(ctx context.Context, args OpportunitiesArgs) ([]*domain.Opportunity, error) {
+	sortBy := filters.OpportunitySortByOpenedAt
+	if args.Active {
+		sortBy = filters.OpportunitySortByPeakProfit
+	}
+
+	return s.opportunityRepo.FindAllByFilter(ctx, filters.OpportunityParams{
+		Limit:       args.Limit,
+		Active:      args.Active,
+		Pair:        args.Pair,
+		Exchange:    args.Exchange,
+		MinDuration: args.MinDuration,
+		SortBy:      sortBy,
+		SortDir:     filters.Desc,
+	})
+}
+
+func (s *Service) History(
+	ctx context.Context,
+	pair string,
+	from, to time.Time,
+	resolution domain.Resolution,
+) ([]*domain.QuoteCandle, []*domain.SpreadCandle, error) {
+	return s.historyService.Find(ctx, pair, from, to, resolution)
+}
+
+// Health возвращает состояние всех бирж, отсортированное по названию
+func (s *Service) Health(ctx context.Context) []*domain.ExchangeHealth {
+	names := s.exchangeFactory.List()
+	sort.Strings(names)
+
+	health := make([]*domain.ExchangeHealth, 0, len(names))
+	for _, name := range names {
+		e, err := s.exchangeFactory.Get(name)
+		if err != nil {
+			continue
+		}
+
+		health = append(health, e.Health(ctx))
+	}
+
+	return health
+}
+
+// Status возвращает состояние биржи
+func (s *Service) Status(ctx context.Context, exchange string) (*domain.ExchangeHealth, error) {
 	e, err := s.exchangeFactory.Get(exchange)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	dataCh := make(chan *domain.Data)
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case data := <-dataCh:
-				ch <- data.Price
-			}
+	return e.Health(ctx), nil
+}
+
+// Ready готов ли сервис считать арбитраж: нужны хотя бы две биржи со свежими котировками
+// или все биржи, если их настроено меньше
+func Ready(health []*domain.ExchangeHealth) bool {
+	usable := 0
+	for _, h := range health {
+		if h.Status.Usable() {
+			usable++
 		}
-	}()
+	}
 
-	e.WSPrice(ctx, pair, dataCh)
-	return nil
+	need := minReadyExchanges
+	if len(health) < need {
+		need = len(health)
+	}
+
+	return usable >= need
+}
+
+// WSPrice подписывает websocket-клиента на котировки спота пары. Подписку нужно закрыть после отключения клиента.
+func (s *Service) WSPrice(exchange string, pair string) (*bus.Subscription, error) {
+	if _, err := s.exchangeFactory.Get(exchange); err != nil {
+		return nil, err
+	}
+
+	return s.bus.Subscribe("ws", bus.Filter{
+		Exchange: exchange,
+		Pair:     pair,
+		Event:    bus.EventQuote,
+	}), nil
 }
@@ -0,0 +1,167 @@
+package health
+
+import (
+	"calc/common/config"
+	"calc/internal/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultStaleAfter = 30 * time.Second
+
+// Tracker хранит время последнего сообщения по каждой паре биржи и определяет устаревшие котировки.
+// Методы nil трекера считают все котировки свежими.
+type Tracker struct {
+	mu                sync.RWMutex
+	defaultStaleAfter time.Duration
+	staleAfter        map[string]time.Duration
+	// updates время последнего сообщения: биржа - пара - время
+	updates map[string]map[string]time.Time
+}
+
+func NewTracker(cfg *config.Config) *Tracker {
+	t := &Tracker{
+		defaultStaleAfter: defaultStaleAfter,
+		staleAfter:        make(map[string]time.Duration),
+		updates:           make(map[string]map[string]time.Time),
+	}
+
+	if cfg.Exchanges == nil {
+		return t
+	}
+
+	if cfg.Exchanges.StaleAfter > 0 {
+		t.defaultStaleAfter = cfg.Exchanges.StaleAfter
+	}
+
+	for exchange, exchangeConfig := range cfg.Exchanges.Configs {
+		if exchangeConfig != nil && exchangeConfig.StaleAfter > 0 {
+			t.staleAfter[exchange] = exchangeConfig.StaleAfter
+		}
+	}
+
+	return t
+}
+
+// Source ключ потока рынка биржи в трекере. Спот отслеживается по имени биржи,
+// фьючерсы приходят отдельным соединением и отслеживаются отдельно.
+func Source(exchange, market string) string {
+	if market == "" || market == domain.MarketSpot {
+		return exchange
+	}
+
+	return exchange + ":" + market
+}
+
+// Touch отмечает сообщение по паре, в том числе не изменившее стакан
+func (t *Tracker) Touch(exchange, pair string, at time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pairs, ok := t.updates[exchange]
+	if !ok {
+		pairs = make(map[string]time.Time)
+		t.updates[exchange] = pairs
+	}
+
+	pairs[pair] = at
+}
+
+// Forget удаляет пару биржи после отписки
+func (t *Tracker) Forget(exchange, pair string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.updates[exchange], pair)
+}
+
+// Fresh обновлялась ли пара биржи не раньше порога устаревания до now
+func (t *Tracker) Fresh(exchange, pair string, now time.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	at, ok := t.updates[exchange][pair]
+
+	return ok && now.Sub(at) <= t.threshold(exchange)
+}
+
+// StaleAfter порог устаревания биржи
+func (t *Tracker) StaleAfter(exchange string) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	return t.threshold(exchange)
+}
+
+// Health собирает состояние биржи по ее парам: без соединения биржа down,
+// если не обновляется ни одна пара - stale, если часть пар - degraded
+func (t *Tracker) Health(exchange string, pairs []string, connection string, connected bool, now time.Time) *domain.ExchangeHealth {
+	health := &domain.ExchangeHealth{
+		Exchange:   exchange,
+		Connection: connection,
+		StaleAfter: t.StaleAfter(exchange),
+		Pairs:      make([]*domain.PairHealth, 0, len(pairs)),
+	}
+
+	stale := 0
+	for _, pair := range pairs {
+		ph := &domain.PairHealth{
+			Pair:  pair,
+			Stale: !t.Fresh(exchange, pair, now),
+		}
+
+		if t != nil {
+			t.mu.RLock()
+			ph.LastUpdate = t.updates[exchange][pair]
+			t.mu.RUnlock()
+		}
+
+		if ph.LastUpdate.After(health.LastUpdate) {
+			health.LastUpdate = ph.LastUpdate
+		}
+		if ph.Stale {
+			stale++
+		}
+
+		health.Pairs = append(health.Pairs, ph)
+	}
+
+	sort.Slice(health.Pairs, func(i, j int) bool {
+		return health.Pairs[i].Pair < health.Pairs[j].Pair
+	})
+
+	switch {
+	case !connected:
+		health.Status = domain.HealthDown
+	case len(pairs) > 0 && stale == len(pairs):
+		health.Status = domain.HealthStale
+	case stale > 0:
+		health.Status = domain.HealthDegraded
+	default:
+		health.Status = domain.HealthConnected
+	}
+
+	return health
+}
+
+func (t *Tracker) threshold(exchange string) time.Duration {
+	if staleAfter, ok := t.staleAfter[exchange]; ok {
+		return staleAfter
+	}
+
+	return t.defaultStaleAfter
+}
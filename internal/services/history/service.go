@@ -0,0 +1,305 @@
+package history
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/db"
+	"calc/internal/adapters/db/filters"
+	"calc/internal/domain"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBuffer         = 10000
+	defaultFlushInterval  = time.Second
+	defaultRollupInterval = time.Minute
+	// maxCandles ограничивает число свечей котировок и спредов в ответе Find
+	maxCandles = 100000
+)
+
+var promDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "calc",
+	Name:      "history_dropped_total",
+	Help:      "history records dropped because the queue is full",
+}, []string{"kind"})
+
+var promRegister sync.Once
+
+type record struct {
+	time      time.Time
+	data      *domain.Data
+	arbitrage *domain.Arbitrage
+}
+
+// Service копит котировки и спреды в секундные свечи и пакетно пишет их в базу,
+// затем сворачивает их в минутные и часовые и удаляет устаревшие по настройкам хранения.
+// Запись не блокирует вызывающего: при переполнении очереди записи отбрасываются.
+type Service struct {
+	candleRepo     db.CandleRepo
+	enabled        bool
+	records        chan record
+	flushInterval  time.Duration
+	rollupInterval time.Duration
+	retention      map[domain.Resolution]time.Duration
+
+	// текущие секундные свечи, принадлежат горутине run
+	quotes  map[string]*domain.QuoteCandle
+	spreads map[string]*domain.SpreadCandle
+	// закрытые свечи, ожидающие записи
+	pendingQuotes  []*domain.QuoteCandle
+	pendingSpreads []*domain.SpreadCandle
+}
+
+func NewService(ctx context.Context, cfg *config.Config, candleRepo db.CandleRepo) *Service {
+	s := &Service{
+		candleRepo: candleRepo,
+	}
+
+	if cfg.History == nil || !cfg.History.Enabled {
+		return s
+	}
+
+	s.enabled = true
+	s.records = make(chan record, valueOr(cfg.History.Buffer, defaultBuffer))
+	s.flushInterval = durationOr(cfg.History.FlushInterval, defaultFlushInterval)
+	s.rollupInterval = durationOr(cfg.History.RollupInterval, defaultRollupInterval)
+	s.retention = make(map[domain.Resolution]time.Duration)
+	s.quotes = make(map[string]*domain.QuoteCandle)
+	s.spreads = make(map[string]*domain.SpreadCandle)
+
+	for resolution, retention := range cfg.History.Retention {
+		r, err := domain.NewResolution(resolution)
+		if err != nil {
+			log.Warn().Str("resolution", resolution).Msg("history: unknown retention resolution")
+			continue
+		}
+
+		s.retention[r] = retention
+	}
+
+	promRegister.Do(func() {
+		prometheus.MustRegister(promDropped)
+	})
+
+	go s.run(ctx)
+	go s.maintain(ctx)
+
+	return s
+}
+
+// Record ставит котировку в очередь записи истории
+func (s *Service) Record(data *domain.Data) {
+	if !s.enabled || data.Bid <= 0 || data.Ask <= 0 {
+		return
+	}
+
+	select {
+	case s.records <- record{time: data.Time, data: data}:
+	default:
+		promDropped.WithLabelValues("quote").Inc()
+	}
+}
+
+// RecordSpread ставит чистую прибыль комбинации бирж в очередь записи истории
+func (s *Service) RecordSpread(arbitrage *domain.Arbitrage) {
+	if !s.enabled {
+		return
+	}
+
+	// время берется из комбинации, чтобы свечи спредов совпадали по времени со свечами котировок
+	t := arbitrage.UpdatedAt
+	if t.IsZero() {
+		t = time.Now().UTC()
+	}
+
+	select {
+	case s.records <- record{time: t, arbitrage: arbitrage}:
+	default:
+		promDropped.WithLabelValues("spread").Inc()
+	}
+}
+
+// Find возвращает свечи котировок по биржам и спредов по комбинациям бирж для пары за период,
+// не больше maxCandles каждого вида
+func (s *Service) Find(
+	ctx context.Context,
+	pair string,
+	from, to time.Time,
+	resolution domain.Resolution,
+) ([]*domain.QuoteCandle, []*domain.SpreadCandle, error) {
+	filter := filters.CandleParams{
+		Pair:       pair,
+		Resolution: resolution,
+		From:       from,
+		To:         to,
+		Limit:      maxCandles,
+	}
+
+	quotes, err := s.candleRepo.FindQuotes(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spreads, err := s.candleRepo.FindSpreads(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return quotes, spreads, nil
+}
+
+func (s *Service) run(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// контекст сервиса уже отменен, последние свечи пишутся с отдельным таймаутом
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.flush(flushCtx, time.Time{})
+			cancel()
+			return
+		case r := <-s.records:
+			s.put(r)
+		case now := <-ticker.C:
+			s.flush(ctx, now.UTC().Truncate(time.Second))
+		}
+	}
+}
+
+func (s *Service) put(r record) {
+	bucket := r.time.Truncate(time.Second)
+
+	if r.data != nil {
+		key := r.data.Exchange + ":" + r.data.Pair
+		mid := (r.data.Bid + r.data.Ask) / 2
+
+		c, ok := s.quotes[key]
+		if ok && c.Time.Equal(bucket) {
+			c.Put(mid)
+		} else {
+			if ok {
+				s.pendingQuotes = append(s.pendingQuotes, c)
+			}
+
+			c = &domain.QuoteCandle{
+				Candle:     domain.NewCandle(bucket, mid),
+				Exchange:   r.data.Exchange,
+				Pair:       r.data.Pair,
+				Resolution: domain.ResolutionSecond,
+			}
+			s.quotes[key] = c
+		}
+
+		c.Bid, c.Ask = r.data.Bid, r.data.Ask
+		return
+	}
+
+	a := r.arbitrage
+	key := a.Pair + ":" + a.BuyExchange + ">" + a.SellExchange
+
+	c, ok := s.spreads[key]
+	if ok && c.Time.Equal(bucket) {
+		c.Put(a.NetProfit)
+		return
+	}
+
+	if ok {
+		s.pendingSpreads = append(s.pendingSpreads, c)
+	}
+
+	s.spreads[key] = &domain.SpreadCandle{
+		Candle:       domain.NewCandle(bucket, a.NetProfit),
+		Pair:         a.Pair,
+		BuyExchange:  a.BuyExchange,
+		SellExchange: a.SellExchange,
+		Resolution:   domain.ResolutionSecond,
+	}
+}
+
+// flush пишет свечи, закрытые до before. Нулевой before закрывает все свечи.
+func (s *Service) flush(ctx context.Context, before time.Time) {
+	for key, c := range s.quotes {
+		if before.IsZero() || c.Time.Before(before) {
+			s.pendingQuotes = append(s.pendingQuotes, c)
+			delete(s.quotes, key)
+		}
+	}
+
+	for key, c := range s.spreads {
+		if before.IsZero() || c.Time.Before(before) {
+			s.pendingSpreads = append(s.pendingSpreads, c)
+			delete(s.spreads, key)
+		}
+	}
+
+	// при ошибке свечи отбрасываются, чтобы очередь не росла, пока база недоступна
+	if len(s.pendingQuotes) > 0 {
+		if err := s.candleRepo.SaveQuotes(ctx, s.pendingQuotes); err != nil {
+			log.Error().Err(err).Int("count", len(s.pendingQuotes)).Msg("history: failed to save quote candles")
+		}
+		s.pendingQuotes = nil
+	}
+
+	if len(s.pendingSpreads) > 0 {
+		if err := s.candleRepo.SaveSpreads(ctx, s.pendingSpreads); err != nil {
+			log.Error().Err(err).Int("count", len(s.pendingSpreads)).Msg("history: failed to save spread candles")
+		}
+		s.pendingSpreads = nil
+	}
+}
+
+// maintain сворачивает свечи в более крупные интервалы и применяет настройки хранения
+func (s *Service) maintain(ctx context.Context) {
+	ticker := time.NewTicker(s.rollupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			now = now.UTC()
+
+			for i := 1; i < len(domain.Resolutions); i++ {
+				from, to := domain.Resolutions[i-1], domain.Resolutions[i]
+
+				// пересчитывается и предыдущий интервал, в который могли попасть свечи после прошлого свертывания
+				since := now.Add(-s.rollupInterval - to.Duration())
+				if err := s.candleRepo.Rollup(ctx, from, to, since); err != nil {
+					log.Error().Err(err).Str("resolution", string(to)).Msg("history: failed to roll up candles")
+				}
+			}
+
+			for resolution, retention := range s.retention {
+				if retention <= 0 {
+					continue
+				}
+
+				if err := s.candleRepo.DeleteBefore(ctx, resolution, now.Add(-retention)); err != nil {
+					log.Error().Err(err).Str("resolution", string(resolution)).Msg("history: failed to apply retention")
+				}
+			}
+		}
+	}
+}
+
+func valueOr(value, def int) int {
+	if value <= 0 {
+		return def
+	}
+
+	return value
+}
+
+func durationOr(value, def time.Duration) time.Duration {
+	if value <= 0 {
+		return def
+	}
+
+	return value
+}
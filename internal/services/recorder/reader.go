@@ -0,0 +1,49 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// maxLineSize ограничивает длину строки записи, стакан в 20 уровней занимает несколько килобайт
+const maxLineSize = 1 << 20
+
+// Reader читает записи, сделанные Recorder
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	return &Reader{
+		scanner: scanner,
+	}
+}
+
+// Next возвращает следующую запись или io.EOF в конце файла
+func (r *Reader) Next() (*Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(r.scanner.Bytes(), &record); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode record at line %d", r.line)
+		}
+
+		return &record, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
@@ -0,0 +1,128 @@
+package recorder
+
+import (
+	"bufio"
+	"calc/internal/domain"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultBuffer = 10000
+	flushInterval = time.Second
+)
+
+var promDropped = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "calc",
+	Name:      "recorder_dropped_total",
+	Help:      "records dropped because the recorder queue is full",
+})
+
+// Record строка записи: котировка, комиссия или сеть перевода с временем получения
+type Record struct {
+	Time    time.Time            `json:"time"`
+	Data    *domain.Data         `json:"data,omitempty"`
+	Fee     *domain.Fee          `json:"fee,omitempty"`
+	Network *domain.AssetNetwork `json:"network,omitempty"`
+}
+
+// Recorder пишет записи в файл в формате JSON Lines из отдельной горутины,
+// при переполнении очереди записи отбрасываются
+type Recorder struct {
+	file    *os.File
+	records chan *Record
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewRecorder(ctx context.Context, path string, buffer int) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create recording directory")
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open recording file")
+	}
+
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Recorder{
+		file:    file,
+		records: make(chan *Record, buffer),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	prometheus.MustRegister(promDropped)
+
+	go r.run(ctx)
+
+	return r, nil
+}
+
+func (r *Recorder) Write(record *Record) {
+	select {
+	case r.records <- record:
+	default:
+		promDropped.Inc()
+	}
+}
+
+// Close дописывает записи из очереди и закрывает файл
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+
+	return r.file.Close()
+}
+
+func (r *Recorder) run(ctx context.Context) {
+	defer close(r.done)
+
+	w := bufio.NewWriter(r.file)
+	enc := json.NewEncoder(w)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case record := <-r.records:
+					r.encode(enc, record)
+				default:
+					r.flush(w)
+					return
+				}
+			}
+		case record := <-r.records:
+			r.encode(enc, record)
+		case <-ticker.C:
+			r.flush(w)
+		}
+	}
+}
+
+func (r *Recorder) encode(enc *json.Encoder, record *Record) {
+	if err := enc.Encode(record); err != nil {
+		log.Error().Err(err).Msg("recorder: failed to encode record")
+	}
+}
+
+func (r *Recorder) flush(w *bufio.Writer) {
+	if err := w.Flush(); err != nil {
+		log.Error().Err(err).Msg("recorder: failed to flush recording")
+	}
+}
@@ -0,0 +1,39 @@
+package recorder
+
+import (
+	"calc/internal/domain"
+	"calc/internal/services/calculator"
+	"time"
+)
+
+// recordingService записывает все входные данные калькулятора перед передачей в него
+type recordingService struct {
+	calculator.CalculateService
+	recorder *Recorder
+}
+
+// Wrap возвращает калькулятор, который пишет котировки, комиссии и сети переводов в recorder
+func Wrap(calculateService calculator.CalculateService, recorder *Recorder) calculator.CalculateService {
+	return &recordingService{
+		CalculateService: calculateService,
+		recorder:         recorder,
+	}
+}
+
+func (s *recordingService) Save(data *domain.Data) error {
+	s.recorder.Write(&Record{Time: data.Time, Data: data})
+
+	return s.CalculateService.Save(data)
+}
+
+func (s *recordingService) SetFee(fee *domain.Fee) {
+	s.recorder.Write(&Record{Time: time.Now().UTC(), Fee: fee})
+
+	s.CalculateService.SetFee(fee)
+}
+
+func (s *recordingService) SetAssetNetwork(network *domain.AssetNetwork) {
+	s.recorder.Write(&Record{Time: time.Now().UTC(), Network: network})
+
+	s.CalculateService.SetAssetNetwork(network)
+}
@@ -0,0 +1,149 @@
+package replay
+
+import (
+	"calc/common/config"
+	"calc/internal/adapters/db/memory"
+	"calc/internal/domain"
+	"calc/internal/services/calculator"
+	"calc/internal/services/history"
+	"calc/internal/services/recorder"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report итог воспроизведения записи
+type Report struct {
+	Records int
+	From    time.Time
+	To      time.Time
+	// Opportunities арбитражные возможности в порядке открытия, незакрытые остаются активными на момент To
+	Opportunities []*domain.Opportunity
+	// PnL гипотетическая прибыль по валютам котировки при сделке на объем trade_size по средней прибыли возможности
+	PnL map[string]float64
+}
+
+// Run воспроизводит запись через калькулятор с хранилищами в памяти.
+// speed задает ускорение относительно реального времени, 0 - без пауз.
+func Run(ctx context.Context, cfg *config.Config, path string, speed float64) (*Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open recording")
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// без порогов возможности не отслеживаются, а отчет строится по ним
+	exchanges := *cfg.Exchanges
+	if exchanges.Opportunity == nil {
+		exchanges.Opportunity = &config.Opportunity{}
+	}
+	// каждая котировка записи должна посчитаться, поэтому Save ждет места в очередях
+	pipeline := config.Pipeline{}
+	if exchanges.Pipeline != nil {
+		pipeline = *exchanges.Pipeline
+	}
+	pipeline.Block = true
+	exchanges.Pipeline = &pipeline
+
+	replayCfg := *cfg
+	replayCfg.Exchanges = &exchanges
+
+	opportunityRepo := memory.NewOpportunityRepo()
+	calculateService := calculator.NewCalculateService(
+		ctx,
+		&replayCfg,
+		memory.NewArbitrageRepo(),
+		memory.NewTriangularArbitrageRepo(),
+		memory.NewCarryRepo(),
+		memory.NewRouteRepo(),
+		opportunityRepo,
+		history.NewService(ctx, &config.Config{}, nil),
+		// в записи нет сообщений, не изменивших стакан, поэтому устаревание котировок не проверяется
+		nil,
+	)
+
+	report := &Report{
+		PnL: make(map[string]float64),
+	}
+
+	reader := recorder.NewReader(file)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if report.Records == 0 {
+			report.From = record.Time
+		} else if err := wait(ctx, record.Time.Sub(report.To), speed); err != nil {
+			return nil, err
+		}
+
+		report.Records++
+		report.To = record.Time
+
+		switch {
+		case record.Data != nil:
+			if err := calculateService.Save(record.Data); err != nil {
+				return nil, err
+			}
+		case record.Fee != nil:
+			// котировки считаются в обработчиках асинхронно: принятые до смены комиссии должны посчитаться со старой
+			if err := calculateService.Flush(ctx); err != nil {
+				return nil, err
+			}
+			calculateService.SetFee(record.Fee)
+		case record.Network != nil:
+			if err := calculateService.Flush(ctx); err != nil {
+				return nil, err
+			}
+			calculateService.SetAssetNetwork(record.Network)
+		}
+	}
+
+	if err := calculateService.Flush(ctx); err != nil {
+		return nil, err
+	}
+
+	report.Opportunities = opportunityRepo.All()
+	sort.Slice(report.Opportunities, func(i, j int) bool {
+		return report.Opportunities[i].OpenedAt.Before(report.Opportunities[j].OpenedAt)
+	})
+
+	for _, o := range report.Opportunities {
+		assets := strings.Split(o.Pair, "_")
+		if len(assets) != 2 {
+			continue
+		}
+
+		report.PnL[assets[1]] += cfg.Exchanges.TradeSize[assets[1]] * o.AvgProfit / 100
+	}
+
+	return report, nil
+}
+
+func wait(ctx context.Context, d time.Duration, speed float64) error {
+	if speed <= 0 || d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(float64(d) / speed))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
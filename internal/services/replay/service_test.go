@@ -0,0 +1,113 @@
+package replay
+
+import (
+	"calc/common/config"
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func replayConfig() *config.Config {
+	return &config.Config{
+		Exchanges: &config.Exchange{
+			Pairs:     []string{"BTC_USDT"},
+			TradeSize: map[string]float64{"USDT": 1000},
+			// в записи нет сетей перевода
+			AllowUnknownTransfers: true,
+			Opportunity: &config.Opportunity{
+				OpenThreshold:  0.5,
+				CloseThreshold: 0.1,
+			},
+		},
+	}
+}
+
+// testdata/session.jsonl: комиссии тейкера 0.1% на binance и okx и котировки BTC_USDT раз в секунду.
+// Покупка на binance с продажей на okx открывается на второй секунде, закрывается на пятой,
+// когда цены сходятся, и снова открывается на шестой.
+func TestRun(t *testing.T) {
+	report, err := Run(context.Background(), replayConfig(), "testdata/session.jsonl", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if report.Records != 8 {
+		t.Errorf("records = %d, want 8", report.Records)
+	}
+
+	if !report.From.Equal(start) || !report.To.Equal(start.Add(6*time.Second)) {
+		t.Errorf("period = %s - %s, want %s - %s", report.From, report.To, start, start.Add(6*time.Second))
+	}
+
+	if len(report.Opportunities) != 2 {
+		t.Fatalf("opportunities = %d, want 2", len(report.Opportunities))
+	}
+
+	first, second := report.Opportunities[0], report.Opportunities[1]
+
+	for _, o := range report.Opportunities {
+		if o.Pair != "BTC_USDT" || o.BuyExchange != "binance" || o.SellExchange != "okx" {
+			t.Errorf("opportunity %s %s>%s, want BTC_USDT binance>okx", o.Pair, o.BuyExchange, o.SellExchange)
+		}
+	}
+
+	if !first.OpenedAt.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("first opened at %s, want %s", first.OpenedAt, start.Add(2*time.Second))
+	}
+
+	if first.ClosedAt == nil || !first.ClosedAt.Equal(start.Add(5*time.Second)) {
+		t.Errorf("first closed at %v, want %s", first.ClosedAt, start.Add(5*time.Second))
+	}
+
+	if first.Updates != 3 {
+		t.Errorf("first updates = %d, want 3", first.Updates)
+	}
+
+	approx(t, "first avg profit", first.AvgProfit, 0.6584924715216202)
+	approx(t, "first peak profit", first.PeakProfit, 0.8889324106715603)
+	approx(t, "first last profit", first.LastProfit, 0.3938721330025846)
+
+	if !second.OpenedAt.Equal(start.Add(6 * time.Second)) {
+		t.Errorf("second opened at %s, want %s", second.OpenedAt, start.Add(6*time.Second))
+	}
+
+	if second.ClosedAt != nil {
+		t.Errorf("second closed at %s, want active", second.ClosedAt)
+	}
+
+	approx(t, "second avg profit", second.AvgProfit, 1.1750966652927595)
+
+	if len(report.PnL) != 1 {
+		t.Errorf("pnl = %v, want USDT only", report.PnL)
+	}
+
+	approx(t, "USDT pnl", report.PnL["USDT"], 18.335891368143795)
+}
+
+func TestRunDeterministic(t *testing.T) {
+	first, err := Run(context.Background(), replayConfig(), "testdata/session.jsonl", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Run(context.Background(), replayConfig(), "testdata/session.jsonl", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("reports differ:\n%+v\n%+v", first, second)
+	}
+}
+
+func approx(t *testing.T, name string, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}